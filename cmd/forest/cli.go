@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 
@@ -43,6 +44,16 @@ func runClientCommand(args []string) {
 		handleResume(cmdArgs)
 	case "reload":
 		handleReload(cmdArgs)
+	case "projection", "projections":
+		handleProjection(cmdArgs)
+	case "humus":
+		handleHumus(cmdArgs)
+	case "soil":
+		handleSoil(cmdArgs)
+	case "registry":
+		handleRegistry(cmdArgs)
+	case "scripts":
+		handleScripts(cmdArgs)
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", command)
 		printClientHelp()
@@ -130,14 +141,19 @@ func printTrees(trees []runtime.TreeInfo) {
 	}
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "  NAME\tWATCHES\tPUBLISHES\tSCRIPT\tSTATUS")
+	fmt.Fprintln(w, "  NAME\tWATCHES\tPUBLISHES\tSCRIPT/TYPE\tSTATUS")
 	for _, t := range trees {
 		status := "stopped"
 		if t.Running {
 			status = "running"
 		}
+		status += sandboxStatus(t.Sandbox)
+		impl := t.Script
+		if t.GoType != "" {
+			impl = t.GoType
+		}
 		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t[%s]\n",
-			t.Name, t.Watches, t.Publishes, t.Script, status)
+			t.Name, t.Watches, t.Publishes, impl, status)
 	}
 	w.Flush()
 }
@@ -150,18 +166,37 @@ func printTreeHouses(treehouses []runtime.ComponentInfo) {
 	}
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "  NAME\tSUBSCRIBES\tPUBLISHES\tSCRIPT\tSTATUS")
+	fmt.Fprintln(w, "  NAME\tSUBSCRIBES\tPUBLISHES\tSCRIPT/TYPE\tSTATUS")
 	for _, th := range treehouses {
 		status := "stopped"
 		if th.Running {
 			status = "running"
 		}
+		if d := th.Determinism; d != nil && d.Mismatches > 0 {
+			status += fmt.Sprintf(", %d nondeterministic", d.Mismatches)
+		}
+		status += sandboxStatus(th.Sandbox)
+		impl := th.Script
+		if th.GoType != "" {
+			impl = th.GoType
+		}
 		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t[%s]\n",
-			th.Name, th.Subscribes, th.Publishes, th.Script, status)
+			th.Name, th.Subscribes, th.Publishes, impl, status)
 	}
 	w.Flush()
 }
 
+// sandboxStatus summarizes the script calls a Lua component's sandbox stopped.
+func sandboxStatus(s *runtime.SandboxStats) string {
+	if s == nil {
+		return ""
+	}
+	if limited := s.Timeouts + s.MemoryExceeded + s.StackOverflows; limited > 0 {
+		return fmt.Sprintf(", %d over limits", limited)
+	}
+	return ""
+}
+
 func printNims(nims []runtime.ComponentInfo) {
 	fmt.Println("NIMS:")
 	if len(nims) == 0 {
@@ -170,14 +205,18 @@ func printNims(nims []runtime.ComponentInfo) {
 	}
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "  NAME\tSUBSCRIBES\tPUBLISHES\tPROMPT\tSTATUS")
+	fmt.Fprintln(w, "  NAME\tSUBSCRIBES\tPUBLISHES\tPROMPT/TYPE\tSTATUS")
 	for _, nim := range nims {
 		status := "stopped"
 		if nim.Running {
 			status = "running"
 		}
+		impl := nim.Prompt
+		if nim.GoType != "" {
+			impl = nim.GoType
+		}
 		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t[%s]\n",
-			nim.Name, nim.Subscribes, nim.Publishes, nim.Prompt, status)
+			nim.Name, nim.Subscribes, nim.Publishes, impl, status)
 	}
 	w.Flush()
 }
@@ -363,12 +402,14 @@ func printSourceHelp() {
 
 func handleAddTree(args []string) {
 	// Parse flags
-	var name, watches, publishes, script, configPath string
+	var name, watches, publishes, script, goType, configPath string
 
 	for i := 0; i < len(args); i++ {
 		arg := args[i]
 		if strings.HasPrefix(arg, "--config=") {
 			configPath = strings.TrimPrefix(arg, "--config=")
+		} else if strings.HasPrefix(arg, "--type=") {
+			goType = strings.TrimPrefix(arg, "--type=")
 		} else if strings.HasPrefix(arg, "--watches=") {
 			watches = strings.TrimPrefix(arg, "--watches=")
 		} else if strings.HasPrefix(arg, "--publishes=") {
@@ -410,9 +451,18 @@ func handleAddTree(args []string) {
 	if name == "" {
 		fmt.Fprintln(os.Stderr, "Error: name is required")
 		fmt.Fprintln(os.Stderr, "Usage: forest add tree <name> --watches=<subj> --publishes=<subj> --script=<path>")
+		fmt.Fprintln(os.Stderr, "   or: forest add tree <name> --type=go:<registered type>")
 		fmt.Fprintln(os.Stderr, "   or: forest add tree --config=<path>")
 		os.Exit(1)
 	}
+	if goType != "" {
+		if err := client.AddGoTree(name, goType); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Added tree '%s' (%s)\n", name, goType)
+		return
+	}
 	if watches == "" || publishes == "" || script == "" {
 		fmt.Fprintln(os.Stderr, "Error: --watches, --publishes, and --script are required")
 		fmt.Fprintln(os.Stderr, "Usage: forest add tree <name> --watches=<subj> --publishes=<subj> --script=<path>")
@@ -428,12 +478,22 @@ func handleAddTree(args []string) {
 
 func handleAddTreeHouse(args []string) {
 	// Parse flags
-	var name, subscribes, publishes, script, configPath string
+	var name, subscribes, publishes, script, goType, configPath string
+	var verify float64
 
 	for i := 0; i < len(args); i++ {
 		arg := args[i]
 		if strings.HasPrefix(arg, "--config=") {
 			configPath = strings.TrimPrefix(arg, "--config=")
+		} else if strings.HasPrefix(arg, "--type=") {
+			goType = strings.TrimPrefix(arg, "--type=")
+		} else if strings.HasPrefix(arg, "--verify=") {
+			v, err := strconv.ParseFloat(strings.TrimPrefix(arg, "--verify="), 64)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: invalid --verify: %v\n", err)
+				os.Exit(1)
+			}
+			verify = v
 		} else if strings.HasPrefix(arg, "--subscribes=") {
 			subscribes = strings.TrimPrefix(arg, "--subscribes=")
 		} else if strings.HasPrefix(arg, "--publishes=") {
@@ -475,9 +535,18 @@ func handleAddTreeHouse(args []string) {
 	if name == "" {
 		fmt.Fprintln(os.Stderr, "Error: name is required")
 		fmt.Fprintln(os.Stderr, "Usage: forest add treehouse <name> --subscribes=<subj> --publishes=<subj> --script=<path>")
+		fmt.Fprintln(os.Stderr, "   or: forest add treehouse <name> --type=go:<registered type> [--verify=<0-1>]")
 		fmt.Fprintln(os.Stderr, "   or: forest add treehouse --config=<path>")
 		os.Exit(1)
 	}
+	if goType != "" {
+		if err := client.AddGoTreeHouse(name, goType, verify); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Added treehouse '%s' (%s)\n", name, goType)
+		return
+	}
 	if subscribes == "" || publishes == "" || script == "" {
 		fmt.Fprintln(os.Stderr, "Error: --subscribes, --publishes, and --script are required")
 		fmt.Fprintln(os.Stderr, "Usage: forest add treehouse <name> --subscribes=<subj> --publishes=<subj> --script=<path>")
@@ -493,12 +562,14 @@ func handleAddTreeHouse(args []string) {
 
 func handleAddNim(args []string) {
 	// Parse flags
-	var name, subscribes, publishes, prompt, configPath string
+	var name, subscribes, publishes, prompt, goType, configPath string
 
 	for i := 0; i < len(args); i++ {
 		arg := args[i]
 		if strings.HasPrefix(arg, "--config=") {
 			configPath = strings.TrimPrefix(arg, "--config=")
+		} else if strings.HasPrefix(arg, "--type=") {
+			goType = strings.TrimPrefix(arg, "--type=")
 		} else if strings.HasPrefix(arg, "--subscribes=") {
 			subscribes = strings.TrimPrefix(arg, "--subscribes=")
 		} else if strings.HasPrefix(arg, "--publishes=") {
@@ -540,9 +611,18 @@ func handleAddNim(args []string) {
 	if name == "" {
 		fmt.Fprintln(os.Stderr, "Error: name is required")
 		fmt.Fprintln(os.Stderr, "Usage: forest add nim <name> --subscribes=<subj> --publishes=<subj> --prompt=<path>")
+		fmt.Fprintln(os.Stderr, "   or: forest add nim <name> --type=go:<registered type>")
 		fmt.Fprintln(os.Stderr, "   or: forest add nim --config=<path>")
 		os.Exit(1)
 	}
+	if goType != "" {
+		if err := client.AddGoNim(name, goType); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Added nim '%s' (%s)\n", name, goType)
+		return
+	}
 	if subscribes == "" || publishes == "" || prompt == "" {
 		fmt.Fprintln(os.Stderr, "Error: --subscribes, --publishes, and --prompt are required")
 		fmt.Fprintln(os.Stderr, "Usage: forest add nim <name> --subscribes=<subj> --publishes=<subj> --prompt=<path>")
@@ -556,6 +636,63 @@ func handleAddNim(args []string) {
 	fmt.Printf("✅ Added nim '%s'\n", name)
 }
 
+// =============================================================================
+// Registry Command
+// =============================================================================
+
+func handleRegistry(args []string) {
+	client := runtime.NewClientFromEnv()
+
+	registry, err := client.RegisteredTypes()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	kinds := []struct {
+		title string
+		names []string
+	}{
+		{"NIMS", registry.Nims},
+		{"TREES", registry.Trees},
+		{"TREEHOUSES", registry.TreeHouses},
+	}
+	for i, kind := range kinds {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s:\n", kind.title)
+		if len(kind.names) == 0 {
+			fmt.Println("  (none)")
+		}
+		for _, name := range kind.names {
+			fmt.Printf("  %s%s\n", runtime.GoTypePrefix, name)
+		}
+	}
+}
+
+func handleScripts(args []string) {
+	client := runtime.NewClientFromEnv()
+
+	scripts, err := client.Scripts()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(scripts) == 0 {
+		fmt.Println("No scripts watched (hot reload off or no Lua components)")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAME\tPATH\tVERSION\tLOADED\tRELOADS\tERROR")
+	for _, s := range scripts {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
+			s.Kind, s.Name, s.Path, s.Hash[:12], s.LoadedAt.Format("15:04:05"), s.Reloads, s.Error)
+	}
+	w.Flush()
+}
+
 // =============================================================================
 // Remove Command
 // =============================================================================
@@ -659,13 +796,60 @@ func handleResume(args []string) {
 // =============================================================================
 
 func handleReload(args []string) {
+	dryRun := false
+	for _, arg := range args {
+		if arg == "--dry-run" || arg == "-n" {
+			dryRun = true
+		}
+	}
+
 	client := runtime.NewClientFromEnv()
 
-	if err := client.Reload(); err != nil {
+	result, err := client.Reload(dryRun)
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
-	fmt.Println("✅ Configuration reloaded")
+
+	printChanges := func(title string, changes []runtime.ReloadChange) {
+		if len(changes) == 0 {
+			return
+		}
+		fmt.Printf("%s:\n", title)
+		for _, c := range changes {
+			switch {
+			case c.Error != "":
+				fmt.Printf("  %s %s: %s\n", c.Kind, c.Name, c.Error)
+			case len(c.Fields) > 0:
+				fmt.Printf("  %s %s (%s)\n", c.Kind, c.Name, strings.Join(c.Fields, ", "))
+			default:
+				fmt.Printf("  %s %s\n", c.Kind, c.Name)
+			}
+		}
+	}
+
+	verb := func(done, planned string) string {
+		if dryRun {
+			return planned
+		}
+		return done
+	}
+	printChanges(verb("Added", "Would add"), result.Added)
+	printChanges(verb("Changed", "Would change"), result.Changed)
+	printChanges(verb("Removed", "Would remove"), result.Removed)
+	printChanges("Failed", result.Failed)
+
+	switch {
+	case len(result.Failed) > 0:
+		fmt.Fprintf(os.Stderr, "❌ %d component(s) failed to reload\n", len(result.Failed))
+		os.Exit(1)
+	case len(result.Added)+len(result.Changed)+len(result.Removed) == 0:
+		fmt.Println("No changes")
+	case dryRun:
+		fmt.Println("Dry run: nothing applied")
+	default:
+		fmt.Println("✅ Configuration reloaded")
+	}
 }
 
 // =============================================================================
@@ -744,7 +928,20 @@ CLI Commands (talk to running daemon):
   forest remove nim <name>                         Remove a nim
   forest pause source <name>                       Pause a source
   forest resume source <name>                      Resume a source
-  forest reload                                    Reload configuration from disk
+  forest reload [--dry-run]                        Reload configuration from disk
+  forest projection [list]                         List projections
+  forest projection rebuild <name>                 Rebuild a projection from humus
+  forest projection get <name> <key>               Show a projection value
+  forest humus [status]                            Show humus retention and archive
+  forest humus import <file|dir>... [--stream=S]   Load humus archives into a stream
+  forest soil [buckets]                            List soil buckets
+  forest soil query <pattern> [--where=f=v]...     Find entities in soil
+  forest soil get|history <entity>                 Show an entity and its revisions
+  forest soil set <entity> <json>                  Edit an entity through humus (nim=admin)
+  forest soil export [--pattern=P] > dump.jsonl    Export soil entities as JSON lines
+  forest soil import [file] [--policy=P] [--humus] Import an export (skip|overwrite|revision)
+  forest registry                                  List Go nims, trees and treehouses compiled in
+  forest scripts                                   Show hot reload status of scripts and prompts
 
 Add Source Examples (feeds external data into River):
   forest add source stripe-webhook \
@@ -768,14 +965,17 @@ Add Source Examples (feeds external data into River):
 
 Add Tree Examples (parses external data from River):
   forest add tree stripe --watches=river.stripe.webhook --publishes=payment.completed --script=./parse_stripe.lua
+  forest add tree payments --type=go:payment
   forest add tree --config=./tree.yaml
 
 Add TreeHouse Examples (transforms internal Leaves):
   forest add treehouse scoring --subscribes=contact.created --publishes=lead.scored --script=./scoring.lua
   forest add treehouse --config=./treehouse.yaml
+  forest add treehouse enricher --type=go:enricher --verify=0.05   (re-run 5% of leaves to check determinism)
 
 Add Nim Examples (AI-powered processing):
   forest add nim qualify --subscribes=lead.scored --publishes=lead.qualified --prompt=./qualify.md
+  forest add nim aftersales --type=go:aftersales   (compiled Go nim, see forest registry)
   forest add nim --config=./nim.yaml
 
 Environment:
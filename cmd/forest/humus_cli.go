@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yourusername/nimsforest/internal/core"
+	"github.com/yourusername/nimsforest/pkg/runtime"
+)
+
+// handleHumus handles the humus subcommands.
+func handleHumus(args []string) {
+	if len(args) == 0 {
+		args = []string{"status"}
+	}
+
+	client := runtime.NewClientFromEnv()
+
+	switch args[0] {
+	case "status":
+		status, err := client.HumusStatus()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println("HUMUS:")
+		fmt.Printf("  Composts:   %d (slots %d-%d)\n", status.Messages, status.FirstSlot, status.LastSlot)
+		fmt.Printf("  Size:       %d bytes\n", status.Bytes)
+		fmt.Printf("  Partitions: %d\n", status.Partitions)
+		fmt.Printf("  Retention:  max_age=%s max_msgs=%d max_bytes=%d\n", status.MaxAge, status.MaxMsgs, status.MaxBytes)
+
+		fmt.Println()
+		fmt.Println("ARCHIVE:")
+		if status.Archive == nil {
+			fmt.Println("  (not configured)")
+		} else {
+			fmt.Printf("  Dir:        %s\n", status.Archive.Dir)
+			fmt.Printf("  Files:      %d\n", status.Archive.Files)
+			fmt.Printf("  Archived:   %d (last slot %d)\n", status.Archive.Archived, status.Archive.LastSlot)
+			if status.Archive.CurrentFile != "" {
+				fmt.Printf("  Writing:    %s\n", filepath.Base(status.Archive.CurrentFile))
+			}
+		}
+
+		fmt.Println()
+		fmt.Println("BEDROCK:")
+		if status.Bedrock == nil {
+			fmt.Println("  (not configured)")
+			return
+		}
+		fmt.Printf("  Type:       %s\n", status.Bedrock.Type)
+		fmt.Printf("  Synced:     %d (last slot %d)\n", status.Bedrock.Synced, status.Bedrock.LastSlot)
+		fmt.Printf("  Rehydrated: %d entities\n", status.Bedrock.Rehydrated)
+
+	case "rotate":
+		if err := client.RotateHumusArchive(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("✅ Humus archive rotated")
+
+	case "import":
+		var stream string
+		var files []string
+		for _, arg := range args[1:] {
+			if strings.HasPrefix(arg, "--stream=") {
+				stream = strings.TrimPrefix(arg, "--stream=")
+				continue
+			}
+			expanded, err := expandArchivePath(arg)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			files = append(files, expanded...)
+		}
+		if len(files) == 0 {
+			fmt.Fprintln(os.Stderr, "Usage: forest humus import <file|dir>... [--stream=HUMUS_AUDIT]")
+			os.Exit(1)
+		}
+
+		result, err := client.ImportHumusArchives(files, stream)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Imported %d composts from %d files into %s (%d duplicates skipped)\n",
+			result.Imported, result.Files, result.Stream, result.Duplicates)
+
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown humus command: %s\n\n", args[0])
+		printHumusHelp()
+		os.Exit(1)
+	}
+}
+
+// expandArchivePath returns the absolute archive files for a file or directory argument.
+func expandArchivePath(path string) ([]string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{abs}, nil
+	}
+	files, err := core.ListHumusArchives(abs)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no humus archives in %s", path)
+	}
+	return files, nil
+}
+
+func printHumusHelp() {
+	fmt.Print(`Humus Commands:
+
+  forest humus [status]                          Show stream retention and archive progress
+  forest humus rotate                            Finish the current archive file
+  forest humus import <file|dir>... [--stream=S] Load archives into a stream (default: HUMUS)
+`)
+}
@@ -7,6 +7,8 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
 	"syscall"
 	"time"
 
@@ -16,8 +18,6 @@ import (
 	"github.com/yourusername/nimsforest/internal/land"
 	"github.com/yourusername/nimsforest/internal/natsclusterconfig"
 	"github.com/yourusername/nimsforest/internal/natsembed"
-	"github.com/yourusername/nimsforest/internal/nims"
-	"github.com/yourusername/nimsforest/internal/trees"
 	"github.com/yourusername/nimsforest/internal/updater"
 	"github.com/yourusername/nimsforest/internal/viewmodel"
 	"github.com/yourusername/nimsforest/internal/windwaker"
@@ -59,9 +59,15 @@ func main() {
 		case "viewmodel":
 			handleViewmodel(os.Args[2:])
 			return
+		case "lua-docs":
+			fmt.Print(runtime.LuaStdlibDocs())
+			return
+		case "test-script":
+			handleTestScript(os.Args[2:])
+			return
 
 		// CLI client commands (talk to running daemon)
-		case "list", "ls", "status", "add", "remove", "rm", "reload":
+		case "list", "ls", "status", "add", "remove", "rm", "reload", "projection", "projections", "humus", "soil", "registry", "scripts":
 			runClientCommand(os.Args[1:])
 			return
 
@@ -140,9 +146,16 @@ func printHelp() {
 	fmt.Println("  add             Add a treehouse or nim at runtime")
 	fmt.Println("  remove          Remove a treehouse or nim")
 	fmt.Println("  reload          Reload configuration from disk")
+	fmt.Println("  projection      List, inspect or rebuild projections")
+	fmt.Println("  humus           Show humus retention, rotate or import archives")
+	fmt.Println("  soil            List soil buckets and indexes, query entities")
+	fmt.Println("  registry        List the Go nims, trees and treehouses compiled in")
+	fmt.Println("  scripts         Show hot reload status of scripts and prompts")
 	fmt.Println()
 	fmt.Println("Other Commands:")
 	fmt.Println("  viewmodel       View cluster state (print, summary, viewer)")
+	fmt.Println("  lua-docs        Print the Lua standard library reference (Markdown)")
+	fmt.Println("  test-script     Run a Lua script or WASM module against test cases (forest test-script x.lua --cases x.yaml)")
 	fmt.Println("  version         Show version information")
 	fmt.Println("  update          Check for updates and install if available")
 	fmt.Println("  check-update    Check for updates without installing")
@@ -178,6 +191,7 @@ func printHelp() {
 	fmt.Println("  forest list                                # List running components")
 	fmt.Println("  forest add treehouse x --config=x.yaml     # Add component")
 	fmt.Println("  forest reload                              # Reload config")
+	fmt.Println("  forest reload --dry-run                    # Show what a reload would change")
 	fmt.Println("  ANTHROPIC_API_KEY=sk-... forest standalone # With Claude AI")
 	fmt.Println("  forest                                     # Production cluster mode")
 	fmt.Println()
@@ -267,6 +281,16 @@ func runForest() {
 		fmt.Printf("  🎮 GPU: %s (%s VRAM)\n", thisLand.GPUModel, formatLandBytes(thisLand.GPUVram))
 	}
 
+	// Load YAML runtime config early - it also tunes the core components
+	var runtimeConfig *runtime.Config
+	configPath := getConfigPath()
+	if configPath != "" {
+		runtimeConfig, err = runtime.LoadConfig(configPath)
+		if err != nil {
+			log.Printf("⚠️  Failed to load runtime config: %v\n", err)
+		}
+	}
+
 	// Initialize core components
 	fmt.Println("Initializing core components...")
 
@@ -287,11 +311,11 @@ func runForest() {
 	}
 	fmt.Println("  ✅ River (External Data Stream) ready")
 
-	humus, err := core.NewHumus(js)
+	humus, err := core.NewHumusWithConfig(js, humusConfig(runtimeConfig))
 	if err != nil {
 		log.Fatalf("❌ Failed to create humus: %v\n", err)
 	}
-	fmt.Println("  ✅ Humus (State Change Stream) ready")
+	fmt.Printf("  ✅ Humus (State Change Stream) ready (%d partitions)\n", humus.Partitions())
 
 	soil, err := core.NewSoil(js)
 	if err != nil {
@@ -299,69 +323,97 @@ func runForest() {
 	}
 	fmt.Println("  ✅ Soil (KV Store) ready")
 
-	// Start decomposer worker
-	fmt.Println("Starting decomposer worker...")
-	decomposer, err := core.RunDecomposer(humus, soil)
-	if err != nil {
-		log.Fatalf("❌ Failed to start decomposer: %v\n", err)
+	// Open named soil buckets from config
+	soilBuckets := make(map[string]*core.Soil)
+	for name, bucketCfg := range soilBucketConfigs(runtimeConfig) {
+		bucket, err := core.NewSoilWithConfig(js, bucketCfg)
+		if err != nil {
+			log.Fatalf("❌ Failed to create soil bucket %s: %v\n", name, err)
+		}
+		soilBuckets[name] = bucket
+		fmt.Printf("  ✅ Soil bucket %s ready (%s)\n", name, bucket.Bucket())
 	}
-	defer decomposer.Stop()
-	fmt.Println("  ✅ Decomposer worker running")
 
-	// Create context for lifecycle management
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	// Plant trees
-	fmt.Println("Planting trees...")
+	// Declare soil indexes from config
+	for _, idx := range soilIndexes(runtimeConfig) {
+		if err := soil.DeclareIndex(idx); err != nil {
+			log.Printf("⚠️  Failed to declare soil index %s: %v\n", idx.Name, err)
+		} else {
+			fmt.Printf("  ✅ Soil index %s on %s\n", idx.Name, idx.Pattern)
+		}
+	}
 
-	// Specific payment tree for Stripe webhooks
-	paymentTree := trees.NewPaymentTree(wind, river)
-	if err := paymentTree.Start(ctx); err != nil {
-		log.Fatalf("❌ Failed to start payment tree: %v\n", err)
+	// Restore soil from bedrock before decomposing, then mirror humus into it
+	var bedrockSyncer *core.BedrockSyncer
+	if bedrockCfg := soilBedrockConfig(runtimeConfig); bedrockCfg != nil {
+		bedrock, err := openBedrock(runtimeConfig.ResolvePath(bedrockCfg.Dir), bedrockCfg.Type)
+		if err != nil {
+			log.Fatalf("❌ Failed to open bedrock: %v\n", err)
+		}
+		bedrockSyncer, err = core.RunBedrockSyncer(humus, soil, bedrock, core.BedrockSyncConfig{
+			ConsumerName: core.NodeConsumerName("bedrock-syncer", nodeInfo.NodeID),
+		})
+		if err != nil {
+			log.Fatalf("❌ Failed to start bedrock syncer: %v\n", err)
+		}
+		defer bedrockSyncer.Stop()
+		fmt.Printf("  ✅ Bedrock (%s) at %s, %d entities rehydrated\n",
+			bedrock.Type(), bedrockCfg.Dir, bedrockSyncer.Status().Rehydrated)
 	}
-	defer paymentTree.Stop()
-	fmt.Println("  🌳 PaymentTree planted (watches: river.stripe.webhook)")
 
-	// General tree that demonstrates extensibility
-	generalTree := trees.NewGeneralTree(wind, river)
-	if err := generalTree.Start(ctx); err != nil {
-		log.Fatalf("❌ Failed to start general tree: %v\n", err)
+	// Start decomposer worker
+	fmt.Println("Starting decomposer pool...")
+	decomposers, err := core.RunDecomposerPool(js, humus, soil, core.DecomposerPoolConfig{
+		MemberID: nodeInfo.NodeID,
+	})
+	if err != nil {
+		log.Fatalf("❌ Failed to start decomposer pool: %v\n", err)
 	}
-	defer generalTree.Stop()
-	fmt.Println("  🌳 GeneralTree planted (watches: river.general.>)")
+	defer decomposers.Stop()
+	fmt.Printf("  ✅ Decomposer pool running (partitions: %v)\n", decomposers.OwnedPartitions())
 
-	// Awaken nims
-	fmt.Println("Awakening nims...")
+	// Start soil expirer - AfterSalesNim follows up on tasks whose due_date passes
+	expirer, err := core.RunSoilExpirer(js, soil, wind, soilExpiryConfig(runtimeConfig, humus))
+	if err != nil {
+		log.Fatalf("❌ Failed to start soil expirer: %v\n", err)
+	}
+	defer expirer.Stop()
+	fmt.Println("  ✅ Soil expirer running")
 
-	// Specific aftersales nim for payment events
-	afterSalesNim := nims.NewAfterSalesNim(wind, humus, soil)
-	if err := afterSalesNim.Start(ctx); err != nil {
-		log.Fatalf("❌ Failed to start aftersales nim: %v\n", err)
+	// Republish soil changes as soil.changed.<key> leaves if configured
+	if runtimeConfig != nil && runtimeConfig.Soil != nil && runtimeConfig.Soil.Bridge != nil {
+		bridge, err := core.RunSoilBridge(js, soil, wind, core.SoilBridgeConfig{Patterns: runtimeConfig.Soil.Bridge.Patterns})
+		if err != nil {
+			log.Fatalf("❌ Failed to start soil bridge: %v\n", err)
+		}
+		defer bridge.Stop()
+		fmt.Println("  ✅ Soil bridge publishing soil.changed.* leaves")
 	}
-	defer afterSalesNim.Stop()
-	fmt.Println("  🧚 AfterSalesNim awake (catches: payment.completed, payment.failed)")
 
-	// General nim that demonstrates extensibility
-	generalNim := nims.NewGeneralNim(wind, humus, soil)
-	if err := generalNim.Start(ctx); err != nil {
-		log.Fatalf("❌ Failed to start general nim: %v\n", err)
+	// Start humus archiver if configured
+	var archiver *core.HumusArchiver
+	if archiveCfg := humusArchiveConfig(runtimeConfig, nodeInfo.NodeID); archiveCfg != nil {
+		archiver, err = core.RunHumusArchiver(humus, wind, *archiveCfg)
+		if err != nil {
+			log.Printf("⚠️  Failed to start humus archiver: %v\n", err)
+		} else {
+			defer archiver.Stop()
+			fmt.Printf("  ✅ Humus archiver exporting to %s\n", archiveCfg.Dir)
+		}
 	}
-	defer generalNim.Stop()
-	fmt.Println("  🧚 GeneralNim awake (catches: data.received, status.update, etc.)")
+
+	// Create context for lifecycle management
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
 	// Start YAML-configured runtime (TreeHouses + AI Nims)
 	var runtimeForest *runtime.Forest
-	var runtimeConfig *runtime.Config
-	configPath := getConfigPath()
 	if configPath != "" {
 		fmt.Println("\n🌳 Loading YAML-configured runtime...")
 		fmt.Printf("   Config: %s\n", configPath)
 
-		var err error
-		runtimeConfig, err = runtime.LoadConfig(configPath)
-		if err != nil {
-			log.Printf("⚠️  Failed to load runtime config: %v\n", err)
+		if runtimeConfig == nil {
+			log.Println("⚠️  Runtime config not loaded, skipping YAML runtime")
 		} else {
 			// Create brain from AI service
 			aiBrain, err := createBrain()
@@ -395,6 +447,24 @@ func runForest() {
 				// Set River so Trees can be added at runtime
 				runtimeForest.SetRiver(river)
 
+				// Keep treehouse state in JetStream KV buckets
+				runtimeForest.SetJetStream(js)
+
+				// Give scripts and the API access to soil queries
+				runtimeForest.SetSoil(soil)
+				runtimeForest.SetSoilBuckets(soilBuckets)
+				runtimeForest.SetSoilExpirer(expirer)
+
+				// Report bedrock progress with the humus status
+				if bedrockSyncer != nil {
+					runtimeForest.SetBedrockSyncer(bedrockSyncer)
+				}
+
+				// Expose the humus archiver through the API
+				if archiver != nil {
+					runtimeForest.SetHumusArchiver(archiver)
+				}
+
 				if err := runtimeForest.Start(ctx); err != nil {
 					log.Printf("⚠️  Failed to start runtime forest: %v\n", err)
 				} else {
@@ -406,7 +476,11 @@ func runForest() {
 					for name := range runtimeConfig.TreeHouses {
 						fmt.Printf("   🏠 TreeHouse:%s (Lua script)\n", name)
 					}
-					for name := range runtimeConfig.Nims {
+					for name, nimCfg := range runtimeConfig.Nims {
+						if nimCfg.Type != "" {
+							fmt.Printf("   🧚 Nim:%s (%s)\n", name, nimCfg.Type)
+							continue
+						}
 						fmt.Printf("   🧚 Nim:%s (AI-powered)\n", name)
 					}
 
@@ -428,6 +502,21 @@ func runForest() {
 		}
 	}
 
+	// Run the built-in trees and nims, except those forest.yaml declares
+	// (type: go:<name>) and the runtime forest started with their config
+	fmt.Println("Planting built-in trees and nims...")
+	builtins, err := runtime.StartBuiltins(ctx, runtimeForest, runtime.GoDeps{Wind: wind, River: river, Humus: humus, Soil: soil})
+	if err != nil {
+		log.Fatalf("❌ Failed to start built-ins: %v\n", err)
+	}
+	defer builtins.Stop()
+	for _, tree := range builtins.Trees {
+		fmt.Printf("  🌳 %s planted (watches: %s)\n", tree.Name(), strings.Join(tree.Patterns(), ", "))
+	}
+	for _, nim := range builtins.Nims {
+		fmt.Printf("  🧚 %s awake (catches: %s)\n", nim.Name(), strings.Join(nim.Subjects(), ", "))
+	}
+
 	// Start viewmodel publisher if configured (for external viewers)
 	var vmPublisher *viewmodel.Publisher
 	if runtimeConfig != nil && runtimeConfig.Viewer != nil && runtimeConfig.Viewer.Enabled {
@@ -560,6 +649,116 @@ func getConfigPath() string {
 	return ""
 }
 
+// humusConfig converts the optional runtime humus section to a core config.
+func humusConfig(cfg *runtime.Config) core.HumusConfig {
+	if cfg == nil || cfg.Humus == nil {
+		return core.HumusConfig{}
+	}
+	maxAge, _ := time.ParseDuration(cfg.Humus.MaxAge) // Validated on load
+	return core.HumusConfig{
+		Partitions: cfg.Humus.Partitions,
+		MaxAge:     maxAge,
+		MaxMsgs:    cfg.Humus.MaxMsgs,
+		MaxBytes:   cfg.Humus.MaxBytes,
+	}
+}
+
+// humusArchiveConfig converts the optional runtime humus archive section to
+// a core config, with a consumer of this node's own since the archive dir is
+// local. Returns nil when archiving is not configured.
+func humusArchiveConfig(cfg *runtime.Config, nodeID string) *core.HumusArchiveConfig {
+	if cfg == nil || cfg.Humus == nil || cfg.Humus.Archive == nil {
+		return nil
+	}
+	archive := cfg.Humus.Archive
+	rotateEvery, _ := time.ParseDuration(archive.RotateEvery) // Validated on load
+	return &core.HumusArchiveConfig{
+		Dir:          cfg.ResolvePath(archive.Dir),
+		ConsumerName: core.NodeConsumerName("humus-archiver", nodeID),
+		MaxFileBytes: archive.MaxFileBytes,
+		RotateEvery:  rotateEvery,
+	}
+}
+
+// soilBedrockConfig returns the optional soil bedrock section.
+func soilBedrockConfig(cfg *runtime.Config) *runtime.BedrockConfig {
+	if cfg == nil || cfg.Soil == nil {
+		return nil
+	}
+	return cfg.Soil.Bedrock
+}
+
+// openBedrock opens a bedrock of the given type ("file" or "git") in dir.
+func openBedrock(dir, bedrockType string) (core.Bedrock, error) {
+	if bedrockType == "git" {
+		return core.NewGitBedrock(dir)
+	}
+	return core.NewFileBedrock(dir)
+}
+
+// soilIndexes converts the optional runtime soil index section to core
+// index declarations, sorted by name.
+func soilIndexes(cfg *runtime.Config) []core.SoilIndex {
+	if cfg == nil || cfg.Soil == nil {
+		return nil
+	}
+	indexes := make([]core.SoilIndex, 0, len(cfg.Soil.Indexes))
+	for name, idx := range cfg.Soil.Indexes {
+		indexes = append(indexes, core.SoilIndex{Name: name, Pattern: idx.Pattern, Fields: idx.Fields})
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i].Name < indexes[j].Name })
+	return indexes
+}
+
+// soilBucketConfigs converts the optional runtime soil bucket section to
+// core configs, keyed by bucket name.
+func soilBucketConfigs(cfg *runtime.Config) map[string]core.SoilConfig {
+	if cfg == nil || cfg.Soil == nil {
+		return nil
+	}
+	configs := make(map[string]core.SoilConfig, len(cfg.Soil.Buckets))
+	for name, b := range cfg.Soil.Buckets {
+		ttl, _ := time.ParseDuration(b.TTL) // Validated on load
+		storage := nats.FileStorage
+		if b.Storage == "memory" {
+			storage = nats.MemoryStorage
+		}
+		configs[name] = core.SoilConfig{
+			Bucket:      core.SoilBucket(name),
+			Description: b.Description,
+			History:     uint8(b.History),
+			TTL:         ttl,
+			Storage:     storage,
+			Replicas:    b.Replicas,
+		}
+	}
+	return configs
+}
+
+// soilExpiryConfig converts the optional runtime soil expiry section to a
+// core config. Without a due list, follow-up tasks are scheduled by their
+// due_date (runtime.DefaultSoilDue). TTL deletes are composted to humus.
+func soilExpiryConfig(cfg *runtime.Config, humus *core.Humus) core.SoilExpiryConfig {
+	var section *runtime.SoilExpiryConfig
+	if cfg != nil && cfg.Soil != nil {
+		section = cfg.Soil.Expiry
+	}
+
+	expiryCfg := core.SoilExpiryConfig{Humus: humus}
+	for _, d := range section.DueFields() {
+		expiryCfg.Due = append(expiryCfg.Due, core.SoilDueField{Pattern: d.Pattern, Field: d.Field})
+	}
+	if section == nil {
+		return expiryCfg
+	}
+	for _, ttl := range section.TTL {
+		after, _ := time.ParseDuration(ttl.After) // Validated on load
+		expiryCfg.TTL = append(expiryCfg.TTL, core.SoilTTLField{Pattern: ttl.Pattern, After: after})
+	}
+	expiryCfg.CheckEvery, _ = time.ParseDuration(section.CheckEvery) // Validated on load
+	return expiryCfg
+}
+
 // createBrain creates an AI service brain from environment configuration.
 func createBrain() (*runtime.AIServiceBrain, error) {
 	// Check for API keys in order of preference
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/yourusername/nimsforest/pkg/runtime"
+)
+
+// handleProjection handles the projection subcommands.
+func handleProjection(args []string) {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	client := runtime.NewClientFromEnv()
+
+	switch args[0] {
+	case "list", "ls", "status":
+		projections, err := client.ListProjections()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			fmt.Fprintln(os.Stderr, "Is the nimsforest daemon running?")
+			os.Exit(1)
+		}
+
+		fmt.Println("PROJECTIONS:")
+		if len(projections) == 0 {
+			fmt.Println("  (none)")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  NAME\tFILTER\tBUCKET\tPROCESSED\tERRORS\tLAST SLOT\tSTATUS")
+		for _, p := range projections {
+			status := "stopped"
+			if p.Running {
+				status = "running"
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%d\t%d\t%d\t[%s]\n",
+				p.Name, p.Filter, p.Bucket, p.Processed, p.Errors, p.LastSlot, status)
+		}
+		w.Flush()
+
+		for _, p := range projections {
+			if p.LastError != "" {
+				fmt.Printf("\n⚠️  %s: %s\n", p.Name, p.LastError)
+			}
+		}
+
+	case "rebuild":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: forest projection rebuild <name>")
+			os.Exit(1)
+		}
+		name := args[1]
+		if err := client.RebuildProjection(name); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Rebuilding projection '%s' from humus\n", name)
+
+	case "keys":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: forest projection keys <name>")
+			os.Exit(1)
+		}
+		keys, err := client.ListProjectionKeys(args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, key := range keys {
+			fmt.Println(key)
+		}
+
+	case "get":
+		if len(args) < 3 {
+			fmt.Fprintln(os.Stderr, "Usage: forest projection get <name> <key>")
+			os.Exit(1)
+		}
+		value, err := client.GetProjectionKey(args[1], args[2])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		data, _ := json.MarshalIndent(value, "", "  ")
+		fmt.Println(string(data))
+
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown projection command: %s\n\n", args[0])
+		printProjectionHelp()
+		os.Exit(1)
+	}
+}
+
+func printProjectionHelp() {
+	fmt.Print(`Projection Commands:
+
+  forest projection [list]               List projections and their progress
+  forest projection rebuild <name>       Discard a projection's view and replay humus
+  forest projection keys <name>          List keys in a projection's view
+  forest projection get <name> <key>     Show the value stored at a key
+`)
+}
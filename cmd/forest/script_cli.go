@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yourusername/nimsforest/pkg/runtime"
+)
+
+// handleTestScript runs a tree or treehouse script against a cases file
+// without a forest and exits non-zero if any case fails.
+func handleTestScript(args []string) {
+	var script, casesPath, configPath string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--cases" && i+1 < len(args):
+			i++
+			casesPath = args[i]
+		case arg == "--config" && i+1 < len(args):
+			i++
+			configPath = args[i]
+		case strings.HasPrefix(arg, "--cases="):
+			casesPath = strings.TrimPrefix(arg, "--cases=")
+		case strings.HasPrefix(arg, "--config="):
+			configPath = strings.TrimPrefix(arg, "--config=")
+		default:
+			script = arg
+		}
+	}
+	if script == "" || casesPath == "" {
+		fmt.Fprintln(os.Stderr, "Usage: forest test-script <script.lua|module.wasm> --cases <cases.yaml> [--config forest.yaml]")
+		os.Exit(1)
+	}
+
+	// Run with the forest's Lua or WASM limits when a config is given
+	cfg := &runtime.Config{}
+	if configPath != "" {
+		var err error
+		if cfg, err = runtime.LoadConfig(configPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	cases, err := runtime.LoadScriptCases(casesPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var results []runtime.ScriptCaseResult
+	if strings.EqualFold(filepath.Ext(script), ".wasm") {
+		results = runtime.RunWASMCases(script, cases, cfg.WASM.Limits())
+	} else {
+		results = runtime.RunScriptCases(script, cases, cfg.Lua.Limits())
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Passed {
+			fmt.Printf("✅ %s\n", r.Name)
+			continue
+		}
+		failed++
+		fmt.Printf("❌ %s\n", r.Name)
+		if r.Error != "" {
+			fmt.Printf("   error: %s\n", r.Error)
+		}
+		printJSONChanges("   ", r.Changes)
+	}
+
+	fmt.Printf("\n%d passed, %d failed\n", len(cases)-failed, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
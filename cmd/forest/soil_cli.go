@@ -0,0 +1,382 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/yourusername/nimsforest/internal/core"
+	"github.com/yourusername/nimsforest/pkg/runtime"
+)
+
+// handleSoil handles the soil subcommands.
+func handleSoil(args []string) {
+	if len(args) == 0 {
+		args = []string{"buckets"}
+	}
+
+	client := runtime.NewClientFromEnv()
+
+	switch args[0] {
+	case "buckets":
+		buckets, err := client.ListSoilBuckets()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tBUCKET\tENTITIES\tBYTES\tHISTORY\tTTL")
+		for _, b := range buckets {
+			ttl := b.TTL
+			if ttl == "" {
+				ttl = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%s\n", b.Name, b.Bucket, b.Entities, b.Bytes, b.History, ttl)
+		}
+		w.Flush()
+
+	case "indexes":
+		indexes, err := client.ListSoilIndexes()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(indexes) == 0 {
+			fmt.Println("No soil indexes declared")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tPATTERN\tFIELDS")
+		for _, idx := range indexes {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", idx.Name, idx.Pattern, strings.Join(idx.Fields, ", "))
+		}
+		w.Flush()
+
+	case "query":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: forest soil query <pattern> [--where=field=value]... [--sort=field] [--desc] [--limit=N]")
+			os.Exit(1)
+		}
+
+		var filter core.QueryFilter
+		for _, arg := range args[2:] {
+			switch {
+			case strings.HasPrefix(arg, "--where="):
+				cond, err := parseQueryCondition(strings.TrimPrefix(arg, "--where="))
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				filter.Where = append(filter.Where, cond)
+			case strings.HasPrefix(arg, "--sort="):
+				filter.Sort = strings.TrimPrefix(arg, "--sort=")
+			case arg == "--desc":
+				filter.Desc = true
+			case strings.HasPrefix(arg, "--limit="):
+				filter.Limit, _ = strconv.Atoi(strings.TrimPrefix(arg, "--limit="))
+			}
+		}
+
+		results, err := client.QuerySoil(args[1], filter)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, r := range results {
+			fmt.Printf("%s (rev %d): %s\n", r.Entity, r.Revision, r.Data)
+		}
+		fmt.Printf("\n%d entities\n", len(results))
+
+	case "expiry":
+		pending, err := client.ListSoilExpiries()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(pending) == 0 {
+			fmt.Println("No scheduled expiries")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ENTITY\tAT\tKIND")
+		for _, e := range pending {
+			kind := "due"
+			if e.Delete {
+				kind = "ttl"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", e.Entity, e.At.Format(time.RFC3339), kind)
+		}
+		w.Flush()
+
+	case "expire":
+		if len(args) < 3 {
+			fmt.Fprintln(os.Stderr, "Usage: forest soil expire <entity> <ttl>   (e.g. 24h)")
+			os.Exit(1)
+		}
+		if err := client.ExpireSoilEntity(args[1], args[2]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ %s expires in %s\n", args[1], args[2])
+
+	case "persist":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: forest soil persist <entity>")
+			os.Exit(1)
+		}
+		if err := client.CancelSoilExpiry(args[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Expiry of %s cancelled\n", args[1])
+
+	case "keys":
+		prefix := ""
+		if len(args) > 1 {
+			prefix = args[1]
+		}
+		keys, err := client.BrowseSoil(flagValue(args, "--bucket="), prefix)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, key := range keys {
+			fmt.Println(key)
+		}
+
+	case "get":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: forest soil get <entity> [--bucket=B]")
+			os.Exit(1)
+		}
+		entity, err := client.GetSoilEntity(flagValue(args, "--bucket="), args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		var pretty bytes.Buffer
+		json.Indent(&pretty, entity.Data, "", "  ")
+		fmt.Printf("%s (rev %d)\n%s\n", entity.Entity, entity.Revision, pretty.String())
+
+	case "history":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: forest soil history <entity> [--bucket=B]")
+			os.Exit(1)
+		}
+		revisions, err := client.SoilHistory(flagValue(args, "--bucket="), args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "REVISION\tOP\tCREATED\tDATA")
+		for _, r := range revisions {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", r.Revision, r.Op, r.Created.Format(time.RFC3339), r.Data)
+		}
+		w.Flush()
+
+	case "diff":
+		if len(args) < 3 {
+			fmt.Fprintln(os.Stderr, "Usage: forest soil diff <entity> <from-revision> [to-revision] [--bucket=B]")
+			os.Exit(1)
+		}
+		from, err := strconv.ParseUint(args[2], 10, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid revision %q\n", args[2])
+			os.Exit(1)
+		}
+		var to uint64
+		if len(args) > 3 && !strings.HasPrefix(args[3], "--") {
+			if to, err = strconv.ParseUint(args[3], 10, 64); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: invalid revision %q\n", args[3])
+				os.Exit(1)
+			}
+		}
+		changes, err := client.DiffSoilEntity(flagValue(args, "--bucket="), args[1], from, to)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(changes) == 0 {
+			fmt.Println("No changes")
+			return
+		}
+		printJSONChanges("", changes)
+
+	case "set":
+		if len(args) < 3 {
+			fmt.Fprintln(os.Stderr, "Usage: forest soil set <entity> <json> [--revision=N]")
+			os.Exit(1)
+		}
+		revision, _ := strconv.ParseUint(flagValue(args, "--revision="), 10, 64)
+		slot, err := client.EditSoilEntity(args[1], []byte(args[2]), revision)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ %s composted as admin (slot %d)\n", args[1], slot)
+
+	case "rm":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Usage: forest soil rm <entity> [--revision=N]")
+			os.Exit(1)
+		}
+		revision, _ := strconv.ParseUint(flagValue(args, "--revision="), 10, 64)
+		slot, err := client.DeleteSoilEntity(args[1], revision)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Deletion of %s composted as admin (slot %d)\n", args[1], slot)
+
+	case "export":
+		var bucket, pattern string
+		for _, arg := range args[1:] {
+			switch {
+			case strings.HasPrefix(arg, "--pattern="):
+				pattern = strings.TrimPrefix(arg, "--pattern=")
+			case strings.HasPrefix(arg, "--bucket="):
+				bucket = strings.TrimPrefix(arg, "--bucket=")
+			}
+		}
+		if err := client.ExportSoil(os.Stdout, bucket, pattern); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "import":
+		var bucket, policy string
+		var viaHumus bool
+		input := os.Stdin
+		for _, arg := range args[1:] {
+			switch {
+			case strings.HasPrefix(arg, "--policy="):
+				policy = strings.TrimPrefix(arg, "--policy=")
+			case strings.HasPrefix(arg, "--bucket="):
+				bucket = strings.TrimPrefix(arg, "--bucket=")
+			case arg == "--humus":
+				viaHumus = true
+			case !strings.HasPrefix(arg, "--"):
+				f, err := os.Open(arg)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				defer f.Close()
+				input = f
+			}
+		}
+		if _, err := core.ParseSoilConflictPolicy(policy); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		result, err := client.ImportSoil(input, bucket, policy, viaHumus)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Imported %d entities (%d skipped, %d conflicts)\n",
+			result.Imported, result.Skipped, len(result.Conflicts))
+		for _, entity := range result.Conflicts {
+			fmt.Printf("   conflict: %s\n", entity)
+		}
+		if len(result.Conflicts) > 0 {
+			os.Exit(1)
+		}
+
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown soil command: %s\n\n", args[0])
+		printSoilHelp()
+		os.Exit(1)
+	}
+}
+
+// flagValue returns the value of the first argument starting with prefix
+// (e.g. "--bucket="), or "".
+// printJSONChanges prints field-level changes, one per line.
+func printJSONChanges(indent string, changes []core.JSONChange) {
+	for _, c := range changes {
+		from, _ := json.Marshal(c.From)
+		to, _ := json.Marshal(c.To)
+		path := c.Path
+		if path == "" {
+			path = "(whole value)"
+		}
+		switch c.Op {
+		case "added":
+			fmt.Printf("%s+ %s: %s\n", indent, path, to)
+		case "removed":
+			fmt.Printf("%s- %s: %s\n", indent, path, from)
+		default:
+			fmt.Printf("%s~ %s: %s -> %s\n", indent, path, from, to)
+		}
+	}
+}
+
+func flagValue(args []string, prefix string) string {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, prefix) {
+			return strings.TrimPrefix(arg, prefix)
+		}
+	}
+	return ""
+}
+
+// parseQueryCondition parses "field<op>value", e.g. "status=open" or "priority>=5".
+// Values that look like numbers or booleans are compared as such.
+func parseQueryCondition(s string) (core.QueryCondition, error) {
+	for _, op := range []string{">=", "<=", "!=", ">", "<", "="} {
+		if i := strings.Index(s, op); i > 0 {
+			return core.QueryCondition{Field: s[:i], Op: op, Value: parseQueryValue(s[i+len(op):])}, nil
+		}
+	}
+	return core.QueryCondition{}, fmt.Errorf("invalid condition %q (expected field=value, field>=value, ...)", s)
+}
+
+func parseQueryValue(s string) interface{} {
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	if b, err := strconv.ParseBool(s); err == nil {
+		return b
+	}
+	return s
+}
+
+func printSoilHelp() {
+	fmt.Print(`Soil Commands:
+
+  forest soil [buckets]                          List soil buckets
+  forest soil indexes                            List secondary indexes
+  forest soil expiry                             List scheduled expiries
+  forest soil expire <entity> <ttl>              Delete an entity after ttl (e.g. 24h)
+  forest soil persist <entity>                   Cancel an entity's expiry
+  forest soil query <pattern> [options]          Find entities, e.g.
+      forest soil query 'tasks/*' --where=status=open --where=priority>=5 --sort=priority --desc --limit=10
+  forest soil keys [prefix] [--bucket=B]         List keys starting with prefix
+  forest soil get <entity> [--bucket=B]          Show an entity's current state
+  forest soil history <entity> [--bucket=B]      Show the revisions kept for an entity
+  forest soil diff <entity> <from> [to]          Compare revisions (to defaults to latest)
+  forest soil set <entity> <json> [--revision=N] Compost new state as nim "admin"
+  forest soil rm <entity> [--revision=N]         Compost a deletion as nim "admin"
+  forest soil export [--pattern=P] [--bucket=B]  Write entities as JSON lines to stdout
+  forest soil import [file] [options]            Load an export (default: stdin)
+      --policy=skip|overwrite|revision           Existing entities: keep, replace, or replace
+                                                 only if unchanged since the export (default: skip)
+      --humus                                    Record the import in humus as nim "import"
+      --bucket=B                                 Target a named bucket
+
+  Copy state between forests:
+      NIMSFOREST_API=staging:8080 forest soil export --pattern='task-*' > dump.jsonl
+      forest soil import dump.jsonl --policy=revision --humus
+`)
+}
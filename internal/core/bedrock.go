@@ -0,0 +1,178 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// BedrockChange describes the compost behind a bedrock write, so
+// implementations that keep history can record who changed what.
+type BedrockChange struct {
+	Nim    string
+	Action string
+	Slot   uint64
+	Time   time.Time
+}
+
+// BedrockChangeFor returns the change described by a compost.
+func BedrockChangeFor(compost Compost) BedrockChange {
+	return BedrockChange{
+		Nim:    compost.NimName,
+		Action: compost.Action,
+		Slot:   compost.Slot,
+		Time:   compost.Timestamp,
+	}
+}
+
+// Bedrock is persistent storage beneath soil. Soil is fast working memory;
+// bedrock survives the loss of the NATS cluster and can rebuild soil.
+type Bedrock interface {
+	// Type names the implementation, e.g. "file" or "git".
+	Type() string
+
+	// Write stores the current state of an entity.
+	Write(entity string, data []byte, change BedrockChange) error
+
+	// Delete removes an entity. Deleting a missing entity is not an error.
+	Delete(entity string, change BedrockChange) error
+
+	// Read returns the stored state of an entity, or ErrEntityNotFound.
+	Read(entity string) ([]byte, error)
+
+	// Walk calls fn for every stored entity.
+	Walk(fn func(entity string, data []byte) error) error
+}
+
+// FileBedrock stores entities on the local filesystem: a directory per
+// namespace (the entity type, see EntityType) and one JSON file per entity.
+// Writes go to a temporary file that is synced and renamed into place, so a
+// crash leaves either the old or the new state.
+type FileBedrock struct {
+	dir string
+}
+
+// NewFileBedrock creates a filesystem bedrock rooted at dir.
+func NewFileBedrock(dir string) (*FileBedrock, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("bedrock dir cannot be empty")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create bedrock dir %s: %w", dir, err)
+	}
+	return &FileBedrock{dir: dir}, nil
+}
+
+// Type returns "file".
+func (b *FileBedrock) Type() string { return "file" }
+
+// Dir returns the root directory.
+func (b *FileBedrock) Dir() string { return b.dir }
+
+// Path returns the file an entity is stored in, relative to the root.
+// Entity keys are path-escaped, so "tasks/followup-1" is stored as
+// tasks/tasks%2Ffollowup-1.json.
+func (b *FileBedrock) Path(entity string) string {
+	return filepath.Join(EntityType(entity), url.PathEscape(entity)+".json")
+}
+
+// Write atomically replaces an entity's file.
+func (b *FileBedrock) Write(entity string, data []byte, change BedrockChange) error {
+	if entity == "" {
+		return fmt.Errorf("entity key cannot be empty")
+	}
+	path := filepath.Join(b.dir, b.Path(entity))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create namespace dir: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op after a successful rename
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write entity %s: %w", entity, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync entity %s: %w", entity, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close entity %s: %w", entity, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to store entity %s: %w", entity, err)
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// Delete removes an entity's file.
+func (b *FileBedrock) Delete(entity string, change BedrockChange) error {
+	path := filepath.Join(b.dir, b.Path(entity))
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete entity %s: %w", entity, err)
+	}
+	return nil
+}
+
+// Read returns an entity's stored state.
+func (b *FileBedrock) Read(entity string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(b.dir, b.Path(entity)))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrEntityNotFound, entity)
+		}
+		return nil, fmt.Errorf("failed to read entity %s: %w", entity, err)
+	}
+	return data, nil
+}
+
+// Walk calls fn for every stored entity. Hidden files and directories,
+// such as temporary files and .git, are skipped.
+func (b *FileBedrock) Walk(fn func(entity string, data []byte) error) error {
+	return filepath.WalkDir(b.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && path != b.dir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".json") {
+			return nil
+		}
+
+		entity, err := url.PathUnescape(strings.TrimSuffix(d.Name(), ".json"))
+		if err != nil {
+			return nil // Not written by bedrock
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		return fn(entity, data)
+	})
+}
+
+// syncDir flushes a directory entry so a rename survives a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open dir %s: %w", dir, err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync dir %s: %w", dir, err)
+	}
+	return nil
+}
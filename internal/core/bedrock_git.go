@@ -0,0 +1,136 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GitBedrock is a FileBedrock inside a git repository that commits every
+// change, with the nim that composted it as the commit author. The
+// repository history is a readable audit trail of soil.
+type GitBedrock struct {
+	*FileBedrock
+
+	mu sync.Mutex
+}
+
+// NewGitBedrock creates a git bedrock in dir, initializing a repository
+// there if there isn't one. The git binary must be installed.
+func NewGitBedrock(dir string) (*GitBedrock, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git bedrock requires git: %w", err)
+	}
+	files, err := NewFileBedrock(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &GitBedrock{FileBedrock: files}
+	if _, err := os.Stat(filepath.Join(dir, ".git")); errors.Is(err, fs.ErrNotExist) {
+		if _, err := b.git("init", "-q"); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// Type returns "git".
+func (b *GitBedrock) Type() string { return "git" }
+
+// Write stores an entity and commits it.
+func (b *GitBedrock) Write(entity string, data []byte, change BedrockChange) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.FileBedrock.Write(entity, data, change); err != nil {
+		return err
+	}
+	return b.commit(entity, change)
+}
+
+// Delete removes an entity and commits the removal.
+func (b *GitBedrock) Delete(entity string, change BedrockChange) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.FileBedrock.Delete(entity, change); err != nil {
+		return err
+	}
+	return b.commit(entity, change)
+}
+
+// commit records one change. Every compost gets a commit, even when it
+// didn't change the file, so the log mirrors humus.
+func (b *GitBedrock) commit(entity string, change BedrockChange) error {
+	if _, err := b.git("add", "-A"); err != nil {
+		return err
+	}
+
+	nim := change.Nim
+	if nim == "" {
+		nim = "nimsforest"
+	}
+	action := change.Action
+	if action == "" {
+		action = "write"
+	}
+	when := change.Time
+	if when.IsZero() {
+		when = time.Now()
+	}
+
+	args := []string{
+		"commit", "-q", "--allow-empty",
+		"--author", fmt.Sprintf("%s <%s@nimsforest>", nim, nim),
+		"--date", when.Format(time.RFC3339),
+		"-m", fmt.Sprintf("%s %s", action, entity),
+	}
+	if change.Slot > 0 {
+		args = append(args, "-m", fmt.Sprintf("Humus-Slot: %d", change.Slot))
+	}
+	_, err := b.git(args...)
+	return err
+}
+
+// Log returns the subjects and authors of the latest commits, newest first.
+func (b *GitBedrock) Log(limit int) ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	out, err := b.git("log", fmt.Sprintf("-%d", limit), "--format=%an: %s")
+	if err != nil {
+		return nil, err
+	}
+	out = strings.TrimSpace(out)
+	if out == "" {
+		return nil, nil
+	}
+	return strings.Split(out, "\n"), nil
+}
+
+// git runs a git command in the repository with a fixed committer, so
+// commits work without a global git identity.
+func (b *GitBedrock) git(args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{
+		"-c", "user.name=nimsforest",
+		"-c", "user.email=nimsforest@nimsforest",
+		"-c", "commit.gpgsign=false",
+	}, args...)...)
+	cmd.Dir = b.dir
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
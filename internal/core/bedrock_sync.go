@@ -0,0 +1,238 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// BedrockSyncConfig configures a BedrockSyncer.
+type BedrockSyncConfig struct {
+	// ConsumerName is the durable humus consumer that tracks sync progress.
+	// Every land mirroring to its own bedrock needs its own, see NodeConsumerName.
+	// Default: "bedrock-syncer"
+	ConsumerName string
+}
+
+// BedrockSyncStatus is a snapshot of the syncer's progress.
+type BedrockSyncStatus struct {
+	Type       string `json:"type"`
+	Running    bool   `json:"running"`
+	Synced     uint64 `json:"synced"`
+	LastSlot   uint64 `json:"last_slot"`
+	Rehydrated int    `json:"rehydrated"`
+}
+
+// BedrockSyncer mirrors humus into a bedrock and rebuilds soil from it.
+// Composts are applied in stream order and only acknowledged once bedrock
+// has stored them, so a restarted syncer continues where it stopped.
+type BedrockSyncer struct {
+	humus   *Humus
+	soil    *Soil
+	bedrock Bedrock
+	config  BedrockSyncConfig
+
+	mu         sync.Mutex
+	sub        *nats.Subscription
+	done       chan struct{}
+	wg         sync.WaitGroup
+	synced     uint64
+	lastSlot   uint64
+	rehydrated int
+}
+
+// NewBedrockSyncer creates a syncer between humus, soil and a bedrock.
+func NewBedrockSyncer(humus *Humus, soil *Soil, bedrock Bedrock, cfg BedrockSyncConfig) (*BedrockSyncer, error) {
+	if humus == nil {
+		return nil, fmt.Errorf("humus is required")
+	}
+	if soil == nil {
+		return nil, fmt.Errorf("soil is required")
+	}
+	if bedrock == nil {
+		return nil, fmt.Errorf("bedrock is required")
+	}
+	if cfg.ConsumerName == "" {
+		cfg.ConsumerName = "bedrock-syncer"
+	}
+
+	return &BedrockSyncer{
+		humus:   humus,
+		soil:    soil,
+		bedrock: bedrock,
+		config:  cfg,
+	}, nil
+}
+
+// RunBedrockSyncer creates a syncer, rehydrates soil from bedrock and
+// starts mirroring humus.
+func RunBedrockSyncer(humus *Humus, soil *Soil, bedrock Bedrock, cfg BedrockSyncConfig) (*BedrockSyncer, error) {
+	syncer, err := NewBedrockSyncer(humus, soil, bedrock, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := syncer.Rehydrate(); err != nil {
+		return nil, err
+	}
+	if err := syncer.Start(); err != nil {
+		return nil, err
+	}
+	return syncer, nil
+}
+
+// Rehydrate creates every bedrock entity that is missing from soil and
+// returns how many were restored. Entities already in soil are left alone,
+// since soil is at least as new as bedrock.
+func (s *BedrockSyncer) Rehydrate() (int, error) {
+	restored := 0
+	err := s.bedrock.Walk(func(entity string, data []byte) error {
+		if _, err := s.soil.kv.Get(entity); err == nil {
+			return nil
+		} else if err == nats.ErrInvalidKey {
+			log.Printf("[BedrockSyncer] Skipping entity with invalid soil key: %s", entity)
+			return nil
+		} else if err != nats.ErrKeyNotFound {
+			return fmt.Errorf("failed to check entity %s: %w", entity, err)
+		}
+
+		if err := s.soil.Bury(entity, data, 0); err != nil {
+			if isRevisionConflict(err) {
+				return nil // Written since we checked
+			}
+			return err
+		}
+		restored++
+		return nil
+	})
+	if err != nil {
+		return restored, fmt.Errorf("failed to rehydrate soil from %s bedrock: %w", s.bedrock.Type(), err)
+	}
+
+	s.mu.Lock()
+	s.rehydrated += restored
+	s.mu.Unlock()
+
+	log.Printf("[BedrockSyncer] Rehydrated %d entities from %s bedrock", restored, s.bedrock.Type())
+	return restored, nil
+}
+
+// Start begins mirroring humus into bedrock. A new consumer starts from the
+// oldest compost still in humus.
+func (s *BedrockSyncer) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sub != nil {
+		return fmt.Errorf("bedrock syncer already running")
+	}
+
+	// One compost in flight keeps bedrock writes in stream order
+	if err := s.humus.ensureConsumer(s.config.ConsumerName, "humus.>", 1); err != nil {
+		return err
+	}
+	sub, err := s.humus.js.PullSubscribe("humus.>", s.config.ConsumerName, nats.Bind(s.humus.stream, s.config.ConsumerName))
+	if err != nil {
+		return fmt.Errorf("failed to bind consumer %s: %w", s.config.ConsumerName, err)
+	}
+	s.sub = sub
+	s.done = make(chan struct{})
+
+	s.wg.Add(1)
+	go s.run(sub, s.done)
+
+	log.Printf("[BedrockSyncer] Mirroring humus into %s bedrock", s.bedrock.Type())
+	return nil
+}
+
+// Stop stops mirroring. The consumer keeps its position.
+func (s *BedrockSyncer) Stop() {
+	s.mu.Lock()
+	if s.sub == nil {
+		s.mu.Unlock()
+		return
+	}
+	close(s.done)
+	sub := s.sub
+	s.sub = nil
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	sub.Unsubscribe()
+	log.Printf("[BedrockSyncer] Stopped (synced: %d, last slot: %d)", s.synced, s.lastSlot)
+}
+
+// Status returns the syncer's progress.
+func (s *BedrockSyncer) Status() BedrockSyncStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return BedrockSyncStatus{
+		Type:       s.bedrock.Type(),
+		Running:    s.sub != nil,
+		Synced:     s.synced,
+		LastSlot:   s.lastSlot,
+		Rehydrated: s.rehydrated,
+	}
+}
+
+func (s *BedrockSyncer) run(sub *nats.Subscription, done chan struct{}) {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		msgs, err := sub.Fetch(1, nats.MaxWait(time.Second))
+		if err != nil {
+			if err != nats.ErrTimeout {
+				log.Printf("[BedrockSyncer] Fetch error: %v", err)
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+
+		for _, msg := range msgs {
+			var compost Compost
+			if err := json.Unmarshal(msg.Data, &compost); err != nil {
+				log.Printf("[BedrockSyncer] Skipping invalid compost: %v", err)
+				msg.Term()
+				continue
+			}
+			if meta, err := msg.Metadata(); err == nil {
+				compost.Slot = meta.Sequence.Stream
+			}
+
+			if err := s.apply(compost); err != nil {
+				log.Printf("[BedrockSyncer] Failed to store slot %d: %v", compost.Slot, err)
+				msg.NakWithDelay(time.Second)
+				continue
+			}
+			msg.Ack()
+
+			s.mu.Lock()
+			s.synced++
+			s.lastSlot = compost.Slot
+			s.mu.Unlock()
+		}
+	}
+}
+
+// apply stores one compost in bedrock.
+func (s *BedrockSyncer) apply(compost Compost) error {
+	change := BedrockChangeFor(compost)
+	switch compost.Action {
+	case "create", "update":
+		return s.bedrock.Write(compost.Entity, compost.Data, change)
+	case "delete":
+		return s.bedrock.Delete(compost.Entity, change)
+	default:
+		log.Printf("[BedrockSyncer] Skipping slot %d with unknown action: %s", compost.Slot, compost.Action)
+		return nil
+	}
+}
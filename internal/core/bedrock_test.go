@@ -0,0 +1,135 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileBedrock(t *testing.T) {
+	dir := t.TempDir()
+	b, err := NewFileBedrock(dir)
+	if err != nil {
+		t.Fatalf("Failed to create bedrock: %v", err)
+	}
+
+	b.Write("task:1", []byte(`{"status":"open"}`), BedrockChange{})
+	b.Write("tasks/followup-2", []byte(`{"status":"done"}`), BedrockChange{})
+	b.Write("session-1", []byte(`{}`), BedrockChange{})
+
+	// A directory per namespace, a file per entity
+	for _, path := range []string{"task/task:1.json", "tasks/tasks%2Ffollowup-2.json", "untyped/session-1.json"} {
+		if _, err := os.Stat(filepath.Join(dir, path)); err != nil {
+			t.Errorf("Expected %s: %v", path, err)
+		}
+	}
+
+	b.Write("task:1", []byte(`{"status":"closed"}`), BedrockChange{})
+	if data, err := b.Read("task:1"); err != nil || string(data) != `{"status":"closed"}` {
+		t.Errorf("Expected overwritten task, got %s (%v)", data, err)
+	}
+
+	b.Delete("session-1", BedrockChange{})
+	if _, err := b.Read("session-1"); !errors.Is(err, ErrEntityNotFound) {
+		t.Errorf("Expected ErrEntityNotFound, got %v", err)
+	}
+	if err := b.Delete("session-1", BedrockChange{}); err != nil {
+		t.Errorf("Deleting a missing entity should succeed: %v", err)
+	}
+
+	// Leftover temp files are not entities
+	os.WriteFile(filepath.Join(dir, "task", ".tmp-123"), []byte("partial"), 0644)
+	walked := map[string]string{}
+	b.Walk(func(entity string, data []byte) error {
+		walked[entity] = string(data)
+		return nil
+	})
+	if len(walked) != 2 || walked["tasks/followup-2"] != `{"status":"done"}` {
+		t.Errorf("Unexpected walk: %v", walked)
+	}
+}
+
+func TestGitBedrock(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	b, err := NewGitBedrock(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create git bedrock: %v", err)
+	}
+
+	b.Write("task:1", []byte(`{"status":"open"}`), BedrockChange{Nim: "aftersales", Action: "create", Slot: 1})
+	b.Write("task:1", []byte(`{"status":"open"}`), BedrockChange{Nim: "aftersales", Action: "update", Slot: 2})
+	b.Delete("task:1", BedrockChange{Nim: "admin", Action: "delete", Slot: 3})
+
+	log, err := b.Log(10)
+	if err != nil {
+		t.Fatalf("Log failed: %v", err)
+	}
+	want := []string{"admin: delete task:1", "aftersales: update task:1", "aftersales: create task:1"}
+	if len(log) != len(want) {
+		t.Fatalf("Expected a commit per change, got %v", log)
+	}
+	for i := range want {
+		if log[i] != want[i] {
+			t.Errorf("Commit %d = %q, want %q", i, log[i], want[i])
+		}
+	}
+}
+
+func TestBedrockSyncer(t *testing.T) {
+	js, nc := setupTestJetStream(t)
+	defer nc.Close()
+
+	js.DeleteKeyValue("SOIL")
+	js.DeleteStream("HUMUS")
+	soil, err := NewSoil(js)
+	if err != nil {
+		t.Fatalf("Failed to create soil: %v", err)
+	}
+	humus, err := NewHumus(js)
+	if err != nil {
+		t.Fatalf("Failed to create humus: %v", err)
+	}
+	bedrock, err := NewFileBedrock(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create bedrock: %v", err)
+	}
+
+	// Rehydrate restores missing entities and leaves newer soil state alone
+	bedrock.Write("tasks/1", []byte(`{"status":"archived"}`), BedrockChange{})
+	bedrock.Write("tasks/2", []byte(`{"status":"old"}`), BedrockChange{})
+	soil.Put("tasks/2", []byte(`{"status":"new"}`))
+
+	syncer, err := RunBedrockSyncer(humus, soil, bedrock, BedrockSyncConfig{})
+	if err != nil {
+		t.Fatalf("Failed to start syncer: %v", err)
+	}
+	defer syncer.Stop()
+
+	if data, _, _ := soil.Dig("tasks/1"); string(data) != `{"status":"archived"}` {
+		t.Errorf("Expected tasks/1 rehydrated, got %s", data)
+	}
+	if data, _, _ := soil.Dig("tasks/2"); string(data) != `{"status":"new"}` {
+		t.Errorf("Expected tasks/2 untouched, got %s", data)
+	}
+
+	// Composts are mirrored in order
+	humus.Add("aftersales", "tasks/3", "create", []byte(`{"status":"open"}`))
+	humus.Add("aftersales", "tasks/3", "update", []byte(`{"status":"done"}`))
+	humus.Add("admin", "tasks/1", "delete", nil)
+	waitFor(t, func() bool { return syncer.Status().Synced == 3 })
+
+	if data, _ := bedrock.Read("tasks/3"); string(data) != `{"status":"done"}` {
+		t.Errorf("Expected mirrored tasks/3, got %s", data)
+	}
+	if _, err := bedrock.Read("tasks/1"); !errors.Is(err, ErrEntityNotFound) {
+		t.Errorf("Expected tasks/1 deleted from bedrock, got %v", err)
+	}
+	if s := syncer.Status(); s.LastSlot != 3 || s.Rehydrated != 1 || s.Type != "file" {
+		t.Errorf("Unexpected status: %+v", s)
+	}
+}
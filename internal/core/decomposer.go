@@ -72,13 +72,19 @@ func (d *Decomposer) Stop() {
 
 // processCompost applies a single compost entry to soil.
 func (d *Decomposer) processCompost(compost Compost) error {
+	return applyCompost(d.soil, compost)
+}
+
+// applyCompost applies a single compost entry to soil.
+// It is shared by the single decomposer and the partitioned DecomposerPool.
+func applyCompost(soil *Soil, compost Compost) error {
 	log.Printf("[Decomposer] Processing: slot=%d, entity=%s, action=%s, nim=%s",
 		compost.Slot, compost.Entity, compost.Action, compost.NimName)
 
 	switch compost.Action {
 	case "create":
 		// Create new entity in soil
-		err := d.soil.Bury(compost.Entity, compost.Data, 0)
+		err := soil.Bury(compost.Entity, compost.Data, 0)
 		if err != nil {
 			// If entity already exists, that's okay - might be a replay
 			return fmt.Errorf("failed to create entity %s: %w", compost.Entity, err)
@@ -88,11 +94,11 @@ func (d *Decomposer) processCompost(compost Compost) error {
 	case "update":
 		// Update existing entity
 		// Read current state to get revision
-		_, currentRevision, err := d.soil.Dig(compost.Entity)
+		_, currentRevision, err := soil.Dig(compost.Entity)
 		if err != nil {
 			// Entity doesn't exist, create it instead
 			log.Printf("[Decomposer] Entity %s not found, creating instead", compost.Entity)
-			err = d.soil.Bury(compost.Entity, compost.Data, 0)
+			err = soil.Bury(compost.Entity, compost.Data, 0)
 			if err != nil {
 				return fmt.Errorf("failed to create entity %s during update: %w", compost.Entity, err)
 			}
@@ -100,7 +106,7 @@ func (d *Decomposer) processCompost(compost Compost) error {
 		}
 
 		// Update with optimistic locking
-		err = d.soil.Bury(compost.Entity, compost.Data, currentRevision)
+		err = soil.Bury(compost.Entity, compost.Data, currentRevision)
 		if err != nil {
 			return fmt.Errorf("failed to update entity %s: %w", compost.Entity, err)
 		}
@@ -108,7 +114,7 @@ func (d *Decomposer) processCompost(compost Compost) error {
 
 	case "delete":
 		// Delete entity from soil
-		err := d.soil.Delete(compost.Entity)
+		err := soil.Delete(compost.Entity)
 		if err != nil {
 			// If entity doesn't exist, that's okay - might be a replay
 			log.Printf("[Decomposer] Entity %s not found for deletion (might be replay)", compost.Entity)
@@ -0,0 +1,347 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// DecomposerPoolConfig configures a DecomposerPool.
+type DecomposerPoolConfig struct {
+	// MemberID uniquely identifies this pool in the cluster (e.g. the node ID).
+	MemberID string
+
+	// ConsumerPrefix is the prefix for the per-partition durable consumers.
+	// Default: "decomposer"
+	ConsumerPrefix string
+
+	// Heartbeat is how often this member refreshes its membership and
+	// re-checks the partition assignment. Members that miss three
+	// heartbeats of the slowest member are considered gone.
+	// Default: 5s
+	Heartbeat time.Duration
+}
+
+// decomposerMember is the membership record stored in the DECOMPOSERS bucket.
+type decomposerMember struct {
+	ID       string    `json:"id"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// DecomposerPool applies humus to soil with one worker per owned partition.
+// Composts for the same entity always share a partition and are applied in
+// order, while unrelated entities are applied in parallel. Composts from
+// before partitioning are drained once on Start (see Humus.DrainLegacy).
+//
+// Pools on different lands coordinate through the DECOMPOSERS KV bucket:
+// each member keeps a heartbeat entry, and partitions are spread across the
+// sorted member list. When a member joins or leaves, every pool recomputes
+// the assignment and starts or stops partition workers accordingly.
+type DecomposerPool struct {
+	humus   *Humus
+	soil    *Soil
+	members nats.KeyValue
+	config  DecomposerPoolConfig
+
+	mu      sync.Mutex
+	owned   map[int]context.CancelFunc
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	watcher nats.KeyWatcher
+}
+
+// NewDecomposerPool creates a decomposer pool.
+// The DECOMPOSERS membership bucket is created if it doesn't exist.
+func NewDecomposerPool(js nats.JetStreamContext, humus *Humus, soil *Soil, cfg DecomposerPoolConfig) (*DecomposerPool, error) {
+	if cfg.MemberID == "" {
+		return nil, fmt.Errorf("member ID cannot be empty")
+	}
+	if cfg.ConsumerPrefix == "" {
+		cfg.ConsumerPrefix = "decomposer"
+	}
+	if cfg.Heartbeat <= 0 {
+		cfg.Heartbeat = 5 * time.Second
+	}
+
+	bucketName := "DECOMPOSERS"
+	ttl := 3 * cfg.Heartbeat // Members expire after missed heartbeats
+	kv, err := js.KeyValue(bucketName)
+	if err != nil {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:      bucketName,
+			Description: "NimsForest decomposer pool membership",
+			History:     1,
+			TTL:         ttl,
+			Storage:     nats.MemoryStorage,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create KV bucket %s: %w", bucketName, err)
+		}
+		log.Printf("[DecomposerPool] Created KV bucket: %s", bucketName)
+	} else if err := raiseMemberTTL(js, kv, ttl); err != nil {
+		return nil, err
+	}
+
+	return &DecomposerPool{
+		humus:   humus,
+		soil:    soil,
+		members: kv,
+		config:  cfg,
+		owned:   make(map[int]context.CancelFunc),
+	}, nil
+}
+
+// raiseMemberTTL raises the TTL of an existing membership bucket to ttl, so
+// a member with a longer heartbeat than the land that created the bucket
+// doesn't expire between heartbeats. A longer TTL is kept, as other members
+// may rely on it.
+func raiseMemberTTL(js nats.JetStreamContext, kv nats.KeyValue, ttl time.Duration) error {
+	status, err := kv.Status()
+	if err != nil {
+		return fmt.Errorf("failed to get KV bucket status: %w", err)
+	}
+	if status.TTL() >= ttl {
+		return nil
+	}
+
+	streamName := "KV_" + status.Bucket()
+	info, err := js.StreamInfo(streamName)
+	if err != nil {
+		return fmt.Errorf("failed to get stream info: %w", err)
+	}
+	streamCfg := info.Config
+	streamCfg.MaxAge = ttl
+	if _, err := js.UpdateStream(&streamCfg); err != nil {
+		return fmt.Errorf("failed to raise member TTL of KV bucket %s to %v: %w", status.Bucket(), ttl, err)
+	}
+	log.Printf("[DecomposerPool] Raised member TTL of KV bucket %s from %v to %v", status.Bucket(), status.TTL(), ttl)
+	return nil
+}
+
+// Start joins the pool and begins processing the partitions assigned to this member.
+// Composts from before partitioning are drained first; after that, this runs
+// in the background and returns immediately.
+func (p *DecomposerPool) Start() error {
+	p.mu.Lock()
+	if p.cancel != nil {
+		p.mu.Unlock()
+		return fmt.Errorf("decomposer pool already running")
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	p.mu.Unlock()
+
+	// Composts from before partitioning match no partition consumer
+	if err := p.humus.DrainLegacy(p.ctx, p.apply); err != nil {
+		p.cancel()
+		return fmt.Errorf("failed to drain legacy composts: %w", err)
+	}
+
+	if err := p.heartbeat(); err != nil {
+		p.cancel()
+		return fmt.Errorf("failed to join decomposer pool: %w", err)
+	}
+
+	watcher, err := p.members.WatchAll(nats.IgnoreDeletes())
+	if err != nil {
+		p.cancel()
+		return fmt.Errorf("failed to watch decomposer pool: %w", err)
+	}
+	p.watcher = watcher
+
+	p.rebalance()
+
+	p.wg.Add(1)
+	go p.run()
+
+	log.Printf("[DecomposerPool] Started member %s (%d partitions)", p.config.MemberID, p.humus.Partitions())
+	return nil
+}
+
+// Stop leaves the pool and stops all partition workers.
+// Other members pick up the released partitions on their next rebalance.
+func (p *DecomposerPool) Stop() {
+	p.mu.Lock()
+	if p.cancel == nil {
+		p.mu.Unlock()
+		return
+	}
+	p.cancel()
+	p.mu.Unlock()
+
+	if p.watcher != nil {
+		p.watcher.Stop()
+	}
+	p.wg.Wait()
+
+	if err := p.members.Delete(p.config.MemberID); err != nil {
+		log.Printf("[DecomposerPool] Warning: failed to leave pool: %v", err)
+	}
+
+	p.mu.Lock()
+	p.owned = make(map[int]context.CancelFunc)
+	p.cancel = nil
+	p.mu.Unlock()
+
+	log.Printf("[DecomposerPool] Stopped member %s", p.config.MemberID)
+}
+
+// OwnedPartitions returns the partitions currently processed by this member, sorted.
+func (p *DecomposerPool) OwnedPartitions() []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	partitions := make([]int, 0, len(p.owned))
+	for partition := range p.owned {
+		partitions = append(partitions, partition)
+	}
+	sort.Ints(partitions)
+	return partitions
+}
+
+// run keeps membership fresh and rebalances on membership changes.
+func (p *DecomposerPool) run() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.config.Heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.heartbeat(); err != nil {
+				log.Printf("[DecomposerPool] Heartbeat failed: %v", err)
+			}
+			// Expired members don't produce watch events, so check on every beat
+			p.rebalance()
+		case entry, ok := <-p.watcher.Updates():
+			if !ok {
+				return
+			}
+			// nil marks the end of the initial values
+			if entry == nil || entry.Key() != p.config.MemberID {
+				p.rebalance()
+			}
+		}
+	}
+}
+
+// heartbeat refreshes this member's entry in the membership bucket.
+func (p *DecomposerPool) heartbeat() error {
+	data, err := json.Marshal(decomposerMember{
+		ID:       p.config.MemberID,
+		JoinedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = p.members.Put(p.config.MemberID, data)
+	return err
+}
+
+// rebalance recomputes the partition assignment and starts or stops
+// partition workers to match it.
+func (p *DecomposerPool) rebalance() {
+	keys, err := p.members.Keys()
+	if err != nil && err != nats.ErrNoKeysFound {
+		log.Printf("[DecomposerPool] Failed to list members: %v", err)
+		return
+	}
+
+	want := AssignPartitions(keys, p.humus.Partitions(), p.config.MemberID)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.ctx == nil || p.ctx.Err() != nil {
+		return
+	}
+
+	wanted := make(map[int]bool, len(want))
+	for _, partition := range want {
+		wanted[partition] = true
+	}
+
+	// Release partitions now owned by another member
+	for partition, stop := range p.owned {
+		if !wanted[partition] {
+			stop()
+			delete(p.owned, partition)
+			log.Printf("[DecomposerPool] Released partition %d", partition)
+		}
+	}
+
+	// Claim newly assigned partitions
+	for _, partition := range want {
+		if _, ok := p.owned[partition]; ok {
+			continue
+		}
+		ctx, stop := context.WithCancel(p.ctx)
+		p.owned[partition] = stop
+
+		p.wg.Add(1)
+		go func(partition int) {
+			defer p.wg.Done()
+			err := p.humus.DecomposePartition(ctx, p.config.ConsumerPrefix, partition, p.apply)
+			if err != nil {
+				log.Printf("[DecomposerPool] Partition %d stopped: %v", partition, err)
+				// Forget the partition so the next rebalance retries it
+				p.mu.Lock()
+				if ctx.Err() == nil {
+					stop()
+					delete(p.owned, partition)
+				}
+				p.mu.Unlock()
+			}
+		}(partition)
+		log.Printf("[DecomposerPool] Claimed partition %d", partition)
+	}
+}
+
+// apply applies a compost to soil, logging failures.
+func (p *DecomposerPool) apply(compost Compost) {
+	if err := applyCompost(p.soil, compost); err != nil {
+		log.Printf("[DecomposerPool] Error processing compost slot %d: %v", compost.Slot, err)
+	}
+}
+
+// AssignPartitions returns the partitions owned by memberID when the given
+// members share the partitions. Members are sorted so that every land
+// computes the same assignment independently.
+func AssignPartitions(members []string, partitions int, memberID string) []int {
+	sorted := append([]string(nil), members...)
+	sort.Strings(sorted)
+
+	index := sort.SearchStrings(sorted, memberID)
+	if index >= len(sorted) || sorted[index] != memberID {
+		return nil
+	}
+
+	var owned []int
+	for partition := 0; partition < partitions; partition++ {
+		if partition%len(sorted) == index {
+			owned = append(owned, partition)
+		}
+	}
+	return owned
+}
+
+// RunDecomposerPool is a convenience function that creates and starts a decomposer pool.
+func RunDecomposerPool(js nats.JetStreamContext, humus *Humus, soil *Soil, cfg DecomposerPoolConfig) (*DecomposerPool, error) {
+	pool, err := NewDecomposerPool(js, humus, soil, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := pool.Start(); err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
@@ -0,0 +1,267 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestPartitionFor(t *testing.T) {
+	// Same entity always maps to the same partition
+	for _, entity := range []string{"task-1", "users/alice", "order:42"} {
+		first := PartitionFor(entity, 8)
+		for i := 0; i < 10; i++ {
+			if got := PartitionFor(entity, 8); got != first {
+				t.Errorf("PartitionFor(%q) not stable: %d != %d", entity, got, first)
+			}
+		}
+		if first < 0 || first >= 8 {
+			t.Errorf("PartitionFor(%q) = %d, out of range", entity, first)
+		}
+	}
+
+	if got := PartitionFor("anything", 1); got != 0 {
+		t.Errorf("Expected partition 0 with a single partition, got %d", got)
+	}
+	if got := PartitionFor("anything", 0); got != 0 {
+		t.Errorf("Expected partition 0 with no partitions, got %d", got)
+	}
+}
+
+func TestAssignPartitions(t *testing.T) {
+	tests := []struct {
+		name       string
+		members    []string
+		partitions int
+		member     string
+		want       []int
+	}{
+		{
+			name:       "single member owns all",
+			members:    []string{"land-a"},
+			partitions: 4,
+			member:     "land-a",
+			want:       []int{0, 1, 2, 3},
+		},
+		{
+			name:       "two members split evenly",
+			members:    []string{"land-b", "land-a"},
+			partitions: 4,
+			member:     "land-b",
+			want:       []int{1, 3},
+		},
+		{
+			name:       "unknown member owns nothing",
+			members:    []string{"land-a", "land-b"},
+			partitions: 4,
+			member:     "land-c",
+			want:       nil,
+		},
+		{
+			name:       "more members than partitions",
+			members:    []string{"a", "b", "c"},
+			partitions: 2,
+			member:     "c",
+			want:       nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AssignPartitions(tt.members, tt.partitions, tt.member)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AssignPartitions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssignPartitions_CoversAllPartitions(t *testing.T) {
+	members := []string{"land-1", "land-2", "land-3"}
+	seen := make(map[int]string)
+
+	for _, member := range members {
+		for _, partition := range AssignPartitions(members, 16, member) {
+			if owner, ok := seen[partition]; ok {
+				t.Errorf("Partition %d assigned to both %s and %s", partition, owner, member)
+			}
+			seen[partition] = member
+		}
+	}
+
+	if len(seen) != 16 {
+		t.Errorf("Expected all 16 partitions assigned, got %d", len(seen))
+	}
+}
+
+func TestDecomposerPool_AppliesComposts(t *testing.T) {
+	js, nc := setupTestJetStream(t)
+	defer nc.Close()
+
+	js.DeleteStream("HUMUS")
+	js.DeleteKeyValue("SOIL")
+	js.DeleteKeyValue("DECOMPOSERS")
+
+	humus, err := NewHumusWithConfig(js, HumusConfig{Partitions: 4})
+	if err != nil {
+		t.Fatalf("Failed to create humus: %v", err)
+	}
+	if humus.Partitions() != 4 {
+		t.Fatalf("Expected 4 partitions, got %d", humus.Partitions())
+	}
+	soil, _ := NewSoil(js)
+
+	pool, err := NewDecomposerPool(js, humus, soil, DecomposerPoolConfig{
+		MemberID:  "test-land",
+		Heartbeat: time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create decomposer pool: %v", err)
+	}
+	if err := pool.Start(); err != nil {
+		t.Fatalf("Failed to start decomposer pool: %v", err)
+	}
+	defer pool.Stop()
+
+	if got := pool.OwnedPartitions(); !reflect.DeepEqual(got, []int{0, 1, 2, 3}) {
+		t.Errorf("Expected to own all partitions, got %v", got)
+	}
+
+	// Several updates per entity must be applied in order
+	for i := 0; i < 5; i++ {
+		entity := fmt.Sprintf("pool/entity-%d", i)
+		humus.Add("test-nim", entity, "create", []byte(`{"version": 0}`))
+		for v := 1; v <= 3; v++ {
+			humus.Add("test-nim", entity, "update", []byte(fmt.Sprintf(`{"version": %d}`, v)))
+		}
+	}
+
+	time.Sleep(2 * time.Second)
+
+	for i := 0; i < 5; i++ {
+		entity := fmt.Sprintf("pool/entity-%d", i)
+		data, _, err := soil.Dig(entity)
+		if err != nil {
+			t.Fatalf("Failed to dig %s: %v", entity, err)
+		}
+		if !jsonEqual(data, []byte(`{"version": 3}`)) {
+			t.Errorf("Entity %s: expected final version 3, got %s", entity, data)
+		}
+	}
+}
+
+func TestDecomposerPool_DrainsLegacyComposts(t *testing.T) {
+	js, nc := setupTestJetStream(t)
+	defer nc.Close()
+
+	js.DeleteStream("HUMUS")
+	js.DeleteKeyValue("SOIL")
+	js.DeleteKeyValue("DECOMPOSERS")
+
+	// A HUMUS stream as the single decomposer left it: unpartitioned
+	// subjects, and a durable that acknowledged the first compost only
+	if _, err := js.AddStream(&nats.StreamConfig{Name: "HUMUS", Subjects: []string{"humus.>"}}); err != nil {
+		t.Fatalf("Failed to create legacy stream: %v", err)
+	}
+	for _, c := range []Compost{
+		{Entity: "legacy/applied", Action: "create", Data: []byte(`{"v": 1}`), NimName: "old"},
+		{Entity: "legacy/a", Action: "create", Data: []byte(`{"v": 1}`), NimName: "old"},
+		{Entity: "legacy/a", Action: "update", Data: []byte(`{"v": 2}`), NimName: "old"},
+		{Entity: "legacy/b", Action: "create", Data: []byte(`{"v": 1}`), NimName: "old"},
+	} {
+		payload, _ := json.Marshal(c)
+		if _, err := js.Publish("humus.old."+c.Action, payload); err != nil {
+			t.Fatalf("Failed to publish legacy compost: %v", err)
+		}
+	}
+	if _, err := js.AddConsumer("HUMUS", &nats.ConsumerConfig{Durable: "decomposer", AckPolicy: nats.AckExplicitPolicy}); err != nil {
+		t.Fatalf("Failed to create legacy consumer: %v", err)
+	}
+	sub, _ := js.PullSubscribe("humus.>", "decomposer", nats.Bind("HUMUS", "decomposer"))
+	msgs, err := sub.Fetch(1)
+	if err != nil {
+		t.Fatalf("Failed to fetch legacy compost: %v", err)
+	}
+	msgs[0].AckSync()
+	sub.Unsubscribe()
+
+	humus, err := NewHumusWithConfig(js, HumusConfig{Partitions: 4})
+	if err != nil {
+		t.Fatalf("Failed to create humus: %v", err)
+	}
+	soil, _ := NewSoil(js)
+
+	pool, err := RunDecomposerPool(js, humus, soil, DecomposerPoolConfig{MemberID: "test-land", Heartbeat: time.Second})
+	if err != nil {
+		t.Fatalf("Failed to start decomposer pool: %v", err)
+	}
+	defer pool.Stop()
+
+	// Drained before Start returns, from where the old durable stopped
+	if _, _, err := soil.Dig("legacy/applied"); !errors.Is(err, ErrEntityNotFound) {
+		t.Errorf("Expected the acknowledged compost to be skipped, got %v", err)
+	}
+	if data, _, err := soil.Dig("legacy/a"); err != nil || !jsonEqual(data, []byte(`{"v": 2}`)) {
+		t.Errorf("Expected legacy/a at v2, got %s, %v", data, err)
+	}
+	if _, _, err := soil.Dig("legacy/b"); err != nil {
+		t.Errorf("Expected legacy/b to be applied, got %v", err)
+	}
+
+	for _, name := range []string{"decomposer", "decomposer-legacy"} {
+		if _, err := js.ConsumerInfo("HUMUS", name); err != nats.ErrConsumerNotFound {
+			t.Errorf("Expected consumer %s to be deleted, got %v", name, err)
+		}
+	}
+	info, _ := js.StreamInfo("HUMUS")
+	if _, ok := info.Config.Metadata[humusLegacyMetadataKey]; ok {
+		t.Error("Expected the stream to be marked drained")
+	}
+}
+
+func TestDecomposerPool_MemberTTL(t *testing.T) {
+	js, nc := setupTestJetStream(t)
+	defer nc.Close()
+
+	js.DeleteStream("HUMUS")
+	js.DeleteKeyValue("SOIL")
+	js.DeleteKeyValue("DECOMPOSERS")
+	humus, _ := NewHumus(js)
+	soil, _ := NewSoil(js)
+
+	ttl := func() time.Duration {
+		t.Helper()
+		kv, err := js.KeyValue("DECOMPOSERS")
+		if err != nil {
+			t.Fatalf("Failed to open bucket: %v", err)
+		}
+		status, err := kv.Status()
+		if err != nil {
+			t.Fatalf("Failed to get bucket status: %v", err)
+		}
+		return status.TTL()
+	}
+
+	// A land with a longer heartbeat raises the TTL of an existing bucket,
+	// and one with a shorter heartbeat keeps it
+	for _, tt := range []struct {
+		heartbeat time.Duration
+		want      time.Duration
+	}{
+		{time.Second, 3 * time.Second},
+		{5 * time.Second, 15 * time.Second},
+		{time.Second, 15 * time.Second},
+	} {
+		if _, err := NewDecomposerPool(js, humus, soil, DecomposerPoolConfig{MemberID: "test-land", Heartbeat: tt.heartbeat}); err != nil {
+			t.Fatalf("Failed to create decomposer pool: %v", err)
+		}
+		if got := ttl(); got != tt.want {
+			t.Errorf("Heartbeat %v: expected member TTL %v, got %v", tt.heartbeat, tt.want, got)
+		}
+	}
+}
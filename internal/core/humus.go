@@ -1,9 +1,13 @@
 package core
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"log"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/nats-io/nats.go"
@@ -20,19 +24,102 @@ type Compost struct {
 	Slot      uint64          `json:"slot"`   // Sequence number in the stream
 }
 
+// DefaultHumusPartitions is the number of entity partitions used when
+// none is configured.
+const DefaultHumusPartitions = 8
+
+// humusPartitionsMetadataKey records the partition count on the HUMUS stream
+// so every land in the cluster agrees on the entity-to-partition mapping.
+const humusPartitionsMetadataKey = "nimsforest.partitions"
+
+// humusLegacyMetadataKey marks a HUMUS stream created before composts were
+// partitioned, until the composts it holds on the old
+// humus.<nim>.<action> subjects have been applied to soil.
+const humusLegacyMetadataKey = "nimsforest.legacy_composts"
+
+// Legacy compost layout. Composts on these subjects match no partition
+// consumer, so DrainLegacy applies them once before partitions are claimed.
+const (
+	legacyCompostSubject = "humus.*.*"
+	legacyConsumerName   = "decomposer"        // Durable of the single, unpartitioned decomposer
+	legacyDrainConsumer  = "decomposer-legacy" // Shared by every land draining
+)
+
+// HumusConfig configures the HUMUS stream.
+type HumusConfig struct {
+	// Partitions is the number of entity partitions. All composts for the
+	// same entity land in the same partition, so they are applied in order,
+	// while composts for unrelated entities can be applied in parallel.
+	// Default: DefaultHumusPartitions
+	Partitions int
+
+	// MaxAge is how long composts are retained. Negative means forever.
+	// Default: DefaultHumusMaxAge
+	MaxAge time.Duration
+
+	// MaxMsgs is the maximum number of composts retained. Negative means unlimited.
+	// Default: DefaultHumusMaxMsgs
+	MaxMsgs int64
+
+	// MaxBytes is the maximum stream size in bytes. Zero or negative means unlimited.
+	MaxBytes int64
+}
+
+// Default humus retention. Older composts are discarded once either limit
+// is reached; run a HumusArchiver to keep them as files.
+const (
+	DefaultHumusMaxAge  = 7 * 24 * time.Hour
+	DefaultHumusMaxMsgs = 1000000
+)
+
+// retention resolves the configured limits to JetStream stream limits.
+func (c HumusConfig) retention() (maxAge time.Duration, maxMsgs, maxBytes int64) {
+	maxAge, maxMsgs, maxBytes = c.MaxAge, c.MaxMsgs, c.MaxBytes
+	switch {
+	case maxAge == 0:
+		maxAge = DefaultHumusMaxAge
+	case maxAge < 0:
+		maxAge = 0 // JetStream: unlimited
+	}
+	switch {
+	case maxMsgs == 0:
+		maxMsgs = DefaultHumusMaxMsgs
+	case maxMsgs < 0:
+		maxMsgs = -1
+	}
+	if maxBytes <= 0 {
+		maxBytes = -1
+	}
+	return maxAge, maxMsgs, maxBytes
+}
+
 // Humus represents a JetStream stream for persistent state changes.
 // State changes flow from nims into humus, and the decomposer
 // applies them to soil (the KV store).
 type Humus struct {
-	js     nats.JetStreamContext
-	stream string
+	js         nats.JetStreamContext
+	stream     string
+	partitions int
 }
 
 // NewHumus creates a new Humus backed by a JetStream stream.
 // The stream is created if it doesn't exist, with the name "HUMUS".
 func NewHumus(js nats.JetStreamContext) (*Humus, error) {
+	return NewHumusWithConfig(js, HumusConfig{})
+}
+
+// NewHumusWithConfig creates a new Humus with the given configuration.
+// If the stream already exists, its recorded partition count wins over
+// the configured one, since changing it would reorder in-flight entities.
+func NewHumusWithConfig(js nats.JetStreamContext, cfg HumusConfig) (*Humus, error) {
 	streamName := "HUMUS"
 
+	partitions := cfg.Partitions
+	if partitions <= 0 {
+		partitions = DefaultHumusPartitions
+	}
+	maxAge, maxMsgs, maxBytes := cfg.retention()
+
 	// Create or update the stream
 	streamInfo, err := js.StreamInfo(streamName)
 	if err != nil {
@@ -41,25 +128,158 @@ func NewHumus(js nats.JetStreamContext) (*Humus, error) {
 			Name:      streamName,
 			Subjects:  []string{"humus.>"},
 			Storage:   nats.FileStorage,
-			Retention: nats.LimitsPolicy,  // Keep all messages up to limits
-			MaxAge:    7 * 24 * time.Hour, // Keep for 7 days
+			Retention: nats.LimitsPolicy, // Keep all messages up to limits
+			MaxAge:    maxAge,
 			Discard:   nats.DiscardOld,
-			MaxMsgs:   1000000, // Maximum messages in stream
+			MaxMsgs:   maxMsgs,
+			MaxBytes:  maxBytes,
+			Metadata: map[string]string{
+				humusPartitionsMetadataKey: strconv.Itoa(partitions),
+			},
 		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to create stream %s: %w", streamName, err)
 		}
-		log.Printf("[Humus] Created stream: %s", streamName)
+		log.Printf("[Humus] Created stream: %s (partitions: %d, max_age: %v, max_msgs: %d)",
+			streamName, partitions, maxAge, maxMsgs)
 	} else {
-		log.Printf("[Humus] Using existing stream: %s (msgs: %d)", streamName, streamInfo.State.Msgs)
+		streamCfg := streamInfo.Config
+		changed := false
+
+		if recorded, ok := streamInfo.Config.Metadata[humusPartitionsMetadataKey]; ok {
+			if n, err := strconv.Atoi(recorded); err == nil && n > 0 {
+				if cfg.Partitions > 0 && cfg.Partitions != n {
+					log.Printf("[Humus] Warning: stream %s has %d partitions, ignoring configured %d",
+						streamName, n, cfg.Partitions)
+				}
+				partitions = n
+			}
+		} else {
+			// Stream predates partitioning - record the partition count now,
+			// and leave its composts for DrainLegacy
+			if streamCfg.Metadata == nil {
+				streamCfg.Metadata = make(map[string]string)
+			}
+			streamCfg.Metadata[humusPartitionsMetadataKey] = strconv.Itoa(partitions)
+			if streamInfo.State.Msgs > 0 {
+				streamCfg.Metadata[humusLegacyMetadataKey] = "pending"
+				log.Printf("[Humus] Stream %s predates partitioning, %d composts to drain before decomposing",
+					streamName, streamInfo.State.Msgs)
+			}
+			changed = true
+		}
+
+		// Retention can change at any time
+		if streamCfg.MaxAge != maxAge || streamCfg.MaxMsgs != maxMsgs || streamCfg.MaxBytes != maxBytes {
+			log.Printf("[Humus] Updating retention on stream %s (max_age: %v, max_msgs: %d, max_bytes: %d)",
+				streamName, maxAge, maxMsgs, maxBytes)
+			streamCfg.MaxAge, streamCfg.MaxMsgs, streamCfg.MaxBytes = maxAge, maxMsgs, maxBytes
+			changed = true
+		}
+
+		if changed {
+			if _, err := js.UpdateStream(&streamCfg); err != nil {
+				log.Printf("[Humus] Warning: failed to update stream %s: %v", streamName, err)
+			}
+		}
+		log.Printf("[Humus] Using existing stream: %s (msgs: %d, partitions: %d)",
+			streamName, streamInfo.State.Msgs, partitions)
 	}
 
 	return &Humus{
-		js:     js,
-		stream: streamName,
+		js:         js,
+		stream:     streamName,
+		partitions: partitions,
 	}, nil
 }
 
+// Partitions returns the number of entity partitions in humus.
+func (h *Humus) Partitions() int {
+	return h.partitions
+}
+
+// PartitionFor returns the partition an entity belongs to.
+// The mapping is a stable hash, so every land computes the same partition.
+func PartitionFor(entity string, partitions int) int {
+	if partitions <= 1 {
+		return 0
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(entity))
+	return int(hash.Sum32() % uint32(partitions))
+}
+
+// PartitionSubject returns the subject filter matching every compost in a partition.
+func (h *Humus) PartitionSubject(partition int) string {
+	return fmt.Sprintf("humus.*.*.*.%d", partition)
+}
+
+// untypedEntity is the entity type token for entities without a type prefix.
+const untypedEntity = "untyped"
+
+// EntityType returns the type/namespace of an entity key: the part before
+// the first ':' or '/' (e.g. "task" for "task:123", "tasks" for
+// "tasks/followup-123"). Keys without a prefix are "untyped".
+func EntityType(entity string) string {
+	if i := strings.IndexAny(entity, ":/"); i > 0 {
+		return subjectToken(entity[:i])
+	}
+	return untypedEntity
+}
+
+// compostSubject builds the subject a compost is published on:
+// humus.<entity_type>.<nim>.<action>.<partition>
+func (h *Humus) compostSubject(nimName, entity, action string) string {
+	return fmt.Sprintf("humus.%s.%s.%s.%d",
+		EntityType(entity), subjectToken(nimName), action, PartitionFor(entity, h.partitions))
+}
+
+// CompostFilter selects composts by the tokens of their humus subject.
+// Empty fields match anything.
+type CompostFilter struct {
+	EntityType string // e.g. "task" matches "task:*" entities
+	Nim        string // Nim that composted the change
+	Action     string // create, update or delete
+}
+
+// Subject returns the humus subject pattern matching this filter.
+func (f CompostFilter) Subject() string {
+	token := func(s string) string {
+		if s == "" {
+			return "*"
+		}
+		return subjectToken(s)
+	}
+	return fmt.Sprintf("humus.%s.%s.%s.*", token(f.EntityType), token(f.Nim), token(f.Action))
+}
+
+// String returns a readable form of the filter for logging.
+func (f CompostFilter) String() string {
+	return f.Subject()
+}
+
+// subjectToken makes a value safe to use as a single NATS subject token.
+func subjectToken(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '*', '>', ' ', '\t', '\r', '\n':
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// NodeConsumerName returns the durable consumer name prefix uses on one
+// node. Consumers that write to storage local to a node, like the archiver
+// and the bedrock syncer, need one each: a shared durable would split the
+// composts between nodes.
+func NodeConsumerName(prefix, nodeID string) string {
+	if nodeID == "" {
+		return prefix
+	}
+	return prefix + "-" + subjectToken(nodeID)
+}
+
 // Add composts a state change into humus.
 // Returns the sequence number (slot) assigned to this compost.
 func (h *Humus) Add(nimName, entity, action string, data []byte) (uint64, error) {
@@ -102,8 +322,8 @@ func (h *Humus) Add(nimName, entity, action string, data []byte) (uint64, error)
 		return 0, fmt.Errorf("failed to marshal compost: %w", err)
 	}
 
-	// Publish to the stream
-	subject := fmt.Sprintf("humus.%s.%s", nimName, action)
+	// Publish to the stream, keyed by the entity's partition
+	subject := h.compostSubject(nimName, entity, action)
 	ack, err := h.js.Publish(subject, payload)
 	if err != nil {
 		return 0, fmt.Errorf("failed to publish compost: %w", err)
@@ -196,6 +416,325 @@ func (h *Humus) DecomposeWithConsumer(consumerName string, handler func(compost
 	return nil
 }
 
+// DecomposePartition processes compost entries for a single partition.
+// It uses a durable pull consumer named "<consumerPrefix>-p<partition>" that
+// allows only one unacknowledged compost at a time, so entries are applied
+// strictly in order even if two lands briefly pull the same partition during
+// a rebalance. It blocks until ctx is cancelled.
+func (h *Humus) DecomposePartition(ctx context.Context, consumerPrefix string, partition int, handler func(compost Compost)) error {
+	if consumerPrefix == "" {
+		return fmt.Errorf("consumer prefix cannot be empty")
+	}
+	if partition < 0 || partition >= h.partitions {
+		return fmt.Errorf("partition %d out of range (0-%d)", partition, h.partitions-1)
+	}
+
+	consumerName := fmt.Sprintf("%s-p%d", consumerPrefix, partition)
+	if err := h.ensurePartitionConsumer(consumerName, partition); err != nil {
+		return err
+	}
+
+	// Bind to the existing consumer so unsubscribing never deletes it
+	sub, err := h.js.PullSubscribe(h.PartitionSubject(partition), consumerName, nats.Bind(h.stream, consumerName))
+	if err != nil {
+		return fmt.Errorf("failed to bind consumer %s: %w", consumerName, err)
+	}
+	defer sub.Unsubscribe()
+
+	log.Printf("[Humus] Decomposing partition %d with consumer: %s", partition, consumerName)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		msgs, err := sub.Fetch(1, nats.MaxWait(time.Second))
+		if err != nil {
+			if err == nats.ErrTimeout || ctx.Err() != nil {
+				continue
+			}
+			log.Printf("[Humus] Fetch error on %s: %v", consumerName, err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		for _, msg := range msgs {
+			h.deliver(msg, handler)
+		}
+	}
+}
+
+// DrainLegacy applies the composts a HUMUS stream holds on the
+// unpartitioned humus.<nim>.<action> subjects, then deletes the durable
+// consumer the single decomposer used and marks the stream drained. It
+// picks up where that consumer stopped, or at the start of the stream if
+// there is none, and returns at once on streams that need no draining.
+// Lands starting together share one consumer, so each legacy compost is
+// applied once and in order. It blocks until the drain is done or ctx is
+// cancelled.
+func (h *Humus) DrainLegacy(ctx context.Context, handler func(compost Compost)) error {
+	info, err := h.js.StreamInfo(h.stream)
+	if err != nil {
+		return fmt.Errorf("failed to get stream info: %w", err)
+	}
+	if _, ok := info.Config.Metadata[humusLegacyMetadataKey]; !ok {
+		return nil
+	}
+
+	if err := h.ensureLegacyDrainConsumer(); err != nil {
+		return err
+	}
+	sub, err := h.js.PullSubscribe(legacyCompostSubject, legacyDrainConsumer, nats.Bind(h.stream, legacyDrainConsumer))
+	if err != nil {
+		return fmt.Errorf("failed to bind consumer %s: %w", legacyDrainConsumer, err)
+	}
+	defer sub.Unsubscribe()
+
+	log.Printf("[Humus] Draining legacy composts with consumer: %s", legacyDrainConsumer)
+
+	for ctx.Err() == nil {
+		msgs, err := sub.Fetch(1, nats.MaxWait(time.Second))
+		if err != nil && err != nats.ErrTimeout {
+			log.Printf("[Humus] Fetch error on %s: %v", legacyDrainConsumer, err)
+			time.Sleep(time.Second)
+			continue
+		}
+		for _, msg := range msgs {
+			h.deliver(msg, handler)
+		}
+		if len(msgs) > 0 {
+			continue
+		}
+
+		consumer, err := h.js.ConsumerInfo(h.stream, legacyDrainConsumer)
+		if err == nats.ErrConsumerNotFound {
+			return nil // Another land finished the drain
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get consumer %s: %w", legacyDrainConsumer, err)
+		}
+		if consumer.NumPending == 0 && consumer.NumAckPending == 0 {
+			return h.finishLegacyDrain()
+		}
+	}
+	return ctx.Err()
+}
+
+// ensureLegacyDrainConsumer creates the consumer draining legacy composts,
+// starting after the last compost the single decomposer acknowledged.
+func (h *Humus) ensureLegacyDrainConsumer() error {
+	if _, err := h.js.ConsumerInfo(h.stream, legacyDrainConsumer); err == nil {
+		return nil
+	} else if err != nats.ErrConsumerNotFound {
+		return fmt.Errorf("failed to get consumer %s: %w", legacyDrainConsumer, err)
+	}
+
+	start := uint64(1)
+	if legacy, err := h.js.ConsumerInfo(h.stream, legacyConsumerName); err == nil {
+		start = legacy.AckFloor.Stream + 1
+	} else if err != nats.ErrConsumerNotFound {
+		return fmt.Errorf("failed to get consumer %s: %w", legacyConsumerName, err)
+	}
+
+	_, err := h.js.AddConsumer(h.stream, &nats.ConsumerConfig{
+		Durable:       legacyDrainConsumer,
+		FilterSubject: legacyCompostSubject,
+		DeliverPolicy: nats.DeliverByStartSequencePolicy,
+		OptStartSeq:   start,
+		AckPolicy:     nats.AckExplicitPolicy,
+		AckWait:       30 * time.Second,
+		MaxAckPending: 1, // Legacy composts aren't partitioned: apply them strictly in order
+	})
+	if err != nil {
+		// Another land may have created it first
+		if _, infoErr := h.js.ConsumerInfo(h.stream, legacyDrainConsumer); infoErr == nil {
+			return nil
+		}
+		return fmt.Errorf("failed to create consumer %s: %w", legacyDrainConsumer, err)
+	}
+	log.Printf("[Humus] Created consumer %s from slot %d", legacyDrainConsumer, start)
+	return nil
+}
+
+// finishLegacyDrain marks the stream drained, then deletes the legacy
+// consumers. The mark goes first, so a land starting meanwhile either
+// skips the drain or binds to a consumer with nothing left.
+func (h *Humus) finishLegacyDrain() error {
+	info, err := h.js.StreamInfo(h.stream)
+	if err != nil {
+		return fmt.Errorf("failed to get stream info: %w", err)
+	}
+	if _, ok := info.Config.Metadata[humusLegacyMetadataKey]; ok {
+		streamCfg := info.Config
+		streamCfg.Metadata = make(map[string]string, len(info.Config.Metadata))
+		for key, value := range info.Config.Metadata {
+			if key != humusLegacyMetadataKey {
+				streamCfg.Metadata[key] = value
+			}
+		}
+		if _, err := h.js.UpdateStream(&streamCfg); err != nil {
+			return fmt.Errorf("failed to mark stream %s drained: %w", h.stream, err)
+		}
+	}
+
+	for _, name := range []string{legacyConsumerName, legacyDrainConsumer} {
+		if err := h.js.DeleteConsumer(h.stream, name); err != nil && err != nats.ErrConsumerNotFound {
+			log.Printf("[Humus] Warning: failed to delete consumer %s: %v", name, err)
+		}
+	}
+	log.Printf("[Humus] Drained legacy composts")
+	return nil
+}
+
+// ensurePartitionConsumer creates the durable consumer for a partition if it
+// doesn't exist yet, or updates its filter if the subject layout changed.
+func (h *Humus) ensurePartitionConsumer(consumerName string, partition int) error {
+	return h.ensureConsumer(consumerName, h.PartitionSubject(partition), 1) // Strict per-partition ordering
+}
+
+// ensureConsumer creates a durable pull consumer if it doesn't exist yet,
+// or updates its filter if the subject layout changed. Consumers created
+// here are bound to rather than owned by subscriptions, so unsubscribing
+// never deletes them.
+func (h *Humus) ensureConsumer(consumerName, filter string, maxAckPending int) error {
+	info, err := h.js.ConsumerInfo(h.stream, consumerName)
+	if err == nil {
+		if info.Config.FilterSubject == filter {
+			return nil
+		}
+		cfg := info.Config
+		cfg.FilterSubject = filter
+		if _, err := h.js.UpdateConsumer(h.stream, &cfg); err != nil {
+			return fmt.Errorf("failed to update consumer %s: %w", consumerName, err)
+		}
+		return nil
+	}
+	if err != nats.ErrConsumerNotFound {
+		return fmt.Errorf("failed to get consumer %s: %w", consumerName, err)
+	}
+
+	_, err = h.js.AddConsumer(h.stream, &nats.ConsumerConfig{
+		Durable:       consumerName,
+		FilterSubject: filter,
+		DeliverPolicy: nats.DeliverAllPolicy,
+		AckPolicy:     nats.AckExplicitPolicy,
+		AckWait:       30 * time.Second,
+		MaxAckPending: maxAckPending,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create consumer %s: %w", consumerName, err)
+	}
+	return nil
+}
+
+// HumusSubscription is a secondary consumer of humus created by Subscribe
+// or SubscribeDurable.
+type HumusSubscription struct {
+	sub    *nats.Subscription
+	filter CompostFilter
+	done   chan struct{} // Closed when a durable subscription's fetch loop exits
+}
+
+// Stop stops delivering composts to the handler. For a durable
+// subscription it waits until the compost being delivered, if any, has
+// been handled, so none arrives after Stop returns. It must not be called
+// from the handler.
+// Durable consumers keep their position and resume on the next SubscribeDurable.
+func (s *HumusSubscription) Stop() error {
+	if s.sub == nil {
+		return nil
+	}
+	if err := s.sub.Unsubscribe(); err != nil {
+		return fmt.Errorf("failed to stop humus subscription %s: %w", s.filter, err)
+	}
+	s.sub = nil
+	if s.done != nil {
+		<-s.done
+	}
+	return nil
+}
+
+// Subscribe delivers new composts matching the filter to the handler.
+// Unlike the decomposer, secondary subscribers each get their own copy of
+// every matching compost, so a search indexer or notification nim can react
+// to state changes without competing with soil updates.
+// The subscription is ephemeral: only composts added after the call are delivered.
+func (h *Humus) Subscribe(filter CompostFilter, handler func(compost Compost)) (*HumusSubscription, error) {
+	sub, err := h.js.Subscribe(filter.Subject(), func(msg *nats.Msg) {
+		h.deliver(msg, handler)
+	},
+		nats.BindStream(h.stream),
+		nats.DeliverNew(),
+		nats.AckExplicit(),
+		nats.ManualAck(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to humus %s: %w", filter, err)
+	}
+
+	log.Printf("[Humus] Subscribed to %s", filter)
+	return &HumusSubscription{sub: sub, filter: filter}, nil
+}
+
+// SubscribeDurable delivers composts matching the filter through a named
+// durable consumer. A new consumer starts from the beginning of the stream;
+// an existing one resumes where it left off, even across restarts.
+// Composts are delivered one at a time in stream order.
+func (h *Humus) SubscribeDurable(consumerName string, filter CompostFilter, handler func(compost Compost)) (*HumusSubscription, error) {
+	if consumerName == "" {
+		return nil, fmt.Errorf("consumer name cannot be empty")
+	}
+	if err := h.ensureConsumer(consumerName, filter.Subject(), 1); err != nil {
+		return nil, err
+	}
+
+	sub, err := h.js.PullSubscribe(filter.Subject(), consumerName, nats.Bind(h.stream, consumerName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to bind consumer %s: %w", consumerName, err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for sub.IsValid() {
+			msgs, err := sub.Fetch(1, nats.MaxWait(time.Second))
+			if err != nil {
+				if err != nats.ErrTimeout && sub.IsValid() {
+					log.Printf("[Humus] Fetch error on %s: %v", consumerName, err)
+					time.Sleep(time.Second)
+				}
+				continue
+			}
+			for _, msg := range msgs {
+				h.deliver(msg, handler)
+			}
+		}
+	}()
+
+	log.Printf("[Humus] Subscribed to %s with consumer: %s", filter, consumerName)
+	return &HumusSubscription{sub: sub, filter: filter, done: done}, nil
+}
+
+// deliver decodes a compost message, calls the handler and acknowledges it.
+func (h *Humus) deliver(msg *nats.Msg, handler func(compost Compost)) {
+	var compost Compost
+	if err := json.Unmarshal(msg.Data, &compost); err != nil {
+		log.Printf("[Humus] Failed to unmarshal compost: %v", err)
+		msg.Term()
+		return
+	}
+
+	meta, err := msg.Metadata()
+	if err == nil {
+		compost.Slot = meta.Sequence.Stream
+	}
+
+	handler(compost)
+	msg.Ack()
+}
+
 // StreamInfo returns information about the humus stream.
 func (h *Humus) StreamInfo() (*nats.StreamInfo, error) {
 	info, err := h.js.StreamInfo(h.stream)
@@ -0,0 +1,597 @@
+package core
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// archiveExt is the extension of finished archive files.
+	archiveExt = ".jsonl.gz"
+
+	// partialExt marks the archive file currently being written.
+	partialExt = ".partial"
+
+	// archiveBatch is how many composts are written between fsyncs.
+	archiveBatch = 256
+
+	// importedHeader marks composts loaded by ImportArchives, which are
+	// already archived.
+	importedHeader = "Nimsforest-Imported"
+)
+
+// HumusArchiveConfig configures a HumusArchiver.
+type HumusArchiveConfig struct {
+	// Dir is the directory archive files are written to. Required.
+	Dir string
+
+	// ConsumerName is the durable consumer that tracks archive progress.
+	// Every node archiving to its own dir needs its own, see NodeConsumerName.
+	// Default: "humus-archiver"
+	ConsumerName string
+
+	// MaxFileBytes rotates the current file once this many uncompressed
+	// bytes have been written to it.
+	// Default: 64 MiB
+	MaxFileBytes int64
+
+	// RotateEvery rotates the current file on a fixed cadence, like a
+	// ceremony. It is counted in WindWaker beats when a Wind is given,
+	// and on a wall clock otherwise. Zero rotates on size only.
+	RotateEvery time.Duration
+
+	// Hz is the WindWaker frequency used to count beats.
+	// Default: 90
+	Hz int
+}
+
+// HumusArchiveStatus is a snapshot of the archiver's progress.
+type HumusArchiveStatus struct {
+	Dir         string    `json:"dir"`
+	Running     bool      `json:"running"`
+	CurrentFile string    `json:"current_file,omitempty"`
+	Files       int       `json:"files"`
+	Archived    uint64    `json:"archived"`
+	LastSlot    uint64    `json:"last_slot"`
+	LastRotated time.Time `json:"last_rotated,omitempty"`
+}
+
+// HumusArchiver continuously exports humus to gzip-compressed JSONL files,
+// one compost per line. Files are named after the first slot they contain,
+// so sorting them by name gives stream order. Composts are only acknowledged
+// after they are synced to disk, so the archive survives humus retention.
+type HumusArchiver struct {
+	humus  *Humus
+	wind   *Wind
+	config HumusArchiveConfig
+
+	mu          sync.Mutex
+	sub         *nats.Subscription
+	beatSub     *nats.Subscription
+	done        chan struct{}
+	wg          sync.WaitGroup
+	file        *os.File
+	gz          *gzip.Writer
+	path        string // Final path of the current file
+	written     int64
+	beats       uint64
+	archived    uint64
+	lastSlot    uint64
+	lastRotated time.Time
+}
+
+// NewHumusArchiver creates a humus archiver. wind is optional and only used
+// to count beats for RotateEvery.
+func NewHumusArchiver(humus *Humus, wind *Wind, cfg HumusArchiveConfig) (*HumusArchiver, error) {
+	if humus == nil {
+		return nil, fmt.Errorf("humus is required")
+	}
+	if cfg.Dir == "" {
+		return nil, fmt.Errorf("archive dir cannot be empty")
+	}
+	if cfg.ConsumerName == "" {
+		cfg.ConsumerName = "humus-archiver"
+	}
+	if cfg.MaxFileBytes <= 0 {
+		cfg.MaxFileBytes = 64 << 20
+	}
+	if cfg.Hz <= 0 {
+		cfg.Hz = 90
+	}
+
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create archive dir %s: %w", cfg.Dir, err)
+	}
+
+	return &HumusArchiver{
+		humus:  humus,
+		wind:   wind,
+		config: cfg,
+	}, nil
+}
+
+// Start begins exporting humus. It resumes after the last archived compost.
+func (a *HumusArchiver) Start() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.sub != nil {
+		return fmt.Errorf("humus archiver already running")
+	}
+
+	if err := a.recoverPartial(); err != nil {
+		return err
+	}
+
+	if err := a.humus.ensureConsumer(a.config.ConsumerName, "humus.>", archiveBatch); err != nil {
+		return err
+	}
+	sub, err := a.humus.js.PullSubscribe("humus.>", a.config.ConsumerName, nats.Bind(a.humus.stream, a.config.ConsumerName))
+	if err != nil {
+		return fmt.Errorf("failed to bind consumer %s: %w", a.config.ConsumerName, err)
+	}
+	a.sub = sub
+	a.done = make(chan struct{})
+
+	if a.config.RotateEvery > 0 {
+		if a.wind != nil {
+			a.beatSub, err = a.wind.Catch("dance.beat", a.onBeat)
+			if err != nil {
+				sub.Unsubscribe()
+				a.sub = nil
+				return fmt.Errorf("failed to catch beats: %w", err)
+			}
+		} else {
+			a.wg.Add(1)
+			go a.rotateOnClock(a.done)
+		}
+	}
+
+	a.wg.Add(1)
+	go a.run(sub, a.done)
+
+	log.Printf("[HumusArchiver] Started - dir: %s, max_file_bytes: %d, rotate_every: %v",
+		a.config.Dir, a.config.MaxFileBytes, a.config.RotateEvery)
+	return nil
+}
+
+// Stop stops exporting and closes the current file.
+// The consumer keeps its position, so a restart continues where it stopped.
+func (a *HumusArchiver) Stop() error {
+	a.mu.Lock()
+	if a.sub == nil {
+		a.mu.Unlock()
+		return nil
+	}
+	close(a.done)
+	if a.beatSub != nil {
+		a.beatSub.Unsubscribe()
+		a.beatSub = nil
+	}
+	a.mu.Unlock()
+
+	a.wg.Wait()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sub.Unsubscribe()
+	a.sub = nil
+
+	err := a.closeFile()
+	log.Printf("[HumusArchiver] Stopped (archived: %d, last slot: %d)", a.archived, a.lastSlot)
+	return err
+}
+
+// Rotate finishes the current archive file. The next compost starts a new one.
+func (a *HumusArchiver) Rotate() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.closeFile()
+}
+
+// Status returns the archiver's progress.
+func (a *HumusArchiver) Status() HumusArchiveStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	files, _ := ListHumusArchives(a.config.Dir)
+	return HumusArchiveStatus{
+		Dir:         a.config.Dir,
+		Running:     a.sub != nil,
+		CurrentFile: a.path,
+		Files:       len(files),
+		Archived:    a.archived,
+		LastSlot:    a.lastSlot,
+		LastRotated: a.lastRotated,
+	}
+}
+
+// run fetches composts in batches and writes them to the current file.
+func (a *HumusArchiver) run(sub *nats.Subscription, done chan struct{}) {
+	defer a.wg.Done()
+
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		msgs, err := sub.Fetch(archiveBatch, nats.MaxWait(time.Second))
+		if err != nil {
+			if err != nats.ErrTimeout {
+				log.Printf("[HumusArchiver] Fetch error: %v", err)
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+
+		if err := a.write(msgs); err != nil {
+			log.Printf("[HumusArchiver] Failed to archive batch: %v", err)
+			for _, msg := range msgs {
+				msg.Nak()
+			}
+			time.Sleep(time.Second)
+			continue
+		}
+		for _, msg := range msgs {
+			msg.Ack()
+		}
+	}
+}
+
+// write appends a batch of compost messages and syncs them to disk.
+func (a *HumusArchiver) write(msgs []*nats.Msg) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, msg := range msgs {
+		if msg.Header.Get(importedHeader) != "" {
+			continue // Replayed from an archive
+		}
+		var compost Compost
+		if err := json.Unmarshal(msg.Data, &compost); err != nil {
+			log.Printf("[HumusArchiver] Skipping invalid compost: %v", err)
+			continue
+		}
+		if meta, err := msg.Metadata(); err == nil {
+			compost.Slot = meta.Sequence.Stream
+		}
+
+		line, err := json.Marshal(compost)
+		if err != nil {
+			return fmt.Errorf("failed to marshal compost: %w", err)
+		}
+		line = append(line, '\n')
+
+		if a.gz == nil {
+			if err := a.openFile(compost.Slot); err != nil {
+				return err
+			}
+		}
+		if _, err := a.gz.Write(line); err != nil {
+			return fmt.Errorf("failed to write archive: %w", err)
+		}
+		a.written += int64(len(line))
+		a.archived++
+		a.lastSlot = compost.Slot
+
+		if a.written >= a.config.MaxFileBytes {
+			if err := a.closeFile(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if a.gz != nil {
+		if err := a.gz.Flush(); err != nil {
+			return fmt.Errorf("failed to flush archive: %w", err)
+		}
+		if err := a.file.Sync(); err != nil {
+			return fmt.Errorf("failed to sync archive: %w", err)
+		}
+	}
+	return nil
+}
+
+// openFile starts a new archive file named after its first slot.
+func (a *HumusArchiver) openFile(firstSlot uint64) error {
+	base := fmt.Sprintf("humus-%020d", firstSlot)
+	path := filepath.Join(a.config.Dir, base+archiveExt)
+	// A crash before acknowledging can replay slots that are already archived
+	for i := 1; fileExists(path) || fileExists(path+partialExt); i++ {
+		path = filepath.Join(a.config.Dir, fmt.Sprintf("%s-%d%s", base, i, archiveExt))
+	}
+
+	file, err := os.OpenFile(path+partialExt, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create archive file: %w", err)
+	}
+
+	a.file = file
+	a.gz = gzip.NewWriter(file)
+	a.path = path
+	a.written = 0
+	return nil
+}
+
+// closeFile finishes the current archive file, if any.
+func (a *HumusArchiver) closeFile() error {
+	if a.gz == nil {
+		return nil
+	}
+
+	err := a.gz.Close()
+	if syncErr := a.file.Sync(); err == nil {
+		err = syncErr
+	}
+	if closeErr := a.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(a.path+partialExt, a.path)
+	}
+
+	if err == nil {
+		log.Printf("[HumusArchiver] Rotated %s (%d bytes uncompressed)", filepath.Base(a.path), a.written)
+	}
+
+	a.file, a.gz, a.path, a.written = nil, nil, "", 0
+	a.lastRotated = time.Now()
+	if err != nil {
+		return fmt.Errorf("failed to finish archive file: %w", err)
+	}
+	return nil
+}
+
+// recoverPartial finishes files left behind by a crash. Their content was
+// synced before being acknowledged, but the gzip trailer may be missing;
+// ReadHumusArchive tolerates that.
+func (a *HumusArchiver) recoverPartial() error {
+	partials, err := filepath.Glob(filepath.Join(a.config.Dir, "*"+archiveExt+partialExt))
+	if err != nil {
+		return err
+	}
+	for _, partial := range partials {
+		if err := os.Rename(partial, strings.TrimSuffix(partial, partialExt)); err != nil {
+			return fmt.Errorf("failed to recover %s: %w", partial, err)
+		}
+		log.Printf("[HumusArchiver] Recovered unfinished archive %s", filepath.Base(partial))
+	}
+	return nil
+}
+
+// onBeat counts WindWaker beats and rotates every RotateEvery.
+func (a *HumusArchiver) onBeat(leaf Leaf) {
+	beatsPerRotate := uint64(a.config.RotateEvery.Seconds() * float64(a.config.Hz))
+	if beatsPerRotate == 0 {
+		beatsPerRotate = 1
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.beats++
+	if a.beats >= beatsPerRotate {
+		a.beats = 0
+		if err := a.closeFile(); err != nil {
+			log.Printf("[HumusArchiver] Rotation failed: %v", err)
+		}
+	}
+}
+
+// rotateOnClock rotates every RotateEvery when no Wind is available.
+func (a *HumusArchiver) rotateOnClock(done chan struct{}) {
+	defer a.wg.Done()
+
+	ticker := time.NewTicker(a.config.RotateEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := a.Rotate(); err != nil {
+				log.Printf("[HumusArchiver] Rotation failed: %v", err)
+			}
+		}
+	}
+}
+
+// RunHumusArchiver is a convenience function that creates and starts a humus archiver.
+func RunHumusArchiver(humus *Humus, wind *Wind, cfg HumusArchiveConfig) (*HumusArchiver, error) {
+	archiver, err := NewHumusArchiver(humus, wind, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := archiver.Start(); err != nil {
+		return nil, err
+	}
+	return archiver, nil
+}
+
+// ListHumusArchives returns the finished archive files in dir, in slot order.
+func ListHumusArchives(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "humus-*"+archiveExt))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// ReadHumusArchive calls fn for every compost in an archive file, in order.
+// Plain (uncompressed) .jsonl files are read as well. A truncated gzip
+// stream, as left by a crash, ends the file without an error.
+func ReadHumusArchive(path string, fn func(compost Compost) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if strings.HasSuffix(path, ".gz") || strings.HasSuffix(path, ".gz"+partialExt) {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("failed to read archive %s: %w", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var compost Compost
+		if err := json.Unmarshal(scanner.Bytes(), &compost); err != nil {
+			return fmt.Errorf("%s:%d: invalid compost: %w", path, line, err)
+		}
+		if err := fn(compost); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("failed to read archive %s: %w", path, err)
+	}
+	return nil
+}
+
+// ErrReservedStream is returned for archive imports into a stream name that
+// belongs to, or would publish on the subjects of, the forest's own streams.
+var ErrReservedStream = errors.New("reserved stream name")
+
+// reservedStreams are the forest's own streams. Import streams publish on
+// their lowercased name, so these are reserved in any case.
+var reservedStreams = []string{"HUMUS", "RIVER", "SOIL"}
+
+// reservedStreamPrefixes are the prefixes of the streams backing KV buckets
+// and object stores, e.g. KV_SOIL, and of per-land soil streams.
+var reservedStreamPrefixes = []string{"KV_", "OBJ_", "SOIL_"}
+
+// validateImportStream checks that stream can hold imported composts
+// without touching a live stream. Importing into humus itself is allowed.
+func (h *Humus) validateImportStream(stream string) error {
+	if stream == h.stream {
+		return nil
+	}
+	if strings.ContainsAny(stream, " \t.*>/\\") {
+		return fmt.Errorf("invalid stream name %q: must not contain spaces, '.', '*', '>', '/' or '\\'", stream)
+	}
+	if strings.EqualFold(stream, h.stream) {
+		return fmt.Errorf("%w: %s collides with stream %s", ErrReservedStream, stream, h.stream)
+	}
+	upper := strings.ToUpper(stream)
+	for _, name := range reservedStreams {
+		if upper == name {
+			return fmt.Errorf("%w: %s collides with stream %s", ErrReservedStream, stream, name)
+		}
+	}
+	for _, prefix := range reservedStreamPrefixes {
+		if strings.HasPrefix(upper, prefix) {
+			return fmt.Errorf("%w: %s starts with %s", ErrReservedStream, stream, prefix)
+		}
+	}
+	return nil
+}
+
+// ImportResult summarises an archive import.
+type ImportResult struct {
+	Stream     string `json:"stream"`
+	Files      int    `json:"files"`
+	Imported   int    `json:"imported"`
+	Duplicates int    `json:"duplicates"`
+}
+
+// ImportArchives loads archive files back into a stream, for audits or to
+// rebuild soil. Composts are published on "<stream>.<entity_type>.<nim>.<action>.<partition>"
+// with the stream name lowercased, so importing into "HUMUS" replays them
+// through the decomposers while e.g. "HUMUS_AUDIT" keeps them separate.
+// Streams other than HUMUS are created without limits if they don't exist.
+// Composts are deduplicated by their original slot within the stream's
+// duplicate window, so an interrupted import can simply be retried. They
+// carry a header that keeps the archiver from archiving them again.
+// Names of the forest's own streams are rejected with ErrReservedStream.
+func (h *Humus) ImportArchives(paths []string, stream string) (ImportResult, error) {
+	if stream == "" {
+		stream = h.stream
+	}
+	result := ImportResult{Stream: stream}
+	if err := h.validateImportStream(stream); err != nil {
+		return result, err
+	}
+	prefix := strings.ToLower(stream)
+
+	if stream != h.stream {
+		if _, err := h.js.StreamInfo(stream); err != nil {
+			_, err = h.js.AddStream(&nats.StreamConfig{
+				Name:        stream,
+				Description: "NimsForest humus archive import",
+				Subjects:    []string{prefix + ".>"},
+				Storage:     nats.FileStorage,
+				Retention:   nats.LimitsPolicy,
+			})
+			if err != nil {
+				return result, fmt.Errorf("failed to create stream %s: %w", stream, err)
+			}
+			log.Printf("[Humus] Created import stream: %s", stream)
+		}
+	}
+
+	for _, path := range paths {
+		err := ReadHumusArchive(path, func(compost Compost) error {
+			payload, err := json.Marshal(compost)
+			if err != nil {
+				return fmt.Errorf("failed to marshal compost: %w", err)
+			}
+			subject := fmt.Sprintf("%s.%s.%s.%s.%d", prefix,
+				EntityType(compost.Entity), subjectToken(compost.NimName), compost.Action,
+				PartitionFor(compost.Entity, h.partitions))
+
+			msg := nats.NewMsg(subject)
+			msg.Data = payload
+			msg.Header.Set(importedHeader, strconv.FormatUint(compost.Slot, 10))
+			ack, err := h.js.PublishMsg(msg, nats.MsgId(fmt.Sprintf("humus-archive-%d", compost.Slot)))
+			if err != nil {
+				return fmt.Errorf("failed to import slot %d: %w", compost.Slot, err)
+			}
+			if ack.Duplicate {
+				result.Duplicates++
+			} else {
+				result.Imported++
+			}
+			return nil
+		})
+		if err != nil {
+			return result, err
+		}
+		result.Files++
+	}
+
+	log.Printf("[Humus] Imported %d composts from %d archive files into %s (%d duplicates)",
+		result.Imported, result.Files, stream, result.Duplicates)
+	return result, nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,219 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHumusConfig_Retention(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       HumusConfig
+		wantAge   time.Duration
+		wantMsgs  int64
+		wantBytes int64
+	}{
+		{"defaults", HumusConfig{}, DefaultHumusMaxAge, DefaultHumusMaxMsgs, -1},
+		{"configured", HumusConfig{MaxAge: time.Hour, MaxMsgs: 10, MaxBytes: 1024}, time.Hour, 10, 1024},
+		{"unlimited", HumusConfig{MaxAge: -1, MaxMsgs: -1}, 0, -1, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			age, msgs, bytes := tt.cfg.retention()
+			if age != tt.wantAge || msgs != tt.wantMsgs || bytes != tt.wantBytes {
+				t.Errorf("retention() = (%v, %d, %d), want (%v, %d, %d)",
+					age, msgs, bytes, tt.wantAge, tt.wantMsgs, tt.wantBytes)
+			}
+		})
+	}
+}
+
+func TestNewHumus_UpdatesRetention(t *testing.T) {
+	js, nc := setupTestJetStream(t)
+	defer nc.Close()
+
+	js.DeleteStream("HUMUS")
+	if _, err := NewHumus(js); err != nil {
+		t.Fatalf("Failed to create humus: %v", err)
+	}
+
+	if _, err := NewHumusWithConfig(js, HumusConfig{MaxAge: 30 * 24 * time.Hour, MaxMsgs: -1}); err != nil {
+		t.Fatalf("Failed to reopen humus: %v", err)
+	}
+
+	info, _ := js.StreamInfo("HUMUS")
+	if info.Config.MaxAge != 30*24*time.Hour {
+		t.Errorf("Expected MaxAge 720h, got %v", info.Config.MaxAge)
+	}
+	if info.Config.MaxMsgs != -1 {
+		t.Errorf("Expected unlimited MaxMsgs, got %d", info.Config.MaxMsgs)
+	}
+}
+
+func TestHumusArchiver_ExportAndImport(t *testing.T) {
+	js, nc := setupTestJetStream(t)
+	defer nc.Close()
+
+	js.DeleteStream("HUMUS")
+	js.DeleteStream("HUMUS_AUDIT_TEST")
+	defer js.DeleteStream("HUMUS_AUDIT_TEST")
+	humus, _ := NewHumus(js)
+
+	for i := 0; i < 10; i++ {
+		humus.Add("test-nim", fmt.Sprintf("tasks/%d", i), "create", []byte(fmt.Sprintf(`{"n": %d}`, i)))
+	}
+
+	dir := t.TempDir()
+	archiver, err := NewHumusArchiver(humus, nil, HumusArchiveConfig{
+		Dir:          dir,
+		MaxFileBytes: 600, // A few composts per file
+	})
+	if err != nil {
+		t.Fatalf("Failed to create archiver: %v", err)
+	}
+	if err := archiver.Start(); err != nil {
+		t.Fatalf("Failed to start archiver: %v", err)
+	}
+
+	waitFor(t, func() bool { return archiver.Status().Archived == 10 })
+	if err := archiver.Stop(); err != nil {
+		t.Fatalf("Failed to stop archiver: %v", err)
+	}
+
+	files, _ := ListHumusArchives(dir)
+	if len(files) < 2 {
+		t.Fatalf("Expected size-based rotation into several files, got %v", files)
+	}
+	if partials, _ := filepath.Glob(filepath.Join(dir, "*.partial")); len(partials) != 0 {
+		t.Errorf("Expected no partial files after stop, got %v", partials)
+	}
+
+	// Files are in slot order
+	var slots []uint64
+	for _, file := range files {
+		err := ReadHumusArchive(file, func(c Compost) error {
+			slots = append(slots, c.Slot)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+	}
+	if len(slots) != 10 {
+		t.Fatalf("Expected 10 archived composts, got %d", len(slots))
+	}
+	for i := 1; i < len(slots); i++ {
+		if slots[i] <= slots[i-1] {
+			t.Errorf("Archive out of order: %v", slots)
+		}
+	}
+
+	// Import into a separate stream, twice - the second run is deduplicated
+	result, err := humus.ImportArchives(files, "HUMUS_AUDIT_TEST")
+	if err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	if result.Imported != 10 || result.Files != len(files) {
+		t.Errorf("Unexpected import result: %+v", result)
+	}
+	result, _ = humus.ImportArchives(files, "HUMUS_AUDIT_TEST")
+	if result.Imported != 0 || result.Duplicates != 10 {
+		t.Errorf("Expected all duplicates on re-import, got %+v", result)
+	}
+
+	info, _ := js.StreamInfo("HUMUS_AUDIT_TEST")
+	if info.State.Msgs != 10 {
+		t.Errorf("Expected 10 messages in audit stream, got %d", info.State.Msgs)
+	}
+}
+
+func TestHumus_ImportReservedStream(t *testing.T) {
+	js, nc := setupTestJetStream(t)
+	defer nc.Close()
+
+	js.DeleteStream("HUMUS")
+	humus, _ := NewHumus(js)
+
+	for _, stream := range []string{"humus", "Humus", "river", "SOIL", "soil_archive", "KV_SOIL", "kv_decomposers", "OBJ_files"} {
+		_, err := humus.ImportArchives(nil, stream)
+		if !errors.Is(err, ErrReservedStream) {
+			t.Errorf("ImportArchives(%q): expected ErrReservedStream, got %v", stream, err)
+		}
+	}
+	for _, stream := range []string{"humus.audit", "audit*", "a b"} {
+		if _, err := humus.ImportArchives(nil, stream); err == nil {
+			t.Errorf("ImportArchives(%q): expected an invalid name error", stream)
+		}
+	}
+
+	// HUMUS itself replays into soil
+	if _, err := humus.ImportArchives(nil, ""); err != nil {
+		t.Errorf("Expected importing into HUMUS to be allowed: %v", err)
+	}
+}
+
+func TestHumusArchiver_SkipsImported(t *testing.T) {
+	js, nc := setupTestJetStream(t)
+	defer nc.Close()
+
+	js.DeleteStream("HUMUS")
+	humus, _ := NewHumus(js)
+	for i := 0; i < 3; i++ {
+		humus.Add("test-nim", fmt.Sprintf("tasks/%d", i), "create", []byte(`{}`))
+	}
+
+	dir := t.TempDir()
+	archiver, _ := NewHumusArchiver(humus, nil, HumusArchiveConfig{Dir: dir})
+	if err := archiver.Start(); err != nil {
+		t.Fatalf("Failed to start archiver: %v", err)
+	}
+	defer archiver.Stop()
+	waitFor(t, func() bool { return archiver.Status().Archived == 3 })
+	archiver.Rotate()
+
+	// Replaying the archive into HUMUS doesn't archive it again
+	files, _ := ListHumusArchives(dir)
+	if result, err := humus.ImportArchives(files, ""); err != nil || result.Imported != 3 {
+		t.Fatalf("Unexpected import result: %+v (err=%v)", result, err)
+	}
+	humus.Add("test-nim", "tasks/3", "create", []byte(`{}`))
+	waitFor(t, func() bool { return archiver.Status().Archived >= 4 })
+	if archived := archiver.Status().Archived; archived != 4 {
+		t.Errorf("Expected 4 archived composts, got %d", archived)
+	}
+}
+
+func TestNodeConsumerName(t *testing.T) {
+	if got := NodeConsumerName("humus-archiver", "land.eu 1"); got != "humus-archiver-land_eu_1" {
+		t.Errorf("NodeConsumerName() = %q", got)
+	}
+	if got := NodeConsumerName("humus-archiver", ""); got != "humus-archiver" {
+		t.Errorf("NodeConsumerName() = %q", got)
+	}
+}
+
+func TestHumusArchiver_RecoversPartialFile(t *testing.T) {
+	js, nc := setupTestJetStream(t)
+	defer nc.Close()
+
+	js.DeleteStream("HUMUS")
+	humus, _ := NewHumus(js)
+
+	dir := t.TempDir()
+	partial := filepath.Join(dir, "humus-00000000000000000001.jsonl.gz.partial")
+	os.WriteFile(partial, nil, 0644)
+
+	archiver, _ := NewHumusArchiver(humus, nil, HumusArchiveConfig{Dir: dir})
+	if err := archiver.Start(); err != nil {
+		t.Fatalf("Failed to start archiver: %v", err)
+	}
+	defer archiver.Stop()
+
+	if _, err := os.Stat(filepath.Join(dir, "humus-00000000000000000001.jsonl.gz")); err != nil {
+		t.Errorf("Expected partial file to be recovered: %v", err)
+	}
+}
@@ -328,3 +328,132 @@ func TestHumus_OrderingGuarantee(t *testing.T) {
 		t.Fatal("Timeout waiting for decomposition")
 	}
 }
+
+func TestEntityType(t *testing.T) {
+	tests := []struct {
+		entity string
+		want   string
+	}{
+		{"task:123", "task"},
+		{"tasks/followup-123", "tasks"},
+		{"lead:acme:42", "lead"},
+		{"task-cus_alice-1700000000", "untyped"},
+		{":leading-colon", "untyped"},
+		{"a.b:1", "a_b"},
+	}
+
+	for _, tt := range tests {
+		if got := EntityType(tt.entity); got != tt.want {
+			t.Errorf("EntityType(%q) = %q, want %q", tt.entity, got, tt.want)
+		}
+	}
+}
+
+func TestCompostFilter_Subject(t *testing.T) {
+	tests := []struct {
+		filter CompostFilter
+		want   string
+	}{
+		{CompostFilter{}, "humus.*.*.*.*"},
+		{CompostFilter{EntityType: "task"}, "humus.task.*.*.*"},
+		{CompostFilter{Nim: "aftersales", Action: "create"}, "humus.*.aftersales.create.*"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.filter.Subject(); got != tt.want {
+			t.Errorf("Subject() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestHumus_Subscribe(t *testing.T) {
+	js, nc := setupTestJetStream(t)
+	defer nc.Close()
+
+	js.DeleteStream("HUMUS")
+	humus, err := NewHumus(js)
+	if err != nil {
+		t.Fatalf("Failed to create humus: %v", err)
+	}
+
+	tasks := make(chan Compost, 10)
+	sub, err := humus.Subscribe(CompostFilter{EntityType: "task"}, func(compost Compost) {
+		tasks <- compost
+	})
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	defer sub.Stop()
+
+	time.Sleep(200 * time.Millisecond)
+
+	humus.Add("test-nim", "lead:1", "create", []byte(`{"lead": true}`))
+	humus.Add("test-nim", "task:1", "create", []byte(`{"task": true}`))
+
+	select {
+	case compost := <-tasks:
+		if compost.Entity != "task:1" {
+			t.Errorf("Expected entity task:1, got %s", compost.Entity)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Timeout waiting for task compost")
+	}
+
+	select {
+	case compost := <-tasks:
+		t.Errorf("Unexpected compost delivered: %s", compost.Entity)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestHumus_SubscribeDurable(t *testing.T) {
+	js, nc := setupTestJetStream(t)
+	defer nc.Close()
+
+	js.DeleteStream("HUMUS")
+	humus, err := NewHumus(js)
+	if err != nil {
+		t.Fatalf("Failed to create humus: %v", err)
+	}
+
+	// Composts added before subscribing are delivered to a new durable consumer
+	humus.Add("test-nim", "task:1", "create", []byte(`{"n": 1}`))
+
+	received := make(chan Compost, 10)
+	sub, err := humus.SubscribeDurable("indexer", CompostFilter{EntityType: "task"}, func(compost Compost) {
+		received <- compost
+	})
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	select {
+	case compost := <-received:
+		if compost.Entity != "task:1" {
+			t.Errorf("Expected entity task:1, got %s", compost.Entity)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Timeout waiting for durable delivery")
+	}
+	sub.Stop()
+
+	// Resuming only delivers what was missed
+	humus.Add("test-nim", "task:2", "create", []byte(`{"n": 2}`))
+
+	sub, err = humus.SubscribeDurable("indexer", CompostFilter{EntityType: "task"}, func(compost Compost) {
+		received <- compost
+	})
+	if err != nil {
+		t.Fatalf("Failed to resubscribe: %v", err)
+	}
+	defer sub.Stop()
+
+	select {
+	case compost := <-received:
+		if compost.Entity != "task:2" {
+			t.Errorf("Expected entity task:2 after resume, got %s", compost.Entity)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Timeout waiting for resumed delivery")
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"sync"
+
+	"github.com/nats-io/nats.go"
 )
 
 // Nim represents a business logic component that reacts to leaves.
@@ -34,6 +37,9 @@ type BaseNim struct {
 	wind  *Wind
 	humus *Humus
 	soil  *Soil
+
+	mu   sync.Mutex
+	subs []*nats.Subscription
 }
 
 // NewBaseNim creates a new base nim with the given name and connections.
@@ -143,13 +149,23 @@ func (n *BaseNim) BuryStruct(entity string, data interface{}, expectedRevision u
 	return n.Bury(entity, jsonData, expectedRevision)
 }
 
+// Query finds entities in soil matching a key pattern and filter.
+func (n *BaseNim) Query(pattern string, filter QueryFilter) ([]QueryResult, error) {
+	results, err := n.soil.Query(pattern, filter)
+	if err != nil {
+		return nil, fmt.Errorf("nim %s failed to query %s: %w", n.name, pattern, err)
+	}
+	return results, nil
+}
+
 // Catch starts listening for leaves matching the given subject pattern.
 // This is a helper method for concrete nims to use in their Start() implementation.
 func (n *BaseNim) Catch(subject string, handler func(leaf Leaf)) error {
-	_, err := n.wind.Catch(subject, handler)
+	sub, err := n.wind.Catch(subject, handler)
 	if err != nil {
 		return fmt.Errorf("nim %s failed to catch %s: %w", n.name, subject, err)
 	}
+	n.track(sub)
 
 	log.Printf("[Nim:%s] Catching leaves: %s", n.name, subject)
 	return nil
@@ -157,15 +173,34 @@ func (n *BaseNim) Catch(subject string, handler func(leaf Leaf)) error {
 
 // CatchWithQueue starts listening with a queue group for load balancing.
 func (n *BaseNim) CatchWithQueue(subject, queue string, handler func(leaf Leaf)) error {
-	_, err := n.wind.CatchWithQueue(subject, queue, handler)
+	sub, err := n.wind.CatchWithQueue(subject, queue, handler)
 	if err != nil {
 		return fmt.Errorf("nim %s failed to catch %s with queue %s: %w", n.name, subject, queue, err)
 	}
+	n.track(sub)
 
 	log.Printf("[Nim:%s] Catching leaves with queue: %s (queue: %s)", n.name, subject, queue)
 	return nil
 }
 
+// StopCatching unsubscribes everything caught with Catch and
+// CatchWithQueue, so a stopped nim can be started again or removed.
+// Concrete nims should call it from Stop.
+func (n *BaseNim) StopCatching() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, sub := range n.subs {
+		sub.Unsubscribe()
+	}
+	n.subs = nil
+}
+
+func (n *BaseNim) track(sub *nats.Subscription) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.subs = append(n.subs, sub)
+}
+
 // GetWind returns the wind connection (for testing or advanced usage).
 func (n *BaseNim) GetWind() *Wind {
 	return n.wind
@@ -0,0 +1,671 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// ProjectionFunc updates a projection's view for a single compost.
+// It is called once per matching compost, in stream order.
+type ProjectionFunc func(compost Compost, view *View) error
+
+// Projection is a read model derived from humus.
+// It consumes matching composts through its own durable consumer and keeps
+// indexes, counters or aggregates in a dedicated KV bucket (its View), so
+// queries like "open tasks for customer X" don't need a scan of soil.
+//
+// Lands running the same projection share its consumer and view. Each
+// rebuild starts a new generation, recorded in the PROJECTIONS bucket, with
+// a consumer and bucket of its own; every land watches the generation and
+// switches over, so no land keeps feeding the view being rebuilt.
+type Projection struct {
+	name   string
+	humus  *Humus
+	filter CompostFilter
+	fn     ProjectionFunc
+	bucket string // Bucket of generation 0
+	gens   nats.KeyValue
+
+	lifecycle sync.Mutex // Serializes Start, Stop, Rebuild and generation switches
+
+	mu         sync.Mutex
+	generation uint64
+	view       *View
+	sub        *HumusSubscription
+	watcher    nats.KeyWatcher
+	processed  uint64
+	errors     uint64
+	lastSlot   uint64
+	lastError  string
+	updatedAt  time.Time
+}
+
+// ProjectionStatus is a snapshot of a projection's progress.
+type ProjectionStatus struct {
+	Name       string    `json:"name"`
+	Generation uint64    `json:"generation"`
+	Bucket     string    `json:"bucket"`
+	Consumer   string    `json:"consumer"`
+	Filter     string    `json:"filter"`
+	Running    bool      `json:"running"`
+	Processed  uint64    `json:"processed"`
+	Errors     uint64    `json:"errors"`
+	LastSlot   uint64    `json:"last_slot"`
+	LastError  string    `json:"last_error,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at,omitempty"`
+}
+
+// NewProjection creates a projection over humus.
+// If bucket is empty, it defaults to "PROJECTION_<NAME>"; later generations
+// append "_G<n>". The bucket of the current generation is created if it
+// doesn't exist.
+func NewProjection(humus *Humus, name string, filter CompostFilter, bucket string, fn ProjectionFunc) (*Projection, error) {
+	if humus == nil {
+		return nil, fmt.Errorf("humus is required")
+	}
+	if name == "" {
+		return nil, fmt.Errorf("projection name cannot be empty")
+	}
+	if fn == nil {
+		return nil, fmt.Errorf("projection function is required")
+	}
+	if bucket == "" {
+		bucket = ProjectionBucket(name)
+	}
+
+	gens, err := projectionGenerations(humus.js)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Projection{
+		name:   name,
+		humus:  humus,
+		filter: filter,
+		fn:     fn,
+		bucket: bucket,
+		gens:   gens,
+	}
+
+	generation, _, err := p.currentGeneration()
+	if err != nil {
+		return nil, err
+	}
+	view, err := p.openView(generation)
+	if err != nil {
+		return nil, err
+	}
+	p.generation, p.view = generation, view
+
+	return p, nil
+}
+
+// projectionGenerationsBucket records the current generation of each projection.
+const projectionGenerationsBucket = "PROJECTIONS"
+
+// projectionGenerations gets or creates the bucket of projection generations.
+func projectionGenerations(js nats.JetStreamContext) (nats.KeyValue, error) {
+	kv, err := js.KeyValue(projectionGenerationsBucket)
+	if err == nil {
+		return kv, nil
+	}
+	kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+		Bucket:      projectionGenerationsBucket,
+		Description: "NimsForest projection generations",
+		History:     1,
+		Storage:     nats.FileStorage,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create KV bucket %s: %w", projectionGenerationsBucket, err)
+	}
+	log.Printf("[Projection] Created KV bucket: %s", projectionGenerationsBucket)
+	return kv, nil
+}
+
+// ProjectionBucket returns the default KV bucket name for a projection.
+func ProjectionBucket(name string) string {
+	return "PROJECTION_" + strings.ToUpper(invalidBucketChars.ReplaceAllString(name, "_"))
+}
+
+var invalidBucketChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// key returns the projection's key in the PROJECTIONS bucket.
+func (p *Projection) key() string {
+	return invalidBucketChars.ReplaceAllString(p.name, "_")
+}
+
+// consumerName returns the durable consumer a generation of this
+// projection uses.
+func (p *Projection) consumerName(generation uint64) string {
+	name := "projection-" + p.key()
+	if generation > 0 {
+		name += fmt.Sprintf("-g%d", generation)
+	}
+	return name
+}
+
+// bucketName returns the KV bucket holding a generation's view.
+func (p *Projection) bucketName(generation uint64) string {
+	if generation > 0 {
+		return fmt.Sprintf("%s_G%d", p.bucket, generation)
+	}
+	return p.bucket
+}
+
+// currentGeneration reads the projection's generation and its revision in
+// the PROJECTIONS bucket. A projection never rebuilt is at generation 0.
+func (p *Projection) currentGeneration() (uint64, uint64, error) {
+	entry, err := p.gens.Get(p.key())
+	if err == nats.ErrKeyNotFound {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get generation of projection %s: %w", p.name, err)
+	}
+	generation, err := strconv.ParseUint(string(entry.Value()), 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid generation of projection %s: %w", p.name, err)
+	}
+	return generation, entry.Revision(), nil
+}
+
+// openView gets or creates the KV bucket of a generation's view.
+func (p *Projection) openView(generation uint64) (*View, error) {
+	bucket := p.bucketName(generation)
+	kv, err := p.humus.js.KeyValue(bucket)
+	if err != nil {
+		kv, err = p.humus.js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:      bucket,
+			Description: fmt.Sprintf("NimsForest projection %s", p.name),
+			History:     1,
+			Storage:     nats.FileStorage,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create KV bucket %s: %w", bucket, err)
+		}
+		log.Printf("[Projection:%s] Created KV bucket: %s", p.name, bucket)
+	}
+	return &View{kv: kv, projection: p.name}, nil
+}
+
+// Name returns the projection name.
+func (p *Projection) Name() string {
+	return p.name
+}
+
+// View returns the projection's read model.
+func (p *Projection) View() *View {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.view
+}
+
+// Start begins consuming humus. A new projection starts from the beginning
+// of the stream; an existing one resumes where it left off. It follows
+// rebuilds started on other lands until stopped.
+func (p *Projection) Start() error {
+	p.lifecycle.Lock()
+	defer p.lifecycle.Unlock()
+
+	p.mu.Lock()
+	running := p.sub != nil
+	p.mu.Unlock()
+	if running {
+		return fmt.Errorf("projection %s already running", p.name)
+	}
+
+	// Catch up with rebuilds made while stopped
+	generation, _, err := p.currentGeneration()
+	if err != nil {
+		return err
+	}
+	if err := p.switchTo(generation); err != nil {
+		return err
+	}
+
+	watcher, err := p.gens.Watch(p.key(), nats.UpdatesOnly())
+	if err != nil {
+		return fmt.Errorf("failed to watch generation of projection %s: %w", p.name, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sub, err := p.humus.SubscribeDurable(p.consumerName(p.generation), p.filter, p.apply)
+	if err != nil {
+		watcher.Stop()
+		return fmt.Errorf("failed to start projection %s: %w", p.name, err)
+	}
+	p.sub, p.watcher = sub, watcher
+	go p.follow(watcher)
+
+	log.Printf("[Projection:%s] Started - filter: %s, bucket: %s", p.name, p.filter, p.bucketName(p.generation))
+	return nil
+}
+
+// Stop stops consuming humus. The consumer keeps its position.
+func (p *Projection) Stop() error {
+	p.lifecycle.Lock()
+	defer p.lifecycle.Unlock()
+
+	p.mu.Lock()
+	watcher := p.watcher
+	p.watcher = nil
+	p.mu.Unlock()
+	if watcher != nil {
+		watcher.Stop()
+	}
+	return p.stop()
+}
+
+// stop stops consuming and waits for the compost being applied, which
+// needs p.mu, so it must be called without holding it.
+func (p *Projection) stop() error {
+	p.mu.Lock()
+	sub := p.sub
+	p.sub = nil
+	p.mu.Unlock()
+
+	if sub == nil {
+		return nil
+	}
+	err := sub.Stop()
+	log.Printf("[Projection:%s] Stopped", p.name)
+	return err
+}
+
+// follow switches to the generations other lands start by rebuilding,
+// until the watcher is stopped.
+func (p *Projection) follow(watcher nats.KeyWatcher) {
+	for entry := range watcher.Updates() {
+		if entry == nil || entry.Operation() != nats.KeyValuePut {
+			continue
+		}
+		generation, err := strconv.ParseUint(string(entry.Value()), 10, 64)
+		if err != nil {
+			log.Printf("[Projection:%s] Ignoring invalid generation %q", p.name, entry.Value())
+			continue
+		}
+
+		p.lifecycle.Lock()
+		p.mu.Lock()
+		current := p.watcher == watcher && generation > p.generation
+		p.mu.Unlock()
+		if current {
+			log.Printf("[Projection:%s] Switching to generation %d rebuilt by another land", p.name, generation)
+			if err := p.switchTo(generation); err != nil {
+				log.Printf("[Projection:%s] Failed to switch to generation %d: %v", p.name, generation, err)
+			}
+		}
+		p.lifecycle.Unlock()
+	}
+}
+
+// switchTo moves the projection to a generation: it stops consuming the
+// old generation, waiting for the compost in flight so none reaches the new
+// view, then opens the new view and resumes on the new consumer if it was
+// running. A new consumer starts from the beginning of humus. The caller
+// holds p.lifecycle.
+func (p *Projection) switchTo(generation uint64) error {
+	p.mu.Lock()
+	same := generation == p.generation
+	wasRunning := p.sub != nil
+	p.mu.Unlock()
+	if same {
+		return nil
+	}
+
+	if err := p.stop(); err != nil {
+		log.Printf("[Projection:%s] Warning: error stopping generation switch: %v", p.name, err)
+	}
+
+	view, err := p.openView(generation)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.generation, p.view = generation, view
+	p.processed, p.errors, p.lastSlot, p.lastError = 0, 0, 0, ""
+
+	if wasRunning {
+		sub, err := p.humus.SubscribeDurable(p.consumerName(generation), p.filter, p.apply)
+		if err != nil {
+			return fmt.Errorf("failed to restart projection %s: %w", p.name, err)
+		}
+		p.sub = sub
+	}
+	return nil
+}
+
+// Rebuild discards the view and replays humus from the beginning.
+// Only composts still retained by the HUMUS stream are replayed.
+// It starts a new generation that every land running the projection
+// switches to, then deletes the consumer and bucket of the old one.
+func (p *Projection) Rebuild() error {
+	p.lifecycle.Lock()
+	defer p.lifecycle.Unlock()
+
+	old, generation, err := p.nextGeneration()
+	if err != nil {
+		return err
+	}
+	if err := p.switchTo(generation); err != nil {
+		return err
+	}
+
+	// Lands still on the old generation switch on the watch; until then
+	// their fetches fail, and nothing recreates the old consumer
+	if err := p.humus.js.DeleteConsumer(p.humus.stream, p.consumerName(old)); err != nil && err != nats.ErrConsumerNotFound {
+		log.Printf("[Projection:%s] Warning: failed to delete consumer of generation %d: %v", p.name, old, err)
+	}
+	if err := p.humus.js.DeleteKeyValue(p.bucketName(old)); err != nil && err != nats.ErrBucketNotFound && err != nats.ErrStreamNotFound {
+		log.Printf("[Projection:%s] Warning: failed to delete view of generation %d: %v", p.name, old, err)
+	}
+
+	log.Printf("[Projection:%s] Rebuilding from the start of humus (generation %d)", p.name, generation)
+	return nil
+}
+
+// nextGeneration records a new generation in the PROJECTIONS bucket,
+// retrying if another land rebuilds at the same time, and returns the
+// generation it replaces and the new one.
+func (p *Projection) nextGeneration() (uint64, uint64, error) {
+	for attempt := 0; attempt < 10; attempt++ {
+		current, revision, err := p.currentGeneration()
+		if err != nil {
+			return 0, 0, err
+		}
+		next := []byte(strconv.FormatUint(current+1, 10))
+		if revision == 0 {
+			_, err = p.gens.Create(p.key(), next)
+		} else {
+			_, err = p.gens.Update(p.key(), next, revision)
+		}
+		if err == nil {
+			return current, current + 1, nil
+		}
+		if !isRevisionConflict(err) {
+			return 0, 0, fmt.Errorf("failed to record generation of projection %s: %w", p.name, err)
+		}
+	}
+	return 0, 0, fmt.Errorf("failed to record generation of projection %s: too many concurrent rebuilds", p.name)
+}
+
+// Status returns the projection's progress.
+func (p *Projection) Status() ProjectionStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return ProjectionStatus{
+		Name:       p.name,
+		Generation: p.generation,
+		Bucket:     p.bucketName(p.generation),
+		Consumer:   p.consumerName(p.generation),
+		Filter:     p.filter.Subject(),
+		Running:    p.sub != nil,
+		Processed:  p.processed,
+		Errors:     p.errors,
+		LastSlot:   p.lastSlot,
+		LastError:  p.lastError,
+		UpdatedAt:  p.updatedAt,
+	}
+}
+
+// apply runs the projection function for one compost and records progress.
+func (p *Projection) apply(compost Compost) {
+	p.mu.Lock()
+	view := p.view
+	p.mu.Unlock()
+
+	err := p.fn(compost, view)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.processed++
+	p.lastSlot = compost.Slot
+	p.updatedAt = time.Now()
+	if err != nil {
+		p.errors++
+		p.lastError = err.Error()
+		log.Printf("[Projection:%s] Error projecting slot %d (%s): %v", p.name, compost.Slot, compost.Entity, err)
+	}
+}
+
+// View is the KV-backed read model maintained by a projection.
+// Keys may contain any characters; they are encoded to valid KV keys.
+// Keys that can't be encoded, like "" or ones starting or ending with ".",
+// are skipped with a log line: reads find nothing and writes do nothing.
+type View struct {
+	kv         nats.KeyValue
+	projection string
+}
+
+// escapedKeyChars matches characters NATS KV keys don't allow, plus the
+// "=" used to escape them.
+var escapedKeyChars = regexp.MustCompile(`[^-/_.a-zA-Z0-9]`)
+
+// viewKey encodes an arbitrary key into a valid KV key, replacing each
+// escaped byte with =XX so viewKeyDecode can restore it.
+func viewKey(key string) string {
+	return escapedKeyChars.ReplaceAllStringFunc(key, func(s string) string {
+		var b strings.Builder
+		for i := 0; i < len(s); i++ {
+			fmt.Fprintf(&b, "=%02X", s[i])
+		}
+		return b.String()
+	})
+}
+
+// validViewKey reports whether an encoded key is a valid KV key: not
+// empty, and without leading, trailing or consecutive dots.
+func validViewKey(key string) bool {
+	return key != "" && key[0] != '.' && key[len(key)-1] != '.' && !strings.Contains(key, "..")
+}
+
+// key encodes key, or logs and returns false if it isn't usable.
+func (v *View) key(key string) (string, bool) {
+	k := viewKey(key)
+	if !validViewKey(k) {
+		log.Printf("[Projection:%s] Skipping invalid view key %q", v.projection, key)
+		return "", false
+	}
+	return k, true
+}
+
+// viewKeyDecode reverses viewKey.
+func viewKeyDecode(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		if key[i] == '=' && i+2 < len(key) {
+			if n, err := strconv.ParseUint(key[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(key[i])
+	}
+	return b.String()
+}
+
+// Get returns the value stored at key, or nil if it doesn't exist.
+func (v *View) Get(key string) ([]byte, error) {
+	k, ok := v.key(key)
+	if !ok {
+		return nil, nil
+	}
+	entry, err := v.kv.Get(k)
+	if err != nil {
+		if err == nats.ErrKeyNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get view key %s: %w", key, err)
+	}
+	return entry.Value(), nil
+}
+
+// Put stores a value at key.
+func (v *View) Put(key string, data []byte) error {
+	k, ok := v.key(key)
+	if !ok {
+		return nil
+	}
+	if _, err := v.kv.Put(k, data); err != nil {
+		return fmt.Errorf("failed to put view key %s: %w", key, err)
+	}
+	return nil
+}
+
+// Delete removes key from the view. Deleting a missing key is not an error.
+func (v *View) Delete(key string) error {
+	k, ok := v.key(key)
+	if !ok {
+		return nil
+	}
+	if err := v.kv.Delete(k); err != nil && err != nats.ErrKeyNotFound {
+		return fmt.Errorf("failed to delete view key %s: %w", key, err)
+	}
+	return nil
+}
+
+// Keys returns all keys in the view.
+func (v *View) Keys() ([]string, error) {
+	keys, err := v.kv.Keys()
+	if err != nil {
+		if err == nats.ErrNoKeysFound {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("failed to list view keys: %w", err)
+	}
+	for i, k := range keys {
+		keys[i] = viewKeyDecode(k)
+	}
+	return keys, nil
+}
+
+// Incr adds delta to the counter at key and returns the new value.
+// Missing counters start at zero.
+func (v *View) Incr(key string, delta float64) (float64, error) {
+	var result float64
+	err := v.modify(key, func(current []byte) ([]byte, error) {
+		value := 0.0
+		if current != nil {
+			parsed, err := strconv.ParseFloat(string(current), 64)
+			if err != nil {
+				return nil, fmt.Errorf("view key %s is not a counter: %w", key, err)
+			}
+			value = parsed
+		}
+		result = value + delta
+		return []byte(strconv.FormatFloat(result, 'f', -1, 64)), nil
+	})
+	return result, err
+}
+
+// Members returns the members of the set at key, sorted.
+func (v *View) Members(key string) ([]string, error) {
+	data, err := v.Get(key)
+	if err != nil || data == nil {
+		return []string{}, err
+	}
+	var members []string
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, fmt.Errorf("view key %s is not a set: %w", key, err)
+	}
+	return members, nil
+}
+
+// Add adds member to the set at key.
+func (v *View) Add(key, member string) error {
+	return v.modifySet(key, func(set map[string]bool) { set[member] = true })
+}
+
+// Remove removes member from the set at key.
+func (v *View) Remove(key, member string) error {
+	return v.modifySet(key, func(set map[string]bool) { delete(set, member) })
+}
+
+// modifySet applies fn to the set stored at key, stored as a sorted JSON array.
+func (v *View) modifySet(key string, fn func(set map[string]bool)) error {
+	return v.modify(key, func(current []byte) ([]byte, error) {
+		set := make(map[string]bool)
+		if current != nil {
+			var members []string
+			if err := json.Unmarshal(current, &members); err != nil {
+				return nil, fmt.Errorf("view key %s is not a set: %w", key, err)
+			}
+			for _, m := range members {
+				set[m] = true
+			}
+		}
+		fn(set)
+
+		members := make([]string, 0, len(set))
+		for m := range set {
+			members = append(members, m)
+		}
+		sort.Strings(members)
+		return json.Marshal(members)
+	})
+}
+
+// modify performs a read-modify-write on key with optimistic locking,
+// retrying if the key changed concurrently.
+func (v *View) modify(key string, fn func(current []byte) ([]byte, error)) error {
+	k, ok := v.key(key)
+	if !ok {
+		return nil
+	}
+
+	for attempt := 0; attempt < 10; attempt++ {
+		var current []byte
+		var revision uint64
+
+		entry, err := v.kv.Get(k)
+		if err == nil {
+			current, revision = entry.Value(), entry.Revision()
+		} else if err != nats.ErrKeyNotFound {
+			return fmt.Errorf("failed to get view key %s: %w", key, err)
+		}
+
+		updated, err := fn(current)
+		if err != nil {
+			return err
+		}
+
+		if revision == 0 {
+			_, err = v.kv.Create(k, updated)
+		} else {
+			_, err = v.kv.Update(k, updated, revision)
+		}
+		if err == nil {
+			return nil
+		}
+		if !isRevisionConflict(err) {
+			return fmt.Errorf("failed to write view key %s: %w", key, err)
+		}
+	}
+	return fmt.Errorf("failed to write view key %s: too many concurrent modifications", key)
+}
+
+// isRevisionConflict reports whether a KV write failed because the key
+// changed since it was read.
+func isRevisionConflict(err error) bool {
+	var apiErr *nats.APIError
+	return errors.Is(err, nats.ErrKeyExists) ||
+		(errors.As(err, &apiErr) && apiErr.ErrorCode == nats.JSErrCodeStreamWrongLastSequence)
+}
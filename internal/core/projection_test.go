@@ -0,0 +1,293 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestProjectionBucket(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"open-tasks", "PROJECTION_OPEN_TASKS"},
+		{"by_customer", "PROJECTION_BY_CUSTOMER"},
+		{"tasks.v2", "PROJECTION_TASKS_V2"},
+	}
+	for _, tt := range tests {
+		if got := ProjectionBucket(tt.name); got != tt.want {
+			t.Errorf("ProjectionBucket(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestProjection_View(t *testing.T) {
+	js, nc := setupTestJetStream(t)
+	defer nc.Close()
+
+	js.DeleteStream("HUMUS")
+	resetProjection(js, "view-test")
+	humus, _ := NewHumus(js)
+
+	proj, err := NewProjection(humus, "view-test", CompostFilter{}, "", func(Compost, *View) error { return nil })
+	if err != nil {
+		t.Fatalf("Failed to create projection: %v", err)
+	}
+	view := proj.View()
+
+	// Counters
+	view.Incr("count:open", 1)
+	if got, _ := view.Incr("count:open", 2); got != 3 {
+		t.Errorf("Expected counter 3, got %v", got)
+	}
+
+	// Sets
+	view.Add("customer:42", "task-b")
+	view.Add("customer:42", "task-a")
+	view.Add("customer:42", "task-a")
+	view.Remove("customer:42", "task-b")
+	members, _ := view.Members("customer:42")
+	if !reflect.DeepEqual(members, []string{"task-a"}) {
+		t.Errorf("Expected [task-a], got %v", members)
+	}
+
+	// Plain values, with keys that aren't valid KV keys
+	view.Put("task:1", []byte(`{"status":"open"}`))
+	data, _ := view.Get("task:1")
+	if string(data) != `{"status":"open"}` {
+		t.Errorf("Unexpected value: %s", data)
+	}
+	view.Delete("task:1")
+	if data, _ := view.Get("task:1"); data != nil {
+		t.Errorf("Expected deleted key, got %s", data)
+	}
+
+	// Keys that differ only in escaped characters stay distinct and round-trip
+	view.Put("a:b", []byte("1"))
+	view.Put("a_b", []byte("2"))
+	view.Put("a=3Ab", []byte("3"))
+	if data, _ := view.Get("a:b"); string(data) != "1" {
+		t.Errorf("Expected a:b to keep its own value, got %s", data)
+	}
+	// Keys that can't be stored are skipped rather than failing the write
+	for _, key := range []string{"", ".hidden", "trailing.", "a..b"} {
+		if err := view.Put(key, []byte("x")); err != nil {
+			t.Errorf("Put(%q): expected the key to be skipped, got %v", key, err)
+		}
+		if _, err := view.Incr(key, 1); err != nil {
+			t.Errorf("Incr(%q): expected the key to be skipped, got %v", key, err)
+		}
+		if data, err := view.Get(key); data != nil || err != nil {
+			t.Errorf("Get(%q): expected nothing, got %s, %v", key, data, err)
+		}
+	}
+
+	keys, _ := view.Keys()
+	sort.Strings(keys)
+	want := []string{"a:b", "a=3Ab", "a_b", "count:open", "customer:42"}
+	sort.Strings(want)
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("Expected keys %v, got %v", want, keys)
+	}
+}
+
+func TestViewKey(t *testing.T) {
+	for _, key := range []string{"plain/key.1", "a:b", "a_b", "a=b", "ünï code", "=", "=4"} {
+		if got := viewKeyDecode(viewKey(key)); got != key {
+			t.Errorf("viewKey(%q) round-tripped to %q", key, got)
+		}
+	}
+	if got := viewKey("count:open"); got != "count=3Aopen" {
+		t.Errorf("Unexpected encoding %q", got)
+	}
+}
+
+func TestProjection_ConsumesAndRebuilds(t *testing.T) {
+	js, nc := setupTestJetStream(t)
+	defer nc.Close()
+
+	js.DeleteStream("HUMUS")
+	resetProjection(js, "task-count")
+	humus, _ := NewHumus(js)
+
+	var calls atomic.Int32
+	proj, err := NewProjection(humus, "task-count", CompostFilter{EntityType: "tasks"}, "", func(c Compost, v *View) error {
+		calls.Add(1)
+		_, err := v.Incr(c.Action, 1)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to create projection: %v", err)
+	}
+	defer func() { js.DeleteConsumer("HUMUS", proj.Status().Consumer) }()
+
+	humus.Add("nim", "tasks/1", "create", []byte(`{}`))
+	humus.Add("nim", "tasks/2", "create", []byte(`{}`))
+	humus.Add("nim", "tasks/1", "update", []byte(`{}`))
+	humus.Add("nim", "contacts/1", "create", []byte(`{}`)) // Filtered out
+
+	if err := proj.Start(); err != nil {
+		t.Fatalf("Failed to start projection: %v", err)
+	}
+	defer proj.Stop()
+
+	waitFor(t, func() bool { return proj.Status().Processed == 3 })
+
+	if data, _ := proj.View().Get("create"); string(data) != "2" {
+		t.Errorf("Expected 2 creates, got %s", data)
+	}
+
+	if err := proj.Rebuild(); err != nil {
+		t.Fatalf("Failed to rebuild: %v", err)
+	}
+	waitFor(t, func() bool { return proj.Status().Processed == 3 })
+
+	// The view is rebuilt from scratch, not added to
+	if data, _ := proj.View().Get("create"); string(data) != "2" {
+		t.Errorf("Expected 2 creates after rebuild, got %s", data)
+	}
+	if got := calls.Load(); got != 6 {
+		t.Errorf("Expected 6 projection calls, got %d", got)
+	}
+}
+
+func TestProjection_RebuildWhileApplying(t *testing.T) {
+	js, nc := setupTestJetStream(t)
+	defer nc.Close()
+
+	js.DeleteStream("HUMUS")
+	resetProjection(js, "slow-count")
+	humus, _ := NewHumus(js)
+
+	applying := make(chan struct{}, 1)
+	proj, err := NewProjection(humus, "slow-count", CompostFilter{EntityType: "tasks"}, "", func(c Compost, v *View) error {
+		select {
+		case applying <- struct{}{}:
+		default:
+		}
+		time.Sleep(100 * time.Millisecond) // Keep a compost in flight
+		_, err := v.Incr("total", 1)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to create projection: %v", err)
+	}
+	defer func() { js.DeleteConsumer("HUMUS", proj.Status().Consumer) }()
+
+	for i := 0; i < 3; i++ {
+		humus.Add("nim", fmt.Sprintf("tasks/%d", i), "create", []byte(`{}`))
+	}
+	if err := proj.Start(); err != nil {
+		t.Fatalf("Failed to start projection: %v", err)
+	}
+	defer proj.Stop()
+
+	// Rebuild while the first compost is being applied
+	<-applying
+	if err := proj.Rebuild(); err != nil {
+		t.Fatalf("Failed to rebuild: %v", err)
+	}
+	waitFor(t, func() bool { return proj.Status().Processed == 3 })
+	time.Sleep(200 * time.Millisecond) // A stale compost would land now
+
+	if data, _ := proj.View().Get("total"); string(data) != "3" {
+		t.Errorf("Expected each compost applied once, got total %s", data)
+	}
+	if got := proj.Status().Processed; got != 3 {
+		t.Errorf("Expected 3 processed, got %d", got)
+	}
+}
+
+func TestProjection_RebuildAcrossLands(t *testing.T) {
+	js, nc := setupTestJetStream(t)
+	defer nc.Close()
+
+	js.DeleteStream("HUMUS")
+	resetProjection(js, "shared-count")
+	humus, _ := NewHumus(js)
+
+	count := func(c Compost, v *View) error {
+		_, err := v.Incr("total", 1)
+		return err
+	}
+	// The same projection running on two lands
+	lands := make([]*Projection, 2)
+	for i := range lands {
+		proj, err := NewProjection(humus, "shared-count", CompostFilter{EntityType: "tasks"}, "", count)
+		if err != nil {
+			t.Fatalf("Failed to create projection: %v", err)
+		}
+		if err := proj.Start(); err != nil {
+			t.Fatalf("Failed to start projection: %v", err)
+		}
+		defer proj.Stop()
+		lands[i] = proj
+	}
+	defer func() { js.DeleteConsumer("HUMUS", lands[0].Status().Consumer) }()
+
+	for i := 0; i < 4; i++ {
+		humus.Add("nim", fmt.Sprintf("tasks/%d", i), "create", []byte(`{}`))
+	}
+	total := func(proj *Projection) string {
+		data, _ := proj.View().Get("total")
+		return string(data)
+	}
+	waitFor(t, func() bool { return total(lands[0]) == "4" })
+
+	// The other land follows the rebuild to the new generation
+	if err := lands[0].Rebuild(); err != nil {
+		t.Fatalf("Failed to rebuild: %v", err)
+	}
+	waitFor(t, func() bool { return lands[1].Status().Generation == 1 })
+	for i := 4; i < 6; i++ {
+		humus.Add("nim", fmt.Sprintf("tasks/%d", i), "create", []byte(`{}`))
+	}
+	waitFor(t, func() bool { return total(lands[0]) == "6" })
+	time.Sleep(200 * time.Millisecond) // A compost applied twice would land now
+
+	for i, proj := range lands {
+		status := proj.Status()
+		if status.Bucket != "PROJECTION_SHARED_COUNT_G1" || status.Consumer != "projection-shared_count-g1" {
+			t.Errorf("Land %d: expected generation 1 names, got %s and %s", i, status.Bucket, status.Consumer)
+		}
+		if got := total(proj); got != "6" {
+			t.Errorf("Land %d: expected total 6, got %s", i, got)
+		}
+	}
+	if _, err := js.KeyValue("PROJECTION_SHARED_COUNT"); err == nil {
+		t.Error("Expected the old generation's view to be deleted")
+	}
+	if _, err := js.ConsumerInfo("HUMUS", "projection-shared_count"); err != nats.ErrConsumerNotFound {
+		t.Errorf("Expected the old generation's consumer to be deleted, got %v", err)
+	}
+}
+
+// resetProjection deletes a projection's views and generation.
+func resetProjection(js nats.JetStreamContext, name string) {
+	bucket := ProjectionBucket(name)
+	js.DeleteKeyValue(bucket)
+	for generation := 1; generation <= 3; generation++ {
+		js.DeleteKeyValue(fmt.Sprintf("%s_G%d", bucket, generation))
+	}
+	if kv, err := js.KeyValue(projectionGenerationsBucket); err == nil {
+		kv.Purge(invalidBucketChars.ReplaceAllString(name, "_"))
+	}
+}
+
+// waitFor polls cond until it holds or the test times out.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for condition")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -97,6 +98,12 @@ func (r *River) Flow(subject string, data []byte) error {
 // The pattern can include wildcards (* and >).
 // The handler is called for each matching message.
 func (r *River) Observe(pattern string, handler func(data RiverData)) error {
+	_, err := r.observe(pattern, handler)
+	return err
+}
+
+// observe is Observe returning the subscription, so trees can stop watching.
+func (r *River) observe(pattern string, handler func(data RiverData)) (*nats.Subscription, error) {
 	if pattern == "" {
 		pattern = "river.>"
 	}
@@ -118,7 +125,7 @@ func (r *River) Observe(pattern string, handler func(data RiverData)) error {
 	}
 
 	// Subscribe to the stream
-	_, err := r.js.Subscribe(pattern, func(msg *nats.Msg) {
+	sub, err := r.js.Subscribe(pattern, func(msg *nats.Msg) {
 		// Deserialize the river data
 		var data RiverData
 		if err := json.Unmarshal(msg.Data, &data); err != nil {
@@ -135,11 +142,11 @@ func (r *River) Observe(pattern string, handler func(data RiverData)) error {
 	}, nats.Durable(consumerConfig.Durable), nats.ManualAck())
 
 	if err != nil {
-		return fmt.Errorf("failed to observe river pattern %s: %w", pattern, err)
+		return nil, fmt.Errorf("failed to observe river pattern %s: %w", pattern, err)
 	}
 
 	log.Printf("[River] Observing pattern: %s", pattern)
-	return nil
+	return sub, nil
 }
 
 // ObserveWithConsumer is like Observe but allows specifying a custom consumer name.
@@ -178,6 +185,135 @@ func (r *River) ObserveWithConsumer(pattern, consumerName string, handler func(d
 	return nil
 }
 
+// ObserveAsync is like ObserveWithConsumer, but the handler may go on
+// processing after it returns: it calls ack once done, and only then is the
+// data acknowledged, or redelivered if it was not processed. Observers using
+// the same consumer name share the data as a queue group, so the same
+// component in several forests processes each message once.
+func (r *River) ObserveAsync(pattern, consumerName string, handler func(data RiverData, ack func(processed bool))) (*nats.Subscription, error) {
+	if pattern == "" {
+		pattern = "river.>"
+	}
+	if consumerName == "" {
+		return nil, fmt.Errorf("consumer name cannot be empty")
+	}
+
+	// Ensure pattern starts with "river."
+	if len(pattern) < 6 || pattern[:6] != "river." {
+		pattern = "river." + pattern
+	}
+	consumerName = subjectToken(consumerName)
+
+	// Create the consumer ourselves and bind to it: the library deletes
+	// consumers it created on Unsubscribe, even while others are bound
+	if err := r.ensureConsumer(consumerName, pattern); err != nil {
+		return nil, err
+	}
+
+	sub, err := r.js.QueueSubscribe(pattern, consumerName, func(msg *nats.Msg) {
+		var data RiverData
+		if err := json.Unmarshal(msg.Data, &data); err != nil {
+			log.Printf("[River] Failed to unmarshal data from subject %s: %v", msg.Subject, err)
+			msg.Nak()
+			return
+		}
+
+		handler(data, func(processed bool) {
+			if processed {
+				msg.Ack()
+			} else {
+				msg.Nak()
+			}
+		})
+	}, nats.Bind(r.stream, consumerName), nats.ManualAck())
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to observe with consumer %s: %w", consumerName, err)
+	}
+
+	log.Printf("[River] Observing pattern %s with consumer %s", pattern, consumerName)
+	return sub, nil
+}
+
+// ensureConsumer creates the durable push consumer ObserveAsync binds to,
+// or points an existing one at pattern. A consumer left behind by an
+// observer of another pattern, e.g. a tree before its watches were
+// reloaded, would otherwise keep delivering the old subjects.
+func (r *River) ensureConsumer(consumerName, pattern string) error {
+	info, err := r.js.ConsumerInfo(r.stream, consumerName)
+	if err == nil {
+		if info.Config.FilterSubject == pattern {
+			return nil
+		}
+		cfg := info.Config
+		cfg.FilterSubject = pattern
+		if _, err := r.js.UpdateConsumer(r.stream, &cfg); err != nil {
+			return fmt.Errorf("failed to update consumer %s: %w", consumerName, err)
+		}
+		log.Printf("[River] Updated consumer %s from %s to %s", consumerName, info.Config.FilterSubject, pattern)
+		return nil
+	}
+	if !errors.Is(err, nats.ErrConsumerNotFound) {
+		return fmt.Errorf("failed to get consumer %s: %w", consumerName, err)
+	}
+
+	_, err = r.js.AddConsumer(r.stream, &nats.ConsumerConfig{
+		Durable:        consumerName,
+		DeliverSubject: nats.NewInbox(),
+		DeliverGroup:   consumerName,
+		FilterSubject:  pattern,
+		AckPolicy:      nats.AckExplicitPolicy,
+		DeliverPolicy:  nats.DeliverAllPolicy,
+	})
+	if err != nil {
+		// Another forest may have created it first
+		if _, infoErr := r.js.ConsumerInfo(r.stream, consumerName); infoErr != nil {
+			return fmt.Errorf("failed to create consumer %s: %w", consumerName, err)
+		}
+	}
+	return nil
+}
+
+// unobserveWait is how long Unobserve waits for the server to see that a
+// consumer has no other observers before leaving it in place.
+const unobserveWait = time.Second
+
+// Unobserve stops a subscription made by ObserveAsync and deletes its durable
+// consumer unless another observer is still bound to it. Unacknowledged data
+// stays in the stream for the next observer, which may watch a new pattern.
+func (r *River) Unobserve(sub *nats.Subscription) error {
+	info, err := sub.ConsumerInfo()
+	if err != nil {
+		sub.Unsubscribe()
+		return fmt.Errorf("failed to get consumer info: %w", err)
+	}
+	if err := sub.Unsubscribe(); err != nil {
+		return fmt.Errorf("failed to unsubscribe consumer %s: %w", info.Name, err)
+	}
+
+	// The server notices the lost interest shortly after the unsubscribe
+	name := info.Name
+	for deadline := time.Now().Add(unobserveWait); ; time.Sleep(20 * time.Millisecond) {
+		info, err = r.js.ConsumerInfo(r.stream, name)
+		if errors.Is(err, nats.ErrConsumerNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get consumer info: %w", err)
+		}
+		if !info.PushBound {
+			break
+		}
+		if time.Now().After(deadline) {
+			return nil // Still shared with another observer
+		}
+	}
+	if err := r.js.DeleteConsumer(r.stream, name); err != nil && !errors.Is(err, nats.ErrConsumerNotFound) {
+		return fmt.Errorf("failed to delete consumer %s: %w", name, err)
+	}
+	return nil
+}
+
 // StreamInfo returns information about the river stream.
 func (r *River) StreamInfo() (*nats.StreamInfo, error) {
 	info, err := r.js.StreamInfo(r.stream)
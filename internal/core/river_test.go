@@ -1,8 +1,11 @@
 package core
 
 import (
+	"fmt"
 	"testing"
 	"time"
+
+	"github.com/nats-io/nats.go"
 )
 
 func TestNewRiver(t *testing.T) {
@@ -213,6 +216,185 @@ func TestRiver_ObserveWithConsumer(t *testing.T) {
 	}
 }
 
+func TestRiver_ObserveAsync(t *testing.T) {
+	js, nc := setupTestJetStream(t)
+	defer nc.Close()
+
+	js.DeleteStream("RIVER")
+	river, err := NewRiver(js)
+	if err != nil {
+		t.Fatalf("Failed to create river: %v", err)
+	}
+
+	type delivery struct {
+		data RiverData
+		ack  func(processed bool)
+	}
+	observed := make(chan delivery, 10)
+	handler := func(data RiverData, ack func(processed bool)) {
+		observed <- delivery{data, ack}
+	}
+
+	// Two observers with the same consumer name share the data
+	for i := 0; i < 2; i++ {
+		sub, err := river.ObserveAsync("async.>", "async-test", handler)
+		if err != nil {
+			t.Fatalf("Failed to observe: %v", err)
+		}
+		defer sub.Unsubscribe()
+	}
+
+	river.Flow("async.test", []byte(`{"n": 0}`))
+	var d delivery
+	select {
+	case d = <-observed:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Timeout waiting for observation")
+	}
+
+	// Not acknowledged until the handler says it is done
+	info, err := js.ConsumerInfo("RIVER", "async-test")
+	if err != nil {
+		t.Fatalf("Failed to get consumer info: %v", err)
+	}
+	if info.NumAckPending != 1 {
+		t.Errorf("Expected 1 pending ack before ack, got %d", info.NumAckPending)
+	}
+
+	// Data that was not processed is redelivered
+	d.ack(false)
+	select {
+	case d = <-observed:
+		d.ack(true)
+	case <-time.After(3 * time.Second):
+		t.Fatal("Expected unprocessed data to be redelivered")
+	}
+
+	for i := 1; i <= 4; i++ {
+		river.Flow("async.test", []byte(fmt.Sprintf(`{"n": %d}`, i)))
+	}
+	for i := 0; i < 4; i++ {
+		select {
+		case d = <-observed:
+			d.ack(true)
+		case <-time.After(3 * time.Second):
+			t.Fatalf("Timeout waiting for observation %d", i)
+		}
+	}
+	select {
+	case d = <-observed:
+		t.Errorf("Expected each message once, got %s again", d.data.Data)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		info, _ := river.StreamInfo()
+		if info.State.Msgs == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected acknowledged data to leave the stream, %d left", info.State.Msgs)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestRiver_Unobserve(t *testing.T) {
+	js, nc := setupTestJetStream(t)
+	defer nc.Close()
+
+	js.DeleteStream("RIVER")
+	river, err := NewRiver(js)
+	if err != nil {
+		t.Fatalf("Failed to create river: %v", err)
+	}
+
+	handler := func(data RiverData, ack func(processed bool)) { ack(true) }
+	first, err := river.ObserveAsync("unobserve.a", "unobserve-test", handler)
+	if err != nil {
+		t.Fatalf("Failed to observe: %v", err)
+	}
+	second, err := river.ObserveAsync("unobserve.a", "unobserve-test", handler)
+	if err != nil {
+		t.Fatalf("Failed to observe: %v", err)
+	}
+
+	// A consumer still shared with another observer is kept
+	if err := river.Unobserve(first); err != nil {
+		t.Fatalf("Unobserve failed: %v", err)
+	}
+	if _, err := js.ConsumerInfo("RIVER", "unobserve-test"); err != nil {
+		t.Errorf("Expected the shared consumer to be kept: %v", err)
+	}
+
+	// The last observer deletes it, so the name can watch another pattern
+	if err := river.Unobserve(second); err != nil {
+		t.Fatalf("Unobserve failed: %v", err)
+	}
+	if _, err := js.ConsumerInfo("RIVER", "unobserve-test"); err == nil {
+		t.Error("Expected the consumer to be deleted")
+	}
+	sub, err := river.ObserveAsync("unobserve.b", "unobserve-test", handler)
+	if err != nil {
+		t.Fatalf("Failed to observe a new pattern: %v", err)
+	}
+	river.Unobserve(sub)
+}
+
+func TestRiver_ObserveAsyncUpdatesFilter(t *testing.T) {
+	js, nc := setupTestJetStream(t)
+	defer nc.Close()
+
+	js.DeleteStream("RIVER")
+	river, err := NewRiver(js)
+	if err != nil {
+		t.Fatalf("Failed to create river: %v", err)
+	}
+
+	// A durable left behind by an observer of the old pattern
+	_, err = js.AddConsumer("RIVER", &nats.ConsumerConfig{
+		Durable:        "refilter-test",
+		DeliverSubject: nats.NewInbox(),
+		DeliverGroup:   "refilter-test",
+		FilterSubject:  "river.refilter.old",
+		AckPolicy:      nats.AckExplicitPolicy,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create consumer: %v", err)
+	}
+	defer js.DeleteConsumer("RIVER", "refilter-test")
+
+	received := make(chan string, 2)
+	sub, err := river.ObserveAsync("refilter.new", "refilter-test", func(data RiverData, ack func(processed bool)) {
+		received <- data.Subject
+		ack(true)
+	})
+	if err != nil {
+		t.Fatalf("Failed to observe a new pattern: %v", err)
+	}
+	defer river.Unobserve(sub)
+
+	info, err := js.ConsumerInfo("RIVER", "refilter-test")
+	if err != nil {
+		t.Fatalf("Failed to get consumer info: %v", err)
+	}
+	if info.Config.FilterSubject != "river.refilter.new" {
+		t.Errorf("Expected filter river.refilter.new, got %s", info.Config.FilterSubject)
+	}
+
+	river.Flow("refilter.old", []byte(`{}`))
+	river.Flow("refilter.new", []byte(`{}`))
+	select {
+	case subject := <-received:
+		if subject != "river.refilter.new" {
+			t.Errorf("Expected data from the new pattern, got %s", subject)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for data")
+	}
+}
+
 func TestRiver_StreamInfo(t *testing.T) {
 	js, nc := setupTestJetStream(t)
 	defer nc.Close()
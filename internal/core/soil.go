@@ -3,6 +3,9 @@ package core
 import (
 	"fmt"
 	"log"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/nats-io/nats.go"
 )
@@ -11,12 +14,69 @@ import (
 // It provides optimistic locking for concurrent updates.
 type Soil struct {
 	kv nats.KeyValue
+
+	indexMu sync.RWMutex
+	indexes map[string]*soilIndex
+}
+
+// SoilConfig configures a soil KV bucket.
+type SoilConfig struct {
+	// Bucket is the KV bucket name. Default: "SOIL"
+	Bucket string
+
+	// Description is stored with the bucket.
+	Description string
+
+	// History is the number of revisions kept per entity (max 64).
+	// Default: 10
+	History uint8
+
+	// TTL expires entities this long after their last write. Zero keeps them forever.
+	TTL time.Duration
+
+	// Storage is file or memory storage. Default: nats.FileStorage
+	Storage nats.StorageType
+
+	// Replicas is the number of bucket replicas in a cluster. Default: 1
+	Replicas int
+}
+
+// DefaultSoilHistory is the number of revisions kept per entity.
+const DefaultSoilHistory = 10
+
+// SoilBucket returns the KV bucket name for a named soil bucket,
+// e.g. "tasks" becomes "SOIL_TASKS".
+func SoilBucket(name string) string {
+	return "SOIL_" + strings.ToUpper(invalidBucketChars.ReplaceAllString(name, "_"))
 }
 
 // NewSoil creates a new Soil backed by a JetStream KV bucket.
 // The bucket is created if it doesn't exist, with the name "SOIL".
 func NewSoil(js nats.JetStreamContext) (*Soil, error) {
-	bucketName := "SOIL"
+	return NewSoilWithConfig(js, SoilConfig{})
+}
+
+// NewSoilWithConfig creates a Soil backed by the configured KV bucket.
+// The bucket is created if it doesn't exist. On an existing bucket only
+// the history, TTL and replicas set in cfg are updated; zero values keep
+// what the bucket already has.
+func NewSoilWithConfig(js nats.JetStreamContext, cfg SoilConfig) (*Soil, error) {
+	bucketName := cfg.Bucket
+	if bucketName == "" {
+		bucketName = "SOIL"
+	}
+	history := cfg.History
+	if history == 0 {
+		history = DefaultSoilHistory
+	}
+	description := cfg.Description
+	if description == "" {
+		description = "NimsForest current state storage"
+	}
+	replicas := cfg.Replicas
+	if replicas <= 0 {
+		replicas = 1
+	}
 
 	// Try to get existing bucket
 	kv, err := js.KeyValue(bucketName)
@@ -24,24 +84,68 @@ func NewSoil(js nats.JetStreamContext) (*Soil, error) {
 		// Bucket doesn't exist, create it
 		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
 			Bucket:      bucketName,
-			Description: "NimsForest current state storage",
-			History:     10, // Keep last 10 revisions
-			TTL:         0,  // No TTL - data persists
-			Storage:     nats.FileStorage,
+			Description: description,
+			History:     history,
+			TTL:         cfg.TTL,
+			Storage:     cfg.Storage,
+			Replicas:    replicas,
 		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to create KV bucket %s: %w", bucketName, err)
 		}
-		log.Printf("[Soil] Created KV bucket: %s", bucketName)
+		log.Printf("[Soil] Created KV bucket: %s (history: %d, ttl: %v)", bucketName, history, cfg.TTL)
 	} else {
+		updateSoilBucket(js, bucketName, cfg)
 		log.Printf("[Soil] Using existing KV bucket: %s", bucketName)
 	}
 
 	return &Soil{
-		kv: kv,
+		kv:      kv,
+		indexes: make(map[string]*soilIndex),
 	}, nil
 }
 
+// updateSoilBucket applies the history, TTL and replicas set in cfg to an
+// existing bucket. The storage type of a bucket can't be changed.
+func updateSoilBucket(js nats.JetStreamContext, bucket string, cfg SoilConfig) {
+	info, err := js.StreamInfo("KV_" + bucket)
+	if err != nil {
+		log.Printf("[Soil] Warning: failed to read bucket %s config: %v", bucket, err)
+		return
+	}
+
+	streamCfg := info.Config
+	if cfg.Storage == nats.MemoryStorage && streamCfg.Storage != cfg.Storage {
+		log.Printf("[Soil] Warning: bucket %s uses %s storage, ignoring configured %s",
+			bucket, streamCfg.Storage, cfg.Storage)
+	}
+
+	history, ttl, replicas := streamCfg.MaxMsgsPerSubject, streamCfg.MaxAge, streamCfg.Replicas
+	if cfg.History > 0 {
+		history = int64(cfg.History)
+	}
+	if cfg.TTL > 0 {
+		ttl = cfg.TTL
+	}
+	if cfg.Replicas > 0 {
+		replicas = cfg.Replicas
+	}
+	if streamCfg.MaxMsgsPerSubject == history && streamCfg.MaxAge == ttl && streamCfg.Replicas == replicas {
+		return
+	}
+
+	log.Printf("[Soil] Updating bucket %s (history: %d, ttl: %v, replicas: %d)", bucket, history, ttl, replicas)
+	streamCfg.MaxMsgsPerSubject, streamCfg.MaxAge, streamCfg.Replicas = history, ttl, replicas
+	if _, err := js.UpdateStream(&streamCfg); err != nil {
+		log.Printf("[Soil] Warning: failed to update bucket %s: %v", bucket, err)
+	}
+}
+
+// Bucket returns the name of the KV bucket backing this soil.
+func (s *Soil) Bucket() string {
+	return s.kv.Bucket()
+}
+
 // Dig reads the current state of an entity from soil.
 // Returns the data, the current revision number, and any error.
 // The revision number is needed for optimistic locking with Bury.
@@ -53,7 +157,7 @@ func (s *Soil) Dig(entity string) ([]byte, uint64, error) {
 	entry, err := s.kv.Get(entity)
 	if err != nil {
 		if err == nats.ErrKeyNotFound {
-			return nil, 0, fmt.Errorf("entity not found: %s", entity)
+			return nil, 0, fmt.Errorf("%w: %s", ErrEntityNotFound, entity)
 		}
 		return nil, 0, fmt.Errorf("failed to dig entity %s: %w", entity, err)
 	}
@@ -75,22 +179,23 @@ func (s *Soil) Bury(entity string, data []byte, expectedRevision uint64) error {
 		return fmt.Errorf("data cannot be empty")
 	}
 
+	var revision uint64
 	var err error
 
 	if expectedRevision == 0 {
 		// Create - entity should not exist
-		_, err = s.kv.Create(entity, data)
+		revision, err = s.kv.Create(entity, data)
 		if err != nil {
 			// Check if it's because key already exists
 			if err == nats.ErrKeyExists {
-				return fmt.Errorf("entity already exists: %s (use non-zero revision for updates)", entity)
+				return fmt.Errorf("entity already exists: %s (use non-zero revision for updates): %w", entity, err)
 			}
 			return fmt.Errorf("failed to create entity %s: %w", entity, err)
 		}
 		log.Printf("[Soil] Buried new entity: %s (size: %d bytes)", entity, len(data))
 	} else {
 		// Update - check revision matches
-		_, err = s.kv.Update(entity, data, expectedRevision)
+		revision, err = s.kv.Update(entity, data, expectedRevision)
 		if err != nil {
 			// Check for revision mismatch
 			if err == nats.ErrKeyExists {
@@ -102,6 +207,7 @@ func (s *Soil) Bury(entity string, data []byte, expectedRevision uint64) error {
 			entity, expectedRevision, len(data))
 	}
 
+	s.indexWrite(entity, data, revision)
 	return nil
 }
 
@@ -120,22 +226,23 @@ func (s *Soil) Put(entity string, data []byte) (uint64, error) {
 		return 0, fmt.Errorf("failed to put entity %s: %w", entity, err)
 	}
 
+	s.indexWrite(entity, data, revision)
 	log.Printf("[Soil] Put entity: %s (revision: %d)", entity, revision)
 	return revision, nil
 }
 
-// Delete removes an entity from soil.
-// Note: NATS KV delete is idempotent and doesn't return an error if the key doesn't exist.
+// Delete removes an entity from soil. It returns ErrEntityNotFound if the
+// entity doesn't exist; NATS KV delete itself would succeed.
 func (s *Soil) Delete(entity string) error {
 	if entity == "" {
 		return fmt.Errorf("entity key cannot be empty")
 	}
 
 	// Check if entity exists first
-	_, err := s.kv.Get(entity)
+	entry, err := s.kv.Get(entity)
 	if err != nil {
 		if err == nats.ErrKeyNotFound {
-			return fmt.Errorf("entity not found: %s", entity)
+			return fmt.Errorf("%w: %s", ErrEntityNotFound, entity)
 		}
 		return fmt.Errorf("failed to check entity %s: %w", entity, err)
 	}
@@ -145,50 +252,30 @@ func (s *Soil) Delete(entity string) error {
 		return fmt.Errorf("failed to delete entity %s: %w", entity, err)
 	}
 
+	s.indexDelete(entity, entry.Revision())
 	log.Printf("[Soil] Deleted entity: %s", entity)
 	return nil
 }
 
-// Watch observes changes to entities matching a pattern.
-// The pattern can include wildcards (> for multi-level).
-// The handler is called for each change.
-func (s *Soil) Watch(pattern string, handler func(entity string, data []byte, revision uint64)) error {
-	if pattern == "" {
-		pattern = ">"
-	}
-
-	// Create a watcher
-	watcher, err := s.kv.Watch(pattern)
-	if err != nil {
-		return fmt.Errorf("failed to watch pattern %s: %w", pattern, err)
+// DeleteExpected removes an entity with optimistic locking, like Bury:
+// the delete fails if the entity changed since expectedRevision.
+func (s *Soil) DeleteExpected(entity string, expectedRevision uint64) error {
+	if entity == "" {
+		return fmt.Errorf("entity key cannot be empty")
 	}
 
-	// Start watching in a goroutine
-	go func() {
-		for entry := range watcher.Updates() {
-			if entry == nil {
-				continue
-			}
-
-			// Skip deleted entries
-			if entry.Operation() == nats.KeyValueDelete || entry.Operation() == nats.KeyValuePurge {
-				log.Printf("[Soil] Watched entity deleted: %s", entry.Key())
-				continue
-			}
-
-			handler(entry.Key(), entry.Value(), entry.Revision())
+	if err := s.kv.Delete(entity, nats.LastRevision(expectedRevision)); err != nil {
+		if isRevisionConflict(err) {
+			return fmt.Errorf("entity %s was modified (revision mismatch): %w", entity, err)
 		}
-	}()
+		return fmt.Errorf("failed to delete entity %s: %w", entity, err)
+	}
 
-	log.Printf("[Soil] Watching pattern: %s", pattern)
+	s.indexDelete(entity, expectedRevision)
+	log.Printf("[Soil] Deleted entity: %s (revision: %d)", entity, expectedRevision)
 	return nil
 }
 
-// WatchAll watches all changes to the bucket.
-func (s *Soil) WatchAll(handler func(entity string, data []byte, revision uint64)) error {
-	return s.Watch(">", handler)
-}
-
 // Keys returns all keys in the bucket.
 func (s *Soil) Keys() ([]string, error) {
 	keys, err := s.kv.Keys()
@@ -0,0 +1,197 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// SoilRevision is one revision of an entity from the bucket history.
+type SoilRevision struct {
+	Entity   string          `json:"entity"`
+	Revision uint64          `json:"revision"`
+	Op       SoilOp          `json:"op"`
+	Data     json.RawMessage `json:"data,omitempty"` // Omitted for deletes and purges
+	Created  time.Time       `json:"created"`
+}
+
+// JSONChange is one difference between two JSON documents. Path is the
+// dotted field path ("" for the whole document), with list indexes as
+// numbers, e.g. "items.0.qty".
+type JSONChange struct {
+	Path string      `json:"path"`
+	Op   string      `json:"op"` // added, removed or changed
+	From interface{} `json:"from,omitempty"`
+	To   interface{} `json:"to,omitempty"`
+}
+
+// KeysWithPrefix returns the sorted keys starting with prefix.
+// An empty prefix returns every key.
+func (s *Soil) KeysWithPrefix(prefix string) ([]string, error) {
+	keys, err := s.kv.Keys()
+	if err != nil {
+		if err == nats.ErrNoKeysFound {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("failed to get keys: %w", err)
+	}
+
+	matched := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			matched = append(matched, key)
+		}
+	}
+	sort.Strings(matched)
+	return matched, nil
+}
+
+// History returns the revisions of an entity kept by the bucket, oldest
+// first, including deletes. The bucket's History setting limits how many
+// are kept. It returns ErrEntityNotFound if the entity has no history.
+func (s *Soil) History(entity string) ([]SoilRevision, error) {
+	if entity == "" {
+		return nil, fmt.Errorf("entity key cannot be empty")
+	}
+
+	entries, err := s.kv.History(entity)
+	if err != nil {
+		if err == nats.ErrKeyNotFound {
+			return nil, fmt.Errorf("%w: %s", ErrEntityNotFound, entity)
+		}
+		return nil, fmt.Errorf("failed to get history of %s: %w", entity, err)
+	}
+
+	revisions := make([]SoilRevision, 0, len(entries))
+	for _, entry := range entries {
+		rev := SoilRevision{
+			Entity:   entry.Key(),
+			Revision: entry.Revision(),
+			Op:       SoilPut,
+			Created:  entry.Created(),
+		}
+		switch entry.Operation() {
+		case nats.KeyValueDelete:
+			rev.Op = SoilDelete
+		case nats.KeyValuePurge:
+			rev.Op = SoilPurge
+		default:
+			rev.Data = jsonOrString(entry.Value())
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, nil
+}
+
+// Diff compares two revisions of an entity from its history. A zero to
+// compares against the latest revision. Deleted revisions compare as null.
+func (s *Soil) Diff(entity string, from, to uint64) ([]JSONChange, error) {
+	history, err := s.History(entity)
+	if err != nil {
+		return nil, err
+	}
+	if to == 0 {
+		to = history[len(history)-1].Revision
+	}
+
+	find := func(revision uint64) (json.RawMessage, error) {
+		for _, rev := range history {
+			if rev.Revision == revision {
+				if rev.Data == nil {
+					return json.RawMessage("null"), nil
+				}
+				return rev.Data, nil
+			}
+		}
+		return nil, fmt.Errorf("revision %d of %s is not in the bucket history", revision, entity)
+	}
+
+	before, err := find(from)
+	if err != nil {
+		return nil, err
+	}
+	after, err := find(to)
+	if err != nil {
+		return nil, err
+	}
+	return DiffJSON(before, after)
+}
+
+// DiffJSON returns the field-level differences between two JSON documents,
+// sorted by path.
+func DiffJSON(before, after []byte) ([]JSONChange, error) {
+	var a, b interface{}
+	if err := json.Unmarshal(before, &a); err != nil {
+		return nil, fmt.Errorf("invalid JSON in first document: %w", err)
+	}
+	if err := json.Unmarshal(after, &b); err != nil {
+		return nil, fmt.Errorf("invalid JSON in second document: %w", err)
+	}
+
+	changes := []JSONChange{}
+	diffValues("", a, b, &changes)
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+func diffValues(path string, a, b interface{}, changes *[]JSONChange) {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		if bv, ok := b.(map[string]interface{}); ok {
+			for k, v := range av {
+				if w, ok := bv[k]; ok {
+					diffValues(joinPath(path, k), v, w, changes)
+				} else {
+					*changes = append(*changes, JSONChange{Path: joinPath(path, k), Op: "removed", From: v})
+				}
+			}
+			for k, w := range bv {
+				if _, ok := av[k]; !ok {
+					*changes = append(*changes, JSONChange{Path: joinPath(path, k), Op: "added", To: w})
+				}
+			}
+			return
+		}
+	case []interface{}:
+		if bv, ok := b.([]interface{}); ok {
+			for i := 0; i < len(av) || i < len(bv); i++ {
+				p := joinPath(path, fmt.Sprint(i))
+				switch {
+				case i >= len(bv):
+					*changes = append(*changes, JSONChange{Path: p, Op: "removed", From: av[i]})
+				case i >= len(av):
+					*changes = append(*changes, JSONChange{Path: p, Op: "added", To: bv[i]})
+				default:
+					diffValues(p, av[i], bv[i], changes)
+				}
+			}
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		*changes = append(*changes, JSONChange{Path: path, Op: "changed", From: a, To: b})
+	}
+}
+
+func joinPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+// jsonOrString returns JSON values as-is and other values as a JSON string.
+func jsonOrString(data []byte) json.RawMessage {
+	if json.Valid(data) {
+		return bytes.Clone(data)
+	}
+	quoted, _ := json.Marshal(string(data))
+	return quoted
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDiffJSON(t *testing.T) {
+	changes, err := DiffJSON(
+		[]byte(`{"status":"open","tags":["a","b"],"owner":{"name":"ada"},"old":1}`),
+		[]byte(`{"status":"done","tags":["a"],"owner":{"name":"ada","team":"ops"},"new":true}`),
+	)
+	if err != nil {
+		t.Fatalf("DiffJSON failed: %v", err)
+	}
+
+	want := []JSONChange{
+		{Path: "new", Op: "added", To: true},
+		{Path: "old", Op: "removed", From: float64(1)},
+		{Path: "owner.team", Op: "added", To: "ops"},
+		{Path: "status", Op: "changed", From: "open", To: "done"},
+		{Path: "tags.1", Op: "removed", From: "b"},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("Expected %d changes, got %+v", len(want), changes)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("Change %d = %+v, want %+v", i, changes[i], want[i])
+		}
+	}
+
+	if changes, _ := DiffJSON([]byte(`{"a":1}`), []byte(`{"a":1}`)); len(changes) != 0 {
+		t.Errorf("Expected no changes, got %+v", changes)
+	}
+}
+
+func TestSoil_HistoryAndDiff(t *testing.T) {
+	js, nc := setupTestJetStream(t)
+	defer nc.Close()
+
+	js.DeleteKeyValue("SOIL")
+	soil, err := NewSoil(js)
+	if err != nil {
+		t.Fatalf("Failed to create soil: %v", err)
+	}
+
+	first, _ := soil.Put("tasks/1", []byte(`{"status":"open"}`))
+	soil.Put("tasks/1", []byte(`{"status":"stuck","retries":3}`))
+	soil.Put("tasks/2", []byte(`{}`))
+	soil.Put("contacts/1", []byte(`{}`))
+	soil.Delete("tasks/2")
+
+	keys, err := soil.KeysWithPrefix("tasks/")
+	if err != nil || len(keys) != 1 || keys[0] != "tasks/1" {
+		t.Errorf("Expected [tasks/1], got %v (%v)", keys, err)
+	}
+
+	history, err := soil.History("tasks/1")
+	if err != nil || len(history) != 2 {
+		t.Fatalf("Expected 2 revisions, got %+v (%v)", history, err)
+	}
+	if history[0].Revision != first || string(history[1].Data) != `{"status":"stuck","retries":3}` {
+		t.Errorf("Unexpected history: %+v", history)
+	}
+
+	deleted, _ := soil.History("tasks/2")
+	if len(deleted) != 2 || deleted[1].Op != SoilDelete || deleted[1].Data != nil {
+		t.Errorf("Expected put then delete, got %+v", deleted)
+	}
+	if _, err := soil.History("tasks/missing"); !errors.Is(err, ErrEntityNotFound) {
+		t.Errorf("Expected ErrEntityNotFound, got %v", err)
+	}
+
+	changes, err := soil.Diff("tasks/1", first, 0)
+	if err != nil || len(changes) != 2 || changes[0].Path != "retries" || changes[1].To != "stuck" {
+		t.Errorf("Unexpected diff: %+v (%v)", changes, err)
+	}
+	if _, err := soil.Diff("tasks/1", 999, 0); err == nil {
+		t.Error("Expected error for revision outside history")
+	}
+}
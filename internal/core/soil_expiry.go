@@ -0,0 +1,754 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// SoilDueField schedules entities matching Pattern, a key glob, for the
+// time stored in one of their JSON fields, e.g. {Pattern: "task-*", Field: "due_date"}.
+type SoilDueField struct {
+	Pattern string
+	Field   string // RFC 3339 timestamp field, may be dotted
+}
+
+// SoilTTLField deletes entities matching Pattern, a key glob, a fixed time
+// after their last write, e.g. {Pattern: "session-*", After: 24 * time.Hour}.
+type SoilTTLField struct {
+	Pattern string
+	After   time.Duration
+}
+
+// SoilExpirerNim is the nim TTL deletes are composted as.
+const SoilExpirerNim = "soil-expirer"
+
+// SoilExpiryConfig configures a SoilExpirer.
+type SoilExpiryConfig struct {
+	// Bucket is the KV bucket holding the expiry schedule.
+	// Default: "SOIL_EXPIRY"
+	Bucket string
+
+	// Due lists entity fields holding a due time. Entities are scheduled
+	// when the field is set or changed and unscheduled when it is removed.
+	Due []SoilDueField
+
+	// TTL lists entities deleted a fixed time after each write. A TTL
+	// takes precedence over a due field of the same entity.
+	TTL []SoilTTLField
+
+	// Consumer is the durable consumer following soil writes for Due and
+	// TTL, shared by every land so each write is scheduled once.
+	// Default: "soil-expirer"
+	Consumer string
+
+	// Humus, if set, receives TTL deletes as composts by SoilExpirerNim,
+	// so they are audited, projected and mirrored to bedrock like any
+	// other change. Without it entities are deleted from soil directly,
+	// at the revision that expired.
+	Humus *Humus
+
+	// CheckEvery is how often the schedule is checked. Checks run on
+	// WindWaker beats, at most this often however many lands beat.
+	// Default: 1s
+	CheckEvery time.Duration
+}
+
+// SoilExpiry is a scheduled expiry in the soil expiry index.
+type SoilExpiry struct {
+	Entity string    `json:"entity"`
+	At     time.Time `json:"at"`
+	Delete bool      `json:"delete,omitempty"` // TTL: delete the entity when it expires
+	Fired  bool      `json:"fired,omitempty"`  // Claimed by a land that is firing it
+}
+
+// SoilExpired is the data of a soil.expired.<entity> leaf.
+type SoilExpired struct {
+	Entity  string          `json:"entity"`
+	At      time.Time       `json:"at"`
+	Reason  string          `json:"reason"`  // "ttl" or "due"
+	Deleted bool            `json:"deleted"` // True when the entity was removed from soil, or its delete composted
+	Data    json.RawMessage `json:"data"`    // Entity state when it expired
+}
+
+// SoilExpiredSubject returns the leaf subject for an expired entity.
+func SoilExpiredSubject(entity string) string {
+	return "soil.expired." + entity
+}
+
+// scheduledExpiry is a SoilExpiry with its revision in the expiry bucket.
+type scheduledExpiry struct {
+	SoilExpiry
+	revision uint64
+}
+
+// SoilExpirer drives per-entity expiry for soil. The schedule is kept in
+// its own KV bucket, so it survives restarts and is shared by every land.
+// On each check, due entries are claimed with a revision check, so only one
+// land fires each expiry, and a soil.expired.<entity> leaf is dropped on the
+// wind. Entities with a TTL are also deleted from soil. Once its leaf is
+// dropped, an entry is removed from the schedule.
+type SoilExpirer struct {
+	js     nats.JetStreamContext
+	soil   *Soil
+	wind   *Wind
+	kv     nats.KeyValue
+	config SoilExpiryConfig
+	due    []dueMatcher
+	ttl    []ttlMatcher
+
+	mu       sync.Mutex
+	schedule map[string]*scheduledExpiry
+	watcher  nats.KeyWatcher
+	soilSub  *nats.Subscription
+	done     chan struct{}
+	wg       sync.WaitGroup
+	beatSub  *nats.Subscription
+	checked  time.Time
+	fired    uint64
+
+	// unsent holds leaves of claimed expiries that failed to drop. No
+	// other land fires a claimed expiry, so they are retried on each check.
+	unsent []unsentExpiry
+}
+
+type dueMatcher struct {
+	SoilDueField
+	match *regexp.Regexp
+}
+
+type ttlMatcher struct {
+	SoilTTLField
+	match *regexp.Regexp
+}
+
+// unsentExpiry is a leaf that failed to drop, with the claim to remove
+// from the schedule once it is dropped.
+type unsentExpiry struct {
+	leaf     Leaf
+	entity   string
+	revision uint64
+}
+
+// NewSoilExpirer creates a soil expirer. The schedule bucket is created if
+// it doesn't exist.
+func NewSoilExpirer(js nats.JetStreamContext, soil *Soil, wind *Wind, cfg SoilExpiryConfig) (*SoilExpirer, error) {
+	if soil == nil {
+		return nil, fmt.Errorf("soil is required")
+	}
+	if wind == nil {
+		return nil, fmt.Errorf("wind is required")
+	}
+	if cfg.Bucket == "" {
+		cfg.Bucket = "SOIL_EXPIRY"
+	}
+	if cfg.CheckEvery <= 0 {
+		cfg.CheckEvery = time.Second
+	}
+	if cfg.Consumer == "" {
+		cfg.Consumer = "soil-expirer"
+	}
+
+	kv, err := js.KeyValue(cfg.Bucket)
+	if err != nil {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:      cfg.Bucket,
+			Description: "NimsForest soil expiry schedule",
+			History:     1,
+			Storage:     nats.FileStorage,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create KV bucket %s: %w", cfg.Bucket, err)
+		}
+		log.Printf("[SoilExpirer] Created KV bucket: %s", cfg.Bucket)
+	}
+
+	e := &SoilExpirer{
+		js:       js,
+		soil:     soil,
+		wind:     wind,
+		kv:       kv,
+		config:   cfg,
+		schedule: make(map[string]*scheduledExpiry),
+	}
+	for _, d := range cfg.Due {
+		if d.Pattern == "" || d.Field == "" {
+			return nil, fmt.Errorf("due field requires pattern and field")
+		}
+		e.due = append(e.due, dueMatcher{SoilDueField: d, match: compileKeyGlob(d.Pattern)})
+	}
+	for _, t := range cfg.TTL {
+		if t.Pattern == "" || t.After <= 0 {
+			return nil, fmt.Errorf("ttl requires pattern and a positive duration")
+		}
+		e.ttl = append(e.ttl, ttlMatcher{SoilTTLField: t, match: compileKeyGlob(t.Pattern)})
+	}
+	return e, nil
+}
+
+// ExpireAt schedules an entity to be deleted from soil at the given time.
+// Scheduling again replaces the previous time.
+func (e *SoilExpirer) ExpireAt(entity string, at time.Time) error {
+	if entity == "" {
+		return fmt.Errorf("entity key cannot be empty")
+	}
+	data, _ := json.Marshal(SoilExpiry{Entity: entity, At: at, Delete: true})
+	if _, err := e.kv.Put(entity, data); err != nil {
+		return fmt.Errorf("failed to schedule expiry of %s: %w", entity, err)
+	}
+	return nil
+}
+
+// ExpireAfter schedules an entity to be deleted from soil after ttl.
+func (e *SoilExpirer) ExpireAfter(entity string, ttl time.Duration) error {
+	return e.ExpireAt(entity, time.Now().Add(ttl))
+}
+
+// Cancel removes any scheduled expiry of an entity.
+func (e *SoilExpirer) Cancel(entity string) error {
+	if err := e.kv.Delete(entity); err != nil {
+		return fmt.Errorf("failed to cancel expiry of %s: %w", entity, err)
+	}
+	return nil
+}
+
+// Start loads the schedule, begins tracking due fields and TTLs and
+// checks the schedule on WindWaker beats.
+func (e *SoilExpirer) Start() error {
+	e.mu.Lock()
+	if e.watcher != nil {
+		e.mu.Unlock()
+		return fmt.Errorf("soil expirer already running")
+	}
+	watcher, err := e.kv.WatchAll()
+	if err != nil {
+		e.mu.Unlock()
+		return fmt.Errorf("failed to watch expiry schedule: %w", err)
+	}
+	e.watcher = watcher
+	e.mu.Unlock()
+
+	// Load the schedule before tracking writes, so changes are made at
+	// the revisions other lands left.
+	ready := make(chan struct{})
+	go e.syncSchedule(watcher, ready)
+	<-ready
+
+	if len(e.due) > 0 || len(e.ttl) > 0 {
+		if err := e.startTracking(); err != nil {
+			e.Stop()
+			return err
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.beatSub, err = e.wind.Catch("dance.beat", e.onBeat)
+	if err != nil {
+		e.stopLocked()
+		return fmt.Errorf("failed to catch beats: %w", err)
+	}
+
+	log.Printf("[SoilExpirer] Started - bucket: %s, scheduled: %d, due fields: %d, ttls: %d, check_every: %v",
+		e.config.Bucket, len(e.schedule), len(e.due), len(e.ttl), e.config.CheckEvery)
+	return nil
+}
+
+// startTracking follows soil writes through the durable consumer. A new
+// consumer starts with the next write, after the entities already in soil
+// are scheduled; an existing one resumes where it stopped, so a restart
+// doesn't schedule writes again.
+func (e *SoilExpirer) startTracking() error {
+	stream := "KV_" + e.soil.Bucket()
+	created, err := e.ensureConsumer(stream)
+	if err != nil {
+		return err
+	}
+	sub, err := e.js.PullSubscribe(e.filter(), e.config.Consumer, nats.Bind(stream, e.config.Consumer))
+	if err != nil {
+		return fmt.Errorf("failed to bind soil expirer consumer: %w", err)
+	}
+	if created {
+		e.scheduleExisting()
+	}
+
+	done := make(chan struct{})
+	e.mu.Lock()
+	e.soilSub, e.done = sub, done
+	e.mu.Unlock()
+	e.wg.Add(1)
+	go e.run(sub, done)
+	return nil
+}
+
+// ensureConsumer creates the durable consumer if it doesn't exist and
+// reports whether it did.
+func (e *SoilExpirer) ensureConsumer(stream string) (bool, error) {
+	_, err := e.js.ConsumerInfo(stream, e.config.Consumer)
+	if err == nil {
+		return false, nil
+	}
+	if err != nats.ErrConsumerNotFound {
+		return false, fmt.Errorf("failed to get consumer %s: %w", e.config.Consumer, err)
+	}
+
+	_, err = e.js.AddConsumer(stream, &nats.ConsumerConfig{
+		Durable:       e.config.Consumer,
+		FilterSubject: e.filter(),
+		DeliverPolicy: nats.DeliverNewPolicy,
+		AckPolicy:     nats.AckExplicitPolicy,
+		AckWait:       30 * time.Second,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to create consumer %s: %w", e.config.Consumer, err)
+	}
+	return true, nil
+}
+
+// filter is the stream subject holding every soil write.
+func (e *SoilExpirer) filter() string {
+	return "$KV." + e.soil.Bucket() + ".>"
+}
+
+// Stop stops checking and tracking. The schedule is kept in its bucket
+// and the consumer keeps its position.
+func (e *SoilExpirer) Stop() error {
+	e.mu.Lock()
+	e.stopLocked()
+	fired := e.fired
+	e.mu.Unlock()
+
+	e.wg.Wait()
+	log.Printf("[SoilExpirer] Stopped (fired: %d)", fired)
+	return nil
+}
+
+func (e *SoilExpirer) stopLocked() {
+	if e.beatSub != nil {
+		e.beatSub.Unsubscribe()
+		e.beatSub = nil
+	}
+	if e.soilSub != nil {
+		close(e.done)
+		e.soilSub.Unsubscribe()
+		e.soilSub = nil
+	}
+	if e.watcher != nil {
+		e.watcher.Stop()
+		e.watcher = nil
+	}
+}
+
+// Pending returns the scheduled expiries that haven't been claimed, soonest
+// first.
+func (e *SoilExpirer) Pending() []SoilExpiry {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	pending := make([]SoilExpiry, 0, len(e.schedule))
+	for _, s := range e.schedule {
+		if !s.Fired {
+			pending = append(pending, s.SoilExpiry)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].At.Before(pending[j].At) })
+	return pending
+}
+
+// Check fires every expiry that is due and returns how many this land fired.
+// Leaves that failed to drop on an earlier check are dropped first.
+func (e *SoilExpirer) Check() (int, error) {
+	fired, err := e.dropUnsent()
+	if err == nil {
+		var n int
+		n, err = e.fireDue(time.Now())
+		fired += n
+	}
+
+	e.mu.Lock()
+	e.fired += uint64(fired)
+	e.mu.Unlock()
+	return fired, err
+}
+
+// fireDue fires the expiries due at now, soonest first.
+func (e *SoilExpirer) fireDue(now time.Time) (int, error) {
+	e.mu.Lock()
+	var due []scheduledExpiry
+	for _, s := range e.schedule {
+		if !s.Fired && !s.At.After(now) {
+			due = append(due, *s)
+		}
+	}
+	e.mu.Unlock()
+
+	sort.Slice(due, func(i, j int) bool { return due[i].At.Before(due[j].At) })
+
+	fired := 0
+	for _, s := range due {
+		ok, err := e.fire(s)
+		if err != nil {
+			return fired, err
+		}
+		if ok {
+			fired++
+		}
+	}
+	return fired, nil
+}
+
+// dropUnsent drops the leaves that failed to drop on earlier checks, in
+// order, and returns how many it dropped.
+func (e *SoilExpirer) dropUnsent() (int, error) {
+	e.mu.Lock()
+	unsent := e.unsent
+	e.unsent = nil
+	e.mu.Unlock()
+
+	for i, u := range unsent {
+		if err := e.wind.Drop(u.leaf); err != nil {
+			e.mu.Lock()
+			e.unsent = append(unsent[i:], e.unsent...)
+			e.mu.Unlock()
+			return i, fmt.Errorf("failed to drop expiry leaf %s: %w", u.leaf.Subject, err)
+		}
+		e.unschedule(u.entity, u.revision)
+	}
+	return len(unsent), nil
+}
+
+// fire claims an expiry, drops its leaf and removes it from the schedule.
+// It returns false when another land claimed it first or the schedule
+// changed since it was read. The entity is read before the claim, so a
+// failed read leaves the expiry for the next check.
+func (e *SoilExpirer) fire(s scheduledExpiry) (bool, error) {
+	entry, err := e.soil.kv.Get(s.Entity)
+	if err != nil && err != nats.ErrKeyNotFound {
+		return false, fmt.Errorf("failed to read %s: %w", s.Entity, err)
+	}
+
+	claimed := s.SoilExpiry
+	claimed.Fired = true
+	data, _ := json.Marshal(claimed)
+	revision, err := e.kv.Update(s.Entity, data, s.revision)
+	if err != nil {
+		if isRevisionConflict(err) || err == nats.ErrKeyNotFound {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to claim expiry of %s: %w", s.Entity, err)
+	}
+
+	if entry == nil {
+		// Entity is already gone - nothing to report
+		e.unschedule(s.Entity, revision)
+		return false, nil
+	}
+	expired := SoilExpired{Entity: s.Entity, At: s.At, Reason: "due", Data: entry.Value()}
+
+	if s.Delete {
+		expired.Reason = "ttl"
+		if err := e.deleteExpired(s.Entity, entry.Revision()); err != nil {
+			if isRevisionConflict(err) {
+				// Written since it was read: release the claim, so the
+				// next check reads the entity again
+				data, _ := json.Marshal(s.SoilExpiry)
+				e.kv.Update(s.Entity, data, revision)
+				return false, nil
+			}
+			log.Printf("[SoilExpirer] Warning: failed to delete expired %s: %v", s.Entity, err)
+		} else {
+			expired.Deleted = true
+		}
+	}
+
+	leafData, err := json.Marshal(expired)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal expiry of %s: %w", s.Entity, err)
+	}
+	leaf := NewLeaf(SoilExpiredSubject(s.Entity), leafData, "soil")
+	if err := e.wind.Drop(*leaf); err != nil {
+		// Claimed, so no other land fires it: keep it for the next check
+		e.mu.Lock()
+		e.unsent = append(e.unsent, unsentExpiry{leaf: *leaf, entity: s.Entity, revision: revision})
+		e.mu.Unlock()
+		return false, fmt.Errorf("failed to drop expiry leaf for %s: %w", s.Entity, err)
+	}
+	e.unschedule(s.Entity, revision)
+
+	log.Printf("[SoilExpirer] Entity %s expired (%s, due: %s)", s.Entity, expired.Reason, s.At.Format(time.RFC3339))
+	return true, nil
+}
+
+// deleteExpired deletes an entity whose TTL passed, through humus when one
+// is configured, or else from soil at the revision that expired.
+func (e *SoilExpirer) deleteExpired(entity string, revision uint64) error {
+	if e.config.Humus != nil {
+		_, err := e.config.Humus.Add(SoilExpirerNim, entity, "delete", nil)
+		return err
+	}
+	return e.soil.DeleteExpected(entity, revision)
+}
+
+// unschedule removes a fired expiry from the schedule unless it was
+// scheduled again since it was claimed.
+func (e *SoilExpirer) unschedule(entity string, revision uint64) {
+	if err := e.kv.Delete(entity, nats.LastRevision(revision)); err != nil && !isRevisionConflict(err) {
+		log.Printf("[SoilExpirer] Warning: failed to remove fired expiry of %s: %v", entity, err)
+	}
+}
+
+// syncSchedule mirrors the schedule bucket in memory.
+func (e *SoilExpirer) syncSchedule(watcher nats.KeyWatcher, ready chan struct{}) {
+	initial := true
+	for entry := range watcher.Updates() {
+		if entry == nil {
+			if initial {
+				initial = false
+				close(ready)
+			}
+			continue
+		}
+
+		e.mu.Lock()
+		if entry.Operation() == nats.KeyValuePut {
+			var s SoilExpiry
+			if err := json.Unmarshal(entry.Value(), &s); err == nil {
+				e.schedule[entry.Key()] = &scheduledExpiry{SoilExpiry: s, revision: entry.Revision()}
+			}
+		} else {
+			delete(e.schedule, entry.Key())
+		}
+		e.mu.Unlock()
+	}
+}
+
+// run schedules the soil writes delivered to the consumer. A write whose
+// schedule change conflicts with this land's view of the schedule is
+// redelivered once the view has caught up.
+func (e *SoilExpirer) run(sub *nats.Subscription, done chan struct{}) {
+	defer e.wg.Done()
+
+	prefix := "$KV." + e.soil.Bucket() + "."
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		msgs, err := sub.Fetch(100, nats.MaxWait(time.Second))
+		if err != nil {
+			if err != nats.ErrTimeout {
+				select {
+				case <-done:
+					return
+				case <-time.After(time.Second):
+				}
+			}
+			continue
+		}
+
+		for _, msg := range msgs {
+			op := nats.KeyValuePut
+			switch msg.Header.Get("KV-Operation") {
+			case "DEL":
+				op = nats.KeyValueDelete
+			case "PURGE":
+				op = nats.KeyValuePurge
+			}
+			meta, err := msg.Metadata()
+			if err != nil {
+				msg.Ack()
+				continue
+			}
+			key := strings.TrimPrefix(msg.Subject, prefix)
+			if err := e.track(key, op, meta.Sequence.Stream, msg.Data, meta.Timestamp, false); err != nil {
+				log.Printf("[SoilExpirer] Rescheduling %s later: %v", key, err)
+				msg.NakWithDelay(time.Second)
+				continue
+			}
+			msg.Ack()
+		}
+	}
+}
+
+// scheduleExisting schedules the entities already in soil, for a consumer
+// that starts with the next write.
+func (e *SoilExpirer) scheduleExisting() {
+	keys, err := e.soil.kv.Keys()
+	if err != nil {
+		if err != nats.ErrNoKeysFound {
+			log.Printf("[SoilExpirer] Warning: failed to list soil: %v", err)
+		}
+		return
+	}
+	for _, key := range keys {
+		if e.dueField(key) == nil && e.ttlField(key) == nil {
+			continue
+		}
+		entry, err := e.soil.kv.Get(key)
+		if err != nil {
+			continue
+		}
+		if err := e.track(key, nats.KeyValuePut, entry.Revision(), entry.Value(), entry.Created(), true); err != nil {
+			log.Printf("[SoilExpirer] Warning: failed to schedule %s: %v", key, err)
+		}
+	}
+}
+
+// track updates the schedule for a soil write. An entity matching a TTL is
+// scheduled for deletion after each write. An entity matching a due field
+// is scheduled when the field is set or differs from the entity's previous
+// revision, so a due time fires once however often the entity is written;
+// existing entities have no previous revision to compare. Schedule changes
+// are made at the revision this land last saw, so they can't overwrite
+// another land's claim or a newer schedule.
+func (e *SoilExpirer) track(key string, op nats.KeyValueOp, revision uint64, data []byte, written time.Time, existing bool) error {
+	due, ttl := e.dueField(key), e.ttlField(key)
+	if due == nil && ttl == nil {
+		return nil
+	}
+
+	e.mu.Lock()
+	current, scheduled := e.schedule[key]
+	e.mu.Unlock()
+
+	if op != nats.KeyValuePut {
+		if scheduled {
+			return e.kv.Delete(key, nats.LastRevision(current.revision))
+		}
+		return nil
+	}
+	if ttl != nil {
+		return e.put(key, current, SoilExpiry{Entity: key, At: written.Add(ttl.After), Delete: true})
+	}
+
+	var doc map[string]interface{}
+	json.Unmarshal(data, &doc)
+	at, ok := parseDueTime(fieldValue(doc, due.Field))
+	switch {
+	case scheduled && current.Delete:
+		// A TTL takes precedence over the due field
+		return nil
+	case !ok:
+		if scheduled {
+			return e.kv.Delete(key, nats.LastRevision(current.revision))
+		}
+		return nil
+	case scheduled && current.At.Equal(at):
+		return nil
+	}
+	if !existing {
+		if previous, ok := e.previousDue(key, revision, due.Field); ok && previous.Equal(at) {
+			return nil // Unchanged - already scheduled or fired
+		}
+	}
+	return e.put(key, current, SoilExpiry{Entity: key, At: at})
+}
+
+// put writes a schedule entry over current, the entry this land last saw,
+// or creates it when there is none.
+func (e *SoilExpirer) put(key string, current *scheduledExpiry, s SoilExpiry) error {
+	data, _ := json.Marshal(s)
+	var err error
+	if current != nil {
+		_, err = e.kv.Update(key, data, current.revision)
+	} else {
+		_, err = e.kv.Create(key, data)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to schedule %s: %w", key, err)
+	}
+	return nil
+}
+
+// previousDue returns the due time of the revision of an entity written
+// before revision, if it is still in the soil history.
+func (e *SoilExpirer) previousDue(key string, revision uint64, field string) (time.Time, bool) {
+	history, err := e.soil.kv.History(key)
+	if err != nil {
+		return time.Time{}, false
+	}
+	var previous nats.KeyValueEntry
+	for _, entry := range history {
+		if entry.Revision() < revision && (previous == nil || entry.Revision() > previous.Revision()) {
+			previous = entry
+		}
+	}
+	if previous == nil || previous.Operation() != nats.KeyValuePut {
+		return time.Time{}, false
+	}
+	var doc map[string]interface{}
+	json.Unmarshal(previous.Value(), &doc)
+	return parseDueTime(fieldValue(doc, field))
+}
+
+func (e *SoilExpirer) dueField(key string) *dueMatcher {
+	for i := range e.due {
+		if e.due[i].match.MatchString(key) {
+			return &e.due[i]
+		}
+	}
+	return nil
+}
+
+func (e *SoilExpirer) ttlField(key string) *ttlMatcher {
+	for i := range e.ttl {
+		if e.ttl[i].match.MatchString(key) {
+			return &e.ttl[i]
+		}
+	}
+	return nil
+}
+
+// onBeat checks the schedule at most every CheckEvery. Every land's
+// WindWaker beats on the same subject, so beats arrive once per land and
+// the interval is measured in time rather than counted in beats.
+func (e *SoilExpirer) onBeat(leaf Leaf) {
+	now := time.Now()
+
+	e.mu.Lock()
+	check := now.Sub(e.checked) >= e.config.CheckEvery
+	if check {
+		e.checked = now
+	}
+	e.mu.Unlock()
+
+	if check {
+		if _, err := e.Check(); err != nil {
+			log.Printf("[SoilExpirer] Check failed: %v", err)
+		}
+	}
+}
+
+// parseDueTime parses an RFC 3339 due time. Zero times are not due.
+func parseDueTime(v interface{}) (time.Time, bool) {
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	at, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil || at.IsZero() {
+		return time.Time{}, false
+	}
+	return at, true
+}
+
+// RunSoilExpirer creates and starts a soil expirer.
+func RunSoilExpirer(js nats.JetStreamContext, soil *Soil, wind *Wind, cfg SoilExpiryConfig) (*SoilExpirer, error) {
+	expirer, err := NewSoilExpirer(js, soil, wind, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := expirer.Start(); err != nil {
+		return nil, err
+	}
+	return expirer, nil
+}
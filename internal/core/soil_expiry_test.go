@@ -0,0 +1,311 @@
+package core
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// setupTestExpirer returns a fresh soil and a func listing the expiry leaves caught so far.
+func setupTestExpirer(t *testing.T) (nats.JetStreamContext, *Soil, *Wind, func() []SoilExpired) {
+	t.Helper()
+	js, nc := setupTestJetStream(t)
+	t.Cleanup(nc.Close)
+
+	js.DeleteKeyValue("SOIL")
+	js.DeleteKeyValue("SOIL_EXPIRY")
+	soil, err := NewSoil(js)
+	if err != nil {
+		t.Fatalf("Failed to create soil: %v", err)
+	}
+	wind := NewWind(nc)
+
+	var mu sync.Mutex
+	var expired []SoilExpired
+	wind.Catch("soil.expired.>", func(leaf Leaf) {
+		var e SoilExpired
+		json.Unmarshal(leaf.Data, &e)
+		mu.Lock()
+		expired = append(expired, e)
+		mu.Unlock()
+	})
+	nc.Flush()
+
+	return js, soil, wind, func() []SoilExpired {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]SoilExpired(nil), expired...)
+	}
+}
+
+func TestSoilExpirer_TTL(t *testing.T) {
+	js, soil, wind, expired := setupTestExpirer(t)
+
+	expirer, err := RunSoilExpirer(js, soil, wind, SoilExpiryConfig{})
+	if err != nil {
+		t.Fatalf("Failed to start expirer: %v", err)
+	}
+	defer expirer.Stop()
+
+	soil.Put("session-1", []byte(`{"user":"ada"}`))
+	soil.Put("session-2", []byte(`{"user":"bob"}`))
+	expirer.ExpireAt("session-1", time.Now().Add(-time.Second))
+	expirer.ExpireAfter("session-2", time.Hour)
+	waitFor(t, func() bool { return len(expirer.Pending()) == 2 })
+
+	if fired, err := expirer.Check(); err != nil || fired != 1 {
+		t.Fatalf("Expected 1 expiry, got %d (%v)", fired, err)
+	}
+	if _, _, err := soil.Dig("session-1"); err == nil {
+		t.Error("Expected expired entity to be deleted")
+	}
+	if _, _, err := soil.Dig("session-2"); err != nil {
+		t.Errorf("Expected session-2 to remain: %v", err)
+	}
+
+	waitFor(t, func() bool { return len(expired()) == 1 })
+	e := expired()[0]
+	if e.Entity != "session-1" || e.Reason != "ttl" || !e.Deleted || string(e.Data) != `{"user":"ada"}` {
+		t.Errorf("Unexpected expiry leaf: %+v", e)
+	}
+
+	// Cancelled expiries never fire
+	expirer.Cancel("session-2")
+	waitFor(t, func() bool { return len(expirer.Pending()) == 0 })
+}
+
+func TestSoilExpirer_DueField(t *testing.T) {
+	cfg := SoilExpiryConfig{Due: []SoilDueField{{Pattern: "task-*", Field: "due_date"}}}
+	js, soil, wind, expired := setupTestExpirer(t)
+
+	expirer, err := RunSoilExpirer(js, soil, wind, cfg)
+	if err != nil {
+		t.Fatalf("Failed to start expirer: %v", err)
+	}
+
+	past := time.Now().Add(-time.Minute).Format(time.RFC3339)
+	soil.Put("task-1", []byte(`{"status":"pending","due_date":"`+past+`"}`))
+	soil.Put("task-2", []byte(`{"status":"pending"}`))
+	waitFor(t, func() bool { return len(expirer.Pending()) == 1 })
+
+	if fired, _ := expirer.Check(); fired != 1 {
+		t.Fatalf("Expected 1 due task, got %d", fired)
+	}
+	waitFor(t, func() bool { return len(expired()) == 1 })
+	if e := expired()[0]; e.Entity != "task-1" || e.Reason != "due" || e.Deleted {
+		t.Errorf("Unexpected expiry leaf: %+v", e)
+	}
+	if _, _, err := soil.Dig("task-1"); err != nil {
+		t.Errorf("Expected due task to remain in soil: %v", err)
+	}
+
+	// A due time fires once, even after a restart
+	expirer.Stop()
+	expirer, err = RunSoilExpirer(js, soil, wind, cfg)
+	if err != nil {
+		t.Fatalf("Failed to restart expirer: %v", err)
+	}
+	defer expirer.Stop()
+	if fired, _ := expirer.Check(); fired != 0 {
+		t.Errorf("Expected no refire after restart, got %d", fired)
+	}
+
+	// Moving the due time schedules it again
+	later := time.Now().Add(-time.Second).Format(time.RFC3339)
+	soil.Put("task-1", []byte(`{"status":"pending","due_date":"`+later+`"}`))
+	waitFor(t, func() bool { return len(expirer.Pending()) == 1 })
+	if fired, _ := expirer.Check(); fired != 1 {
+		t.Errorf("Expected rescheduled task to fire, got %d", fired)
+	}
+
+	// Deleting the entity removes it from the schedule
+	soil.Put("task-3", []byte(`{"due_date":"`+time.Now().Add(time.Hour).Format(time.RFC3339)+`"}`))
+	waitFor(t, func() bool { return len(expirer.Pending()) == 1 })
+	soil.Delete("task-3")
+	waitFor(t, func() bool { return len(expirer.Pending()) == 0 })
+}
+
+// unreadableKV fails every read, like a soil bucket that is briefly unavailable.
+type unreadableKV struct{ nats.KeyValue }
+
+func (unreadableKV) Get(key string) (nats.KeyValueEntry, error) {
+	return nil, nats.ErrTimeout
+}
+
+func TestSoilExpirer_ReadErrorKeepsExpiry(t *testing.T) {
+	js, soil, wind, expired := setupTestExpirer(t)
+
+	expirer, err := RunSoilExpirer(js, soil, wind, SoilExpiryConfig{})
+	if err != nil {
+		t.Fatalf("Failed to start expirer: %v", err)
+	}
+	defer expirer.Stop()
+
+	soil.Put("session-1", []byte(`{"user":"ada"}`))
+	expirer.ExpireAt("session-1", time.Now().Add(-time.Second))
+	waitFor(t, func() bool { return len(expirer.Pending()) == 1 })
+
+	kv := soil.kv
+	soil.kv = unreadableKV{kv}
+	if _, err := expirer.Check(); err == nil {
+		t.Error("Expected the failed read to be reported")
+	}
+	soil.kv = kv
+
+	if fired, err := expirer.Check(); err != nil || fired != 1 {
+		t.Fatalf("Expected the expiry to fire on the next check, got %d (%v)", fired, err)
+	}
+	waitFor(t, func() bool { return len(expired()) == 1 })
+}
+
+func TestSoilExpirer_RemovesFiredEntries(t *testing.T) {
+	cfg := SoilExpiryConfig{Due: []SoilDueField{{Pattern: "task-*", Field: "due_date"}}}
+	js, soil, wind, expired := setupTestExpirer(t)
+
+	expirer, err := RunSoilExpirer(js, soil, wind, cfg)
+	if err != nil {
+		t.Fatalf("Failed to start expirer: %v", err)
+	}
+	defer expirer.Stop()
+
+	past := time.Now().Add(-time.Minute).Format(time.RFC3339)
+	soil.Put("task-1", []byte(`{"status":"pending","due_date":"`+past+`"}`))
+	waitFor(t, func() bool { return len(expirer.Pending()) == 1 })
+	if fired, _ := expirer.Check(); fired != 1 {
+		t.Fatalf("Expected 1 due task, got %d", fired)
+	}
+	if _, err := expirer.kv.Get("task-1"); err != nats.ErrKeyNotFound {
+		t.Errorf("Expected the fired entry to be removed, got %v", err)
+	}
+
+	// Writes that keep the due time don't schedule it again
+	soil.Put("task-1", []byte(`{"status":"escalated","due_date":"`+past+`"}`))
+	time.Sleep(300 * time.Millisecond)
+	if _, err := expirer.kv.Get("task-1"); err != nats.ErrKeyNotFound {
+		t.Errorf("Expected an unchanged due time to stay unscheduled, got %v", err)
+	}
+	if fired, _ := expirer.Check(); fired != 0 {
+		t.Errorf("Expected no refire, got %d", fired)
+	}
+	waitFor(t, func() bool { return len(expired()) == 1 })
+}
+
+func TestSoilExpirer_TTLField(t *testing.T) {
+	js, soil, wind, expired := setupTestExpirer(t)
+	js.DeleteStream("HUMUS")
+	humus, err := NewHumus(js)
+	if err != nil {
+		t.Fatalf("Failed to create humus: %v", err)
+	}
+	composts := make(chan Compost, 1)
+	sub, err := humus.Subscribe(CompostFilter{Nim: SoilExpirerNim}, func(c Compost) { composts <- c })
+	if err != nil {
+		t.Fatalf("Failed to subscribe to humus: %v", err)
+	}
+	defer sub.Stop()
+
+	// Entities already in soil are scheduled when the consumer is created
+	soil.Put("session-1", []byte(`{"user":"ada"}`))
+	cfg := SoilExpiryConfig{TTL: []SoilTTLField{{Pattern: "session-*", After: time.Millisecond}}, Humus: humus}
+	expirer, err := RunSoilExpirer(js, soil, wind, cfg)
+	if err != nil {
+		t.Fatalf("Failed to start expirer: %v", err)
+	}
+	defer expirer.Stop()
+	waitFor(t, func() bool { return len(expirer.Pending()) == 1 })
+	first := expirer.Pending()[0].At
+
+	// Each write moves the deletion
+	soil.Put("session-1", []byte(`{"user":"bob"}`))
+	waitFor(t, func() bool {
+		pending := expirer.Pending()
+		return len(pending) == 1 && pending[0].At.After(first)
+	})
+	time.Sleep(10 * time.Millisecond)
+
+	if fired, err := expirer.Check(); err != nil || fired != 1 {
+		t.Fatalf("Expected 1 expiry, got %d (%v)", fired, err)
+	}
+	select {
+	case c := <-composts:
+		if c.Entity != "session-1" || c.Action != "delete" {
+			t.Errorf("Unexpected compost: %+v", c)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the delete to be composted")
+	}
+	waitFor(t, func() bool { return len(expired()) == 1 })
+	if e := expired()[0]; e.Reason != "ttl" || !e.Deleted || string(e.Data) != `{"user":"bob"}` {
+		t.Errorf("Unexpected expiry leaf: %+v", e)
+	}
+}
+
+func TestSoilExpirer_RetriesFailedDrop(t *testing.T) {
+	js, soil, wind, expired := setupTestExpirer(t)
+
+	expirer, err := RunSoilExpirer(js, soil, wind, SoilExpiryConfig{})
+	if err != nil {
+		t.Fatalf("Failed to start expirer: %v", err)
+	}
+	defer expirer.Stop()
+
+	soil.Put("session-1", []byte(`{"user":"ada"}`))
+	expirer.ExpireAt("session-1", time.Now().Add(-time.Second))
+	waitFor(t, func() bool { return len(expirer.Pending()) == 1 })
+
+	// The expiry is claimed, but its leaf can't be dropped
+	closed := setupTestNATS(t)
+	closed.Close()
+	expirer.wind = NewWind(closed)
+	if _, err := expirer.Check(); err == nil {
+		t.Error("Expected the failed drop to be reported")
+	}
+	if _, err := expirer.Check(); err == nil {
+		t.Error("Expected the drop to be retried")
+	}
+	expirer.wind = wind
+
+	if fired, err := expirer.Check(); err != nil || fired != 1 {
+		t.Fatalf("Expected the leaf to be dropped on the next check, got %d (%v)", fired, err)
+	}
+	waitFor(t, func() bool { return len(expired()) == 1 })
+	if got := expired()[0]; got.Entity != "session-1" || !got.Deleted {
+		t.Errorf("Unexpected expiry leaf: %+v", got)
+	}
+	if fired, _ := expirer.Check(); fired != 0 {
+		t.Errorf("Expected the leaf to be dropped once, got %d more", fired)
+	}
+}
+
+func TestSoilExpirer_ChecksAtMostEveryInterval(t *testing.T) {
+	js, soil, wind, _ := setupTestExpirer(t)
+
+	expirer, err := RunSoilExpirer(js, soil, wind, SoilExpiryConfig{CheckEvery: time.Hour})
+	if err != nil {
+		t.Fatalf("Failed to start expirer: %v", err)
+	}
+	defer expirer.Stop()
+
+	expirer.onBeat(Leaf{}) // Checks: nothing is due yet
+
+	soil.Put("session-1", []byte(`{"user":"ada"}`))
+	expirer.ExpireAt("session-1", time.Now().Add(-time.Second))
+	waitFor(t, func() bool { return len(expirer.Pending()) == 1 })
+
+	// Beats from many lands arrive in a burst; none of them is due to check
+	for i := 0; i < 100; i++ {
+		expirer.onBeat(Leaf{})
+	}
+	if pending := expirer.Pending(); len(pending) != 1 {
+		t.Errorf("Expected no check within the interval, got %d pending", len(pending))
+	}
+
+	expirer.mu.Lock()
+	expirer.checked = time.Now().Add(-time.Hour)
+	expirer.mu.Unlock()
+	expirer.onBeat(Leaf{})
+	waitFor(t, func() bool { return len(expirer.Pending()) == 0 })
+}
@@ -0,0 +1,616 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// SoilIndex declares a secondary index on JSON fields of the entities whose
+// keys match Pattern, a key glob (see compileKeyGlob), e.g. "tasks/*" or
+// "task-*".
+type SoilIndex struct {
+	Name    string
+	Pattern string
+	Fields  []string // Top-level or dotted JSON fields, e.g. "status", "customer.id"
+}
+
+// QueryCondition compares a JSON field of an entity with a value.
+type QueryCondition struct {
+	Field string      `json:"field"`
+	Op    string      `json:"op"` // eq, ne, gt, gte, lt, lte (or =, !=, >, >=, <, <=)
+	Value interface{} `json:"value"`
+}
+
+// QueryFilter selects, sorts and limits the entities returned by Soil.Query.
+type QueryFilter struct {
+	Where []QueryCondition `json:"where,omitempty"` // All conditions must match
+	Sort  string           `json:"sort,omitempty"`  // Field to sort by (default: entity key)
+	Desc  bool             `json:"desc,omitempty"`
+	Limit int              `json:"limit,omitempty"` // 0 means no limit
+
+	// Prefixes, if set, restricts results to entities starting with one of
+	// them, before the limit is applied
+	Prefixes []string `json:"prefixes,omitempty"`
+}
+
+// Eq is shorthand for an equality condition.
+func Eq(field string, value interface{}) QueryCondition {
+	return QueryCondition{Field: field, Op: "eq", Value: value}
+}
+
+// QueryResult is an entity returned by Soil.Query.
+type QueryResult struct {
+	Entity   string          `json:"entity"`
+	Data     json.RawMessage `json:"data"`
+	Revision uint64          `json:"revision"`
+}
+
+// indexEntry holds the indexed field values of one entity.
+type indexEntry struct {
+	revision uint64
+	deleted  bool // Deleted or not a JSON object; kept so late watch updates can't resurrect it
+	values   map[string]interface{}
+}
+
+// soilIndex is the in-memory state of a declared index. It is kept current
+// by soil writes on this land and by a watcher for writes elsewhere.
+type soilIndex struct {
+	SoilIndex
+	match   *regexp.Regexp
+	watcher nats.KeyWatcher
+
+	mu      sync.RWMutex
+	entries map[string]*indexEntry
+}
+
+// DeclareIndex adds a secondary index and builds it from the current soil.
+// It returns once the existing entities are indexed.
+func (s *Soil) DeclareIndex(idx SoilIndex) error {
+	if idx.Name == "" {
+		return fmt.Errorf("index name cannot be empty")
+	}
+	if idx.Pattern == "" {
+		return fmt.Errorf("index %s: pattern cannot be empty", idx.Name)
+	}
+	if len(idx.Fields) == 0 {
+		return fmt.Errorf("index %s: at least one field is required", idx.Name)
+	}
+
+	si := &soilIndex{
+		SoilIndex: idx,
+		match:     compileKeyGlob(idx.Pattern),
+		entries:   make(map[string]*indexEntry),
+	}
+
+	s.indexMu.Lock()
+	if _, exists := s.indexes[idx.Name]; exists {
+		s.indexMu.Unlock()
+		return fmt.Errorf("index %s already exists", idx.Name)
+	}
+	s.indexes[idx.Name] = si
+	s.indexMu.Unlock()
+
+	watcher, err := s.kv.WatchAll()
+	if err != nil {
+		s.DropIndex(idx.Name)
+		return fmt.Errorf("failed to watch soil for index %s: %w", idx.Name, err)
+	}
+	si.watcher = watcher
+
+	ready := make(chan struct{})
+	go func() {
+		initial := true
+		for entry := range watcher.Updates() {
+			if entry == nil {
+				// End of the current values
+				if initial {
+					initial = false
+					close(ready)
+				}
+				continue
+			}
+			if !si.match.MatchString(entry.Key()) {
+				continue
+			}
+			if entry.Operation() == nats.KeyValuePut {
+				si.put(entry.Key(), entry.Value(), entry.Revision())
+			} else {
+				si.remove(entry.Key(), entry.Revision())
+			}
+		}
+	}()
+
+	select {
+	case <-ready:
+	case <-time.After(30 * time.Second):
+		log.Printf("[Soil] Warning: index %s still building after 30s", idx.Name)
+	}
+
+	log.Printf("[Soil] Declared index %s on %s (fields: %s, entities: %d)",
+		idx.Name, idx.Pattern, strings.Join(idx.Fields, ", "), si.size())
+	return nil
+}
+
+// DropIndex removes a secondary index.
+func (s *Soil) DropIndex(name string) {
+	s.indexMu.Lock()
+	si, exists := s.indexes[name]
+	delete(s.indexes, name)
+	s.indexMu.Unlock()
+
+	if exists && si.watcher != nil {
+		si.watcher.Stop()
+	}
+}
+
+// Indexes returns the declared indexes, sorted by name.
+func (s *Soil) Indexes() []SoilIndex {
+	s.indexMu.RLock()
+	defer s.indexMu.RUnlock()
+
+	indexes := make([]SoilIndex, 0, len(s.indexes))
+	for _, si := range s.indexes {
+		indexes = append(indexes, si.SoilIndex)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i].Name < indexes[j].Name })
+	return indexes
+}
+
+// Query returns the entities whose keys match pattern, a key glob, and that
+// satisfy the filter. When an index's pattern covers the query's (see
+// globCovers) and the index holds every filtered and sorted field, the
+// filter is evaluated in memory and only the results are read from soil;
+// otherwise every matching entity is scanned. Both give the
+// same results: a field that is JSON null matches like a missing one.
+func (s *Soil) Query(pattern string, filter QueryFilter) ([]QueryResult, error) {
+	if pattern == "" {
+		pattern = ">"
+	}
+	// Normalize a copy, leaving the caller's conditions as they were
+	where := make([]QueryCondition, len(filter.Where))
+	for i, cond := range filter.Where {
+		op, err := normalizeOp(cond.Op)
+		if err != nil {
+			return nil, err
+		}
+		cond.Op = op
+		where[i] = cond
+	}
+	filter.Where = where
+
+	if si := s.coveringIndex(pattern, filter); si != nil {
+		return s.queryIndex(si, compileKeyGlob(pattern), filter)
+	}
+	return s.queryScan(pattern, filter)
+}
+
+// coveringIndex returns an index that can answer the query on its own.
+func (s *Soil) coveringIndex(pattern string, filter QueryFilter) *soilIndex {
+	s.indexMu.RLock()
+	defer s.indexMu.RUnlock()
+
+	for _, si := range s.indexes {
+		if !globCovers(si.Pattern, pattern) {
+			continue
+		}
+		fields := make(map[string]bool, len(si.Fields))
+		for _, f := range si.Fields {
+			fields[f] = true
+		}
+		covered := filter.Sort == "" || fields[filter.Sort]
+		for _, cond := range filter.Where {
+			covered = covered && fields[cond.Field]
+		}
+		if covered {
+			return si
+		}
+	}
+	return nil
+}
+
+// globCovers reports whether every key matching the glob query also matches
+// the glob index. Besides equal globs, it recognizes an index that is a
+// literal prefix followed by '*' or '>', such as "task-*" for "task-1*" or
+// "task-42". Other combinations are not covered, and such queries scan.
+func globCovers(index, query string) bool {
+	if index == query || index == ">" || index == "*" {
+		return true
+	}
+	prefix, suffix := index[:len(index)-1], index[len(index)-1:]
+	if (suffix != "*" && suffix != ">") || strings.Contains(prefix, "*") {
+		return false
+	}
+	wild := strings.IndexByte(query, '*')
+	if wild < 0 && strings.HasSuffix(query, ">") {
+		wild = len(query) - 1
+	}
+	if wild < 0 {
+		return compileKeyGlob(index).MatchString(query)
+	}
+	// A '>' index needs at least one character past its prefix
+	literal := query[:wild]
+	return strings.HasPrefix(literal, prefix) && (suffix == "*" || len(literal) > len(prefix))
+}
+
+// queryIndex answers a query from an index, keeping the entities that
+// match the query's key glob.
+func (s *Soil) queryIndex(si *soilIndex, match *regexp.Regexp, filter QueryFilter) ([]QueryResult, error) {
+	type candidate struct {
+		entity string
+		values map[string]interface{}
+	}
+
+	si.mu.RLock()
+	var candidates []candidate
+	for entity, entry := range si.entries {
+		if !entry.deleted && match.MatchString(entity) && hasAnyPrefix(filter.Prefixes, entity) && matchConditions(entry.values, filter.Where) {
+			candidates = append(candidates, candidate{entity, entry.values})
+		}
+	}
+	si.mu.RUnlock()
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return lessBy(filter, candidates[i].entity, candidates[i].values[filter.Sort],
+			candidates[j].entity, candidates[j].values[filter.Sort])
+	})
+
+	results := make([]QueryResult, 0, len(candidates))
+	for _, c := range candidates {
+		if filter.Limit > 0 && len(results) >= filter.Limit {
+			break
+		}
+		entry, err := s.kv.Get(c.entity)
+		if err != nil {
+			if err == nats.ErrKeyNotFound {
+				continue // Deleted since it was indexed
+			}
+			return nil, fmt.Errorf("failed to read %s: %w", c.entity, err)
+		}
+		// The index may lag a write, so filter what was actually read
+		var doc map[string]interface{}
+		if err := json.Unmarshal(entry.Value(), &doc); err != nil || !matchConditions(doc, filter.Where) {
+			continue
+		}
+		results = append(results, QueryResult{Entity: c.entity, Data: entry.Value(), Revision: entry.Revision()})
+	}
+	return results, nil
+}
+
+// queryScan answers a query by reading every entity matching the pattern.
+func (s *Soil) queryScan(pattern string, filter QueryFilter) ([]QueryResult, error) {
+	keys, err := s.kv.Keys()
+	if err != nil && err != nats.ErrNoKeysFound {
+		return nil, fmt.Errorf("failed to get keys: %w", err)
+	}
+
+	match := compileKeyGlob(pattern)
+	type candidate struct {
+		result QueryResult
+		doc    map[string]interface{}
+	}
+
+	var candidates []candidate
+	for _, key := range keys {
+		if !match.MatchString(key) || !hasAnyPrefix(filter.Prefixes, key) {
+			continue
+		}
+		entry, err := s.kv.Get(key)
+		if err != nil {
+			if err == nats.ErrKeyNotFound {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read %s: %w", key, err)
+		}
+		var doc map[string]interface{}
+		if err := json.Unmarshal(entry.Value(), &doc); err != nil {
+			continue // Not a JSON object, can't be filtered
+		}
+		if !matchConditions(doc, filter.Where) {
+			continue
+		}
+		candidates = append(candidates, candidate{
+			result: QueryResult{Entity: key, Data: entry.Value(), Revision: entry.Revision()},
+			doc:    doc,
+		})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return lessBy(filter, candidates[i].result.Entity, fieldValue(candidates[i].doc, filter.Sort),
+			candidates[j].result.Entity, fieldValue(candidates[j].doc, filter.Sort))
+	})
+
+	if filter.Limit > 0 && len(candidates) > filter.Limit {
+		candidates = candidates[:filter.Limit]
+	}
+	results := make([]QueryResult, len(candidates))
+	for i, c := range candidates {
+		results[i] = c.result
+	}
+	return results, nil
+}
+
+// indexWrite updates every matching index after a write on this land.
+func (s *Soil) indexWrite(entity string, data []byte, revision uint64) {
+	s.indexMu.RLock()
+	defer s.indexMu.RUnlock()
+	for _, si := range s.indexes {
+		if si.match.MatchString(entity) {
+			si.put(entity, data, revision)
+		}
+	}
+}
+
+// indexDelete updates every matching index after a delete on this land.
+// revision is the last revision before the delete, so stale watch updates
+// for the entity are ignored until the delete itself is seen.
+func (s *Soil) indexDelete(entity string, revision uint64) {
+	s.indexMu.RLock()
+	defer s.indexMu.RUnlock()
+	for _, si := range s.indexes {
+		if si.match.MatchString(entity) {
+			si.remove(entity, revision)
+		}
+	}
+}
+
+// put indexes an entity value unless a newer revision is already known.
+func (si *soilIndex) put(entity string, data []byte, revision uint64) {
+	// Values that are not JSON objects can't be filtered, so queries skip
+	// them as a scan would
+	var doc map[string]interface{}
+	entry := &indexEntry{revision: revision, deleted: true}
+	if err := json.Unmarshal(data, &doc); err == nil {
+		entry = &indexEntry{revision: revision, values: make(map[string]interface{}, len(si.Fields))}
+		for _, field := range si.Fields {
+			// null is indexed like any other value, so it matches exactly
+			// what a scan matches
+			if v, ok := lookupField(doc, field); ok {
+				entry.values[field] = v
+			}
+		}
+	}
+
+	si.mu.Lock()
+	defer si.mu.Unlock()
+	if existing, ok := si.entries[entity]; ok && existing.revision >= revision {
+		return
+	}
+	si.entries[entity] = entry
+}
+
+// remove marks an entity deleted unless a newer revision is already known.
+func (si *soilIndex) remove(entity string, revision uint64) {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+	existing, ok := si.entries[entity]
+	if ok && existing.revision > revision {
+		return
+	}
+	si.entries[entity] = &indexEntry{revision: revision, deleted: true}
+}
+
+func (si *soilIndex) size() int {
+	si.mu.RLock()
+	defer si.mu.RUnlock()
+	n := 0
+	for _, entry := range si.entries {
+		if !entry.deleted {
+			n++
+		}
+	}
+	return n
+}
+
+// compileKeyGlob turns a key glob into a regexp. Soil keys are names like
+// "task-42" rather than dotted subjects, so '*' matches any run of
+// characters, dots included, and a trailing '>' matches any non-empty
+// suffix. This differs from NATS subjects, and from Soil.Watch, where '*'
+// matches exactly one token.
+func compileKeyGlob(pattern string) *regexp.Regexp {
+	if pattern == ">" || pattern == "*" {
+		return regexp.MustCompile(`^.+$`)
+	}
+	suffix := ""
+	if strings.HasSuffix(pattern, ">") {
+		pattern, suffix = strings.TrimSuffix(pattern, ">"), ".+"
+	}
+	quoted := strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*")
+	return regexp.MustCompile("^" + quoted + suffix + "$")
+}
+
+// fieldValue returns a (possibly dotted) field from a decoded JSON object.
+// Missing fields and JSON null are both nil.
+func fieldValue(doc map[string]interface{}, field string) interface{} {
+	v, _ := lookupField(doc, field)
+	return v
+}
+
+// lookupField returns a (possibly dotted) field from a decoded JSON object
+// and whether it is present, including as JSON null.
+func lookupField(doc map[string]interface{}, field string) (interface{}, bool) {
+	if doc == nil || field == "" {
+		return nil, false
+	}
+	var current interface{} = doc
+	for _, part := range strings.Split(field, ".") {
+		obj, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = obj[part]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// normalizeOp maps operator aliases to their canonical names.
+func normalizeOp(op string) (string, error) {
+	switch op {
+	case "", "eq", "=", "==":
+		return "eq", nil
+	case "ne", "!=", "<>":
+		return "ne", nil
+	case "gt", ">":
+		return "gt", nil
+	case "gte", ">=":
+		return "gte", nil
+	case "lt", "<":
+		return "lt", nil
+	case "lte", "<=":
+		return "lte", nil
+	}
+	return "", fmt.Errorf("unknown query operator %q (use eq, ne, gt, gte, lt, lte)", op)
+}
+
+// matchConditions reports whether the values satisfy every condition.
+func matchConditions(values map[string]interface{}, conds []QueryCondition) bool {
+	for _, cond := range conds {
+		var v interface{}
+		if values != nil {
+			if direct, ok := values[cond.Field]; ok {
+				v = direct
+			} else {
+				v = fieldValue(values, cond.Field)
+			}
+		}
+		cmp, comparable := compareValues(v, cond.Value)
+		switch cond.Op {
+		case "eq":
+			if !comparable || cmp != 0 {
+				return false
+			}
+		case "ne":
+			if comparable && cmp == 0 {
+				return false
+			}
+		case "gt":
+			if !comparable || cmp <= 0 {
+				return false
+			}
+		case "gte":
+			if !comparable || cmp < 0 {
+				return false
+			}
+		case "lt":
+			if !comparable || cmp >= 0 {
+				return false
+			}
+		case "lte":
+			if !comparable || cmp > 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// compareValues compares two JSON values of the same kind. Numbers compare
+// numerically, strings lexically (so RFC 3339 timestamps sort in time
+// order) and bools only for equality.
+func compareValues(a, b interface{}) (int, bool) {
+	switch av := a.(type) {
+	case float64:
+		bv, ok := toFloat(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case av < bv:
+			return -1, true
+		case av > bv:
+			return 1, true
+		}
+		return 0, true
+	case string:
+		bv, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(av, bv), true
+	case bool:
+		bv, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		// false sorts before true
+		switch {
+		case av == bv:
+			return 0, true
+		case bv:
+			return -1, true
+		}
+		return 1, true
+	case nil:
+		if b == nil {
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// lessBy orders two entities by the sort field, then by key.
+// Entities missing the sort field sort last.
+func lessBy(filter QueryFilter, keyA string, a interface{}, keyB string, b interface{}) bool {
+	if filter.Sort != "" {
+		switch {
+		case a == nil && b != nil:
+			return false
+		case a != nil && b == nil:
+			return true
+		}
+		if cmp, ok := compareValues(a, b); ok && cmp != 0 {
+			if filter.Desc {
+				return cmp > 0
+			}
+			return cmp < 0
+		}
+	}
+	if filter.Desc && filter.Sort == "" {
+		return keyA > keyB
+	}
+	return keyA < keyB
+}
+
+// hasAnyPrefix reports whether key starts with one of the prefixes, or
+// prefixes is empty.
+func hasAnyPrefix(prefixes []string, key string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
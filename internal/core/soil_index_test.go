@@ -0,0 +1,303 @@
+package core
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestCompileKeyGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		key     string
+		want    bool
+	}{
+		{"tasks/*", "tasks/1", true},
+		{"tasks/*", "contacts/1", false},
+		{"task-*", "task-42", true},
+		{"tasks.>", "tasks.a.b", true},
+		{"tasks.>", "tasks.", false},
+		{">", "anything", true},
+		{"tasks/1", "tasks/10", false},
+		{"tasks.*", "tasks.a.b", true}, // Unlike NATS, '*' spans dots
+	}
+	for _, tt := range tests {
+		if got := compileKeyGlob(tt.pattern).MatchString(tt.key); got != tt.want {
+			t.Errorf("pattern %q on %q = %v, want %v", tt.pattern, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestGlobCovers(t *testing.T) {
+	tests := []struct {
+		index, query string
+		want         bool
+	}{
+		{"tasks/*", "tasks/*", true},
+		{">", "tasks/*", true},
+		{"task-*", "task-1*", true},
+		{"task-*", "task-42", true},
+		{"task-*", "task->", true},
+		{"task->", "task-*", false}, // "task-" matches the query but not the index
+		{"task->", "task-1*", true},
+		{"task-*", "*", false},
+		{"task-*", "tasks/*", false},
+		{"task-*", "contact-1", false},
+		{"*-1", "task-1", false},
+	}
+	for _, tt := range tests {
+		if got := globCovers(tt.index, tt.query); got != tt.want {
+			t.Errorf("globCovers(%q, %q) = %v, want %v", tt.index, tt.query, got, tt.want)
+		}
+	}
+}
+
+func querySeed(t *testing.T) *Soil {
+	t.Helper()
+	js, nc := setupTestJetStream(t)
+	t.Cleanup(nc.Close)
+
+	js.DeleteKeyValue("SOIL")
+	soil, err := NewSoil(js)
+	if err != nil {
+		t.Fatalf("Failed to create soil: %v", err)
+	}
+
+	soil.Put("tasks/1", []byte(`{"customer_id":"acme","status":"open","priority":3}`))
+	soil.Put("tasks/2", []byte(`{"customer_id":"acme","status":"done","priority":1}`))
+	soil.Put("tasks/3", []byte(`{"customer_id":"globex","status":"open","priority":5}`))
+	soil.Put("tasks/4", []byte(`{"customer_id":"acme","status":"open","priority":8}`))
+	soil.Put("contacts/1", []byte(`{"customer_id":"acme","status":"open"}`))
+	return soil
+}
+
+func entities(results []QueryResult) []string {
+	keys := make([]string, len(results))
+	for i, r := range results {
+		keys[i] = r.Entity
+	}
+	return keys
+}
+
+func TestSoil_Query(t *testing.T) {
+	for _, indexed := range []bool{false, true} {
+		name := "scan"
+		if indexed {
+			name = "indexed"
+		}
+		t.Run(name, func(t *testing.T) {
+			soil := querySeed(t)
+			if indexed {
+				err := soil.DeclareIndex(SoilIndex{Name: "tasks", Pattern: "tasks/*", Fields: []string{"customer_id", "status", "priority"}})
+				if err != nil {
+					t.Fatalf("Failed to declare index: %v", err)
+				}
+				defer soil.DropIndex("tasks")
+			}
+
+			results, err := soil.Query("tasks/*", QueryFilter{
+				Where: []QueryCondition{Eq("customer_id", "acme"), Eq("status", "open")},
+				Sort:  "priority",
+				Desc:  true,
+			})
+			if err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+			if got := entities(results); len(got) != 2 || got[0] != "tasks/4" || got[1] != "tasks/1" {
+				t.Errorf("Expected [tasks/4 tasks/1], got %v", got)
+			}
+
+			// Range filter with limit
+			results, _ = soil.Query("tasks/*", QueryFilter{
+				Where: []QueryCondition{{Field: "priority", Op: ">=", Value: 3}},
+				Sort:  "priority",
+				Limit: 2,
+			})
+			if got := entities(results); len(got) != 2 || got[0] != "tasks/1" || got[1] != "tasks/3" {
+				t.Errorf("Expected [tasks/1 tasks/3], got %v", got)
+			}
+
+			// Writes are visible to the next query
+			soil.Put("tasks/5", []byte(`{"customer_id":"acme","status":"open","priority":9}`))
+			soil.Delete("tasks/4")
+			results, _ = soil.Query("tasks/*", QueryFilter{
+				Where: []QueryCondition{Eq("customer_id", "acme"), Eq("status", "open")},
+			})
+			if got := entities(results); len(got) != 2 || got[0] != "tasks/1" || got[1] != "tasks/5" {
+				t.Errorf("Expected [tasks/1 tasks/5], got %v", got)
+			}
+
+			// Prefixes apply before the limit
+			results, _ = soil.Query("*", QueryFilter{
+				Where:    []QueryCondition{Eq("status", "open")},
+				Limit:    1,
+				Prefixes: []string{"contacts/"},
+			})
+			if got := entities(results); len(got) != 1 || got[0] != "contacts/1" {
+				t.Errorf("Expected [contacts/1], got %v", got)
+			}
+
+			// Values that are not JSON objects can't match
+			soil.Put("tasks/6", []byte(`not json`))
+			results, _ = soil.Query("tasks/*", QueryFilter{
+				Where: []QueryCondition{{Field: "status", Op: "ne", Value: "done"}},
+			})
+			if got := entities(results); len(got) != 3 || got[0] != "tasks/1" || got[1] != "tasks/3" || got[2] != "tasks/5" {
+				t.Errorf("Expected [tasks/1 tasks/3 tasks/5], got %v", got)
+			}
+		})
+	}
+}
+
+func TestSoil_QueryNull(t *testing.T) {
+	soil := querySeed(t)
+	soil.Put("tasks/5", []byte(`{"customer_id":null,"status":"open","meta":{"owner":null}}`))
+	soil.Put("tasks/6", []byte(`{"status":"open","meta":{}}`))
+	soil.Put("tasks/7", []byte(`{"customer_id":"acme","status":null,"meta":{"owner":"bob"}}`))
+	if err := soil.DeclareIndex(SoilIndex{Name: "tasks", Pattern: "tasks/*", Fields: []string{"customer_id", "status", "meta.owner"}}); err != nil {
+		t.Fatalf("Failed to declare index: %v", err)
+	}
+	defer soil.DropIndex("tasks")
+
+	filters := []QueryFilter{
+		{Where: []QueryCondition{Eq("customer_id", nil)}},
+		{Where: []QueryCondition{{Field: "customer_id", Op: "ne", Value: nil}}},
+		{Where: []QueryCondition{Eq("meta.owner", nil)}},
+		{Where: []QueryCondition{{Field: "status", Op: "ne", Value: "open"}}},
+		{Where: []QueryCondition{Eq("status", "open")}, Sort: "customer_id"},
+		{Sort: "meta.owner", Desc: true},
+	}
+	for _, filter := range filters {
+		// The same query through the index and through a scan
+		indexed, err := soil.Query("tasks/*", filter)
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if soil.coveringIndex("tasks/*", filter) == nil {
+			t.Fatalf("Expected the index to cover %+v", filter)
+		}
+		scanned, err := soil.queryScan("tasks/*", filter)
+		if err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		if got, want := entities(indexed), entities(scanned); !reflect.DeepEqual(got, want) {
+			t.Errorf("%+v: index returned %v, scan %v", filter, got, want)
+		}
+	}
+
+	results, _ := soil.Query("tasks/*", QueryFilter{Where: []QueryCondition{Eq("customer_id", nil)}})
+	if got := entities(results); len(got) != 2 || got[0] != "tasks/5" || got[1] != "tasks/6" {
+		t.Errorf("Expected null and missing to match null, got %v", got)
+	}
+}
+
+func TestSoil_QueryBool(t *testing.T) {
+	soil := querySeed(t)
+	soil.Put("flags/1", []byte(`{"active":true}`))
+	soil.Put("flags/2", []byte(`{"active":false}`))
+	soil.Put("flags/3", []byte(`{"active":true}`))
+	if err := soil.DeclareIndex(SoilIndex{Name: "flags", Pattern: "flags/*", Fields: []string{"active"}}); err != nil {
+		t.Fatalf("Failed to declare index: %v", err)
+	}
+	defer soil.DropIndex("flags")
+
+	tests := []struct {
+		filter QueryFilter
+		want   []string
+	}{
+		{QueryFilter{Where: []QueryCondition{{Field: "active", Op: "gt", Value: false}}}, []string{"flags/1", "flags/3"}},
+		{QueryFilter{Where: []QueryCondition{{Field: "active", Op: "lt", Value: true}}}, []string{"flags/2"}},
+		{QueryFilter{Where: []QueryCondition{{Field: "active", Op: "lt", Value: false}}}, []string{}},
+		{QueryFilter{Sort: "active"}, []string{"flags/2", "flags/1", "flags/3"}},
+		{QueryFilter{Sort: "active", Desc: true}, []string{"flags/1", "flags/3", "flags/2"}},
+	}
+	for _, tt := range tests {
+		indexed, err := soil.Query("flags/*", tt.filter)
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		scanned, err := soil.queryScan("flags/*", tt.filter)
+		if err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		for name, results := range map[string][]QueryResult{"index": indexed, "scan": scanned} {
+			if got := entities(results); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s %+v: expected %v, got %v", name, tt.filter, tt.want, got)
+			}
+		}
+	}
+}
+
+func TestSoil_QueryCoveringIndex(t *testing.T) {
+	soil := querySeed(t)
+	if err := soil.DeclareIndex(SoilIndex{Name: "tasks", Pattern: "tasks/*", Fields: []string{"status"}}); err != nil {
+		t.Fatalf("Failed to declare index: %v", err)
+	}
+	defer soil.DropIndex("tasks")
+
+	// A narrower query is answered by the index, keeping only its keys
+	filter := QueryFilter{Where: []QueryCondition{Eq("status", "open")}}
+	if soil.coveringIndex("tasks/1*", filter) == nil {
+		t.Fatal("Expected tasks/* to cover tasks/1*")
+	}
+	soil.Put("tasks/10", []byte(`{"status":"open"}`))
+	results, err := soil.Query("tasks/1*", filter)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if got := entities(results); len(got) != 2 || got[0] != "tasks/1" || got[1] != "tasks/10" {
+		t.Errorf("Expected [tasks/1 tasks/10], got %v", got)
+	}
+}
+
+func TestSoil_QueryIndexLag(t *testing.T) {
+	soil := querySeed(t)
+	if err := soil.DeclareIndex(SoilIndex{Name: "tasks", Pattern: "tasks/*", Fields: []string{"status"}}); err != nil {
+		t.Fatalf("Failed to declare index: %v", err)
+	}
+	defer soil.DropIndex("tasks")
+
+	// An index that has not yet seen a write must not return the new value
+	soil.indexes["tasks"].put("tasks/2", []byte(`{"status":"open"}`), math.MaxUint64)
+	results, err := soil.Query("tasks/*", QueryFilter{Where: []QueryCondition{Eq("status", "open")}})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if got := entities(results); len(got) != 3 || got[0] != "tasks/1" || got[1] != "tasks/3" || got[2] != "tasks/4" {
+		t.Errorf("Expected [tasks/1 tasks/3 tasks/4], got %v", got)
+	}
+}
+
+func TestSoil_QueryInvalidOperator(t *testing.T) {
+	soil := querySeed(t)
+	if _, err := soil.Query("tasks/*", QueryFilter{Where: []QueryCondition{{Field: "status", Op: "like", Value: "o%"}}}); err == nil {
+		t.Error("Expected error for unknown operator")
+	}
+}
+
+func TestSoil_QueryLeavesFilterUnchanged(t *testing.T) {
+	soil := querySeed(t)
+	filter := QueryFilter{Where: []QueryCondition{{Field: "status", Op: "=", Value: "open"}}}
+	for i := 0; i < 2; i++ {
+		if _, err := soil.Query("tasks/*", filter); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+	}
+	if filter.Where[0].Op != "=" {
+		t.Errorf("Expected the caller's operator to stay \"=\", got %q", filter.Where[0].Op)
+	}
+}
+
+func TestSoil_DeclareIndexValidation(t *testing.T) {
+	soil := querySeed(t)
+	if err := soil.DeclareIndex(SoilIndex{Name: "x", Pattern: "tasks/*"}); err == nil {
+		t.Error("Expected error for index without fields")
+	}
+	if err := soil.DeclareIndex(SoilIndex{Name: "x", Pattern: "tasks/*", Fields: []string{"status"}}); err != nil {
+		t.Fatalf("Failed to declare index: %v", err)
+	}
+	defer soil.DropIndex("x")
+	if err := soil.DeclareIndex(SoilIndex{Name: "x", Pattern: "tasks/*", Fields: []string{"status"}}); err == nil {
+		t.Error("Expected error for duplicate index")
+	}
+}
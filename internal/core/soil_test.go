@@ -1,6 +1,7 @@
 package core
 
 import (
+	"errors"
 	"testing"
 	"time"
 )
@@ -201,8 +202,38 @@ func TestSoil_Delete(t *testing.T) {
 
 	// Try to delete again (should fail)
 	err = soil.Delete(entity)
-	if err == nil {
-		t.Error("Expected error when deleting non-existent entity")
+	if !errors.Is(err, ErrEntityNotFound) {
+		t.Errorf("Expected ErrEntityNotFound when deleting non-existent entity, got %v", err)
+	}
+}
+
+func TestSoil_DeleteExpected(t *testing.T) {
+	js, nc := setupTestJetStream(t)
+	defer nc.Close()
+
+	js.DeleteKeyValue("SOIL")
+	soil, err := NewSoil(js)
+	if err != nil {
+		t.Fatalf("Failed to create soil: %v", err)
+	}
+
+	revision, _ := soil.Put("test/delete", []byte(`{"v": 1}`))
+	soil.Put("test/delete", []byte(`{"v": 2}`))
+
+	// A delete at a stale revision leaves the newer write
+	if err := soil.DeleteExpected("test/delete", revision); err == nil {
+		t.Fatal("Expected a revision mismatch")
+	}
+	_, current, err := soil.Dig("test/delete")
+	if err != nil {
+		t.Fatalf("Expected entity to remain: %v", err)
+	}
+
+	if err := soil.DeleteExpected("test/delete", current); err != nil {
+		t.Fatalf("Failed to delete entity: %v", err)
+	}
+	if _, _, err := soil.Dig("test/delete"); !errors.Is(err, ErrEntityNotFound) {
+		t.Errorf("Expected entity to be deleted, got %v", err)
 	}
 }
 
@@ -219,12 +250,13 @@ func TestSoil_Watch(t *testing.T) {
 	changes := make(chan string, 10)
 
 	// Watch for changes - use simpler pattern
-	err = soil.Watch("test.watch.>", func(entity string, data []byte, revision uint64) {
-		changes <- entity
+	watch, err := soil.Watch("test.watch.>", func(event SoilEvent) {
+		changes <- event.Entity
 	})
 	if err != nil {
 		t.Fatalf("Failed to watch: %v", err)
 	}
+	defer watch.Stop()
 
 	// Give watcher time to be ready
 	time.Sleep(500 * time.Millisecond)
@@ -0,0 +1,240 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+
+	"github.com/nats-io/nats.go"
+)
+
+// SoilRecord is one line of a soil export. JSON values are kept in Data;
+// other values are stored base64-encoded in Raw.
+type SoilRecord struct {
+	Entity   string          `json:"entity"`
+	Revision uint64          `json:"revision"`
+	Data     json.RawMessage `json:"data,omitempty"`
+	Raw      []byte          `json:"raw,omitempty"`
+}
+
+// value returns the record's stored bytes.
+func (r SoilRecord) value() []byte {
+	if len(r.Data) > 0 {
+		return r.Data
+	}
+	return r.Raw
+}
+
+// SoilConflictPolicy decides what an import does with entities that
+// already exist in the target soil.
+type SoilConflictPolicy string
+
+const (
+	// SoilImportSkip keeps existing entities.
+	SoilImportSkip SoilConflictPolicy = "skip"
+	// SoilImportOverwrite replaces existing entities.
+	SoilImportOverwrite SoilConflictPolicy = "overwrite"
+	// SoilImportRevision replaces an existing entity only if its revision
+	// still equals the revision in the export, and reports a conflict otherwise.
+	SoilImportRevision SoilConflictPolicy = "revision"
+)
+
+// ParseSoilConflictPolicy parses a conflict policy name. Empty means skip.
+func ParseSoilConflictPolicy(s string) (SoilConflictPolicy, error) {
+	switch SoilConflictPolicy(s) {
+	case "":
+		return SoilImportSkip, nil
+	case SoilImportSkip, SoilImportOverwrite, SoilImportRevision:
+		return SoilConflictPolicy(s), nil
+	}
+	return "", fmt.Errorf("unknown conflict policy %q (use skip, overwrite or revision)", s)
+}
+
+// SoilImportOptions configures Import.
+type SoilImportOptions struct {
+	// Policy handles entities that already exist. Default: skip
+	Policy SoilConflictPolicy
+
+	// Humus, if set, routes each write through humus as a create or update
+	// compost, so the audit trail records the import and the decomposers
+	// apply it. Revision checks are made when the compost is added.
+	Humus *Humus
+
+	// NimName is the compost author when importing through humus.
+	// Default: "import"
+	NimName string
+}
+
+// SoilImportResult summarizes an import.
+type SoilImportResult struct {
+	Imported  int      `json:"imported"`
+	Skipped   int      `json:"skipped"`
+	Conflicts []string `json:"conflicts,omitempty"` // Entities rejected by the revision check
+}
+
+// Export writes the entities matching a key glob as JSON lines, sorted
+// by key, and returns the number written. An empty pattern exports everything.
+func (s *Soil) Export(w io.Writer, pattern string) (int, error) {
+	keys, err := s.kv.Keys()
+	if err != nil {
+		if err == nats.ErrNoKeysFound {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to get keys: %w", err)
+	}
+	if pattern == "" {
+		pattern = ">"
+	}
+	match := compileKeyGlob(pattern)
+	sort.Strings(keys)
+
+	enc := json.NewEncoder(w)
+	count := 0
+	for _, key := range keys {
+		if !match.MatchString(key) {
+			continue
+		}
+		entry, err := s.kv.Get(key)
+		if err != nil {
+			if err == nats.ErrKeyNotFound {
+				continue // Deleted while exporting
+			}
+			return count, fmt.Errorf("failed to get entity %s: %w", key, err)
+		}
+
+		record := SoilRecord{Entity: key, Revision: entry.Revision()}
+		if json.Valid(entry.Value()) {
+			record.Data = entry.Value()
+		} else {
+			record.Raw = entry.Value()
+		}
+		if err := enc.Encode(record); err != nil {
+			return count, fmt.Errorf("failed to write entity %s: %w", key, err)
+		}
+		count++
+	}
+
+	log.Printf("[Soil] Exported %d entities matching %s", count, pattern)
+	return count, nil
+}
+
+// ErrRawThroughHumus is returned when an import through humus contains
+// non-JSON values, which composts can't carry.
+var ErrRawThroughHumus = errors.New("non-JSON (raw) values can't be imported through humus")
+
+// Import reads JSON lines written by Export into soil. New entities are
+// always created; existing ones are handled by the conflict policy.
+// All records are read and checked before anything is written.
+func (s *Soil) Import(r io.Reader, opts SoilImportOptions) (*SoilImportResult, error) {
+	policy, err := ParseSoilConflictPolicy(string(opts.Policy))
+	if err != nil {
+		return nil, err
+	}
+	if opts.NimName == "" {
+		opts.NimName = "import"
+	}
+
+	var records []SoilRecord
+	var lines []int
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var record SoilRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("line %d: invalid record: %w", line, err)
+		}
+		if record.Entity == "" || len(record.value()) == 0 {
+			return nil, fmt.Errorf("line %d: record requires entity and data", line)
+		}
+		if opts.Humus != nil && len(record.Data) == 0 {
+			return nil, fmt.Errorf("line %d: entity %s: %w", line, record.Entity, ErrRawThroughHumus)
+		}
+		records = append(records, record)
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read import: %w", err)
+	}
+
+	result := &SoilImportResult{}
+	for i, record := range records {
+		imported, err := s.importRecord(record, policy, opts)
+		if err != nil {
+			if isRevisionConflict(err) {
+				result.Conflicts = append(result.Conflicts, record.Entity)
+				continue
+			}
+			return result, fmt.Errorf("line %d: %w", lines[i], err)
+		}
+		if imported {
+			result.Imported++
+		} else {
+			result.Skipped++
+		}
+	}
+
+	log.Printf("[Soil] Imported %d entities (%d skipped, %d conflicts)",
+		result.Imported, result.Skipped, len(result.Conflicts))
+	return result, nil
+}
+
+// importRecord writes one record according to the policy and reports
+// whether it was written.
+func (s *Soil) importRecord(record SoilRecord, policy SoilConflictPolicy, opts SoilImportOptions) (bool, error) {
+	var current uint64
+	entry, err := s.kv.Get(record.Entity)
+	switch {
+	case err == nil:
+		current = entry.Revision()
+	case err != nats.ErrKeyNotFound:
+		return false, fmt.Errorf("failed to get entity %s: %w", record.Entity, err)
+	}
+
+	if current > 0 {
+		switch policy {
+		case SoilImportSkip:
+			return false, nil
+		case SoilImportRevision:
+			if current != record.Revision {
+				return false, nats.ErrKeyExists
+			}
+		}
+	}
+
+	if opts.Humus != nil {
+		action := "create"
+		if current > 0 {
+			action = "update"
+		}
+		if _, err := opts.Humus.Add(opts.NimName, record.Entity, action, record.value()); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	switch {
+	case current == 0:
+		err = s.Bury(record.Entity, record.value(), 0)
+	case policy == SoilImportRevision:
+		err = s.Bury(record.Entity, record.value(), current)
+	default:
+		_, err = s.Put(record.Entity, record.value())
+	}
+	if err != nil {
+		if policy == SoilImportSkip && current == 0 && isRevisionConflict(err) {
+			return false, nil // Created concurrently
+		}
+		return false, err
+	}
+	return true, nil
+}
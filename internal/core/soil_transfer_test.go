@@ -0,0 +1,141 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSoil_ExportImport(t *testing.T) {
+	js, nc := setupTestJetStream(t)
+	defer nc.Close()
+
+	js.DeleteKeyValue("SOIL")
+	soil, err := NewSoil(js)
+	if err != nil {
+		t.Fatalf("Failed to create soil: %v", err)
+	}
+
+	soil.Put("task-2", []byte(`{"status":"done"}`))
+	soil.Put("task-1", []byte(`{"status":"open"}`))
+	soil.Put("contact-1", []byte(`{"name":"ada"}`))
+	soil.Put("task-blob", []byte("not json"))
+
+	var dump bytes.Buffer
+	n, err := soil.Export(&dump, "task-*")
+	if err != nil || n != 3 {
+		t.Fatalf("Expected 3 exported entities, got %d (%v)", n, err)
+	}
+	lines := strings.Split(strings.TrimSpace(dump.String()), "\n")
+	if !strings.Contains(lines[0], `"entity":"task-1"`) || !strings.Contains(lines[2], `"raw":`) {
+		t.Errorf("Expected sorted records with raw non-JSON values, got:\n%s", dump.String())
+	}
+
+	// Import into an empty bucket restores every entity
+	js.DeleteKeyValue("SOIL_IMPORT_TEST")
+	defer js.DeleteKeyValue("SOIL_IMPORT_TEST")
+	target, err := NewSoilWithConfig(js, SoilConfig{Bucket: "SOIL_IMPORT_TEST"})
+	if err != nil {
+		t.Fatalf("Failed to create target soil: %v", err)
+	}
+	result, err := target.Import(bytes.NewReader(dump.Bytes()), SoilImportOptions{})
+	if err != nil || result.Imported != 3 {
+		t.Fatalf("Expected 3 imported, got %+v (%v)", result, err)
+	}
+	if data, _, _ := target.Dig("task-blob"); string(data) != "not json" {
+		t.Errorf("Expected raw value to round-trip, got %q", data)
+	}
+
+	// Skip keeps existing entities
+	target.Put("task-1", []byte(`{"status":"changed"}`))
+	result, _ = target.Import(bytes.NewReader(dump.Bytes()), SoilImportOptions{Policy: SoilImportSkip})
+	if result.Imported != 0 || result.Skipped != 3 {
+		t.Errorf("Expected all skipped, got %+v", result)
+	}
+
+	// Revision-checked imports only replace entities unchanged since the export
+	dump.Reset()
+	target.Export(&dump, "task-*")
+	target.Put("task-2", []byte(`{"status":"reopened"}`))
+	result, _ = target.Import(bytes.NewReader(dump.Bytes()), SoilImportOptions{Policy: SoilImportRevision})
+	if result.Imported != 2 || len(result.Conflicts) != 1 || result.Conflicts[0] != "task-2" {
+		t.Errorf("Expected task-2 conflict, got %+v", result)
+	}
+
+	// Overwrite replaces everything
+	result, _ = target.Import(bytes.NewReader(dump.Bytes()), SoilImportOptions{Policy: SoilImportOverwrite})
+	if result.Imported != 3 {
+		t.Errorf("Expected 3 overwritten, got %+v", result)
+	}
+	if data, _, _ := target.Dig("task-2"); string(data) != `{"status":"done"}` {
+		t.Errorf("Expected overwritten task-2, got %s", data)
+	}
+
+	if _, err := target.Import(strings.NewReader("{bad"), SoilImportOptions{}); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("Expected line error, got %v", err)
+	}
+	if _, err := target.Import(strings.NewReader(""), SoilImportOptions{Policy: "merge"}); err == nil {
+		t.Error("Expected unknown policy error")
+	}
+}
+
+func TestSoil_ImportThroughHumus(t *testing.T) {
+	js, nc := setupTestJetStream(t)
+	defer nc.Close()
+
+	js.DeleteKeyValue("SOIL")
+	js.DeleteStream("HUMUS")
+	soil, err := NewSoil(js)
+	if err != nil {
+		t.Fatalf("Failed to create soil: %v", err)
+	}
+	humus, err := NewHumus(js)
+	if err != nil {
+		t.Fatalf("Failed to create humus: %v", err)
+	}
+
+	soil.Put("task-1", []byte(`{"status":"open"}`))
+	dump := `{"entity":"task-1","revision":1,"data":{"status":"done"}}
+{"entity":"task-2","revision":4,"data":{"status":"new"}}
+`
+	result, err := soil.Import(strings.NewReader(dump), SoilImportOptions{Policy: SoilImportOverwrite, Humus: humus})
+	if err != nil || result.Imported != 2 {
+		t.Fatalf("Expected 2 imported, got %+v (%v)", result, err)
+	}
+
+	// Writes are composted for the decomposers, not applied directly
+	info, _ := humus.StreamInfo()
+	if info.State.Msgs != 2 {
+		t.Errorf("Expected 2 composts, got %d", info.State.Msgs)
+	}
+	if data, _, _ := soil.Dig("task-1"); string(data) != `{"status":"open"}` {
+		t.Errorf("Expected soil unchanged until decomposed, got %s", data)
+	}
+
+	var composts []Compost
+	for seq := uint64(1); seq <= 2; seq++ {
+		msg, err := js.GetMsg("HUMUS", seq)
+		if err != nil {
+			t.Fatalf("Failed to read compost %d: %v", seq, err)
+		}
+		var c Compost
+		json.Unmarshal(msg.Data, &c)
+		composts = append(composts, c)
+	}
+	if composts[0].NimName != "import" || composts[0].Action != "update" || composts[1].Action != "create" {
+		t.Errorf("Unexpected composts: %+v", composts)
+	}
+
+	// Raw values can't be composted: the whole import is rejected before any write
+	dump = `{"entity":"task-3","data":{"status":"new"}}
+{"entity":"blob-1","raw":"AAEC"}
+`
+	if _, err := soil.Import(strings.NewReader(dump), SoilImportOptions{Humus: humus}); !errors.Is(err, ErrRawThroughHumus) {
+		t.Errorf("Expected ErrRawThroughHumus, got %v", err)
+	}
+	if info, _ := humus.StreamInfo(); info.State.Msgs != 2 {
+		t.Errorf("Expected no new composts, got %d", info.State.Msgs)
+	}
+}
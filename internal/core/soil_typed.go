@@ -0,0 +1,147 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/nats-io/nats.go"
+)
+
+// DefaultUpdateAttempts is how often TypedSoil.Update retries on conflict.
+const DefaultUpdateAttempts = 10
+
+// ErrEntityNotFound is returned by Soil.Dig, Soil.Delete and TypedSoil for
+// missing entities.
+var ErrEntityNotFound = errors.New("entity not found")
+
+// ErrRevisionConflict is returned when an entity isn't at the expected revision.
+var ErrRevisionConflict = errors.New("revision conflict")
+
+// ErrInvalidJSON is returned when entity data must be JSON and isn't.
+var ErrInvalidJSON = errors.New("data must be valid JSON")
+
+// TypedSoil reads and writes entities of type T as JSON in a soil bucket.
+//
+//	tasks := core.Typed[Task](soil)
+//	task, err := tasks.Update("tasks/42", func(t *Task) error {
+//		t.Status = "done"
+//		return nil
+//	})
+type TypedSoil[T any] struct {
+	soil     *Soil
+	attempts int
+}
+
+// TypedEntity is an entity returned by TypedSoil.List.
+type TypedEntity[T any] struct {
+	Entity   string
+	Value    T
+	Revision uint64
+}
+
+// Typed returns a typed accessor for a soil bucket.
+func Typed[T any](bucket *Soil) *TypedSoil[T] {
+	return &TypedSoil[T]{soil: bucket, attempts: DefaultUpdateAttempts}
+}
+
+// Get reads and decodes an entity. It returns ErrEntityNotFound if the
+// entity doesn't exist.
+func (t *TypedSoil[T]) Get(entity string) (T, uint64, error) {
+	var value T
+	if entity == "" {
+		return value, 0, fmt.Errorf("entity key cannot be empty")
+	}
+
+	entry, err := t.soil.kv.Get(entity)
+	if err != nil {
+		if err == nats.ErrKeyNotFound {
+			return value, 0, fmt.Errorf("%w: %s", ErrEntityNotFound, entity)
+		}
+		return value, 0, fmt.Errorf("failed to dig entity %s: %w", entity, err)
+	}
+
+	if err := json.Unmarshal(entry.Value(), &value); err != nil {
+		return value, 0, fmt.Errorf("failed to decode entity %s: %w", entity, err)
+	}
+	return value, entry.Revision(), nil
+}
+
+// Put encodes and writes an entity without checking the revision.
+func (t *TypedSoil[T]) Put(entity string, value T) (uint64, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode entity %s: %w", entity, err)
+	}
+	return t.soil.Put(entity, data)
+}
+
+// Update reads an entity, applies fn and writes it back with optimistic
+// locking. A missing entity starts from the zero value and is created.
+// When another writer gets there first, fn is called again on the new value.
+// If fn returns an error, nothing is written and that error is returned.
+func (t *TypedSoil[T]) Update(entity string, fn func(*T) error) (T, error) {
+	var value T
+	for attempt := 0; attempt < t.attempts; attempt++ {
+		current, revision, err := t.Get(entity)
+		if err != nil && !errors.Is(err, ErrEntityNotFound) {
+			return value, err
+		}
+		if err := fn(&current); err != nil {
+			return value, err
+		}
+
+		data, err := json.Marshal(current)
+		if err != nil {
+			return value, fmt.Errorf("failed to encode entity %s: %w", entity, err)
+		}
+		err = t.soil.Bury(entity, data, revision)
+		if err == nil {
+			return current, nil
+		}
+		if !isRevisionConflict(err) {
+			return value, err
+		}
+	}
+	return value, fmt.Errorf("failed to update entity %s: still conflicting after %d attempts", entity, t.attempts)
+}
+
+// Delete removes an entity.
+func (t *TypedSoil[T]) Delete(entity string) error {
+	return t.soil.Delete(entity)
+}
+
+// List returns the entities whose keys match pattern, sorted by key.
+// Patterns use '*' for any run of characters and a trailing '>' for any
+// suffix; an empty pattern lists every entity.
+func (t *TypedSoil[T]) List(pattern string) ([]TypedEntity[T], error) {
+	keys, err := t.soil.kv.Keys()
+	if err != nil {
+		if err == nats.ErrNoKeysFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get keys: %w", err)
+	}
+	if pattern == "" {
+		pattern = ">"
+	}
+	match := compileKeyGlob(pattern)
+	sort.Strings(keys)
+
+	var entities []TypedEntity[T]
+	for _, key := range keys {
+		if !match.MatchString(key) {
+			continue
+		}
+		value, revision, err := t.Get(key)
+		if err != nil {
+			if errors.Is(err, ErrEntityNotFound) {
+				continue // Deleted while listing
+			}
+			return nil, err
+		}
+		entities = append(entities, TypedEntity[T]{Entity: key, Value: value, Revision: revision})
+	}
+	return entities, nil
+}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+type testTask struct {
+	Status string `json:"status"`
+	Count  int    `json:"count"`
+}
+
+func TestSoilBucket(t *testing.T) {
+	if got := SoilBucket("tasks"); got != "SOIL_TASKS" {
+		t.Errorf("SoilBucket(tasks) = %q, want SOIL_TASKS", got)
+	}
+	if got := SoilBucket("user-sessions"); got != "SOIL_USER_SESSIONS" {
+		t.Errorf("SoilBucket(user-sessions) = %q, want SOIL_USER_SESSIONS", got)
+	}
+}
+
+func TestNewSoilWithConfig(t *testing.T) {
+	js, nc := setupTestJetStream(t)
+	defer nc.Close()
+
+	bucket := SoilBucket("config-test")
+	js.DeleteKeyValue(bucket)
+	defer js.DeleteKeyValue(bucket)
+
+	soil, err := NewSoilWithConfig(js, SoilConfig{Bucket: bucket, History: 3, TTL: time.Hour, Storage: nats.MemoryStorage})
+	if err != nil {
+		t.Fatalf("Failed to create soil: %v", err)
+	}
+	if soil.Bucket() != bucket {
+		t.Errorf("Expected bucket %s, got %s", bucket, soil.Bucket())
+	}
+
+	status, _ := soil.Status()
+	if status.History() != 3 || status.TTL() != time.Hour {
+		t.Errorf("Expected history 3 and ttl 1h, got %d and %v", status.History(), status.TTL())
+	}
+
+	// Reopening with a new history updates only the history
+	soil, err = NewSoilWithConfig(js, SoilConfig{Bucket: bucket, History: 5, Storage: nats.MemoryStorage})
+	if err != nil {
+		t.Fatalf("Failed to reopen soil: %v", err)
+	}
+	status, _ = soil.Status()
+	if status.History() != 5 || status.TTL() != time.Hour {
+		t.Errorf("Expected history 5 and ttl 1h, got %d and %v", status.History(), status.TTL())
+	}
+
+	// Reopening without a config leaves the bucket as it is
+	soil, err = NewSoilWithConfig(js, SoilConfig{Bucket: bucket})
+	if err != nil {
+		t.Fatalf("Failed to reopen soil: %v", err)
+	}
+	status, _ = soil.Status()
+	if status.History() != 5 || status.TTL() != time.Hour {
+		t.Errorf("Expected history 5 and ttl 1h to be kept, got %d and %v", status.History(), status.TTL())
+	}
+
+	// A new TTL replaces the old one
+	soil, _ = NewSoilWithConfig(js, SoilConfig{Bucket: bucket, TTL: 2 * time.Hour})
+	status, _ = soil.Status()
+	if status.History() != 5 || status.TTL() != 2*time.Hour {
+		t.Errorf("Expected history 5 and ttl 2h, got %d and %v", status.History(), status.TTL())
+	}
+}
+
+func TestTypedSoil(t *testing.T) {
+	js, nc := setupTestJetStream(t)
+	defer nc.Close()
+
+	js.DeleteKeyValue("SOIL")
+	soil, err := NewSoil(js)
+	if err != nil {
+		t.Fatalf("Failed to create soil: %v", err)
+	}
+	tasks := Typed[testTask](soil)
+
+	if _, _, err := tasks.Get("tasks/1"); !errors.Is(err, ErrEntityNotFound) {
+		t.Errorf("Expected ErrEntityNotFound, got %v", err)
+	}
+
+	// Update creates missing entities from the zero value
+	task, err := tasks.Update("tasks/1", func(tt *testTask) error {
+		tt.Status = "open"
+		return nil
+	})
+	if err != nil || task.Status != "open" {
+		t.Fatalf("Update failed: %v (%+v)", err, task)
+	}
+	tasks.Put("tasks/2", testTask{Status: "done"})
+	soil.Put("contacts/1", []byte(`{}`))
+
+	// An error from fn aborts the update
+	abort := errors.New("abort")
+	if _, err := tasks.Update("tasks/1", func(*testTask) error { return abort }); !errors.Is(err, abort) {
+		t.Errorf("Expected abort error, got %v", err)
+	}
+
+	// Concurrent updates retry on conflict, so none are lost
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := tasks.Update("tasks/1", func(tt *testTask) error {
+				tt.Count++
+				return nil
+			}); err != nil {
+				t.Errorf("Concurrent update failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	task, _, _ = tasks.Get("tasks/1")
+	if task.Count != 5 || task.Status != "open" {
+		t.Errorf("Expected count 5 and status open, got %+v", task)
+	}
+
+	list, err := tasks.List("tasks/*")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(list) != 2 || list[0].Entity != "tasks/1" || list[1].Value.Status != "done" {
+		t.Errorf("Unexpected list: %+v", list)
+	}
+
+	if err := tasks.Delete("tasks/2"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := tasks.Delete("tasks/2"); !errors.Is(err, ErrEntityNotFound) {
+		t.Errorf("Expected ErrEntityNotFound deleting a missing entity, got %v", err)
+	}
+}
@@ -0,0 +1,388 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// SoilOp is the kind of change in a SoilEvent.
+type SoilOp string
+
+const (
+	SoilPut    SoilOp = "put"
+	SoilDelete SoilOp = "delete"
+	SoilPurge  SoilOp = "purge" // The entity and its history were removed
+)
+
+// SoilEvent is a change to a soil entity delivered to a watch handler.
+// Data is nil for deletes and purges.
+type SoilEvent struct {
+	Entity   string
+	Data     []byte
+	Revision uint64
+	Op       SoilOp
+	Time     time.Time
+}
+
+// SoilWatch is a running soil watch returned by Watch and WatchFrom.
+type SoilWatch struct {
+	watcher  nats.KeyWatcher
+	done     chan struct{}
+	revision atomic.Uint64
+	stopOnce sync.Once
+	stopErr  error
+}
+
+// Stop ends the watch and waits for the handler to return, so no events
+// are delivered after Stop returns. It must not be called from the handler.
+func (w *SoilWatch) Stop() error {
+	w.stopOnce.Do(func() {
+		w.stopErr = w.watcher.Stop()
+		<-w.done
+	})
+	return w.stopErr
+}
+
+// Revision returns the revision of the last event delivered. Passing it to
+// WatchFrom resumes the watch after that event.
+func (w *SoilWatch) Revision() uint64 {
+	return w.revision.Load()
+}
+
+// Watch observes changes to entities matching a pattern.
+// The pattern can include wildcards (* for one token, > for multi-level).
+// The handler is called with the current value of each matching entity,
+// then with every put, delete and purge. Watch returns once the current
+// values have been delivered.
+func (s *Soil) Watch(pattern string, handler func(SoilEvent)) (*SoilWatch, error) {
+	return s.watch(pattern, 0, false, handler)
+}
+
+// WatchFrom observes changes to entities matching a pattern that happened
+// after the given revision, including deletes and purges, then follows
+// live changes. Only revisions still kept in the bucket history are
+// replayed. WatchFrom returns once the missed changes have been delivered.
+func (s *Soil) WatchFrom(pattern string, revision uint64, handler func(SoilEvent)) (*SoilWatch, error) {
+	return s.watch(pattern, revision, true, handler)
+}
+
+// WatchAll watches all changes to the bucket.
+func (s *Soil) WatchAll(handler func(SoilEvent)) (*SoilWatch, error) {
+	return s.Watch(">", handler)
+}
+
+func (s *Soil) watch(pattern string, from uint64, history bool, handler func(SoilEvent)) (*SoilWatch, error) {
+	if pattern == "" {
+		pattern = ">"
+	}
+	if handler == nil {
+		return nil, fmt.Errorf("handler is required")
+	}
+
+	var opts []nats.WatchOpt
+	if history {
+		opts = append(opts, nats.IncludeHistory())
+	}
+	watcher, err := s.kv.Watch(pattern, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch pattern %s: %w", pattern, err)
+	}
+
+	w := &SoilWatch{watcher: watcher, done: make(chan struct{})}
+	w.revision.Store(from)
+	ready := make(chan struct{})
+
+	go func() {
+		defer close(w.done)
+		initial := true
+		for entry := range watcher.Updates() {
+			if entry == nil {
+				if initial {
+					initial = false
+					close(ready)
+				}
+				continue
+			}
+			if entry.Revision() <= from {
+				continue
+			}
+
+			event := SoilEvent{
+				Entity:   entry.Key(),
+				Revision: entry.Revision(),
+				Op:       SoilPut,
+				Time:     entry.Created(),
+			}
+			switch entry.Operation() {
+			case nats.KeyValueDelete:
+				event.Op = SoilDelete
+			case nats.KeyValuePurge:
+				event.Op = SoilPurge
+			default:
+				event.Data = entry.Value()
+			}
+
+			// Entities already deleted before a plain Watch started have no current value
+			if initial && !history && event.Op != SoilPut {
+				continue
+			}
+
+			handler(event)
+			w.revision.Store(event.Revision)
+		}
+		if initial {
+			close(ready)
+		}
+	}()
+
+	<-ready
+	log.Printf("[Soil] Watching pattern: %s", pattern)
+	return w, nil
+}
+
+// SoilChanged is the data of a soil.changed.<entity> leaf.
+type SoilChanged struct {
+	Entity   string          `json:"entity"`
+	Op       SoilOp          `json:"op"`
+	Revision uint64          `json:"revision"`
+	Data     json.RawMessage `json:"data,omitempty"` // New state, omitted for deletes and purges
+}
+
+// SoilChangedSubject returns the leaf subject for a changed entity.
+func SoilChangedSubject(entity string) string {
+	return "soil.changed." + entity
+}
+
+// SoilBridgeConfig configures a SoilBridge.
+type SoilBridgeConfig struct {
+	// Patterns lists the entities to republish as key globs, like soil
+	// indexes, e.g. "task-*".
+	// Default: [">"]
+	Patterns []string
+
+	// Consumer is the durable consumer name, shared by every land so each
+	// change is republished once.
+	// Default: "soil-bridge"
+	Consumer string
+}
+
+// SoilBridge republishes soil changes as soil.changed.<entity> leaves, so
+// TreeHouses and Nims can react to state changes. It reads the soil
+// bucket's stream with a durable consumer, so a restarted bridge resumes
+// where it stopped and changes are not lost while it is down.
+type SoilBridge struct {
+	js     nats.JetStreamContext
+	soil   *Soil
+	wind   *Wind
+	stream string
+	config SoilBridgeConfig
+	match  []*regexp.Regexp
+
+	mu        sync.Mutex
+	sub       *nats.Subscription
+	done      chan struct{}
+	wg        sync.WaitGroup
+	published atomic.Uint64
+}
+
+// NewSoilBridge creates a bridge from soil to the wind.
+func NewSoilBridge(js nats.JetStreamContext, soil *Soil, wind *Wind, cfg SoilBridgeConfig) (*SoilBridge, error) {
+	if soil == nil {
+		return nil, fmt.Errorf("soil is required")
+	}
+	if wind == nil {
+		return nil, fmt.Errorf("wind is required")
+	}
+	if len(cfg.Patterns) == 0 {
+		cfg.Patterns = []string{">"}
+	}
+	if cfg.Consumer == "" {
+		cfg.Consumer = "soil-bridge"
+	}
+
+	match := make([]*regexp.Regexp, len(cfg.Patterns))
+	for i, pattern := range cfg.Patterns {
+		match[i] = compileKeyGlob(pattern)
+	}
+
+	return &SoilBridge{
+		js:     js,
+		soil:   soil,
+		wind:   wind,
+		stream: "KV_" + soil.Bucket(),
+		config: cfg,
+		match:  match,
+	}, nil
+}
+
+// RunSoilBridge creates and starts a soil bridge.
+func RunSoilBridge(js nats.JetStreamContext, soil *Soil, wind *Wind, cfg SoilBridgeConfig) (*SoilBridge, error) {
+	bridge, err := NewSoilBridge(js, soil, wind, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := bridge.Start(); err != nil {
+		return nil, err
+	}
+	return bridge, nil
+}
+
+// Start begins republishing changes. A new bridge starts with the next
+// change; an existing consumer resumes where it stopped.
+func (b *SoilBridge) Start() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.sub != nil {
+		return fmt.Errorf("soil bridge already started")
+	}
+
+	if err := b.ensureConsumer(); err != nil {
+		return err
+	}
+
+	sub, err := b.js.PullSubscribe(b.filter(), b.config.Consumer, nats.Bind(b.stream, b.config.Consumer))
+	if err != nil {
+		return fmt.Errorf("failed to bind soil bridge consumer: %w", err)
+	}
+
+	b.sub = sub
+	b.done = make(chan struct{})
+	b.wg.Add(1)
+	go b.run(sub, b.done)
+
+	log.Printf("[SoilBridge] Republishing soil changes for %s", strings.Join(b.config.Patterns, ", "))
+	return nil
+}
+
+// Stop stops republishing. The consumer keeps its position.
+func (b *SoilBridge) Stop() {
+	b.mu.Lock()
+	if b.sub == nil {
+		b.mu.Unlock()
+		return
+	}
+	close(b.done)
+	sub := b.sub
+	b.sub = nil
+	b.mu.Unlock()
+
+	b.wg.Wait()
+	sub.Unsubscribe()
+	log.Printf("[SoilBridge] Stopped")
+}
+
+// Published returns the number of leaves dropped by this bridge.
+func (b *SoilBridge) Published() uint64 {
+	return b.published.Load()
+}
+
+// ensureConsumer creates the durable consumer if it doesn't exist.
+// Patterns are matched by the bridge, so the consumer sees every change.
+func (b *SoilBridge) ensureConsumer() error {
+	_, err := b.js.ConsumerInfo(b.stream, b.config.Consumer)
+	if err == nil {
+		return nil
+	}
+	if err != nats.ErrConsumerNotFound {
+		return fmt.Errorf("failed to get consumer %s: %w", b.config.Consumer, err)
+	}
+
+	_, err = b.js.AddConsumer(b.stream, &nats.ConsumerConfig{
+		Durable:       b.config.Consumer,
+		FilterSubject: b.filter(),
+		DeliverPolicy: nats.DeliverNewPolicy,
+		AckPolicy:     nats.AckExplicitPolicy,
+		AckWait:       30 * time.Second,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create consumer %s: %w", b.config.Consumer, err)
+	}
+	return nil
+}
+
+// filter is the stream subject holding every soil change.
+func (b *SoilBridge) filter() string {
+	return "$KV." + b.soil.Bucket() + ".>"
+}
+
+// matches reports whether an entity matches one of the bridge patterns.
+func (b *SoilBridge) matches(entity string) bool {
+	for _, re := range b.match {
+		if re.MatchString(entity) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *SoilBridge) run(sub *nats.Subscription, done chan struct{}) {
+	defer b.wg.Done()
+
+	prefix := "$KV." + b.soil.Bucket() + "."
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		msgs, err := sub.Fetch(100, nats.MaxWait(time.Second))
+		if err != nil {
+			if err != nats.ErrTimeout {
+				log.Printf("[SoilBridge] Fetch error: %v", err)
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+
+		for _, msg := range msgs {
+			changed := SoilChanged{
+				Entity: strings.TrimPrefix(msg.Subject, prefix),
+				Op:     SoilPut,
+			}
+			if !b.matches(changed.Entity) {
+				msg.Ack()
+				continue
+			}
+			if meta, err := msg.Metadata(); err == nil {
+				changed.Revision = meta.Sequence.Stream
+			}
+			switch msg.Header.Get("KV-Operation") {
+			case "DEL":
+				changed.Op = SoilDelete
+			case "PURGE":
+				changed.Op = SoilPurge
+			default:
+				changed.Data = jsonOrString(msg.Data)
+			}
+
+			if err := b.publish(changed); err != nil {
+				log.Printf("[SoilBridge] Failed to republish %s: %v", changed.Entity, err)
+				msg.Nak()
+				continue
+			}
+			msg.Ack()
+		}
+	}
+}
+
+func (b *SoilBridge) publish(changed SoilChanged) error {
+	data, err := json.Marshal(changed)
+	if err != nil {
+		return fmt.Errorf("failed to marshal change: %w", err)
+	}
+	if err := b.wind.Drop(*NewLeaf(SoilChangedSubject(changed.Entity), data, "soil")); err != nil {
+		return err
+	}
+	b.published.Add(1)
+	return nil
+}
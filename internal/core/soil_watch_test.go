@@ -0,0 +1,179 @@
+package core
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+// eventLog collects watch events for assertions.
+type eventLog struct {
+	mu     sync.Mutex
+	events []SoilEvent
+}
+
+func (l *eventLog) add(e SoilEvent) {
+	l.mu.Lock()
+	l.events = append(l.events, e)
+	l.mu.Unlock()
+}
+
+func (l *eventLog) get() []SoilEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]SoilEvent(nil), l.events...)
+}
+
+func TestSoil_WatchDeletesAndStop(t *testing.T) {
+	js, nc := setupTestJetStream(t)
+	defer nc.Close()
+
+	js.DeleteKeyValue("SOIL")
+	soil, err := NewSoil(js)
+	if err != nil {
+		t.Fatalf("Failed to create soil: %v", err)
+	}
+
+	soil.Put("tasks.1", []byte(`{"n":1}`))
+	soil.Put("tasks.gone", []byte(`{}`))
+	soil.Delete("tasks.gone")
+
+	var log eventLog
+	watch, err := soil.Watch("tasks.>", log.add)
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+
+	// Current values are delivered before Watch returns; deleted entities are not
+	if events := log.get(); len(events) != 1 || events[0].Entity != "tasks.1" || events[0].Op != SoilPut {
+		t.Fatalf("Unexpected initial events: %+v", events)
+	}
+
+	soil.Delete("tasks.1")
+	soil.Put("tasks.2", []byte(`{"n":2}`))
+	soil.kv.Purge("tasks.2")
+	waitFor(t, func() bool { return len(log.get()) == 4 })
+
+	events := log.get()
+	if events[1].Op != SoilDelete || events[1].Entity != "tasks.1" || events[1].Data != nil {
+		t.Errorf("Expected delete event, got %+v", events[1])
+	}
+	if events[3].Op != SoilPurge || events[3].Entity != "tasks.2" {
+		t.Errorf("Expected purge event, got %+v", events[3])
+	}
+	if watch.Revision() != events[3].Revision {
+		t.Errorf("Expected watch revision %d, got %d", events[3].Revision, watch.Revision())
+	}
+
+	// No events after Stop
+	if err := watch.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	soil.Put("tasks.3", []byte(`{}`))
+	nc.Flush()
+	if n := len(log.get()); n != 4 {
+		t.Errorf("Expected no events after Stop, got %d", n)
+	}
+}
+
+func TestSoil_WatchFrom(t *testing.T) {
+	js, nc := setupTestJetStream(t)
+	defer nc.Close()
+
+	js.DeleteKeyValue("SOIL")
+	soil, err := NewSoil(js)
+	if err != nil {
+		t.Fatalf("Failed to create soil: %v", err)
+	}
+
+	soil.Put("orders.1", []byte(`{"status":"new"}`))
+	var first eventLog
+	watch, err := soil.Watch("orders.>", first.add)
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+	watch.Stop()
+	resumeAt := watch.Revision()
+
+	// Changes made while nobody was watching
+	soil.Put("orders.1", []byte(`{"status":"paid"}`))
+	soil.Delete("orders.1")
+	soil.Put("orders.2", []byte(`{"status":"new"}`))
+
+	var resumed eventLog
+	watch, err = soil.WatchFrom("orders.>", resumeAt, resumed.add)
+	if err != nil {
+		t.Fatalf("Failed to resume watch: %v", err)
+	}
+	defer watch.Stop()
+
+	events := resumed.get()
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 missed events, got %+v", events)
+	}
+	if string(events[0].Data) != `{"status":"paid"}` || events[1].Op != SoilDelete || events[2].Entity != "orders.2" {
+		t.Errorf("Unexpected missed events: %+v", events)
+	}
+
+	// Live changes follow
+	soil.Put("orders.3", []byte(`{}`))
+	waitFor(t, func() bool { return len(resumed.get()) == 4 })
+}
+
+func TestSoilBridge(t *testing.T) {
+	js, nc := setupTestJetStream(t)
+	defer nc.Close()
+
+	js.DeleteKeyValue("SOIL")
+	soil, err := NewSoil(js)
+	if err != nil {
+		t.Fatalf("Failed to create soil: %v", err)
+	}
+	wind := NewWind(nc)
+
+	var mu sync.Mutex
+	var changes []SoilChanged
+	wind.Catch("soil.changed.>", func(leaf Leaf) {
+		var c SoilChanged
+		json.Unmarshal(leaf.Data, &c)
+		mu.Lock()
+		changes = append(changes, c)
+		mu.Unlock()
+	})
+	caught := func() []SoilChanged {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]SoilChanged(nil), changes...)
+	}
+
+	bridge, err := RunSoilBridge(js, soil, wind, SoilBridgeConfig{Patterns: []string{"task-*", "contact-*"}})
+	if err != nil {
+		t.Fatalf("Failed to start bridge: %v", err)
+	}
+
+	soil.Put("task-1", []byte(`{"status":"open"}`))
+	soil.Put("session-1", []byte(`{}`))
+	soil.Delete("task-1")
+	waitFor(t, func() bool { return len(caught()) == 2 })
+
+	got := caught()
+	if got[0].Entity != "task-1" || got[0].Op != SoilPut || string(got[0].Data) != `{"status":"open"}` {
+		t.Errorf("Unexpected put leaf: %+v", got[0])
+	}
+	if got[1].Op != SoilDelete || got[1].Data != nil || got[1].Revision <= got[0].Revision {
+		t.Errorf("Unexpected delete leaf: %+v", got[1])
+	}
+
+	// Changes made while the bridge is stopped are republished on restart
+	bridge.Stop()
+	soil.Put("contact-1", []byte(`{"name":"ada"}`))
+	bridge, err = RunSoilBridge(js, soil, wind, SoilBridgeConfig{Patterns: []string{"task-*", "contact-*"}})
+	if err != nil {
+		t.Fatalf("Failed to restart bridge: %v", err)
+	}
+	defer bridge.Stop()
+	waitFor(t, func() bool { return len(caught()) == 3 })
+	if c := caught()[2]; c.Entity != "contact-1" {
+		t.Errorf("Expected contact-1 after restart, got %+v", c)
+	}
+}
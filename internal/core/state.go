@@ -0,0 +1,415 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// StateStore is the keyed state of one component, e.g. counters kept by a
+// treehouse script, in its own JetStream KV bucket. It survives restarts and
+// is shared by every forest running the component. Values can expire: a key
+// past its expiry reads as missing and is purged when read or swept.
+type StateStore struct {
+	js  nats.JetStreamContext
+	kv  nats.KeyValue
+	ttl time.Duration
+}
+
+// StateConfig configures a StateStore.
+type StateConfig struct {
+	// Bucket is the KV bucket name, e.g. StateBucket("payments").
+	Bucket string
+
+	// TTL expires keys written without a TTL of their own. Zero keeps them
+	// until they are deleted.
+	TTL time.Duration
+
+	// Storage is file or memory storage. Default: nats.FileStorage
+	Storage nats.StorageType
+
+	// Replicas is the number of bucket replicas in a cluster. Default: 1
+	Replicas int
+}
+
+// StateBucket returns the KV bucket name for a component's state,
+// e.g. "payment_failures" becomes "STATE_PAYMENT_FAILURES".
+func StateBucket(name string) string {
+	return "STATE_" + strings.ToUpper(invalidBucketChars.ReplaceAllString(name, "_"))
+}
+
+// stateUpdateRetries bounds the compare-and-swap retries of an update that
+// keeps racing with other writers.
+const stateUpdateRetries = 20
+
+// Prefixes separating values from windows in the bucket.
+const (
+	stateValuePrefix  = "value."
+	stateWindowPrefix = "window."
+)
+
+// stateEntry is a value as stored in the bucket.
+type stateEntry struct {
+	Value   json.RawMessage `json:"value"`
+	Expires int64           `json:"expires,omitempty"` // Unix milliseconds; 0 never expires
+}
+
+// NewStateStore creates a StateStore backed by the configured KV bucket,
+// creating the bucket if it doesn't exist.
+func NewStateStore(js nats.JetStreamContext, cfg StateConfig) (*StateStore, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("bucket is required")
+	}
+	replicas := cfg.Replicas
+	if replicas <= 0 {
+		replicas = 1
+	}
+
+	kv, err := js.KeyValue(cfg.Bucket)
+	if err != nil {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:      cfg.Bucket,
+			Description: "NimsForest component state",
+			History:     1,
+			Storage:     cfg.Storage,
+			Replicas:    replicas,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create KV bucket %s: %w", cfg.Bucket, err)
+		}
+		log.Printf("[State] Created KV bucket: %s", cfg.Bucket)
+	}
+
+	return &StateStore{js: js, kv: kv, ttl: cfg.TTL}, nil
+}
+
+// Get returns the JSON value of key, or false if it is missing or expired.
+func (s *StateStore) Get(key string) (json.RawMessage, bool, error) {
+	entry, _, err := s.get(stateValuePrefix + key)
+	if err != nil || entry == nil {
+		return nil, false, err
+	}
+	return entry.Value, true, nil
+}
+
+// Set stores a JSON value under key. A zero ttl uses the store's TTL.
+func (s *StateStore) Set(key string, value json.RawMessage, ttl time.Duration) error {
+	data, err := s.encode(value, ttl)
+	if err != nil {
+		return err
+	}
+	if _, err := s.kv.Put(stateValuePrefix+key, data); err != nil {
+		return fmt.Errorf("failed to set %s: %w", key, err)
+	}
+	return nil
+}
+
+// Incr adds delta to the number stored under key, starting from 0 when the
+// key is missing or expired, and returns the result. A zero ttl uses the
+// store's TTL; the expiry is renewed on every increment.
+func (s *StateStore) Incr(key string, delta float64, ttl time.Duration) (float64, error) {
+	var result float64
+	err := s.update(stateValuePrefix+key, func(current json.RawMessage) (json.RawMessage, time.Duration, error) {
+		result = delta
+		if current != nil {
+			var n float64
+			if err := json.Unmarshal(current, &n); err != nil {
+				return nil, 0, fmt.Errorf("%s is not a number", key)
+			}
+			result += n
+		}
+		data, _ := json.Marshal(result)
+		return data, ttl, nil
+	})
+	return result, err
+}
+
+// Delete removes key.
+func (s *StateStore) Delete(key string) error {
+	if err := s.kv.Delete(stateValuePrefix + key); err != nil && !errors.Is(err, nats.ErrKeyNotFound) {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	return nil
+}
+
+// Window is a time window aggregated by a StateStore. With Step zero it is
+// a tumbling window: consecutive windows of Size aligned to the Unix epoch.
+// Otherwise it slides by Step and covers the latest Size, kept as buckets
+// of Step each.
+type Window struct {
+	Size time.Duration
+	Step time.Duration
+}
+
+// WindowAggregate summarizes the values added within a window.
+type WindowAggregate struct {
+	Count int       `json:"count"`
+	Sum   float64   `json:"sum"`
+	Min   float64   `json:"min"`
+	Max   float64   `json:"max"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// windowBucket aggregates the values added within one step of a window.
+type windowBucket struct {
+	Start int64   `json:"start"` // Unix milliseconds
+	Count int     `json:"count"`
+	Sum   float64 `json:"sum"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+}
+
+func (w Window) validate() error {
+	if w.Size <= 0 {
+		return fmt.Errorf("window size must be positive")
+	}
+	if w.Step < 0 || w.Step > w.Size {
+		return fmt.Errorf("window step must be between 0 and the size")
+	}
+	if w.step() < time.Millisecond {
+		return fmt.Errorf("window step must be at least 1ms")
+	}
+	return nil
+}
+
+// step returns the bucket width: the whole window when tumbling.
+func (w Window) step() time.Duration {
+	if w.Step == 0 {
+		return w.Size
+	}
+	return w.Step
+}
+
+// key returns where a window of key is stored. Windows of different shapes
+// on the same key are kept apart.
+func (w Window) key(key string) string {
+	return fmt.Sprintf("%s%d-%d.%s", stateWindowPrefix, w.Size.Milliseconds(), w.Step.Milliseconds(), key)
+}
+
+// bucketStart returns the start of the step containing now, in Unix
+// milliseconds. time.Truncate aligns to the zero time, not the Unix epoch,
+// so steps that don't divide evenly into the years since then would start
+// at odd times.
+func (w Window) bucketStart(now time.Time) int64 {
+	ms, step := now.UnixMilli(), w.step().Milliseconds()
+	return ms - ms%step
+}
+
+// bounds returns the window containing now.
+func (w Window) bounds(now time.Time) (start, end time.Time) {
+	end = time.UnixMilli(w.bucketStart(now)).Add(w.step())
+	return end.Add(-w.Size), end
+}
+
+// AddToWindow adds value to the window of key containing now and returns
+// the window's aggregate. now is usually the time of the latest beat.
+func (s *StateStore) AddToWindow(key string, w Window, now time.Time, value float64) (WindowAggregate, error) {
+	if err := w.validate(); err != nil {
+		return WindowAggregate{}, err
+	}
+
+	var buckets []windowBucket
+	err := s.update(w.key(key), func(current json.RawMessage) (json.RawMessage, time.Duration, error) {
+		buckets = w.live(decodeBuckets(current), now)
+		bucketStart := w.bucketStart(now)
+		if n := len(buckets); n > 0 && buckets[n-1].Start == bucketStart {
+			last := &buckets[n-1]
+			last.Count++
+			last.Sum += value
+			last.Min = math.Min(last.Min, value)
+			last.Max = math.Max(last.Max, value)
+		} else {
+			buckets = append(buckets, windowBucket{Start: bucketStart, Count: 1, Sum: value, Min: value, Max: value})
+		}
+		data, _ := json.Marshal(buckets)
+		// Keep the key until its newest bucket leaves the window
+		return data, w.Size + w.step(), nil
+	})
+	if err != nil {
+		return WindowAggregate{}, err
+	}
+	return w.aggregate(buckets, now), nil
+}
+
+// ReadWindow returns the aggregate of the window of key containing now.
+func (s *StateStore) ReadWindow(key string, w Window, now time.Time) (WindowAggregate, error) {
+	if err := w.validate(); err != nil {
+		return WindowAggregate{}, err
+	}
+	entry, _, err := s.get(w.key(key))
+	if err != nil {
+		return WindowAggregate{}, err
+	}
+	var buckets []windowBucket
+	if entry != nil {
+		buckets = w.live(decodeBuckets(entry.Value), now)
+	}
+	return w.aggregate(buckets, now), nil
+}
+
+func decodeBuckets(data json.RawMessage) []windowBucket {
+	var buckets []windowBucket
+	if data != nil {
+		json.Unmarshal(data, &buckets)
+	}
+	return buckets
+}
+
+// live returns the buckets within the window containing now.
+func (w Window) live(buckets []windowBucket, now time.Time) []windowBucket {
+	start, end := w.bounds(now)
+	live := buckets[:0]
+	for _, b := range buckets {
+		if b.Start >= start.UnixMilli() && b.Start < end.UnixMilli() {
+			live = append(live, b)
+		}
+	}
+	return live
+}
+
+func (w Window) aggregate(buckets []windowBucket, now time.Time) WindowAggregate {
+	start, end := w.bounds(now)
+	agg := WindowAggregate{Start: start, End: end}
+	for _, b := range buckets {
+		if agg.Count == 0 {
+			agg.Min, agg.Max = b.Min, b.Max
+		} else {
+			agg.Min = math.Min(agg.Min, b.Min)
+			agg.Max = math.Max(agg.Max, b.Max)
+		}
+		agg.Count += b.Count
+		agg.Sum += b.Sum
+	}
+	return agg
+}
+
+// get reads a stored entry and its revision. A missing or expired entry is
+// returned as nil with the revision to update it from.
+func (s *StateStore) get(key string) (*stateEntry, uint64, error) {
+	kvEntry, err := s.kv.Get(key)
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get %s: %w", key, err)
+	}
+
+	var entry stateEntry
+	if err := json.Unmarshal(kvEntry.Value(), &entry); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode %s: %w", key, err)
+	}
+	if entry.expired(time.Now()) {
+		if err := s.purge(key, kvEntry.Revision()); err != nil {
+			return nil, 0, err
+		}
+		return nil, 0, nil
+	}
+	return &entry, kvEntry.Revision(), nil
+}
+
+func (e stateEntry) expired(now time.Time) bool {
+	return e.Expires != 0 && now.UnixMilli() >= e.Expires
+}
+
+// Sweep purges the expired keys, including those never read again, and
+// returns how many it purged. Every forest running the component may sweep
+// the same bucket.
+func (s *StateStore) Sweep() (int, error) {
+	lister, err := s.kv.ListKeys()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list keys: %w", err)
+	}
+	defer lister.Stop()
+
+	now := time.Now()
+	purged := 0
+	for key := range lister.Keys() {
+		kvEntry, err := s.kv.Get(key)
+		if err != nil {
+			continue // Deleted meanwhile
+		}
+		var entry stateEntry
+		if json.Unmarshal(kvEntry.Value(), &entry) != nil || !entry.expired(now) {
+			continue
+		}
+		if err := s.purge(key, kvEntry.Revision()); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// purge removes key's messages up to revision from the bucket's stream.
+// Unlike a KV purge it leaves no delete marker behind, and a value written
+// after revision is kept.
+func (s *StateStore) purge(key string, revision uint64) error {
+	bucket := s.kv.Bucket()
+	err := s.js.PurgeStream("KV_"+bucket, &nats.StreamPurgeRequest{
+		Subject:  "$KV." + bucket + "." + key,
+		Sequence: revision + 1,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to purge %s: %w", key, err)
+	}
+	return nil
+}
+
+// update applies fn to the current value of key with compare-and-swap,
+// retrying when another writer changed the key in between.
+func (s *StateStore) update(key string, fn func(current json.RawMessage) (json.RawMessage, time.Duration, error)) error {
+	for i := 0; i < stateUpdateRetries; i++ {
+		entry, revision, err := s.get(key)
+		if err != nil {
+			return err
+		}
+		var current json.RawMessage
+		if entry != nil {
+			current = entry.Value
+		}
+
+		value, ttl, err := fn(current)
+		if err != nil {
+			return err
+		}
+		data, err := s.encode(value, ttl)
+		if err != nil {
+			return err
+		}
+
+		if revision == 0 {
+			_, err = s.kv.Create(key, data)
+		} else {
+			_, err = s.kv.Update(key, data, revision)
+		}
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, nats.ErrKeyExists) {
+			return fmt.Errorf("failed to update %s: %w", key, err)
+		}
+	}
+	return fmt.Errorf("failed to update %s: too many concurrent writers", key)
+}
+
+func (s *StateStore) encode(value json.RawMessage, ttl time.Duration) ([]byte, error) {
+	if ttl == 0 {
+		ttl = s.ttl
+	}
+	entry := stateEntry{Value: value}
+	if ttl > 0 {
+		entry.Expires = time.Now().Add(ttl).UnixMilli()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode value: %w", err)
+	}
+	return data, nil
+}
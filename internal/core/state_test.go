@@ -0,0 +1,201 @@
+package core
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func setupTestState(t *testing.T, ttl time.Duration) *StateStore {
+	t.Helper()
+	js, nc := setupTestJetStream(t)
+	t.Cleanup(nc.Close)
+
+	bucket := StateBucket("test_" + t.Name())
+	js.DeleteKeyValue(bucket)
+	t.Cleanup(func() { js.DeleteKeyValue(bucket) })
+
+	store, err := NewStateStore(js, StateConfig{Bucket: bucket, TTL: ttl})
+	if err != nil {
+		t.Fatalf("NewStateStore failed: %v", err)
+	}
+	return store
+}
+
+func TestStateStore(t *testing.T) {
+	store := setupTestState(t, 0)
+
+	if _, ok, err := store.Get("missing"); ok || err != nil {
+		t.Fatalf("expected a missing key, got ok=%v err=%v", ok, err)
+	}
+
+	if err := store.Set("customer.acme", []byte(`{"plan":"pro"}`), 0); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	value, ok, err := store.Get("customer.acme")
+	if err != nil || !ok || string(value) != `{"plan":"pro"}` {
+		t.Fatalf("unexpected value %s (ok=%v, err=%v)", value, ok, err)
+	}
+
+	// Concurrent increments are not lost
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := store.Incr("failures.acme", 1, 0); err != nil {
+				t.Errorf("Incr failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n, err := store.Incr("failures.acme", 0.5, 0); err != nil || n != 10.5 {
+		t.Errorf("expected 10.5, got %v (err=%v)", n, err)
+	}
+	if _, err := store.Incr("customer.acme", 1, 0); err == nil {
+		t.Error("expected an error incrementing a table")
+	}
+
+	// Keys expire after their TTL
+	store.Set("session", []byte(`"abc"`), 50*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	if _, ok, _ := store.Get("session"); ok {
+		t.Error("expected the key to have expired")
+	}
+	if n, _ := store.Incr("session.count", 1, 50*time.Millisecond); n != 1 {
+		t.Errorf("expected 1, got %v", n)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n, _ := store.Incr("session.count", 1, 0); n != 1 {
+		t.Errorf("expected the counter to restart after expiring, got %v", n)
+	}
+
+	store.Delete("customer.acme")
+	if _, ok, _ := store.Get("customer.acme"); ok {
+		t.Error("expected the key to be deleted")
+	}
+}
+
+func TestStateStoreWindows(t *testing.T) {
+	store := setupTestState(t, 0)
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Tumbling: a new hour starts a new window
+	hourly := Window{Size: time.Hour}
+	store.AddToWindow("amount", hourly, base.Add(10*time.Minute), 5)
+	agg, _ := store.AddToWindow("amount", hourly, base.Add(50*time.Minute), 3)
+	if agg.Count != 2 || agg.Sum != 8 || agg.Min != 3 || agg.Max != 5 || !agg.Start.Equal(base) {
+		t.Errorf("unexpected tumbling aggregate %+v", agg)
+	}
+	agg, _ = store.AddToWindow("amount", hourly, base.Add(70*time.Minute), 1)
+	if agg.Count != 1 || agg.Sum != 1 || !agg.Start.Equal(base.Add(time.Hour)) {
+		t.Errorf("expected a fresh window, got %+v", agg)
+	}
+
+	// Sliding: the last hour in 10 minute steps
+	sliding := Window{Size: time.Hour, Step: 10 * time.Minute}
+	store.AddToWindow("fails", sliding, base.Add(5*time.Minute), 1)
+	store.AddToWindow("fails", sliding, base.Add(35*time.Minute), 1)
+	agg, _ = store.AddToWindow("fails", sliding, base.Add(55*time.Minute), 1)
+	if agg.Count != 3 {
+		t.Errorf("expected 3 in the last hour, got %+v", agg)
+	}
+	agg, _ = store.ReadWindow("fails", sliding, base.Add(75*time.Minute))
+	if agg.Count != 2 {
+		t.Errorf("expected the first value to slide out, got %+v", agg)
+	}
+
+	// Windows of another shape on the same key are separate
+	if agg, _ := store.ReadWindow("fails", hourly, base.Add(55*time.Minute)); agg.Count != 0 {
+		t.Errorf("expected an empty tumbling window, got %+v", agg)
+	}
+
+	if _, err := store.AddToWindow("x", Window{Size: time.Minute, Step: time.Hour}, base, 1); err == nil {
+		t.Error("expected an error for a step larger than the size")
+	}
+	if _, err := store.AddToWindow("x", Window{Size: time.Microsecond}, base, 1); err == nil {
+		t.Error("expected an error for a step under 1ms")
+	}
+}
+
+func TestStateStoreWindowsAlignToUnixEpoch(t *testing.T) {
+	store := setupTestState(t, 0)
+
+	// The Unix epoch was a Thursday, so weekly windows start on Thursdays,
+	// not on the Mondays that time.Truncate aligns to
+	weekly := Window{Size: 7 * 24 * time.Hour}
+	now := time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC) // A Monday
+	agg, err := store.AddToWindow("weekly", weekly, now, 1)
+	if err != nil {
+		t.Fatalf("AddToWindow failed: %v", err)
+	}
+	if want := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC); !agg.Start.Equal(want) || !agg.End.Equal(want.Add(weekly.Size)) {
+		t.Errorf("expected the week from %v, got %v to %v", want, agg.Start, agg.End)
+	}
+
+	// Sliding steps are aligned the same way
+	sliding := Window{Size: 21 * time.Minute, Step: 7 * time.Minute}
+	agg, _ = store.AddToWindow("sliding", sliding, now, 1)
+	if end := agg.End.UnixMilli(); end%sliding.Step.Milliseconds() != 0 || agg.End.Before(now) || agg.End.Sub(now) > sliding.Step {
+		t.Errorf("expected the window to end on the next 7 minute step after the epoch, got %v", agg.End)
+	}
+}
+
+func TestStateStoreSweep(t *testing.T) {
+	store := setupTestState(t, 0)
+
+	keys := func() int {
+		t.Helper()
+		keys, err := store.kv.Keys()
+		if err != nil && !errors.Is(err, nats.ErrNoKeysFound) {
+			t.Fatalf("Keys failed: %v", err)
+		}
+		return len(keys)
+	}
+	messages := func() uint64 {
+		t.Helper()
+		info, err := store.js.StreamInfo("KV_" + store.kv.Bucket())
+		if err != nil {
+			t.Fatalf("StreamInfo failed: %v", err)
+		}
+		return info.State.Msgs
+	}
+
+	store.Set("keep", []byte(`1`), 0)
+	store.Set("read", []byte(`1`), 50*time.Millisecond)
+	store.Set("unread", []byte(`1`), 50*time.Millisecond)
+	store.Incr("counter", 1, 50*time.Millisecond)
+	store.AddToWindow("amount", Window{Size: 20 * time.Millisecond}, time.Now(), 1)
+	if n := keys(); n != 5 {
+		t.Fatalf("expected 5 keys, got %d", n)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// Reading an expired key purges it
+	if _, ok, _ := store.Get("read"); ok {
+		t.Error("expected the key to have expired")
+	}
+	if n := keys(); n != 4 {
+		t.Errorf("expected 4 keys after reading an expired one, got %d", n)
+	}
+
+	// Sweeping purges the rest, leaving no delete markers behind
+	purged, err := store.Sweep()
+	if err != nil || purged != 3 {
+		t.Errorf("expected 3 keys purged, got %d (err=%v)", purged, err)
+	}
+	if n := keys(); n != 1 {
+		t.Errorf("expected 1 key after sweeping, got %d", n)
+	}
+	if n := messages(); n != 1 {
+		t.Errorf("expected 1 message in the bucket after sweeping, got %d", n)
+	}
+
+	// Purged keys can be written again
+	if n, err := store.Incr("counter", 1, 0); err != nil || n != 1 {
+		t.Errorf("expected the counter to restart at 1, got %v (err=%v)", n, err)
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sync"
+
+	"github.com/nats-io/nats.go"
 )
 
 // Tree represents a component that watches the river and produces structured leaves.
@@ -32,6 +35,9 @@ type BaseTree struct {
 	name  string
 	wind  *Wind
 	river *River
+
+	mu   sync.Mutex
+	subs []*nats.Subscription
 }
 
 // NewBaseTree creates a new base tree with the given name and wind connection.
@@ -70,15 +76,30 @@ func (t *BaseTree) Watch(pattern string, handler func(data RiverData)) error {
 		return fmt.Errorf("tree %s has no river connection", t.name)
 	}
 
-	err := t.river.Observe(pattern, handler)
+	sub, err := t.river.observe(pattern, handler)
 	if err != nil {
 		return fmt.Errorf("tree %s failed to watch pattern %s: %w", t.name, pattern, err)
 	}
+	t.mu.Lock()
+	t.subs = append(t.subs, sub)
+	t.mu.Unlock()
 
 	log.Printf("[Tree:%s] Watching pattern: %s", t.name, pattern)
 	return nil
 }
 
+// StopWatching unsubscribes everything watched with Watch, so a stopped
+// tree can be started again or removed. Concrete trees should call it
+// from Stop.
+func (t *BaseTree) StopWatching() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, sub := range t.subs {
+		sub.Unsubscribe()
+	}
+	t.subs = nil
+}
+
 // GetWind returns the wind connection (for testing or advanced usage).
 func (t *BaseTree) GetWind() *Wind {
 	return t.wind
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/yourusername/nimsforest/internal/core"
@@ -41,7 +42,7 @@ func NewAfterSalesNim(wind *core.Wind, humus *core.Humus, soil *core.Soil) *Afte
 
 // Subjects returns the leaf patterns this nim listens to.
 func (n *AfterSalesNim) Subjects() []string {
-	return []string{"payment.completed", "payment.failed"}
+	return []string{"payment.completed", "payment.failed", "soil.expired.>"}
 }
 
 // Handle processes a caught leaf.
@@ -52,6 +53,9 @@ func (n *AfterSalesNim) Handle(ctx context.Context, leaf core.Leaf) error {
 	case "payment.failed":
 		return n.handlePaymentFailed(ctx, leaf)
 	default:
+		if strings.HasPrefix(leaf.Subject, "soil.expired.") {
+			return n.handleTaskDue(ctx, leaf)
+		}
 		return fmt.Errorf("unexpected leaf subject: %s", leaf.Subject)
 	}
 }
@@ -167,6 +171,39 @@ func (n *AfterSalesNim) handlePaymentFailed(ctx context.Context, leaf core.Leaf)
 	return nil
 }
 
+// handleTaskDue processes soil expiry leaves for followup tasks.
+// Tasks still open when their due date passes are escalated.
+func (n *AfterSalesNim) handleTaskDue(ctx context.Context, leaf core.Leaf) error {
+	var expired core.SoilExpired
+	if err := json.Unmarshal(leaf.Data, &expired); err != nil {
+		return fmt.Errorf("failed to unmarshal expiry: %w", err)
+	}
+	if !strings.HasPrefix(expired.Entity, "task-") {
+		return nil // Not one of our tasks
+	}
+
+	var task Task
+	if err := json.Unmarshal(expired.Data, &task); err != nil {
+		return fmt.Errorf("failed to unmarshal task %s: %w", expired.Entity, err)
+	}
+	if task.Status == "completed" {
+		return nil
+	}
+
+	log.Printf("[AfterSalesNim] Task %s is overdue (status: %s, due: %s)",
+		expired.Entity, task.Status, task.DueDate.Format(time.RFC3339))
+
+	overdue := leaves.FollowupRequired{
+		CustomerID: task.CustomerID,
+		Reason:     fmt.Sprintf("overdue: %s", task.Type),
+		DueDate:    task.DueDate,
+	}
+	if err := n.LeafStruct("followup.overdue", overdue); err != nil {
+		return fmt.Errorf("failed to emit overdue leaf: %w", err)
+	}
+	return nil
+}
+
 // Start begins listening for payment leaves.
 func (n *AfterSalesNim) Start(ctx context.Context) error {
 	n.ctx, n.cancel = context.WithCancel(ctx)
@@ -189,6 +226,15 @@ func (n *AfterSalesNim) Start(ctx context.Context) error {
 		return fmt.Errorf("failed to catch payment.failed: %w", err)
 	}
 
+	// Catch due followup tasks from the soil expirer
+	if err := n.Catch("soil.expired.>", func(leaf core.Leaf) {
+		if err := n.Handle(n.ctx, leaf); err != nil {
+			log.Printf("[AfterSalesNim] Error handling %s: %v", leaf.Subject, err)
+		}
+	}); err != nil {
+		return fmt.Errorf("failed to catch soil.expired.>: %w", err)
+	}
+
 	log.Printf("[AfterSalesNim] Started listening for payment events")
 	return nil
 }
@@ -198,6 +244,7 @@ func (n *AfterSalesNim) Stop() error {
 	if n.cancel != nil {
 		n.cancel()
 	}
+	n.StopCatching()
 	log.Printf("[AfterSalesNim] Stopped")
 	return nil
 }
@@ -15,13 +15,14 @@ func TestAfterSalesNim_Subjects(t *testing.T) {
 	nim := NewAfterSalesNim(nil, nil, nil)
 	subjects := nim.Subjects()
 
-	if len(subjects) != 2 {
-		t.Errorf("Expected 2 subjects, got %d", len(subjects))
+	if len(subjects) != 3 {
+		t.Errorf("Expected 3 subjects, got %d", len(subjects))
 	}
 
 	expected := map[string]bool{
 		"payment.completed": true,
 		"payment.failed":    true,
+		"soil.expired.>":    true,
 	}
 
 	for _, subject := range subjects {
@@ -222,6 +223,64 @@ func TestAfterSalesNim_HighValuePurchaseEmail(t *testing.T) {
 	}
 }
 
+func TestAfterSalesNim_TaskOverdue(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	nc, _ := core.SetupTestNATS(t)
+	defer nc.Close()
+
+	wind := core.NewWind(nc)
+	nim := NewAfterSalesNim(wind, nil, nil)
+
+	overdue := make(chan leaves.FollowupRequired, 2)
+	_, err := wind.Catch("followup.overdue", func(leaf core.Leaf) {
+		var followup leaves.FollowupRequired
+		json.Unmarshal(leaf.Data, &followup)
+		overdue <- followup
+	})
+	if err != nil {
+		t.Fatalf("Failed to catch overdue leaves: %v", err)
+	}
+
+	expiredLeaf := func(entity string, task Task) core.Leaf {
+		taskData, _ := json.Marshal(task)
+		data, _ := json.Marshal(core.SoilExpired{Entity: entity, At: task.DueDate, Reason: "due", Data: taskData})
+		return *core.NewLeaf(core.SoilExpiredSubject(entity), data, "soil")
+	}
+
+	ctx := context.Background()
+	due := time.Now().Add(-time.Minute)
+
+	// Completed tasks and other entities are ignored
+	if err := nim.Handle(ctx, expiredLeaf("task-done", Task{CustomerID: "cus_1", Status: "completed", DueDate: due})); err != nil {
+		t.Fatalf("Failed to handle completed task: %v", err)
+	}
+	if err := nim.Handle(ctx, expiredLeaf("session-1", Task{})); err != nil {
+		t.Fatalf("Failed to handle non-task entity: %v", err)
+	}
+
+	if err := nim.Handle(ctx, expiredLeaf("task-open", Task{CustomerID: "cus_2", Type: "followup", Status: "pending", DueDate: due})); err != nil {
+		t.Fatalf("Failed to handle overdue task: %v", err)
+	}
+
+	select {
+	case followup := <-overdue:
+		if followup.CustomerID != "cus_2" || followup.Reason != "overdue: followup" {
+			t.Errorf("Unexpected overdue followup: %+v", followup)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for overdue leaf")
+	}
+
+	select {
+	case followup := <-overdue:
+		t.Errorf("Expected a single overdue leaf, got another: %+v", followup)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
 func TestAfterSalesNim_LowValuePurchaseNoEmail(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping integration test in short mode")
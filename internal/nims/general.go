@@ -299,6 +299,7 @@ func (n *GeneralNim) Stop() error {
 	if n.cancel != nil {
 		n.cancel()
 	}
+	n.StopCatching()
 	log.Printf("[GeneralNim] Stopped")
 	return nil
 }
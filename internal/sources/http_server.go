@@ -11,10 +11,12 @@ import (
 
 // WebhookServer manages HTTP endpoints for webhook sources.
 type WebhookServer struct {
-	server  *http.Server
-	mux     *http.ServeMux
-	sources map[string]*WebhookSource
-	address string
+	server   *http.Server
+	mux      *http.ServeMux
+	sources  map[string]*WebhookSource
+	handlers map[string]string           // Pattern of each MountHandler name
+	routes   map[string]http.HandlerFunc // Current handler of each pattern, nil once unmounted
+	address  string
 
 	mu      sync.Mutex
 	running bool
@@ -35,9 +37,11 @@ func NewWebhookServer(address string) *WebhookServer {
 	})
 
 	return &WebhookServer{
-		mux:     mux,
-		sources: make(map[string]*WebhookSource),
-		address: address,
+		mux:      mux,
+		sources:  make(map[string]*WebhookSource),
+		handlers: make(map[string]string),
+		routes:   make(map[string]http.HandlerFunc),
+		address:  address,
 	}
 }
 
@@ -55,7 +59,7 @@ func (s *WebhookServer) Mount(source *WebhookSource) error {
 
 	// Register the handler
 	pattern := fmt.Sprintf("POST %s", path)
-	s.mux.HandleFunc(pattern, source.Handler())
+	s.route(pattern, source.Handler())
 	s.sources[name] = source
 
 	log.Printf("[WebhookServer] Mounted source '%s' at POST %s", name, path)
@@ -70,26 +74,51 @@ func (s *WebhookServer) MountHandler(name, path string, handler http.HandlerFunc
 
 	// Register the handler
 	pattern := fmt.Sprintf("POST %s", path)
-	s.mux.HandleFunc(pattern, handler)
+	s.route(pattern, handler)
+	s.handlers[name] = pattern
 
 	log.Printf("[WebhookServer] Mounted handler '%s' at POST %s", name, path)
 	return nil
 }
 
-// Unmount removes a webhook source from the server.
-// Note: Go's http.ServeMux doesn't support unregistering handlers,
-// so this just removes from our tracking. The handler will return 404
-// when the source is not running.
+// route points pattern at handler. The mux cannot register a pattern twice,
+// so each pattern is registered once with a handler that looks up the
+// current route; an unmounted pattern returns 404. Callers must hold s.mu.
+func (s *WebhookServer) route(pattern string, handler http.HandlerFunc) {
+	if _, registered := s.routes[pattern]; !registered {
+		s.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			s.mu.Lock()
+			current := s.routes[pattern]
+			s.mu.Unlock()
+			if current == nil {
+				http.NotFound(w, r)
+				return
+			}
+			current(w, r)
+		})
+	}
+	s.routes[pattern] = handler
+}
+
+// Unmount removes a webhook source or handler from the server. Its path
+// returns 404 until something is mounted on it again.
 func (s *WebhookServer) Unmount(name string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if pattern, exists := s.handlers[name]; exists {
+		s.routes[pattern] = nil
+		delete(s.handlers, name)
+		log.Printf("[WebhookServer] Unmounted handler '%s'", name)
+		return nil
+	}
 	if _, exists := s.sources[name]; !exists {
 		return fmt.Errorf("source '%s' not found", name)
 	}
 
 	// Stop the source
 	s.sources[name].Stop()
+	s.routes[fmt.Sprintf("POST %s", s.sources[name].Path())] = nil
 	delete(s.sources, name)
 
 	log.Printf("[WebhookServer] Unmounted source '%s'", name)
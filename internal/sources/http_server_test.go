@@ -1,6 +1,8 @@
 package sources
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/yourusername/nimsforest/internal/core"
@@ -76,3 +78,39 @@ func TestWebhookServer_IsRunning(t *testing.T) {
 		t.Error("Server should not be running initially")
 	}
 }
+
+func TestWebhookServer_RemountPath(t *testing.T) {
+	server := NewWebhookServer("")
+
+	mount := func(body string) {
+		t.Helper()
+		if err := server.MountHandler("hook", "/hook", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}); err != nil {
+			t.Fatalf("MountHandler failed: %v", err)
+		}
+	}
+	get := func() (int, string) {
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/hook", nil))
+		return rec.Code, rec.Body.String()
+	}
+
+	mount("first")
+	if _, body := get(); body != "first" {
+		t.Errorf("expected first handler, got %q", body)
+	}
+
+	if err := server.Unmount("hook"); err != nil {
+		t.Fatalf("Unmount failed: %v", err)
+	}
+	if code, _ := get(); code != http.StatusNotFound {
+		t.Errorf("expected 404 after unmount, got %d", code)
+	}
+
+	// Mounting the same path again replaces the route instead of panicking
+	mount("second")
+	if _, body := get(); body != "second" {
+		t.Errorf("expected second handler, got %q", body)
+	}
+}
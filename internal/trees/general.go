@@ -67,50 +67,62 @@ func (t *GeneralTree) Start(ctx context.Context) error {
 	return nil
 }
 
-// parseGeneralData demonstrates how to parse incoming river data and emit leaves.
+// parseGeneralData parses incoming river data and emits the resulting leaf.
+func (t *GeneralTree) parseGeneralData(data core.RiverData) {
+	log.Printf("[GeneralTree] 📥 Received data on %s (%d bytes)", data.Subject, len(data.Data))
+
+	leaf := t.Parse(data.Subject, data.Data)
+	if leaf == nil {
+		return
+	}
+	if err := t.Drop(*leaf); err != nil {
+		log.Printf("[GeneralTree] ❌ Failed to drop leaf: %v", err)
+	} else {
+		log.Printf("[GeneralTree] 🍃 Emitted leaf: %s", leaf.Subject)
+	}
+}
+
+// Parse demonstrates how to turn river data into a leaf.
 // This is where your domain-specific parsing logic goes.
 //
 // TO CUSTOMIZE:
 // 1. Parse the data according to your source format (JSON, XML, CSV, etc.)
 // 2. Extract relevant fields and create strongly-typed leaf events
-// 3. Emit leaves that other nims can catch and process
-func (t *GeneralTree) parseGeneralData(data core.RiverData) {
-	log.Printf("[GeneralTree] 📥 Received data on %s (%d bytes)", data.Subject, len(data.Data))
-
+// 3. Return leaves that other nims can catch and process
+func (t *GeneralTree) Parse(subject string, data []byte) *core.Leaf {
 	// Try to parse as generic JSON
 	var payload map[string]interface{}
-	if err := json.Unmarshal(data.Data, &payload); err != nil {
+	if err := json.Unmarshal(data, &payload); err != nil {
 		log.Printf("[GeneralTree] ⚠️  Not valid JSON: %v", err)
-		return
+		return nil
 	}
 
-	// Example: Inspect the payload and decide what leaves to emit
+	// Example: Inspect the payload and decide what leaf to emit
 	eventType, hasType := payload["type"].(string)
 	if !hasType {
 		log.Printf("[GeneralTree] ⚠️  No 'type' field in payload")
-		return
+		return nil
 	}
 
 	log.Printf("[GeneralTree] 📋 Event type: %s", eventType)
 
 	// Emit different leaves based on the event type
-	// This demonstrates how one river event can fan out to multiple leaves
 	switch eventType {
 	case "data.received":
-		t.emitDataReceivedLeaf(payload)
+		return t.dataReceivedLeaf(payload)
 	case "status.update":
-		t.emitStatusUpdateLeaf(payload)
+		return t.statusUpdateLeaf(payload)
 	case "notification":
-		t.emitNotificationLeaf(payload)
+		return t.notificationLeaf(payload)
 	default:
 		log.Printf("[GeneralTree] 💡 Unknown type '%s' - you can add handler for this!", eventType)
 		// Still emit a generic leaf so nims can process it
-		t.emitGenericLeaf(eventType, payload)
+		return t.genericLeaf(eventType, payload)
 	}
 }
 
-// emitDataReceivedLeaf shows how to emit a specific leaf type
-func (t *GeneralTree) emitDataReceivedLeaf(payload map[string]interface{}) {
+// dataReceivedLeaf shows how to build a specific leaf type
+func (t *GeneralTree) dataReceivedLeaf(payload map[string]interface{}) *core.Leaf {
 	leafData := map[string]interface{}{
 		"event_type": "data.received",
 		"timestamp":  payload["timestamp"],
@@ -119,17 +131,11 @@ func (t *GeneralTree) emitDataReceivedLeaf(payload map[string]interface{}) {
 	}
 
 	data, _ := json.Marshal(leafData)
-	leaf := *core.NewLeaf("data.received", data, t.Name())
-
-	if err := t.Drop(leaf); err != nil {
-		log.Printf("[GeneralTree] ❌ Failed to drop leaf: %v", err)
-	} else {
-		log.Printf("[GeneralTree] 🍃 Emitted leaf: data.received")
-	}
+	return core.NewLeaf("data.received", data, t.Name())
 }
 
-// emitStatusUpdateLeaf shows emitting status updates
-func (t *GeneralTree) emitStatusUpdateLeaf(payload map[string]interface{}) {
+// statusUpdateLeaf shows building status updates
+func (t *GeneralTree) statusUpdateLeaf(payload map[string]interface{}) *core.Leaf {
 	leafData := map[string]interface{}{
 		"event_type": "status.update",
 		"entity_id":  payload["entity_id"],
@@ -138,17 +144,11 @@ func (t *GeneralTree) emitStatusUpdateLeaf(payload map[string]interface{}) {
 	}
 
 	data, _ := json.Marshal(leafData)
-	leaf := *core.NewLeaf("status.update", data, t.Name())
-
-	if err := t.Drop(leaf); err != nil {
-		log.Printf("[GeneralTree] ❌ Failed to drop leaf: %v", err)
-	} else {
-		log.Printf("[GeneralTree] 🍃 Emitted leaf: status.update (entity: %v)", payload["entity_id"])
-	}
+	return core.NewLeaf("status.update", data, t.Name())
 }
 
-// emitNotificationLeaf shows emitting notifications
-func (t *GeneralTree) emitNotificationLeaf(payload map[string]interface{}) {
+// notificationLeaf shows building notifications
+func (t *GeneralTree) notificationLeaf(payload map[string]interface{}) *core.Leaf {
 	leafData := map[string]interface{}{
 		"event_type": "notification",
 		"priority":   payload["priority"],
@@ -157,31 +157,18 @@ func (t *GeneralTree) emitNotificationLeaf(payload map[string]interface{}) {
 	}
 
 	data, _ := json.Marshal(leafData)
-	leaf := *core.NewLeaf("notification.required", data, t.Name())
-
-	if err := t.Drop(leaf); err != nil {
-		log.Printf("[GeneralTree] ❌ Failed to drop leaf: %v", err)
-	} else {
-		priority := payload["priority"]
-		log.Printf("[GeneralTree] 🍃 Emitted leaf: notification.required (priority: %v)", priority)
-	}
+	return core.NewLeaf("notification.required", data, t.Name())
 }
 
-// emitGenericLeaf shows how to handle unknown event types
-func (t *GeneralTree) emitGenericLeaf(eventType string, payload map[string]interface{}) {
+// genericLeaf shows how to handle unknown event types
+func (t *GeneralTree) genericLeaf(eventType string, payload map[string]interface{}) *core.Leaf {
 	leafData := map[string]interface{}{
 		"event_type": eventType,
 		"raw_data":   payload,
 	}
 
 	data, _ := json.Marshal(leafData)
-	leaf := *core.NewLeaf("general.event", data, t.Name())
-
-	if err := t.Drop(leaf); err != nil {
-		log.Printf("[GeneralTree] ❌ Failed to drop leaf: %v", err)
-	} else {
-		log.Printf("[GeneralTree] 🍃 Emitted generic leaf: general.event (type: %s)", eventType)
-	}
+	return core.NewLeaf("general.event", data, t.Name())
 }
 
 // Stop stops the tree from processing river data
@@ -189,6 +176,7 @@ func (t *GeneralTree) Stop() error {
 	if t.cancel != nil {
 		t.cancel()
 	}
+	t.StopWatching()
 	log.Printf("[GeneralTree] Stopped")
 	return nil
 }
@@ -173,6 +173,7 @@ func (t *PaymentTree) Stop() error {
 	if t.cancel != nil {
 		t.cancel()
 	}
+	t.StopWatching()
 	log.Printf("[PaymentTree] Stopped")
 	return nil
 }
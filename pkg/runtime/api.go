@@ -5,12 +5,17 @@ package runtime
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/yourusername/nimsforest/internal/core"
 )
 
 // APIConfig configures the management API server.
@@ -69,7 +74,42 @@ func NewAPI(cfg APIConfig) *API {
 	mux.HandleFunc("POST /api/v1/nims", api.handleAddNim)
 	mux.HandleFunc("DELETE /api/v1/nims/{name}", api.handleRemoveNim)
 
+	// Registered Go component types
+	mux.HandleFunc("GET /api/v1/registry", api.handleListRegistry)
+
+	// Hot reload status of scripts and prompts
+	mux.HandleFunc("GET /api/v1/scripts", api.handleListScripts)
+
+	// Projections
+	mux.HandleFunc("GET /api/v1/projections", api.handleListProjections)
+	mux.HandleFunc("POST /api/v1/projections/{name}/rebuild", api.handleRebuildProjection)
+	mux.HandleFunc("GET /api/v1/projections/{name}/keys", api.handleListProjectionKeys)
+	mux.HandleFunc("GET /api/v1/projections/{name}/keys/{key...}", api.handleGetProjectionKey)
+
+	// Humus
+	mux.HandleFunc("GET /api/v1/humus", api.handleHumusStatus)
+	mux.HandleFunc("POST /api/v1/humus/archive/rotate", api.handleRotateHumusArchive)
+	mux.HandleFunc("POST /api/v1/humus/import", api.handleImportHumus)
+
+	// Soil
+	mux.HandleFunc("POST /api/v1/soil/query", api.handleSoilQuery)
+	mux.HandleFunc("GET /api/v1/soil/indexes", api.handleListSoilIndexes)
+	mux.HandleFunc("GET /api/v1/soil/buckets", api.handleListSoilBuckets)
+	mux.HandleFunc("GET /api/v1/soil/expiry", api.handleListSoilExpiry)
+	mux.HandleFunc("PUT /api/v1/soil/expiry/{entity...}", api.handleExpireSoilEntity)
+	mux.HandleFunc("DELETE /api/v1/soil/expiry/{entity...}", api.handleCancelSoilExpiry)
+	mux.HandleFunc("GET /api/v1/soil/export", api.handleExportSoil)
+	mux.HandleFunc("POST /api/v1/soil/import", api.handleImportSoil)
+	mux.HandleFunc("GET /api/v1/soil/keys", api.handleBrowseSoil)
+	mux.HandleFunc("GET /api/v1/soil/entities/{entity...}", api.handleGetSoilEntity)
+	mux.HandleFunc("PUT /api/v1/soil/entities/{entity...}", api.handleEditSoilEntity)
+	mux.HandleFunc("DELETE /api/v1/soil/entities/{entity...}", api.handleDeleteSoilEntity)
+	mux.HandleFunc("GET /api/v1/soil/history/{entity...}", api.handleSoilHistory)
+	mux.HandleFunc("GET /api/v1/soil/diff/{entity...}", api.handleSoilDiff)
+	mux.HandleFunc("GET /soil", api.handleSoilBrowser)
+
 	// Reload
+	mux.HandleFunc("POST /api/v1/reload", api.handleReload)
 	mux.HandleFunc("POST /-/reload", api.handleReload)
 
 	api.server = &http.Server{
@@ -283,9 +323,12 @@ func (api *API) handleListTrees(w http.ResponseWriter, r *http.Request) {
 func (api *API) handleAddTree(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Name      string `json:"name"`
+		Type      string `json:"type"`
 		Watches   string `json:"watches"`
 		Publishes string `json:"publishes"`
 		Script    string `json:"script"`
+		Pool      int    `json:"pool"`
+		Ordered   bool   `json:"ordered"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -298,6 +341,10 @@ func (api *API) handleAddTree(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "name is required")
 		return
 	}
+	if req.Type != "" {
+		api.addComponent(w, req.Name, api.config.Forest.AddTree(req.Name, TreeConfig{Name: req.Name, Type: req.Type}))
+		return
+	}
 	if req.Watches == "" {
 		writeError(w, http.StatusBadRequest, "watches is required")
 		return
@@ -316,6 +363,8 @@ func (api *API) handleAddTree(w http.ResponseWriter, r *http.Request) {
 		Watches:   req.Watches,
 		Publishes: req.Publishes,
 		Script:    req.Script,
+		Pool:      req.Pool,
+		Ordered:   req.Ordered,
 	}
 
 	if err := api.config.Forest.AddTree(req.Name, cfg); err != nil {
@@ -359,10 +408,16 @@ func (api *API) handleListTreeHouses(w http.ResponseWriter, r *http.Request) {
 
 func (api *API) handleAddTreeHouse(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Name       string `json:"name"`
-		Subscribes string `json:"subscribes"`
-		Publishes  string `json:"publishes"`
-		Script     string `json:"script"`
+		Name       string             `json:"name"`
+		Type       string             `json:"type"`
+		Subscribes string             `json:"subscribes"`
+		Publishes  string             `json:"publishes"`
+		Emits      []string           `json:"emits"`
+		Script     string             `json:"script"`
+		Verify     float64            `json:"verify"`
+		Pool       int                `json:"pool"`
+		Ordered    bool               `json:"ordered"`
+		State      *ScriptStateConfig `json:"state"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -375,6 +430,11 @@ func (api *API) handleAddTreeHouse(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "name is required")
 		return
 	}
+	if req.Type != "" {
+		cfg := TreeHouseConfig{Name: req.Name, Type: req.Type, Verify: req.Verify}
+		api.addComponent(w, req.Name, api.config.Forest.AddTreeHouse(req.Name, cfg))
+		return
+	}
 	if req.Subscribes == "" {
 		writeError(w, http.StatusBadRequest, "subscribes is required")
 		return
@@ -392,7 +452,11 @@ func (api *API) handleAddTreeHouse(w http.ResponseWriter, r *http.Request) {
 		Name:       req.Name,
 		Subscribes: req.Subscribes,
 		Publishes:  req.Publishes,
+		Emits:      req.Emits,
 		Script:     req.Script,
+		Pool:       req.Pool,
+		Ordered:    req.Ordered,
+		State:      req.State,
 	}
 
 	if err := api.config.Forest.AddTreeHouse(req.Name, cfg); err != nil {
@@ -437,6 +501,7 @@ func (api *API) handleListNims(w http.ResponseWriter, r *http.Request) {
 func (api *API) handleAddNim(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Name       string `json:"name"`
+		Type       string `json:"type"`
 		Subscribes string `json:"subscribes"`
 		Publishes  string `json:"publishes"`
 		Prompt     string `json:"prompt"`
@@ -452,6 +517,10 @@ func (api *API) handleAddNim(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "name is required")
 		return
 	}
+	if req.Type != "" {
+		api.addComponent(w, req.Name, api.config.Forest.AddNim(req.Name, NimConfig{Name: req.Name, Type: req.Type}))
+		return
+	}
 	if req.Subscribes == "" {
 		writeError(w, http.StatusBadRequest, "subscribes is required")
 		return
@@ -487,6 +556,23 @@ func (api *API) handleAddNim(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// addComponent writes the response to adding a registered Go component.
+func (api *API) addComponent(w http.ResponseWriter, name string, err error) {
+	if err != nil {
+		if strings.Contains(err.Error(), "already exists") {
+			writeError(w, http.StatusConflict, err.Error())
+		} else {
+			writeError(w, http.StatusBadRequest, err.Error())
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]string{
+		"status": "created",
+		"name":   name,
+	})
+}
+
 func (api *API) handleRemoveNim(w http.ResponseWriter, r *http.Request) {
 	name := r.PathValue("name")
 	if name == "" {
@@ -506,6 +592,446 @@ func (api *API) handleRemoveNim(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// =============================================================================
+// Registry Handlers
+// =============================================================================
+
+func (api *API) handleListRegistry(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, RegisteredTypes())
+}
+
+// =============================================================================
+// Script Handlers
+// =============================================================================
+
+func (api *API) handleListScripts(w http.ResponseWriter, r *http.Request) {
+	scripts := api.config.Forest.ScriptStatus()
+	if scripts == nil {
+		scripts = []ScriptStatus{}
+	}
+	writeJSON(w, http.StatusOK, scripts)
+}
+
+// =============================================================================
+// Projection Handlers
+// =============================================================================
+
+func (api *API) handleListProjections(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, api.config.Forest.ListProjections())
+}
+
+func (api *API) handleRebuildProjection(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	if err := api.config.Forest.RebuildProjection(name); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			writeError(w, http.StatusNotFound, err.Error())
+		} else {
+			writeError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]string{
+		"status": "rebuilding",
+		"name":   name,
+	})
+}
+
+func (api *API) handleListProjectionKeys(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	proj := api.config.Forest.Projection(name)
+	if proj == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("projection '%s' not found", name))
+		return
+	}
+
+	keys, err := proj.Keys()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, keys)
+}
+
+func (api *API) handleGetProjectionKey(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	key := r.PathValue("key")
+	proj := api.config.Forest.Projection(name)
+	if proj == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("projection '%s' not found", name))
+		return
+	}
+
+	value, found, err := proj.Get(key)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("key '%s' not found in projection '%s'", key, name))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"key":   key,
+		"value": value,
+	})
+}
+
+// =============================================================================
+// Humus Handlers
+// =============================================================================
+
+func (api *API) handleHumusStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := api.config.Forest.HumusStatus()
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (api *API) handleRotateHumusArchive(w http.ResponseWriter, r *http.Request) {
+	if err := api.config.Forest.RotateHumusArchive(); err != nil {
+		if strings.Contains(err.Error(), "not configured") {
+			writeError(w, http.StatusNotFound, err.Error())
+		} else {
+			writeError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "rotated"})
+}
+
+func (api *API) handleImportHumus(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Files  []string `json:"files"`
+		Stream string   `json:"stream,omitempty"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	if len(req.Files) == 0 {
+		writeError(w, http.StatusBadRequest, "files is required")
+		return
+	}
+
+	result, err := api.config.Forest.ImportHumusArchives(req.Files, req.Stream)
+	if errors.Is(err, ErrOutsideArchive) {
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if errors.Is(err, core.ErrReservedStream) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (api *API) handleSoilQuery(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Pattern string `json:"pattern"`
+		core.QueryFilter
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	if req.Pattern == "" {
+		writeError(w, http.StatusBadRequest, "pattern is required")
+		return
+	}
+
+	results, err := api.config.Forest.QuerySoil(req.Pattern, req.QueryFilter)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not available"):
+			writeError(w, http.StatusServiceUnavailable, err.Error())
+		case strings.Contains(err.Error(), "unknown query operator"):
+			writeError(w, http.StatusBadRequest, err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	if results == nil {
+		results = []core.QueryResult{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"count":   len(results),
+		"results": results,
+	})
+}
+
+func (api *API) handleListSoilIndexes(w http.ResponseWriter, r *http.Request) {
+	indexes, err := api.config.Forest.SoilIndexes()
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, indexes)
+}
+
+func (api *API) handleListSoilBuckets(w http.ResponseWriter, r *http.Request) {
+	buckets, err := api.config.Forest.ListSoilBuckets()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, buckets)
+}
+
+func (api *API) handleListSoilExpiry(w http.ResponseWriter, r *http.Request) {
+	pending, err := api.config.Forest.PendingSoilExpiries()
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, pending)
+}
+
+func (api *API) handleExpireSoilEntity(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		TTL string `json:"ttl"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	ttl, err := time.ParseDuration(req.TTL)
+	if err != nil || ttl <= 0 {
+		writeError(w, http.StatusBadRequest, "ttl must be a positive duration (e.g. \"24h\")")
+		return
+	}
+
+	entity := r.PathValue("entity")
+	if err := api.config.Forest.ExpireSoilEntity(entity, ttl); err != nil {
+		if strings.Contains(err.Error(), "not available") {
+			writeError(w, http.StatusServiceUnavailable, err.Error())
+		} else {
+			writeError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status":     "scheduled",
+		"entity":     entity,
+		"expires_at": time.Now().Add(ttl).Format(time.RFC3339),
+	})
+}
+
+func (api *API) handleCancelSoilExpiry(w http.ResponseWriter, r *http.Request) {
+	if err := api.config.Forest.CancelSoilExpiry(r.PathValue("entity")); err != nil {
+		if strings.Contains(err.Error(), "not available") {
+			writeError(w, http.StatusServiceUnavailable, err.Error())
+		} else {
+			writeError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// soilErrorStatus maps soil lookup errors to HTTP status codes.
+func soilErrorStatus(err error) int {
+	switch {
+	case strings.Contains(err.Error(), "not available"):
+		return http.StatusServiceUnavailable
+	case strings.Contains(err.Error(), "not found"):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (api *API) handleExportSoil(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	// Check the bucket before streaming so errors get a status code
+	if _, err := api.config.Forest.soilByName(query.Get("bucket")); err != nil {
+		writeError(w, soilErrorStatus(err), err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	if _, err := api.config.Forest.ExportSoil(w, query.Get("bucket"), query.Get("pattern")); err != nil {
+		// Headers are sent; the truncated body is all we can report
+		log.Printf("[API] Soil export failed: %v", err)
+	}
+}
+
+func (api *API) handleImportSoil(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	policy, err := core.ParseSoilConflictPolicy(query.Get("policy"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := api.config.Forest.ImportSoil(r.Body, query.Get("bucket"), policy, query.Get("humus") == "true")
+	if err != nil {
+		status := soilErrorStatus(err)
+		switch {
+		case strings.Contains(err.Error(), "line "):
+			status = http.StatusBadRequest
+		case strings.Contains(err.Error(), "only support"):
+			status = http.StatusBadRequest
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (api *API) handleBrowseSoil(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	keys, err := api.config.Forest.BrowseSoil(query.Get("bucket"), query.Get("prefix"))
+	if err != nil {
+		writeError(w, soilErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"count": len(keys),
+		"keys":  keys,
+	})
+}
+
+func (api *API) handleGetSoilEntity(w http.ResponseWriter, r *http.Request) {
+	entity, err := api.config.Forest.GetSoilEntity(r.URL.Query().Get("bucket"), r.PathValue("entity"))
+	if err != nil {
+		writeError(w, soilErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, entity)
+}
+
+func (api *API) handleSoilHistory(w http.ResponseWriter, r *http.Request) {
+	entity := r.PathValue("entity")
+	revisions, err := api.config.Forest.SoilHistory(r.URL.Query().Get("bucket"), entity)
+	if err != nil {
+		writeError(w, soilErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"entity":    entity,
+		"revisions": revisions,
+	})
+}
+
+func (api *API) handleSoilDiff(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	from, err := strconv.ParseUint(query.Get("from"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "from must be a revision number")
+		return
+	}
+	var to uint64
+	if query.Get("to") != "" {
+		if to, err = strconv.ParseUint(query.Get("to"), 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, "to must be a revision number")
+			return
+		}
+	}
+
+	entity := r.PathValue("entity")
+	changes, err := api.config.Forest.DiffSoilEntity(query.Get("bucket"), entity, from, to)
+	if err != nil {
+		status := soilErrorStatus(err)
+		if strings.Contains(err.Error(), "not in the bucket history") {
+			status = http.StatusNotFound
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"entity":  entity,
+		"from":    from,
+		"to":      to,
+		"changes": changes,
+	})
+}
+
+func (api *API) handleEditSoilEntity(w http.ResponseWriter, r *http.Request) {
+	revision, err := parseRevisionParam(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "failed to read body: "+err.Error())
+		return
+	}
+
+	slot, err := api.config.Forest.EditSoilEntity(r.PathValue("entity"), data, revision)
+	if err != nil {
+		writeError(w, adminEditStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"entity": r.PathValue("entity"),
+		"slot":   slot,
+	})
+}
+
+func (api *API) handleDeleteSoilEntity(w http.ResponseWriter, r *http.Request) {
+	revision, err := parseRevisionParam(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	slot, err := api.config.Forest.DeleteSoilEntity(r.PathValue("entity"), revision)
+	if err != nil {
+		writeError(w, adminEditStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"entity": r.PathValue("entity"),
+		"slot":   slot,
+	})
+}
+
+// parseRevisionParam reads the optional ?revision= of an admin edit.
+func parseRevisionParam(r *http.Request) (uint64, error) {
+	value := r.URL.Query().Get("revision")
+	if value == "" {
+		return 0, nil
+	}
+	revision, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("revision must be a number")
+	}
+	return revision, nil
+}
+
+// adminEditStatus maps soil browser edit errors to HTTP status codes.
+func adminEditStatus(err error) int {
+	switch {
+	case errors.Is(err, core.ErrRevisionConflict):
+		return http.StatusConflict
+	case errors.Is(err, core.ErrInvalidJSON):
+		return http.StatusBadRequest
+	case errors.Is(err, core.ErrEntityNotFound):
+		return http.StatusNotFound
+	default:
+		return soilErrorStatus(err)
+	}
+}
+
+func (api *API) handleSoilBrowser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(soilBrowserHTML)
+}
+
 func (api *API) handleReload(w http.ResponseWriter, r *http.Request) {
 	if api.config.ConfigPath == "" {
 		writeError(w, http.StatusBadRequest, "no config path configured")
@@ -518,14 +1044,19 @@ func (api *API) handleReload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := api.config.Forest.Reload(newCfg); err != nil {
+	// ?dry_run=true returns the plan without applying it
+	if r.URL.Query().Get("dry_run") == "true" {
+		writeJSON(w, http.StatusOK, api.config.Forest.PlanReload(newCfg))
+		return
+	}
+
+	result, err := api.config.Forest.Reload(newCfg)
+	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to reload: "+err.Error())
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]string{
-		"status": "reloaded",
-	})
+	writeJSON(w, http.StatusOK, result)
 }
 
 // =============================================================================
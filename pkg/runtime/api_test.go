@@ -6,6 +6,8 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -242,3 +244,120 @@ func TestClientFromEnv(t *testing.T) {
 
 // Ensure brain.Brain interface is satisfied
 var _ brain.Brain = (*mockBrain)(nil)
+
+func TestAPISoilBrowser(t *testing.T) {
+	js := setupTestJS(t)
+	soil, err := core.NewSoil(js)
+	if err != nil {
+		t.Fatalf("Failed to create soil: %v", err)
+	}
+	humus, err := core.NewHumus(js)
+	if err != nil {
+		t.Fatalf("Failed to create humus: %v", err)
+	}
+	forest := &Forest{soil: soil, humus: humus}
+	handler := NewAPI(APIConfig{Address: "127.0.0.1:0", Forest: forest}).server.Handler
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	soil.Put("tasks/1", []byte(`{"status":"open"}`))
+	soil.Put("tasks/1", []byte(`{"status":"stuck"}`))
+	soil.Put("contacts/1", []byte(`{}`))
+
+	w := do("GET", "/api/v1/soil/keys?prefix=tasks/", "")
+	var keys struct{ Keys []string }
+	json.NewDecoder(w.Body).Decode(&keys)
+	if w.Code != http.StatusOK || len(keys.Keys) != 1 || keys.Keys[0] != "tasks/1" {
+		t.Errorf("Unexpected keys response %d: %+v", w.Code, keys)
+	}
+
+	w = do("GET", "/api/v1/soil/entities/tasks/1", "")
+	var entity SoilEntity
+	json.NewDecoder(w.Body).Decode(&entity)
+	if w.Code != http.StatusOK || entity.Revision != 2 || string(entity.Data) != `{"status":"stuck"}` {
+		t.Errorf("Unexpected entity response %d: %+v", w.Code, entity)
+	}
+	if w := do("GET", "/api/v1/soil/entities/tasks/404", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for missing entity, got %d", w.Code)
+	}
+
+	w = do("GET", "/api/v1/soil/history/tasks/1", "")
+	var history struct{ Revisions []core.SoilRevision }
+	json.NewDecoder(w.Body).Decode(&history)
+	if w.Code != http.StatusOK || len(history.Revisions) != 2 {
+		t.Errorf("Unexpected history response %d: %+v", w.Code, history)
+	}
+
+	w = do("GET", "/api/v1/soil/diff/tasks/1?from=1", "")
+	var diff struct{ Changes []core.JSONChange }
+	json.NewDecoder(w.Body).Decode(&diff)
+	if w.Code != http.StatusOK || len(diff.Changes) != 1 || diff.Changes[0].To != "stuck" {
+		t.Errorf("Unexpected diff response %d: %+v", w.Code, diff)
+	}
+
+	// Edits are revision-checked and composted as admin
+	if w := do("PUT", "/api/v1/soil/entities/tasks/1?revision=1", `{"status":"open"}`); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for stale revision, got %d", w.Code)
+	}
+	if w := do("PUT", "/api/v1/soil/entities/tasks/1", `not json`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid JSON, got %d", w.Code)
+	}
+	if w := do("PUT", "/api/v1/soil/entities/tasks/1?revision=2", `{"status":"open"}`); w.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d: %s", w.Code, w.Body)
+	}
+	if w := do("DELETE", "/api/v1/soil/entities/contacts/1", ""); w.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d: %s", w.Code, w.Body)
+	}
+	if w := do("DELETE", "/api/v1/soil/entities/contacts/404", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for deleting a missing entity, got %d", w.Code)
+	}
+
+	for seq, want := range map[uint64]string{1: "update", 2: "delete"} {
+		msg, err := js.GetMsg("HUMUS", seq)
+		if err != nil {
+			t.Fatalf("Failed to read compost: %v", err)
+		}
+		var compost core.Compost
+		json.Unmarshal(msg.Data, &compost)
+		if compost.NimName != AdminNim || compost.Action != want {
+			t.Errorf("Compost %d: expected admin %s, got %+v", seq, want, compost)
+		}
+	}
+
+	if w := do("GET", "/soil", ""); w.Code != http.StatusOK || !bytes.Contains(w.Body.Bytes(), []byte("/api/v1/soil/keys")) {
+		t.Errorf("Expected soil browser page, got %d", w.Code)
+	}
+}
+
+func TestAPIImportHumusOnlyFromArchiveDir(t *testing.T) {
+	js := setupTestJS(t)
+	humus, err := core.NewHumus(js)
+	if err != nil {
+		t.Fatalf("Failed to create humus: %v", err)
+	}
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "humus-00000000000000000001.jsonl"), []byte(`{"entity":"tasks/1","action":"create","nim":"test","data":{},"slot":1}`+"\n"), 0644)
+	forest := &Forest{humus: humus, config: &Config{Humus: &HumusConfig{Archive: &HumusArchiveConfig{Dir: dir}}}}
+	handler := NewAPI(APIConfig{Address: "127.0.0.1:0", Forest: forest}).server.Handler
+
+	do := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/humus/import", bytes.NewReader([]byte(body)))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, file := range []string{"/etc/passwd", "../secret.jsonl", filepath.Join(dir, "..", "x.jsonl")} {
+		if w := do(`{"files": ["` + file + `"], "stream": "HUMUS_AUDIT_API"}`); w.Code != http.StatusForbidden {
+			t.Errorf("Expected %s to be forbidden, got %d: %s", file, w.Code, w.Body)
+		}
+	}
+	if w := do(`{"files": ["humus-00000000000000000001.jsonl"], "stream": "HUMUS_AUDIT_API"}`); w.Code != http.StatusOK {
+		t.Errorf("Expected a file in the archive dir to import, got %d: %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,105 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/yourusername/nimsforest/internal/core"
+	"github.com/yourusername/nimsforest/internal/nims"
+	"github.com/yourusername/nimsforest/internal/trees"
+)
+
+// The compiled nims and trees that ship with nimsforest.
+var (
+	BuiltinTrees = []string{"payment", "general"}
+	BuiltinNims  = []string{"aftersales", "general"}
+)
+
+func init() {
+	RegisterNim("aftersales", func(deps GoDeps) (core.Nim, error) {
+		if deps.Humus == nil || deps.Soil == nil {
+			return nil, fmt.Errorf("aftersales nim requires humus and soil")
+		}
+		return nims.NewAfterSalesNim(deps.Wind, deps.Humus, deps.Soil), nil
+	})
+	RegisterNim("general", func(deps GoDeps) (core.Nim, error) {
+		if deps.Humus == nil || deps.Soil == nil {
+			return nil, fmt.Errorf("general nim requires humus and soil")
+		}
+		return nims.NewGeneralNim(deps.Wind, deps.Humus, deps.Soil), nil
+	})
+	RegisterTree("payment", func(deps GoDeps) (core.Tree, error) {
+		if deps.River == nil {
+			return nil, fmt.Errorf("payment tree requires river")
+		}
+		return trees.NewPaymentTree(deps.Wind, deps.River), nil
+	})
+	RegisterTree("general", func(deps GoDeps) (core.Tree, error) {
+		if deps.River == nil {
+			return nil, fmt.Errorf("general tree requires river")
+		}
+		return trees.NewGeneralTree(deps.Wind, deps.River), nil
+	})
+}
+
+// Builtins are the built-in trees and nims started by StartBuiltins.
+type Builtins struct {
+	Trees []core.Tree
+	Nims  []core.Nim
+}
+
+// StartBuiltins starts the built-in trees and nims, except those forest
+// runs itself because its config declares them (type: go:<name>). A
+// declared built-in the forest failed to create or start is started here
+// instead. forest may be nil. On error the ones already started are stopped.
+func StartBuiltins(ctx context.Context, forest *Forest, deps GoDeps) (*Builtins, error) {
+	runningTrees, runningNims := forest.runningGoTypes()
+
+	b := &Builtins{}
+	for _, name := range BuiltinTrees {
+		if runningTrees[GoTypePrefix+name] {
+			continue
+		}
+		tree, err := newGoTree(GoTypePrefix+name, deps)
+		if err == nil {
+			if err = tree.Start(ctx); err != nil {
+				tree.Stop() // Release anything started before the failure
+			}
+		}
+		if err != nil {
+			b.Stop()
+			return nil, fmt.Errorf("failed to start %s tree: %w", name, err)
+		}
+		b.Trees = append(b.Trees, tree)
+		log.Printf("[Builtins] Planted %s tree (watches: %v)", name, tree.Patterns())
+	}
+	for _, name := range BuiltinNims {
+		if runningNims[GoTypePrefix+name] {
+			continue
+		}
+		nim, err := newGoNim(GoTypePrefix+name, deps)
+		if err == nil {
+			if err = nim.Start(ctx); err != nil {
+				nim.Stop() // Release anything started before the failure
+			}
+		}
+		if err != nil {
+			b.Stop()
+			return nil, fmt.Errorf("failed to start %s nim: %w", name, err)
+		}
+		b.Nims = append(b.Nims, nim)
+		log.Printf("[Builtins] Awakened %s nim (catches: %v)", name, nim.Subjects())
+	}
+	return b, nil
+}
+
+// Stop stops the built-in trees and nims.
+func (b *Builtins) Stop() {
+	for _, tree := range b.Trees {
+		tree.Stop()
+	}
+	for _, nim := range b.Nims {
+		nim.Stop()
+	}
+}
@@ -7,7 +7,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"time"
+
+	"github.com/yourusername/nimsforest/internal/core"
 )
 
 // Client is the CLI client for the NimsForest management API.
@@ -241,9 +244,23 @@ func (c *Client) AddTree(name, watches, publishes, script string) error {
 
 // AddTreeFromConfig adds a tree from a config struct.
 func (c *Client) AddTreeFromConfig(cfg TreeConfig) error {
+	if cfg.Type != "" {
+		return c.AddGoTree(cfg.Name, cfg.Type)
+	}
+	if cfg.Pool != 0 || cfg.Ordered {
+		return c.addComponent("/api/v1/trees", map[string]any{
+			"name": cfg.Name, "watches": cfg.Watches, "publishes": cfg.Publishes,
+			"script": cfg.Script, "pool": cfg.Pool, "ordered": cfg.Ordered,
+		})
+	}
 	return c.AddTree(cfg.Name, cfg.Watches, cfg.Publishes, cfg.Script)
 }
 
+// AddGoTree adds a registered Go tree, e.g. type "go:payment".
+func (c *Client) AddGoTree(name, goType string) error {
+	return c.addComponent("/api/v1/trees", map[string]any{"name": name, "type": goType})
+}
+
 // RemoveTree removes a tree by name.
 func (c *Client) RemoveTree(name string) error {
 	req, _ := http.NewRequest(http.MethodDelete, c.baseURL+"/api/v1/trees/"+name, nil)
@@ -306,9 +323,25 @@ func (c *Client) AddTreeHouse(name, subscribes, publishes, script string) error
 
 // AddTreeHouseFromConfig adds a treehouse from a config struct.
 func (c *Client) AddTreeHouseFromConfig(cfg TreeHouseConfig) error {
+	if cfg.Type != "" {
+		return c.AddGoTreeHouse(cfg.Name, cfg.Type, cfg.Verify)
+	}
+	if cfg.Pool != 0 || cfg.Ordered || len(cfg.Emits) > 0 || cfg.State != nil {
+		return c.addComponent("/api/v1/treehouses", map[string]any{
+			"name": cfg.Name, "subscribes": cfg.Subscribes, "publishes": cfg.Publishes,
+			"emits": cfg.Emits, "script": cfg.Script, "pool": cfg.Pool, "ordered": cfg.Ordered,
+			"state": cfg.State,
+		})
+	}
 	return c.AddTreeHouse(cfg.Name, cfg.Subscribes, cfg.Publishes, cfg.Script)
 }
 
+// AddGoTreeHouse adds a registered Go treehouse, re-executing the given
+// fraction of leaves (0-1) to verify it is deterministic.
+func (c *Client) AddGoTreeHouse(name, goType string, verify float64) error {
+	return c.addComponent("/api/v1/treehouses", map[string]any{"name": name, "type": goType, "verify": verify})
+}
+
 // RemoveTreeHouse removes a treehouse by name.
 func (c *Client) RemoveTreeHouse(name string) error {
 	req, _ := http.NewRequest(http.MethodDelete, c.baseURL+"/api/v1/treehouses/"+name, nil)
@@ -371,9 +404,50 @@ func (c *Client) AddNim(name, subscribes, publishes, prompt string) error {
 
 // AddNimFromConfig adds a nim from a config struct.
 func (c *Client) AddNimFromConfig(cfg NimConfig) error {
+	if cfg.Type != "" {
+		return c.AddGoNim(cfg.Name, cfg.Type)
+	}
 	return c.AddNim(cfg.Name, cfg.Subscribes, cfg.Publishes, cfg.Prompt)
 }
 
+// AddGoNim adds a registered Go nim, e.g. type "go:aftersales".
+func (c *Client) AddGoNim(name, goType string) error {
+	return c.addComponent("/api/v1/nims", map[string]any{"name": name, "type": goType})
+}
+
+// Scripts returns the running version and latest reload of every script
+// and prompt.
+func (c *Client) Scripts() ([]ScriptStatus, error) {
+	var scripts []ScriptStatus
+	if err := c.getJSON("/api/v1/scripts", &scripts); err != nil {
+		return nil, err
+	}
+	return scripts, nil
+}
+
+// RegisteredTypes returns the Go component types compiled into the daemon.
+func (c *Client) RegisteredTypes() (*GoRegistry, error) {
+	var registry GoRegistry
+	if err := c.getJSON("/api/v1/registry", &registry); err != nil {
+		return nil, err
+	}
+	return &registry, nil
+}
+
+func (c *Client) addComponent(path string, payload map[string]any) error {
+	data, _ := json.Marshal(payload)
+	resp, err := c.httpClient.Post(c.baseURL+path, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("cannot connect to nimsforest daemon: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return c.parseError(resp)
+	}
+	return nil
+}
+
 // RemoveNim removes a nim by name.
 func (c *Client) RemoveNim(name string) error {
 	req, _ := http.NewRequest(http.MethodDelete, c.baseURL+"/api/v1/nims/"+name, nil)
@@ -390,12 +464,109 @@ func (c *Client) RemoveNim(name string) error {
 }
 
 // =============================================================================
-// Reload
+// Projections
 // =============================================================================
 
-// Reload reloads the forest configuration from disk.
-func (c *Client) Reload() error {
-	resp, err := c.httpClient.Post(c.baseURL+"/-/reload", "application/json", nil)
+// ListProjections returns the status of all projections.
+func (c *Client) ListProjections() ([]core.ProjectionStatus, error) {
+	resp, err := c.httpClient.Get(c.baseURL + "/api/v1/projections")
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to nimsforest daemon: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.parseError(resp)
+	}
+
+	var projections []core.ProjectionStatus
+	if err := json.NewDecoder(resp.Body).Decode(&projections); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return projections, nil
+}
+
+// RebuildProjection discards a projection's view and replays Humus into it.
+func (c *Client) RebuildProjection(name string) error {
+	resp, err := c.httpClient.Post(c.baseURL+"/api/v1/projections/"+name+"/rebuild", "application/json", nil)
+	if err != nil {
+		return fmt.Errorf("cannot connect to nimsforest daemon: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return c.parseError(resp)
+	}
+	return nil
+}
+
+// ListProjectionKeys returns all keys in a projection's view.
+func (c *Client) ListProjectionKeys(name string) ([]string, error) {
+	resp, err := c.httpClient.Get(c.baseURL + "/api/v1/projections/" + name + "/keys")
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to nimsforest daemon: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.parseError(resp)
+	}
+
+	var keys []string
+	if err := json.NewDecoder(resp.Body).Decode(&keys); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return keys, nil
+}
+
+// GetProjectionKey returns the value stored at key in a projection's view.
+func (c *Client) GetProjectionKey(name, key string) (interface{}, error) {
+	keyPath := (&url.URL{Path: key}).EscapedPath()
+	resp, err := c.httpClient.Get(c.baseURL + "/api/v1/projections/" + name + "/keys/" + keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to nimsforest daemon: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.parseError(resp)
+	}
+
+	var result struct {
+		Value interface{} `json:"value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return result.Value, nil
+}
+
+// =============================================================================
+// Humus
+// =============================================================================
+
+// HumusStatus returns the state of the HUMUS stream and its archiver.
+func (c *Client) HumusStatus() (*HumusStatus, error) {
+	resp, err := c.httpClient.Get(c.baseURL + "/api/v1/humus")
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to nimsforest daemon: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.parseError(resp)
+	}
+
+	var status HumusStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &status, nil
+}
+
+// RotateHumusArchive finishes the current humus archive file.
+func (c *Client) RotateHumusArchive() error {
+	resp, err := c.httpClient.Post(c.baseURL+"/api/v1/humus/archive/rotate", "application/json", nil)
 	if err != nil {
 		return fmt.Errorf("cannot connect to nimsforest daemon: %w", err)
 	}
@@ -407,6 +578,352 @@ func (c *Client) Reload() error {
 	return nil
 }
 
+// ImportHumusArchives loads archive files (paths on the daemon's host) into a stream.
+func (c *Client) ImportHumusArchives(files []string, stream string) (*core.ImportResult, error) {
+	payload := map[string]interface{}{
+		"files":  files,
+		"stream": stream,
+	}
+
+	data, _ := json.Marshal(payload)
+	resp, err := c.httpClient.Post(c.baseURL+"/api/v1/humus/import", "application/json", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to nimsforest daemon: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.parseError(resp)
+	}
+
+	var result core.ImportResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &result, nil
+}
+
+// =============================================================================
+// Soil
+// =============================================================================
+
+// QuerySoil finds entities in soil matching a key pattern and filter.
+func (c *Client) QuerySoil(pattern string, filter core.QueryFilter) ([]core.QueryResult, error) {
+	payload := struct {
+		Pattern string `json:"pattern"`
+		core.QueryFilter
+	}{pattern, filter}
+
+	data, _ := json.Marshal(payload)
+	resp, err := c.httpClient.Post(c.baseURL+"/api/v1/soil/query", "application/json", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to nimsforest daemon: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.parseError(resp)
+	}
+
+	var result struct {
+		Results []core.QueryResult `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return result.Results, nil
+}
+
+// ListSoilIndexes returns the secondary indexes declared on soil.
+func (c *Client) ListSoilIndexes() ([]core.SoilIndex, error) {
+	resp, err := c.httpClient.Get(c.baseURL + "/api/v1/soil/indexes")
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to nimsforest daemon: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.parseError(resp)
+	}
+
+	var indexes []core.SoilIndex
+	if err := json.NewDecoder(resp.Body).Decode(&indexes); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return indexes, nil
+}
+
+// ListSoilBuckets returns the status of every soil bucket.
+func (c *Client) ListSoilBuckets() ([]SoilBucketStatus, error) {
+	resp, err := c.httpClient.Get(c.baseURL + "/api/v1/soil/buckets")
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to nimsforest daemon: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.parseError(resp)
+	}
+
+	var buckets []SoilBucketStatus
+	if err := json.NewDecoder(resp.Body).Decode(&buckets); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return buckets, nil
+}
+
+// ListSoilExpiries returns the scheduled soil expiries that haven't fired.
+func (c *Client) ListSoilExpiries() ([]core.SoilExpiry, error) {
+	resp, err := c.httpClient.Get(c.baseURL + "/api/v1/soil/expiry")
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to nimsforest daemon: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.parseError(resp)
+	}
+
+	var pending []core.SoilExpiry
+	if err := json.NewDecoder(resp.Body).Decode(&pending); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return pending, nil
+}
+
+// ExpireSoilEntity schedules an entity to be deleted from soil after ttl (e.g. "24h").
+func (c *Client) ExpireSoilEntity(entity, ttl string) error {
+	data, _ := json.Marshal(map[string]string{"ttl": ttl})
+	req, _ := http.NewRequest(http.MethodPut, c.baseURL+"/api/v1/soil/expiry/"+url.PathEscape(entity), bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot connect to nimsforest daemon: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return c.parseError(resp)
+	}
+	return nil
+}
+
+// CancelSoilExpiry removes the scheduled expiry of an entity.
+func (c *Client) CancelSoilExpiry(entity string) error {
+	req, _ := http.NewRequest(http.MethodDelete, c.baseURL+"/api/v1/soil/expiry/"+url.PathEscape(entity), nil)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot connect to nimsforest daemon: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return c.parseError(resp)
+	}
+	return nil
+}
+
+// BrowseSoil returns the keys of a soil bucket starting with prefix.
+func (c *Client) BrowseSoil(bucket, prefix string) ([]string, error) {
+	query := soilQuery(bucket)
+	query.Set("prefix", prefix)
+
+	var result struct {
+		Keys []string `json:"keys"`
+	}
+	if err := c.getJSON("/api/v1/soil/keys?"+query.Encode(), &result); err != nil {
+		return nil, err
+	}
+	return result.Keys, nil
+}
+
+// GetSoilEntity returns an entity's current state.
+func (c *Client) GetSoilEntity(bucket, entity string) (*SoilEntity, error) {
+	var result SoilEntity
+	if err := c.getJSON("/api/v1/soil/entities/"+url.PathEscape(entity)+"?"+soilQuery(bucket).Encode(), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SoilHistory returns the revisions of an entity, oldest first.
+func (c *Client) SoilHistory(bucket, entity string) ([]core.SoilRevision, error) {
+	var result struct {
+		Revisions []core.SoilRevision `json:"revisions"`
+	}
+	if err := c.getJSON("/api/v1/soil/history/"+url.PathEscape(entity)+"?"+soilQuery(bucket).Encode(), &result); err != nil {
+		return nil, err
+	}
+	return result.Revisions, nil
+}
+
+// DiffSoilEntity compares two revisions of an entity; to 0 means the latest.
+func (c *Client) DiffSoilEntity(bucket, entity string, from, to uint64) ([]core.JSONChange, error) {
+	query := soilQuery(bucket)
+	query.Set("from", fmt.Sprint(from))
+	if to > 0 {
+		query.Set("to", fmt.Sprint(to))
+	}
+
+	var result struct {
+		Changes []core.JSONChange `json:"changes"`
+	}
+	if err := c.getJSON("/api/v1/soil/diff/"+url.PathEscape(entity)+"?"+query.Encode(), &result); err != nil {
+		return nil, err
+	}
+	return result.Changes, nil
+}
+
+// EditSoilEntity composts new state for an entity as nim "admin" and
+// returns the humus slot. A non-zero revision must match the current one.
+func (c *Client) EditSoilEntity(entity string, data []byte, revision uint64) (uint64, error) {
+	return c.adminEdit(http.MethodPut, entity, data, revision)
+}
+
+// DeleteSoilEntity composts the deletion of an entity as nim "admin".
+func (c *Client) DeleteSoilEntity(entity string, revision uint64) (uint64, error) {
+	return c.adminEdit(http.MethodDelete, entity, nil, revision)
+}
+
+func (c *Client) adminEdit(method, entity string, data []byte, revision uint64) (uint64, error) {
+	path := c.baseURL + "/api/v1/soil/entities/" + url.PathEscape(entity)
+	if revision > 0 {
+		path += fmt.Sprintf("?revision=%d", revision)
+	}
+	req, _ := http.NewRequest(method, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("cannot connect to nimsforest daemon: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return 0, c.parseError(resp)
+	}
+
+	var result struct {
+		Slot uint64 `json:"slot"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return result.Slot, nil
+}
+
+// getJSON fetches an API path and decodes the JSON response into v.
+func (c *Client) getJSON(path string, v interface{}) error {
+	resp, err := c.httpClient.Get(c.baseURL + path)
+	if err != nil {
+		return fmt.Errorf("cannot connect to nimsforest daemon: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return c.parseError(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// soilQuery returns query parameters selecting a soil bucket.
+func soilQuery(bucket string) url.Values {
+	query := url.Values{}
+	if bucket != "" {
+		query.Set("bucket", bucket)
+	}
+	return query
+}
+
+// ExportSoil streams the entities of a soil bucket matching pattern to w
+// as JSON lines. An empty bucket means the default soil.
+func (c *Client) ExportSoil(w io.Writer, bucket, pattern string) error {
+	query := soilQuery(bucket)
+	if pattern != "" {
+		query.Set("pattern", pattern)
+	}
+
+	resp, err := c.transferClient().Get(c.baseURL + "/api/v1/soil/export?" + query.Encode())
+	if err != nil {
+		return fmt.Errorf("cannot connect to nimsforest daemon: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return c.parseError(resp)
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("failed to read export: %w", err)
+	}
+	return nil
+}
+
+// ImportSoil loads a soil export from r into a bucket. Policy is skip,
+// overwrite or revision; viaHumus records the import in humus.
+func (c *Client) ImportSoil(r io.Reader, bucket, policy string, viaHumus bool) (*core.SoilImportResult, error) {
+	query := soilQuery(bucket)
+	if policy != "" {
+		query.Set("policy", policy)
+	}
+	if viaHumus {
+		query.Set("humus", "true")
+	}
+
+	resp, err := c.transferClient().Post(c.baseURL+"/api/v1/soil/import?"+query.Encode(), "application/x-ndjson", r)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to nimsforest daemon: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.parseError(resp)
+	}
+
+	var result core.SoilImportResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &result, nil
+}
+
+// transferClient returns an HTTP client without the request timeout, for
+// exports and imports that can take longer than a normal API call.
+func (c *Client) transferClient() *http.Client {
+	transfer := *c.httpClient
+	transfer.Timeout = 0
+	return &transfer
+}
+
+// =============================================================================
+// Reload
+// =============================================================================
+
+// Reload reloads the forest configuration from disk and returns what was
+// added, changed, removed and failed. With dryRun it only returns the plan.
+func (c *Client) Reload(dryRun bool) (*ReloadResult, error) {
+	path := "/api/v1/reload"
+	if dryRun {
+		path += "?dry_run=true"
+	}
+	resp, err := c.httpClient.Post(c.baseURL+path, "application/json", nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to nimsforest daemon: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.parseError(resp)
+	}
+
+	var result ReloadResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &result, nil
+}
+
 // =============================================================================
 // Helpers
 // =============================================================================
@@ -0,0 +1,70 @@
+package runtime
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/yourusername/nimsforest/internal/core"
+	"github.com/yourusername/nimsforest/internal/windwaker"
+)
+
+// beatStale is how long a beat is used as the current time before the clock
+// falls back to the wall clock.
+const beatStale = time.Second
+
+// BeatClock tells the time of the latest WindWaker beat, so scripts handling
+// leaves during the same beat see the same time.
+type BeatClock struct {
+	wind *core.Wind
+	last atomic.Int64 // Beat timestamp in nanoseconds
+
+	mu  sync.Mutex
+	sub *nats.Subscription
+}
+
+// NewBeatClock creates a clock that follows the beats on the wind.
+func NewBeatClock(wind *core.Wind) *BeatClock {
+	return &BeatClock{wind: wind}
+}
+
+// Start begins catching beats.
+func (c *BeatClock) Start() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.sub != nil {
+		return nil
+	}
+	sub, err := windwaker.CatchBeatFunc(c.wind, "clock", func(beat windwaker.Beat) error {
+		c.last.Store(beat.Ts)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	c.sub = sub
+	return nil
+}
+
+// Stop stops catching beats.
+func (c *BeatClock) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.sub != nil {
+		c.sub.Unsubscribe()
+		c.sub = nil
+	}
+}
+
+// Now returns the time of the latest beat, or the wall clock if no beat
+// arrived within the last second.
+func (c *BeatClock) Now() time.Time {
+	now := time.Now()
+	if last := time.Unix(0, c.last.Load()); now.Sub(last) < beatStale {
+		return last
+	}
+	return now
+}
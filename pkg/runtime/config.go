@@ -19,6 +19,14 @@ type Config struct {
 	Nims       map[string]NimConfig       `yaml:"nims"`
 	Songbirds  map[string]SongbirdConfig  `yaml:"songbirds"`
 	Viewer     *ViewerConfig              `yaml:"viewer,omitempty"`
+	Humus      *HumusConfig               `yaml:"humus,omitempty"`
+	Soil       *SoilConfig                `yaml:"soil,omitempty"`
+	Lua        *LuaConfig                 `yaml:"lua,omitempty"`
+	WASM       *WASMConfig                `yaml:"wasm,omitempty"`
+	Prompts    *PromptsConfig             `yaml:"prompts,omitempty"`
+	Watch      *WatchConfig               `yaml:"watch,omitempty"`
+
+	Projections map[string]ProjectionConfig `yaml:"projections,omitempty"`
 
 	// BaseDir is the directory from which the config was loaded.
 	// Used to resolve relative script/prompt paths.
@@ -51,6 +59,230 @@ type ViewerConfig struct {
 	OnlyOnChange *bool `yaml:"only_on_change,omitempty"`
 }
 
+// HumusConfig configures the HUMUS state change stream and its decomposers.
+type HumusConfig struct {
+	// Partitions is the number of entity partitions. Each partition is
+	// decomposed in order by one land, and partitions are spread across
+	// the decomposer pool. Only applied when the stream is first created.
+	// Default: 8
+	Partitions int `yaml:"partitions,omitempty"`
+
+	// MaxAge is how long composts are kept in the stream (e.g. "720h").
+	// Use "-1s" to keep them forever.
+	// Default: 168h (7 days)
+	MaxAge string `yaml:"max_age,omitempty"`
+
+	// MaxMsgs is the maximum number of composts kept. -1 means unlimited.
+	// Default: 1000000
+	MaxMsgs int64 `yaml:"max_msgs,omitempty"`
+
+	// MaxBytes is the maximum stream size in bytes. Default: unlimited.
+	MaxBytes int64 `yaml:"max_bytes,omitempty"`
+
+	// Archive exports humus to files before retention discards it.
+	Archive *HumusArchiveConfig `yaml:"archive,omitempty"`
+}
+
+// HumusArchiveConfig configures the humus archiver, which continuously
+// exports composts to rotating, gzip-compressed JSONL files.
+type HumusArchiveConfig struct {
+	// Dir is the directory archive files are written to.
+	Dir string `yaml:"dir"`
+
+	// MaxFileBytes rotates a file after this many uncompressed bytes.
+	// Default: 64 MiB
+	MaxFileBytes int64 `yaml:"max_file_bytes,omitempty"`
+
+	// RotateEvery rotates files on a fixed cadence counted in WindWaker
+	// beats, like a ceremony (e.g. "1h"). Default: rotate on size only.
+	RotateEvery string `yaml:"rotate_every,omitempty"`
+}
+
+// LuaConfig sets the sandbox limits of Lua tree and treehouse scripts.
+// A call that passes a limit fails with a handler error.
+type LuaConfig struct {
+	// Timeout is how long one call may run (e.g. "500ms"). Default: 1s
+	Timeout string `yaml:"timeout,omitempty"`
+
+	// CallStack is the maximum depth of nested Lua calls. Default: 200
+	CallStack int `yaml:"call_stack,omitempty"`
+
+	// Registry is the maximum number of value stack slots. Default: 262144
+	Registry int `yaml:"registry,omitempty"`
+
+	// MemoryMB is how much a call may allocate through library functions or
+	// hold in the VM. Default: 128
+	MemoryMB int `yaml:"memory_mb,omitempty"`
+}
+
+// Limits returns the configured limits; unset ones take their default.
+func (c *LuaConfig) Limits() LuaLimits {
+	if c == nil {
+		return DefaultLuaLimits()
+	}
+	timeout, _ := time.ParseDuration(c.Timeout)
+	return LuaLimits{
+		Timeout:       timeout,
+		CallStackSize: c.CallStack,
+		RegistrySize:  c.Registry,
+		MemoryBytes:   int64(c.MemoryMB) << 20,
+	}.withDefaults()
+}
+
+// WASMConfig sets the limits of WASM tree and treehouse modules. A call
+// that passes a limit fails with a handler error. Only time and memory are
+// limited: wazero has no instruction metering, so there is no fuel limit.
+type WASMConfig struct {
+	// Timeout is how long one call may run (e.g. "500ms"), the only bound
+	// on the work a call does. Default: 1s
+	Timeout string `yaml:"timeout,omitempty"`
+
+	// MemoryMB is how far an instance's linear memory may grow. Default: 64
+	MemoryMB int `yaml:"memory_mb,omitempty"`
+
+	// CacheDir keeps compiled modules across restarts. Default: memory only
+	CacheDir string `yaml:"cache_dir,omitempty"`
+}
+
+// Limits returns the configured limits; unset ones take their default.
+func (c *WASMConfig) Limits() WASMLimits {
+	if c == nil {
+		return DefaultWASMLimits()
+	}
+	timeout, _ := time.ParseDuration(c.Timeout)
+	return WASMLimits{
+		Timeout:     timeout,
+		MemoryBytes: int64(c.MemoryMB) << 20,
+	}.withDefaults()
+}
+
+// PromptsConfig sets what the prompts of template nims share.
+type PromptsConfig struct {
+	Partials string `yaml:"partials,omitempty"` // Directory of templates prompts include by file name
+}
+
+// WatchConfig configures hot reload of Lua scripts and prompt templates.
+// Changed files are reloaded into the running components.
+type WatchConfig struct {
+	Interval string `yaml:"interval,omitempty"` // How often files are checked (default: 1s)
+	Disabled bool   `yaml:"disabled,omitempty"` // Turn hot reload off
+}
+
+// interval returns the polling interval; a nil config polls at the default.
+func (c *WatchConfig) interval() time.Duration {
+	if c == nil || c.Interval == "" {
+		return DefaultWatchInterval
+	}
+	d, _ := time.ParseDuration(c.Interval)
+	return d
+}
+
+// SoilConfig configures the SOIL key-value store.
+type SoilConfig struct {
+	// Indexes are secondary indexes on JSON fields of entities, used to
+	// answer soil queries without scanning every entity.
+	Indexes map[string]SoilIndexConfig `yaml:"indexes,omitempty"`
+
+	// Buckets are additional named soil buckets (KV bucket SOIL_<NAME>),
+	// each with its own history, TTL, storage and replicas.
+	Buckets map[string]SoilBucketConfig `yaml:"buckets,omitempty"`
+
+	// Expiry configures per-entity expiry. Expired and due entities drop a
+	// soil.expired.<key> leaf on the wind.
+	Expiry *SoilExpiryConfig `yaml:"expiry,omitempty"`
+
+	// Bridge republishes soil changes as soil.changed.<key> leaves, so
+	// TreeHouses and Nims can react to state changes.
+	Bridge *SoilBridgeConfig `yaml:"bridge,omitempty"`
+
+	// Bedrock mirrors humus into persistent storage beneath soil and
+	// restores missing soil entities from it on startup.
+	Bedrock *BedrockConfig `yaml:"bedrock,omitempty"`
+}
+
+// BedrockConfig configures the bedrock beneath soil.
+type BedrockConfig struct {
+	// Type is file (a JSON file per entity) or git (a file bedrock that
+	// commits every compost, authored by its nim). Default: file
+	Type string `yaml:"type,omitempty"`
+
+	// Dir is the bedrock root directory.
+	Dir string `yaml:"dir"`
+}
+
+// SoilBridgeConfig configures the soil-to-wind bridge.
+type SoilBridgeConfig struct {
+	// Patterns are the entity key patterns to republish, e.g. "task-*".
+	// Default: all entities
+	Patterns []string `yaml:"patterns,omitempty"`
+}
+
+// SoilExpiryConfig configures the soil expirer.
+type SoilExpiryConfig struct {
+	// Due schedules entities for the timestamp in one of their fields.
+	// Default: DefaultSoilDue; an empty list ("due: []") tracks none
+	Due []SoilDueConfig `yaml:"due,omitempty"`
+
+	// TTL deletes entities a fixed time after each write.
+	TTL []SoilTTLConfig `yaml:"ttl,omitempty"`
+
+	// CheckEvery is how often the schedule is checked, on WindWaker
+	// beats (e.g. "10s"). Default: 1s
+	CheckEvery string `yaml:"check_every,omitempty"`
+}
+
+// SoilDueConfig schedules entities by a timestamp field.
+type SoilDueConfig struct {
+	Pattern string `yaml:"pattern"` // Entity key glob, e.g. "task-*"
+	Field   string `yaml:"field"`   // RFC 3339 timestamp field, e.g. "due_date"
+}
+
+// DefaultSoilDue is the due field tracked when forest.yaml sets none:
+// follow-up tasks by their due_date, which AfterSalesNim escalates.
+var DefaultSoilDue = []SoilDueConfig{{Pattern: "task-*", Field: "due_date"}}
+
+// DueFields returns the configured due fields, or DefaultSoilDue when the
+// section or its due list is missing.
+func (c *SoilExpiryConfig) DueFields() []SoilDueConfig {
+	if c == nil || c.Due == nil {
+		return DefaultSoilDue
+	}
+	return c.Due
+}
+
+// SoilTTLConfig deletes entities a fixed time after each write.
+type SoilTTLConfig struct {
+	Pattern string `yaml:"pattern"` // Entity key glob, e.g. "session-*"
+	After   string `yaml:"after"`   // Duration after each write, e.g. "24h"
+}
+
+// SoilBucketConfig configures a named soil bucket.
+type SoilBucketConfig struct {
+	Description string `yaml:"description,omitempty"`
+	History     int    `yaml:"history,omitempty"`  // Revisions kept per entity, 1-64 (default: 10)
+	TTL         string `yaml:"ttl,omitempty"`      // Expire entities after this long (e.g. "24h"); empty keeps them
+	Storage     string `yaml:"storage,omitempty"`  // file or memory (default: file)
+	Replicas    int    `yaml:"replicas,omitempty"` // Replicas in a cluster (default: 1)
+}
+
+// SoilIndexConfig declares a secondary index on soil entities.
+type SoilIndexConfig struct {
+	Pattern string   `yaml:"pattern"` // Entity key pattern, e.g. "tasks/*"
+	Fields  []string `yaml:"fields"`  // JSON fields to index, e.g. [customer_id, status]
+}
+
+// ProjectionConfig defines a Projection - a read model built from Humus.
+// Exactly one of Script or Func must be set.
+type ProjectionConfig struct {
+	Name     string `yaml:"-"`                  // Set from map key
+	Entities string `yaml:"entities,omitempty"` // Entity type to project (e.g. "tasks"); empty for all
+	Nim      string `yaml:"nim,omitempty"`      // Only project composts from this nim
+	Action   string `yaml:"action,omitempty"`   // Only project this action (create, update, delete)
+	Script   string `yaml:"script,omitempty"`   // Path to Lua script defining project(compost)
+	Func     string `yaml:"func,omitempty"`     // Name of a Go projection registered with RegisterProjection
+	Bucket   string `yaml:"bucket,omitempty"`   // KV bucket for the view (default: PROJECTION_<NAME>)
+}
+
 // SourceConfig defines a Source - an entry point for external data.
 type SourceConfig struct {
 	Name string `yaml:"-"` // Set from map key
@@ -93,27 +325,187 @@ type CursorConfig struct {
 }
 
 // TreeConfig defines a Tree - a River-to-Wind adapter that parses external data.
+// Set Type to "go:<name>" to run a Go tree registered with RegisterTree
+// instead of a script; it then decides what it watches and publishes.
 type TreeConfig struct {
-	Name      string `yaml:"-"`         // Set from map key
-	Watches   string `yaml:"watches"`   // River subject to observe (JetStream)
-	Publishes string `yaml:"publishes"` // Wind subject to publish Leaves to
-	Script    string `yaml:"script"`    // Path to Lua script
+	Name      string `yaml:"-"`                 // Set from map key
+	Type      string `yaml:"type,omitempty"`    // Registered Go tree, e.g. "go:payment"
+	Watches   string `yaml:"watches"`           // River subject to observe (JetStream)
+	Publishes string `yaml:"publishes"`         // Wind subject to publish Leaves to
+	Script    string `yaml:"script"`            // Path to Lua script or WASM module
+	Engine    string `yaml:"engine,omitempty"`  // lua, expr or wasm (default: wasm for .wasm scripts, else lua)
+	Pool      int    `yaml:"pool,omitempty"`    // Lua VMs processing in parallel (default 4)
+	Ordered   bool   `yaml:"ordered,omitempty"` // One VM, data processed in arrival order
+
+	ExprConfig `yaml:",inline"` // Expressions of the expr engine
+	Access     ScriptAccess     `yaml:"access,omitempty"` // Soil and humus the script may use
 }
 
 // TreeHouseConfig defines a TreeHouse - a Lua-based data transformer.
+// Set Type to "go:<name>" to run a Go treehouse registered with
+// RegisterTreeHouse instead; it then decides what it catches and publishes.
 type TreeHouseConfig struct {
-	Name       string `yaml:"-"`          // Set from map key
-	Subscribes string `yaml:"subscribes"` // NATS subject to listen on
-	Publishes  string `yaml:"publishes"`  // NATS subject to publish to
-	Script     string `yaml:"script"`     // Path to Lua script
+	Name       string   `yaml:"-"`                 // Set from map key
+	Type       string   `yaml:"type,omitempty"`    // Registered Go treehouse, e.g. "go:enricher"
+	Subscribes string   `yaml:"subscribes"`        // NATS subject to listen on
+	Publishes  string   `yaml:"publishes"`         // NATS subject to publish to; may contain {field} placeholders
+	Emits      []string `yaml:"emits,omitempty"`   // Other subject patterns script outputs may name, e.g. "alerts.*" or "orders.{region}.>"
+	Script     string   `yaml:"script"`            // Path to Lua script or WASM module
+	Engine     string   `yaml:"engine,omitempty"`  // lua, expr or wasm (default: wasm for .wasm scripts, else lua)
+	Verify     float64  `yaml:"verify,omitempty"`  // Go only: fraction of leaves re-executed to check determinism (0-1)
+	Pool       int      `yaml:"pool,omitempty"`    // Lua VMs processing in parallel (default 4)
+	Ordered    bool     `yaml:"ordered,omitempty"` // One VM and no queue group; leaves processed in arrival order
+
+	ExprConfig `yaml:",inline"`   // Expressions of the expr engine
+	Access     ScriptAccess       `yaml:"access,omitempty"` // Soil and humus the script may use
+	State      *ScriptStateConfig `yaml:"state,omitempty"`  // Keyed state for the script's state module
+}
+
+// ScriptStateConfig gives a Lua treehouse keyed state that survives restarts,
+// kept in its own KV bucket.
+type ScriptStateConfig struct {
+	TTL    string `yaml:"ttl,omitempty" json:"ttl,omitempty"`       // Default expiry of keys, e.g. "24h"; empty keeps them
+	Bucket string `yaml:"bucket,omitempty" json:"bucket,omitempty"` // KV bucket (default: STATE_<NAME>)
+}
+
+// ttl returns the configured default expiry, or zero.
+func (c *ScriptStateConfig) ttl() time.Duration {
+	d, _ := time.ParseDuration(c.TTL)
+	return d
+}
+
+// ScriptAccess declares which soil keys and humus entities a Lua script may
+// use. Entries are key prefixes, e.g. "contacts/"; "*" allows every key.
+// Without entries the script has no access.
+type ScriptAccess struct {
+	Soil  []string `yaml:"soil,omitempty"`  // Keys soil.get and soil.query may read
+	Humus []string `yaml:"humus,omitempty"` // Entities humus.compost may write
+}
+
+// validatePool checks a Lua component's pool size against its ordered mode.
+func validatePool(pool int, ordered bool) error {
+	if pool < 0 {
+		return fmt.Errorf("pool must not be negative")
+	}
+	if ordered && pool > 1 {
+		return fmt.Errorf("ordered runs a single VM; pool must be 0 or 1")
+	}
+	return nil
+}
+
+// validateEmits checks that every emits entry is a valid subject pattern.
+func validateEmits(emits []string) error {
+	for _, pattern := range emits {
+		if !validSubjectPattern(subjectPattern(pattern)) {
+			return fmt.Errorf("invalid emits pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// Engines a tree or treehouse can run on.
+const (
+	EngineLua  = "lua"  // A Lua script (default)
+	EngineExpr = "expr" // Inline expressions, see ExprConfig
+	EngineWASM = "wasm" // A WebAssembly module (default for .wasm scripts)
+)
+
+// scriptEngine returns the engine a component runs on: the configured one,
+// or by the script's extension.
+func scriptEngine(engine, script string) string {
+	switch {
+	case engine != "":
+		return engine
+	case isWASMScript(script):
+		return EngineWASM
+	default:
+		return EngineLua
+	}
+}
+
+// engine returns the engine the tree runs on.
+func (t TreeConfig) engine() string {
+	return scriptEngine(t.Engine, t.Script)
+}
+
+// engine returns the engine the treehouse runs on.
+func (th TreeHouseConfig) engine() string {
+	return scriptEngine(th.Engine, th.Script)
+}
+
+// treeExprVars are the variables a tree adds to the river data it processes.
+var treeExprVars = []string{"_subject", "_source"}
+
+// validateEngine checks a tree's engine settings and compiles its
+// expressions.
+func (t TreeConfig) validateEngine() error {
+	return validateEngine(t.engine(), t.Script, t.Pool, t.Access, t.ExprConfig, treeExprVars...)
+}
+
+// validateEngine checks a treehouse's engine settings and compiles its
+// expressions.
+func (th TreeHouseConfig) validateEngine() error {
+	if th.engine() != EngineLua && th.State != nil {
+		return fmt.Errorf("state is only available to Lua scripts")
+	}
+	return validateEngine(th.engine(), th.Script, th.Pool, th.Access, th.ExprConfig)
+}
+
+// validateEngine checks that the expr settings are only used with the expr
+// engine, and that components use only what their engine supports.
+func validateEngine(engine, script string, pool int, access ScriptAccess, ec ExprConfig, extra ...string) error {
+	if engine != EngineExpr && (ec.Filter != "" || len(ec.Map) > 0 || ec.Subject != "" || ec.Schema != nil) {
+		return fmt.Errorf("filter, map, subject and schema require engine: %s", EngineExpr)
+	}
+	switch engine {
+	case EngineLua:
+		if isWASMScript(script) {
+			return fmt.Errorf("%s is a WASM module; use engine: %s", script, EngineWASM)
+		}
+		return nil
+	case EngineWASM:
+		if len(access.Humus) > 0 {
+			return fmt.Errorf("access.humus is only available to Lua scripts")
+		}
+		return nil
+	case EngineExpr:
+		if script != "" {
+			return fmt.Errorf("engine %s takes inline expressions, not a script", EngineExpr)
+		}
+		if pool != 0 {
+			return fmt.Errorf("pool is only available to Lua and WASM scripts")
+		}
+		if len(access.Soil) > 0 || len(access.Humus) > 0 {
+			return fmt.Errorf("access is only available to Lua and WASM scripts")
+		}
+		_, err := compileExpr(ec, extra...)
+		return err
+	default:
+		return fmt.Errorf("unknown engine %q (use %s, %s or %s)", engine, EngineLua, EngineExpr, EngineWASM)
+	}
+}
+
+// validate checks that no prefix is empty.
+func (a ScriptAccess) validate() error {
+	for _, prefix := range append(a.Soil, a.Humus...) {
+		if prefix == "" {
+			return fmt.Errorf("access prefixes cannot be empty (use \"*\" to allow every key)")
+		}
+	}
+	return nil
 }
 
 // NimConfig defines a Nim - an AI-powered processor.
+// Set Type to "go:<name>" to run a compiled nim registered with RegisterNim
+// instead; it then decides what it catches and publishes.
 type NimConfig struct {
-	Name       string `yaml:"-"`          // Set from map key
-	Subscribes string `yaml:"subscribes"` // NATS subject to listen on
-	Publishes  string `yaml:"publishes"`  // NATS subject to publish to
-	Prompt     string `yaml:"prompt"`     // Path to prompt template (.md file)
+	Name       string `yaml:"-"`              // Set from map key
+	Type       string `yaml:"type,omitempty"` // Registered Go nim, e.g. "go:aftersales"
+	Subscribes string `yaml:"subscribes"`     // NATS subject to listen on
+	Publishes  string `yaml:"publishes"`      // NATS subject to publish to
+	Prompt     string `yaml:"prompt"`         // Path to prompt template (.md file)
+
+	Access ScriptAccess `yaml:"access,omitempty"` // Soil keys the prompt may read with {{soil "key"}}
 }
 
 // SongbirdConfig defines a Songbird - an outbound message handler.
@@ -171,6 +563,11 @@ func LoadConfig(path string) (*Config, error) {
 		sb.Name = name
 		cfg.Songbirds[name] = sb
 	}
+	for name := range cfg.Projections {
+		p := cfg.Projections[name]
+		p.Name = name
+		cfg.Projections[name] = p
+	}
 
 	// Validate config
 	if err := cfg.Validate(); err != nil {
@@ -182,6 +579,52 @@ func LoadConfig(path string) (*Config, error) {
 
 // Validate checks that the configuration is valid.
 func (c *Config) Validate() error {
+	if c.Watch != nil && c.Watch.Interval != "" {
+		if d, err := time.ParseDuration(c.Watch.Interval); err != nil || d <= 0 {
+			return fmt.Errorf("watch: invalid interval %q", c.Watch.Interval)
+		}
+	}
+	if c.Lua != nil {
+		if c.Lua.Timeout != "" {
+			if _, err := time.ParseDuration(c.Lua.Timeout); err != nil {
+				return fmt.Errorf("lua: invalid timeout %q: %w", c.Lua.Timeout, err)
+			}
+		}
+		if c.Lua.CallStack < 0 || c.Lua.Registry < 0 || c.Lua.MemoryMB < 0 {
+			return fmt.Errorf("lua: limits must not be negative")
+		}
+	}
+	if c.WASM != nil {
+		if c.WASM.Timeout != "" {
+			if _, err := time.ParseDuration(c.WASM.Timeout); err != nil {
+				return fmt.Errorf("wasm: invalid timeout %q: %w", c.WASM.Timeout, err)
+			}
+		}
+		if c.WASM.MemoryMB < 0 {
+			return fmt.Errorf("wasm: memory_mb must not be negative")
+		}
+	}
+	if c.Humus != nil {
+		if c.Humus.Partitions < 0 {
+			return fmt.Errorf("humus: partitions must not be negative")
+		}
+		if c.Humus.MaxAge != "" {
+			if _, err := time.ParseDuration(c.Humus.MaxAge); err != nil {
+				return fmt.Errorf("humus: invalid max_age %q: %w", c.Humus.MaxAge, err)
+			}
+		}
+		if a := c.Humus.Archive; a != nil {
+			if a.Dir == "" {
+				return fmt.Errorf("humus: archive requires dir")
+			}
+			if a.RotateEvery != "" {
+				if _, err := time.ParseDuration(a.RotateEvery); err != nil {
+					return fmt.Errorf("humus: invalid archive rotate_every %q: %w", a.RotateEvery, err)
+				}
+			}
+		}
+	}
+
 	// Validate sources
 	for name, s := range c.Sources {
 		if s.Type == "" {
@@ -219,30 +662,92 @@ func (c *Config) Validate() error {
 	}
 
 	for name, t := range c.Trees {
+		if err := t.Access.validate(); err != nil {
+			return fmt.Errorf("tree %q: %w", name, err)
+		}
+		if err := validatePool(t.Pool, t.Ordered); err != nil {
+			return fmt.Errorf("tree %q: %w", name, err)
+		}
+		if t.Type != "" {
+			if err := validateGoType(t.Type); err != nil {
+				return fmt.Errorf("tree %q: %w", name, err)
+			}
+			if t.Script != "" {
+				return fmt.Errorf("tree %q: script and type are mutually exclusive", name)
+			}
+			continue
+		}
 		if t.Watches == "" {
 			return fmt.Errorf("tree %q: missing watches", name)
 		}
 		if t.Publishes == "" {
 			return fmt.Errorf("tree %q: missing publishes", name)
 		}
-		if t.Script == "" {
+		if err := t.validateEngine(); err != nil {
+			return fmt.Errorf("tree %q: %w", name, err)
+		}
+		if t.Script == "" && t.engine() != EngineExpr {
 			return fmt.Errorf("tree %q: missing script", name)
 		}
 	}
 
 	for name, th := range c.TreeHouses {
+		if err := th.Access.validate(); err != nil {
+			return fmt.Errorf("treehouse %q: %w", name, err)
+		}
+		if err := validatePool(th.Pool, th.Ordered); err != nil {
+			return fmt.Errorf("treehouse %q: %w", name, err)
+		}
+		if err := validateEmits(th.Emits); err != nil {
+			return fmt.Errorf("treehouse %q: %w", name, err)
+		}
+		if th.Verify < 0 || th.Verify > 1 {
+			return fmt.Errorf("treehouse %q: verify must be between 0 and 1", name)
+		}
+		if th.State != nil && th.State.TTL != "" {
+			if d, err := time.ParseDuration(th.State.TTL); err != nil || d < 0 {
+				return fmt.Errorf("treehouse %q: state: invalid ttl %q", name, th.State.TTL)
+			}
+		}
+		if th.Type != "" {
+			if err := validateGoType(th.Type); err != nil {
+				return fmt.Errorf("treehouse %q: %w", name, err)
+			}
+			if th.State != nil {
+				return fmt.Errorf("treehouse %q: state is only available to Lua scripts", name)
+			}
+			if th.Script != "" {
+				return fmt.Errorf("treehouse %q: script and type are mutually exclusive", name)
+			}
+			continue
+		}
+		if th.Verify != 0 {
+			return fmt.Errorf("treehouse %q: verify requires a go: type", name)
+		}
 		if th.Subscribes == "" {
 			return fmt.Errorf("treehouse %q: missing subscribes", name)
 		}
 		if th.Publishes == "" {
 			return fmt.Errorf("treehouse %q: missing publishes", name)
 		}
-		if th.Script == "" {
+		if err := th.validateEngine(); err != nil {
+			return fmt.Errorf("treehouse %q: %w", name, err)
+		}
+		if th.Script == "" && th.engine() != EngineExpr {
 			return fmt.Errorf("treehouse %q: missing script", name)
 		}
 	}
 
 	for name, n := range c.Nims {
+		if n.Type != "" {
+			if err := validateGoType(n.Type); err != nil {
+				return fmt.Errorf("nim %q: %w", name, err)
+			}
+			if n.Prompt != "" {
+				return fmt.Errorf("nim %q: prompt and type are mutually exclusive", name)
+			}
+			continue
+		}
 		if n.Subscribes == "" {
 			return fmt.Errorf("nim %q: missing subscribes", name)
 		}
@@ -252,6 +757,12 @@ func (c *Config) Validate() error {
 		if n.Prompt == "" {
 			return fmt.Errorf("nim %q: missing prompt", name)
 		}
+		if len(n.Access.Humus) > 0 {
+			return fmt.Errorf("nim %q: access.humus is only available to Lua scripts", name)
+		}
+		if err := n.Access.validate(); err != nil {
+			return fmt.Errorf("nim %q: %w", name, err)
+		}
 	}
 
 	for name, sb := range c.Songbirds {
@@ -271,6 +782,87 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	if c.Soil != nil {
+		for name, idx := range c.Soil.Indexes {
+			if idx.Pattern == "" {
+				return fmt.Errorf("soil index %q: missing pattern", name)
+			}
+			if len(idx.Fields) == 0 {
+				return fmt.Errorf("soil index %q: missing fields", name)
+			}
+		}
+		for name, b := range c.Soil.Buckets {
+			if b.History < 0 || b.History > 64 {
+				return fmt.Errorf("soil bucket %q: history must be between 1 and 64", name)
+			}
+			if b.TTL != "" {
+				if _, err := time.ParseDuration(b.TTL); err != nil {
+					return fmt.Errorf("soil bucket %q: invalid ttl %q: %w", name, b.TTL, err)
+				}
+			}
+			if b.Storage != "" && b.Storage != "file" && b.Storage != "memory" {
+				return fmt.Errorf("soil bucket %q: unknown storage %q (use file or memory)", name, b.Storage)
+			}
+			if b.Replicas < 0 {
+				return fmt.Errorf("soil bucket %q: replicas must not be negative", name)
+			}
+		}
+		if e := c.Soil.Expiry; e != nil {
+			for _, d := range e.Due {
+				if d.Pattern == "" || d.Field == "" {
+					return fmt.Errorf("soil expiry: due entries require pattern and field")
+				}
+			}
+			for _, ttl := range e.TTL {
+				if ttl.Pattern == "" || ttl.After == "" {
+					return fmt.Errorf("soil expiry: ttl entries require pattern and after")
+				}
+				if d, err := time.ParseDuration(ttl.After); err != nil || d <= 0 {
+					return fmt.Errorf("soil expiry: invalid ttl after %q for %s", ttl.After, ttl.Pattern)
+				}
+			}
+			if e.CheckEvery != "" {
+				if _, err := time.ParseDuration(e.CheckEvery); err != nil {
+					return fmt.Errorf("soil expiry: invalid check_every %q: %w", e.CheckEvery, err)
+				}
+			}
+		}
+		if b := c.Soil.Bedrock; b != nil {
+			if b.Dir == "" {
+				return fmt.Errorf("soil bedrock: requires dir")
+			}
+			if b.Type != "" && b.Type != "file" && b.Type != "git" {
+				return fmt.Errorf("soil bedrock: unknown type %q (use file or git)", b.Type)
+			}
+		}
+		if b := c.Soil.Bridge; b != nil {
+			for _, pattern := range b.Patterns {
+				if pattern == "" {
+					return fmt.Errorf("soil bridge: empty pattern")
+				}
+			}
+		}
+	}
+
+	for name, p := range c.Projections {
+		if p.Script == "" && p.Func == "" {
+			return fmt.Errorf("projection %q: requires script or func", name)
+		}
+		if p.Script != "" && p.Func != "" {
+			return fmt.Errorf("projection %q: script and func are mutually exclusive", name)
+		}
+	}
+
+	return nil
+}
+
+// validateGoType checks a component type is "go:<name>". Whether the name
+// is registered is checked when the component is created.
+func validateGoType(componentType string) error {
+	name, ok := goTypeName(componentType)
+	if !ok || name == "" {
+		return fmt.Errorf("unknown type %q (use go:<registered name>)", componentType)
+	}
 	return nil
 }
 
@@ -282,6 +874,23 @@ func (c *Config) ResolvePath(path string) string {
 	return filepath.Join(c.BaseDir, path)
 }
 
+// promptPartials returns the directory of templates nim prompts share, or
+// "" if there is none.
+func (c *Config) promptPartials() string {
+	if c.Prompts == nil || c.Prompts.Partials == "" {
+		return ""
+	}
+	return c.ResolvePath(c.Prompts.Partials)
+}
+
+// openWASMCache keeps compiled WASM modules in wasm.cache_dir, if set.
+func (c *Config) openWASMCache() error {
+	if c.WASM == nil || c.WASM.CacheDir == "" {
+		return nil
+	}
+	return SetWASMCacheDir(c.ResolvePath(c.WASM.CacheDir))
+}
+
 // GetTreeHouseScript returns the absolute path to a TreeHouse's Lua script.
 func (c *Config) GetTreeHouseScript(name string) (string, error) {
 	th, ok := c.TreeHouses[name]
@@ -3,6 +3,7 @@ package runtime
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -136,6 +137,513 @@ nims:
 			expectError: true,
 			errorMsg:    "missing prompt",
 		},
+		{
+			name: "negative humus partitions",
+			config: `
+humus:
+  partitions: -1
+`,
+			expectError: true,
+			errorMsg:    "partitions must not be negative",
+		},
+		{
+			name: "valid humus partitions",
+			config: `
+humus:
+  partitions: 16
+`,
+			expectError: false,
+		},
+		{
+			name: "invalid humus max_age",
+			config: `
+humus:
+  max_age: 30d
+`,
+			expectError: true,
+			errorMsg:    "invalid max_age",
+		},
+		{
+			name: "humus archive without dir",
+			config: `
+humus:
+  archive:
+    rotate_every: 1h
+`,
+			expectError: true,
+			errorMsg:    "archive requires dir",
+		},
+		{
+			name: "valid humus retention and archive",
+			config: `
+humus:
+  max_age: 720h
+  max_msgs: -1
+  archive:
+    dir: ./archive
+    rotate_every: 1h
+`,
+			expectError: false,
+		},
+		{
+			name: "projection without script or func",
+			config: `
+projections:
+  open-tasks:
+    entities: tasks
+`,
+			expectError: true,
+			errorMsg:    "requires script or func",
+		},
+		{
+			name: "projection with script and func",
+			config: `
+projections:
+  open-tasks:
+    script: open_tasks.lua
+    func: open-tasks
+`,
+			expectError: true,
+			errorMsg:    "mutually exclusive",
+		},
+		{
+			name: "soil index without fields",
+			config: `
+soil:
+  indexes:
+    tasks:
+      pattern: tasks/*
+`,
+			expectError: true,
+			errorMsg:    "missing fields",
+		},
+		{
+			name: "valid soil index",
+			config: `
+soil:
+  indexes:
+    tasks:
+      pattern: tasks/*
+      fields: [customer_id, status]
+`,
+			expectError: false,
+		},
+		{
+			name: "soil bucket with unknown storage",
+			config: `
+soil:
+  buckets:
+    sessions:
+      storage: disk
+`,
+			expectError: true,
+			errorMsg:    "unknown storage",
+		},
+		{
+			name: "valid soil buckets",
+			config: `
+soil:
+  buckets:
+    sessions:
+      history: 1
+      ttl: 24h
+      storage: memory
+    tasks:
+      history: 64
+      replicas: 3
+`,
+			expectError: false,
+		},
+		{
+			name: "soil expiry due without field",
+			config: `
+soil:
+  expiry:
+    due:
+      - pattern: task-*
+`,
+			expectError: true,
+			errorMsg:    "require pattern and field",
+		},
+		{
+			name: "soil expiry ttl without duration",
+			config: `
+soil:
+  expiry:
+    ttl:
+      - pattern: session-*
+        after: soon
+`,
+			expectError: true,
+			errorMsg:    "invalid ttl after",
+		},
+		{
+			name: "valid soil expiry",
+			config: `
+soil:
+  expiry:
+    check_every: 5s
+    due:
+      - pattern: task-*
+        field: due_date
+    ttl:
+      - pattern: session-*
+        after: 24h
+`,
+			expectError: false,
+		},
+		{
+			name: "soil bridge empty pattern",
+			config: `
+soil:
+  bridge:
+    patterns: [""]
+`,
+			expectError: true,
+			errorMsg:    "soil bridge: empty pattern",
+		},
+		{
+			name: "valid soil bridge",
+			config: `
+soil:
+  bridge:
+    patterns: [task-*, contact-*]
+`,
+			expectError: false,
+		},
+		{
+			name: "soil bedrock without dir",
+			config: `
+soil:
+  bedrock:
+    type: git
+`,
+			expectError: true,
+			errorMsg:    "soil bedrock: requires dir",
+		},
+		{
+			name: "soil bedrock unknown type",
+			config: `
+soil:
+  bedrock:
+    type: s3
+    dir: ./data/bedrock
+`,
+			expectError: true,
+			errorMsg:    "unknown type",
+		},
+		{
+			name: "go nim and tree by type",
+			config: `
+trees:
+  payments:
+    type: go:payment
+nims:
+  aftersales:
+    type: go:aftersales
+`,
+			expectError: false,
+		},
+		{
+			name: "nim with soil access and shared partials",
+			config: `
+prompts:
+  partials: ./prompts
+nims:
+  qualify:
+    subscribes: lead.scored
+    publishes: lead.qualified
+    prompt: qualify.md
+    access:
+      soil: [contacts/]
+`,
+			expectError: false,
+		},
+		{
+			name: "nim with humus access",
+			config: `
+nims:
+  qualify:
+    subscribes: lead.scored
+    publishes: lead.qualified
+    prompt: qualify.md
+    access:
+      humus: [leads/]
+`,
+			expectError: true,
+			errorMsg:    "access.humus",
+		},
+		{
+			name: "nim type without go prefix",
+			config: `
+nims:
+  aftersales:
+    type: aftersales
+`,
+			expectError: true,
+			errorMsg:    "unknown type",
+		},
+		{
+			name: "nim with prompt and type",
+			config: `
+nims:
+  aftersales:
+    type: go:aftersales
+    prompt: aftersales.md
+`,
+			expectError: true,
+			errorMsg:    "mutually exclusive",
+		},
+		{
+			name: "go treehouse with verify",
+			config: `
+treehouses:
+  enricher:
+    type: go:enricher
+    verify: 0.1
+`,
+			expectError: false,
+		},
+		{
+			name: "treehouse verify out of range",
+			config: `
+treehouses:
+  enricher:
+    type: go:enricher
+    verify: 1.5
+`,
+			expectError: true,
+			errorMsg:    "between 0 and 1",
+		},
+		{
+			name: "lua treehouse with verify",
+			config: `
+treehouses:
+  router:
+    subscribes: in
+    publishes: out
+    script: router.lua
+    verify: 0.5
+`,
+			expectError: true,
+			errorMsg:    "requires a go: type",
+		},
+		{
+			name: "treehouse access with empty prefix",
+			config: `
+treehouses:
+  scoring:
+    subscribes: contact.created
+    publishes: lead.scored
+    script: scoring.lua
+    access:
+      soil: [contacts/, ""]
+`,
+			expectError: true,
+			errorMsg:    "access prefixes cannot be empty",
+		},
+		{
+			name: "ordered treehouse with pool",
+			config: `
+treehouses:
+  scoring:
+    subscribes: contact.created
+    publishes: lead.scored
+    script: scoring.lua
+    pool: 4
+    ordered: true
+`,
+			expectError: true,
+			errorMsg:    "ordered runs a single VM",
+		},
+		{
+			name: "treehouse with invalid emits pattern",
+			config: `
+treehouses:
+  router:
+    subscribes: orders.new
+    publishes: orders.routed
+    emits: ["orders.>.eu"]
+    script: router.lua
+`,
+			expectError: true,
+			errorMsg:    "invalid emits pattern",
+		},
+		{
+			name: "treehouse with invalid state ttl",
+			config: `
+treehouses:
+  failures:
+    subscribes: payment.failed
+    publishes: payment.failures
+    script: failures.lua
+    state:
+      ttl: forever
+`,
+			expectError: true,
+			errorMsg:    "state: invalid ttl",
+		},
+		{
+			name: "expr tree and treehouse",
+			config: `
+trees:
+  failed:
+    watches: river.payments.>
+    publishes: payment.failed
+    engine: expr
+    filter: status == "failed" && _subject endsWith ".stripe"
+treehouses:
+  scorer:
+    subscribes: lead.captured
+    publishes: lead.scored
+    engine: expr
+    schema: {contact_id: string, company_size: number}
+    map:
+      contact_id: contact_id
+      score: "company_size > 500 ? 50 : 10"
+`,
+			expectError: false,
+		},
+		{
+			name: "expr treehouse failing its schema",
+			config: `
+treehouses:
+  scorer:
+    subscribes: lead.captured
+    publishes: lead.scored
+    engine: expr
+    schema: {company_size: number}
+    filter: company_size contains "x"
+`,
+			expectError: true,
+			errorMsg:    "filter",
+		},
+		{
+			name: "wasm tree and treehouse",
+			config: `
+wasm:
+  timeout: 200ms
+  memory_mb: 32
+trees:
+  stripe:
+    watches: river.stripe.>
+    publishes: payment.completed
+    script: stripe.wasm
+treehouses:
+  scorer:
+    subscribes: lead.captured
+    publishes: lead.scored
+    engine: wasm
+    script: scorer.bin
+    access:
+      soil: [plans/]
+`,
+			expectError: false,
+		},
+		{
+			name: "wasm module on the lua engine",
+			config: `
+treehouses:
+  scorer:
+    subscribes: lead.captured
+    publishes: lead.scored
+    engine: lua
+    script: scorer.wasm
+`,
+			expectError: true,
+			errorMsg:    "is a WASM module",
+		},
+		{
+			name: "wasm treehouse with state",
+			config: `
+treehouses:
+  scorer:
+    subscribes: lead.captured
+    publishes: lead.scored
+    script: scorer.wasm
+    state:
+      ttl: 1h
+`,
+			expectError: true,
+			errorMsg:    "state",
+		},
+		{
+			name: "wasm invalid timeout",
+			config: `
+wasm:
+  timeout: soon
+`,
+			expectError: true,
+			errorMsg:    "invalid timeout",
+		},
+		{
+			name: "tree with negative pool",
+			config: `
+trees:
+  stripe:
+    watches: river.stripe.>
+    publishes: payment.completed
+    script: stripe.lua
+    pool: -2
+`,
+			expectError: true,
+			errorMsg:    "pool must not be negative",
+		},
+		{
+			name: "pooled tree and ordered treehouse",
+			config: `
+trees:
+  stripe:
+    watches: river.stripe.>
+    publishes: payment.completed
+    script: stripe.lua
+    pool: 8
+treehouses:
+  counter:
+    subscribes: payment.completed
+    publishes: payment.counted
+    script: counter.lua
+    ordered: true
+`,
+			expectError: false,
+		},
+		{
+			name: "watch invalid interval",
+			config: `
+watch:
+  interval: often
+`,
+			expectError: true,
+			errorMsg:    "invalid interval",
+		},
+		{
+			name: "lua invalid timeout",
+			config: `
+lua:
+  timeout: fast
+`,
+			expectError: true,
+			errorMsg:    "invalid timeout",
+		},
+		{
+			name: "lua negative memory",
+			config: `
+lua:
+  memory_mb: -1
+`,
+			expectError: true,
+			errorMsg:    "must not be negative",
+		},
+		{
+			name: "valid lua limits",
+			config: `
+lua:
+  timeout: 250ms
+  call_stack: 100
+  memory_mb: 64
+`,
+			expectError: false,
+		},
 		{
 			name: "valid empty config",
 			config: `
@@ -190,3 +698,29 @@ nims: {}
 		t.Errorf("absolute path should remain unchanged: got %s", cfg.ResolvePath(absPath))
 	}
 }
+
+func TestSoilExpiryDueFields(t *testing.T) {
+	var missing *SoilExpiryConfig
+	if got := missing.DueFields(); !reflect.DeepEqual(got, DefaultSoilDue) {
+		t.Errorf("expected the default due fields, got %v", got)
+	}
+
+	tests := map[string]int{
+		"soil:\n  expiry:\n    check_every: 5s\n":                                              len(DefaultSoilDue),
+		"soil:\n  expiry:\n    due: []\n":                                                      0,
+		"soil:\n  expiry:\n    due:\n      - pattern: invoice-*\n        field: payment_due\n": 1,
+	}
+	for config, want := range tests {
+		configPath := filepath.Join(t.TempDir(), "test.yaml")
+		if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+			t.Fatalf("failed to write test config: %v", err)
+		}
+		cfg, err := LoadConfig(configPath)
+		if err != nil {
+			t.Fatalf("LoadConfig failed: %v", err)
+		}
+		if got := cfg.Soil.Expiry.DueFields(); len(got) != want {
+			t.Errorf("%q: expected %d due fields, got %v", config, want, got)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package runtime
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+// ExprConfig is a tree or treehouse written as expressions
+// (https://expr-lang.org), for filters and mappings too simple for a script.
+//
+//	engine: expr
+//	schema: {score: number, email: string}
+//	filter: score >= 50
+//	map:
+//	  email: email
+//	  priority: 'score >= 80 ? "high" : "normal"'
+//	subject: '"leads.scored." + (score >= 80 ? "hot" : "warm")'
+type ExprConfig struct {
+	Filter  string            `yaml:"filter,omitempty"`  // Keeps the input when true
+	Map     map[string]string `yaml:"map,omitempty"`     // Output field -> expression; without it the input is published as is
+	Subject string            `yaml:"subject,omitempty"` // Expression for the subject to publish to (default: publishes)
+	Schema  map[string]string `yaml:"schema,omitempty"`  // Input field -> type, to type-check expressions when compiled
+}
+
+// exprSchemaTypes are the types a schema field can have, as their zero
+// values. Numbers are float64, as in decoded JSON.
+var exprSchemaTypes = map[string]interface{}{
+	"string": "",
+	"number": float64(0),
+	"bool":   false,
+	"list":   []interface{}{},
+	"map":    map[string]interface{}{},
+	"any":    nil,
+}
+
+// exprProgram is an ExprConfig compiled once. It keeps no state between
+// runs, so inputs are evaluated concurrently without a lock.
+type exprProgram struct {
+	filter  *vm.Program
+	fields  []exprField
+	subject *vm.Program
+}
+
+type exprField struct {
+	name    string
+	program *vm.Program
+}
+
+// compileExpr compiles cfg. extra names the variables the engine adds to
+// every input, e.g. a tree's _subject. With a schema, expressions may only
+// use its fields and extra, and are type-checked against them; without one,
+// unknown variables are nil.
+func compileExpr(cfg ExprConfig, extra ...string) (*exprProgram, error) {
+	if cfg.Filter == "" && len(cfg.Map) == 0 {
+		return nil, fmt.Errorf("expr engine requires filter or map")
+	}
+
+	env := make(map[string]interface{}, len(cfg.Schema)+len(extra))
+	for field, typ := range cfg.Schema {
+		zero, ok := exprSchemaTypes[typ]
+		if !ok {
+			return nil, fmt.Errorf("schema: field %s has unknown type %q (use string, number, bool, list, map or any)", field, typ)
+		}
+		env[field] = zero
+	}
+	for _, name := range extra {
+		env[name] = ""
+	}
+	options := []expr.Option{expr.Env(env)}
+	if cfg.Schema == nil {
+		options = append(options, expr.AllowUndefinedVariables())
+	}
+
+	compile := func(what, code string, as ...expr.Option) (*vm.Program, error) {
+		program, err := expr.Compile(code, append(as, options...)...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", what, err)
+		}
+		return program, nil
+	}
+
+	p := &exprProgram{}
+	var err error
+	if cfg.Filter != "" {
+		if p.filter, err = compile("filter", cfg.Filter, expr.AsBool()); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Subject != "" {
+		if p.subject, err = compile("subject", cfg.Subject, expr.AsKind(reflect.String)); err != nil {
+			return nil, err
+		}
+	}
+	for name, code := range cfg.Map {
+		program, err := compile("map."+name, code)
+		if err != nil {
+			return nil, err
+		}
+		p.fields = append(p.fields, exprField{name: name, program: program})
+	}
+	sort.Slice(p.fields, func(i, j int) bool { return p.fields[i].name < p.fields[j].name })
+	return p, nil
+}
+
+// run evaluates the program on input. It returns a nil output when the
+// filter drops the input, and an empty subject when there's no subject
+// expression. env is what expressions see; it defaults to input.
+func (p *exprProgram) run(input, env map[string]interface{}) (map[string]interface{}, string, error) {
+	if env == nil {
+		env = input
+	}
+
+	if p.filter != nil {
+		keep, err := expr.Run(p.filter, env)
+		if err != nil {
+			return nil, "", fmt.Errorf("filter: %w", err)
+		}
+		if keep != true {
+			return nil, "", nil
+		}
+	}
+
+	output := input
+	if len(p.fields) > 0 {
+		output = make(map[string]interface{}, len(p.fields))
+		for _, f := range p.fields {
+			value, err := expr.Run(f.program, env)
+			if err != nil {
+				return nil, "", fmt.Errorf("map.%s: %w", f.name, err)
+			}
+			output[f.name] = value
+		}
+	}
+
+	var subject string
+	if p.subject != nil {
+		value, err := expr.Run(p.subject, env)
+		if err != nil {
+			return nil, "", fmt.Errorf("subject: %w", err)
+		}
+		s, ok := value.(string)
+		if !ok || s == "" {
+			return nil, "", fmt.Errorf("subject: expected a string, got %v", value)
+		}
+		subject = s
+	}
+	return output, subject, nil
+}
@@ -0,0 +1,147 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/nimsforest/internal/core"
+)
+
+func TestCompileExpr(t *testing.T) {
+	p, err := compileExpr(ExprConfig{
+		Filter: "score >= 50",
+		Map: map[string]string{
+			"email":    "email",
+			"priority": `score >= 80 ? "high" : "normal"`,
+		},
+		Subject: `"leads." + (score >= 80 ? "hot" : "warm")`,
+		Schema:  map[string]string{"score": "number", "email": "string", "tags": "list", "extra": "any"},
+	})
+	if err != nil {
+		t.Fatalf("compileExpr failed: %v", err)
+	}
+
+	output, subject, err := p.run(map[string]interface{}{"score": 90.0, "email": "a@b.c"}, nil)
+	if err != nil || subject != "leads.hot" || output["priority"] != "high" || output["email"] != "a@b.c" {
+		t.Errorf("unexpected result %v %q (err=%v)", output, subject, err)
+	}
+	if output, _, err := p.run(map[string]interface{}{"score": 10.0}, nil); output != nil || err != nil {
+		t.Errorf("expected the input to be filtered, got %v (err=%v)", output, err)
+	}
+
+	// Schemas type-check expressions when they're compiled
+	for _, cfg := range []ExprConfig{
+		{Filter: "score", Schema: map[string]string{"score": "number"}},
+		{Filter: "missing > 1", Schema: map[string]string{"score": "number"}},
+		{Map: map[string]string{"x": "email + 1"}, Schema: map[string]string{"email": "string"}},
+		{Filter: "true", Schema: map[string]string{"score": "integer"}},
+		{Filter: "score >"},
+		{Subject: `"a"`},
+	} {
+		if _, err := compileExpr(cfg); err == nil {
+			t.Errorf("expected %+v to fail to compile", cfg)
+		}
+	}
+
+	// Without a schema, unknown fields are nil and a filter passes the input on
+	p, err = compileExpr(ExprConfig{Filter: `status == "failed" && _subject != ""`}, treeExprVars...)
+	if err != nil {
+		t.Fatalf("compileExpr failed: %v", err)
+	}
+	input := map[string]interface{}{"status": "failed"}
+	output, _, err = p.run(input, map[string]interface{}{"status": "failed", "_subject": "river.payments"})
+	if err != nil || len(output) != 1 || output["status"] != "failed" {
+		t.Errorf("expected the input, got %v (err=%v)", output, err)
+	}
+}
+
+func TestExprTreeHouse(t *testing.T) {
+	_, wind, cleanup := setupTestForest(t)
+	defer cleanup()
+
+	out := make(chan core.Leaf, 10)
+	wind.Catch(">", func(leaf core.Leaf) {
+		if leaf.Source == "treehouse:scorer" {
+			out <- leaf
+		}
+	})
+
+	cfg := TreeHouseConfig{
+		Name: "scorer", Subscribes: "lead.captured", Publishes: "lead.scored.{tier}", Engine: EngineExpr,
+		ExprConfig: ExprConfig{
+			Filter: "company_size > 0",
+			Map: map[string]string{
+				"contact_id": "contact_id",
+				"score":      "(company_size > 500 ? 50 : 10) + (title contains 'CEO' ? 40 : 0)",
+				"tier":       "company_size > 500 ? 'enterprise' : 'smb'",
+			},
+			Schema: map[string]string{"contact_id": "string", "company_size": "number", "title": "string"},
+		},
+	}
+	if err := cfg.validateEngine(); err != nil {
+		t.Fatalf("validateEngine failed: %v", err)
+	}
+	th, err := NewTreeHouse(cfg, wind, "")
+	if err != nil {
+		t.Fatalf("NewTreeHouse failed: %v", err)
+	}
+	th.Start(context.Background())
+	defer th.Stop()
+	time.Sleep(50 * time.Millisecond)
+
+	for _, lead := range []string{
+		`{"contact_id": "c1", "company_size": 0, "title": "CEO"}`,
+		`{"contact_id": "c2", "company_size": 1000, "title": "CEO"}`,
+	} {
+		wind.Drop(*core.NewLeaf("lead.captured", []byte(lead), "test"))
+	}
+
+	select {
+	case leaf := <-out:
+		var data map[string]interface{}
+		json.Unmarshal(leaf.Data, &data)
+		if leaf.Subject != "lead.scored.enterprise" || data["contact_id"] != "c2" || data["score"] != 90.0 {
+			t.Errorf("unexpected leaf %s: %s", leaf.Subject, leaf.Data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the scored lead")
+	}
+	select {
+	case leaf := <-out:
+		t.Errorf("expected the first lead to be filtered, got %s", leaf.Data)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if stats := th.SandboxStats(); stats != (SandboxStats{}) {
+		t.Errorf("expected no sandbox stats, got %+v", stats)
+	}
+	if err := th.ReloadScript("x.lua"); err == nil {
+		t.Error("expected an error reloading a script of an expr treehouse")
+	}
+}
+
+func TestValidateEngine(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  TreeHouseConfig
+		want string
+	}{
+		{"lua with map", TreeHouseConfig{Script: "a.lua", ExprConfig: ExprConfig{Map: map[string]string{"a": "b"}}}, "require engine"},
+		{"expr with script", TreeHouseConfig{Engine: EngineExpr, Script: "a.lua", ExprConfig: ExprConfig{Filter: "true"}}, "not a script"},
+		{"expr without expressions", TreeHouseConfig{Engine: EngineExpr}, "filter or map"},
+		{"expr with state", TreeHouseConfig{Engine: EngineExpr, State: &ScriptStateConfig{}, ExprConfig: ExprConfig{Filter: "true"}}, "state"},
+		{"unknown engine", TreeHouseConfig{Engine: "js"}, "unknown engine"},
+		{"bad expression", TreeHouseConfig{Engine: EngineExpr, ExprConfig: ExprConfig{Map: map[string]string{"a": "1 +"}}}, "map.a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.validateEngine()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -2,12 +2,19 @@ package runtime
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/nats-io/nats.go"
 	"github.com/yourusername/nimsforest/internal/core"
 	"github.com/yourusername/nimsforest/internal/songbirds"
 	"github.com/yourusername/nimsforest/internal/sources"
@@ -21,8 +28,15 @@ type Forest struct {
 	wind   *core.Wind
 	river  *core.River // Optional: for Trees and Sources (requires JetStream)
 	humus  *core.Humus // Optional: for state tracking
+	soil   *core.Soil  // Optional: for queries from Lua scripts and the API
 	brain  brain.Brain
 
+	archiver    *core.HumusArchiver   // Optional: humus export to files
+	soilBuckets map[string]*core.Soil // Optional: named soil buckets
+	expirer     *core.SoilExpirer     // Optional: soil entity expiry
+	bedrock     *core.BedrockSyncer   // Optional: humus mirror beneath soil
+	js          nats.JetStreamContext // Optional: for treehouse state buckets
+
 	// Land info - detected capabilities of this compute node
 	thisLand *core.LandInfo
 
@@ -33,6 +47,21 @@ type Forest struct {
 	nims       map[string]*Nim
 	songbirds  map[string]songbirds.Songbird
 
+	// Registered Go components (type: go:<name>) are created on Start,
+	// once river, humus and soil are set
+	goTrees      map[string]*GoTree
+	goTreeHouses map[string]*GoTreeHouse
+	goNims       map[string]*GoNim
+
+	// Projections require Humus and are created on Start
+	projections map[string]*Projection
+
+	// Time of the latest beat, read by scripts through time.now()
+	clock *BeatClock
+
+	// Reloads changed scripts and prompts while running
+	watcher *ScriptWatcher
+
 	// HTTP server for webhook sources
 	webhookServer *sources.WebhookServer
 	sourceFactory *sources.Factory
@@ -69,14 +98,27 @@ func NewForestFromConfig(cfg *Config, wind *core.Wind, b brain.Brain) (*Forest,
 		treehouses: make(map[string]*TreeHouse),
 		nims:       make(map[string]*Nim),
 		songbirds:  make(map[string]songbirds.Songbird),
+		goTrees:    make(map[string]*GoTree),
+		goNims:     make(map[string]*GoNim),
+
+		goTreeHouses: make(map[string]*GoTreeHouse),
+
+		projections: make(map[string]*Projection),
+		clock:       NewBeatClock(wind),
 	}
 
 	// Note: Trees and Sources require River, which must be set via SetRiver() before Start()
 
+	if err := cfg.openWASMCache(); err != nil {
+		return nil, err
+	}
+
 	// Create TreeHouses
 	for name, thCfg := range cfg.TreeHouses {
-		scriptPath := cfg.ResolvePath(thCfg.Script)
-		th, err := NewTreeHouse(thCfg, wind, scriptPath)
+		if thCfg.Type != "" {
+			continue // Created on Start
+		}
+		th, err := newTreeHouse(thCfg, wind, cfg)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create treehouse %s: %w", name, err)
 		}
@@ -85,8 +127,10 @@ func NewForestFromConfig(cfg *Config, wind *core.Wind, b brain.Brain) (*Forest,
 
 	// Create Nims
 	for name, nimCfg := range cfg.Nims {
-		promptPath := cfg.ResolvePath(nimCfg.Prompt)
-		nim, err := NewNim(nimCfg, wind, b, promptPath)
+		if nimCfg.Type != "" {
+			continue // Created on Start
+		}
+		nim, err := newNim(nimCfg, wind, nil, b, cfg)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create nim %s: %w", name, err)
 		}
@@ -124,14 +168,27 @@ func NewForestWithHumus(cfg *Config, wind *core.Wind, humus *core.Humus, b brain
 		treehouses: make(map[string]*TreeHouse),
 		nims:       make(map[string]*Nim),
 		songbirds:  make(map[string]songbirds.Songbird),
+		goTrees:    make(map[string]*GoTree),
+		goNims:     make(map[string]*GoNim),
+
+		goTreeHouses: make(map[string]*GoTreeHouse),
+
+		projections: make(map[string]*Projection),
+		clock:       NewBeatClock(wind),
 	}
 
 	// Note: Trees and Sources require River, which must be set via SetRiver() before Start()
 
+	if err := cfg.openWASMCache(); err != nil {
+		return nil, err
+	}
+
 	// Create TreeHouses
 	for name, thCfg := range cfg.TreeHouses {
-		scriptPath := cfg.ResolvePath(thCfg.Script)
-		th, err := NewTreeHouse(thCfg, wind, scriptPath)
+		if thCfg.Type != "" {
+			continue // Created on Start
+		}
+		th, err := newTreeHouse(thCfg, wind, cfg)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create treehouse %s: %w", name, err)
 		}
@@ -140,16 +197,10 @@ func NewForestWithHumus(cfg *Config, wind *core.Wind, humus *core.Humus, b brain
 
 	// Create Nims (with Humus if provided)
 	for name, nimCfg := range cfg.Nims {
-		promptPath := cfg.ResolvePath(nimCfg.Prompt)
-		var nim *Nim
-		var err error
-
-		if humus != nil {
-			nim, err = NewNimWithHumus(nimCfg, wind, humus, b, promptPath)
-		} else {
-			nim, err = NewNim(nimCfg, wind, b, promptPath)
+		if nimCfg.Type != "" {
+			continue // Created on Start
 		}
-
+		nim, err := newNim(nimCfg, wind, humus, b, cfg)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create nim %s: %w", name, err)
 		}
@@ -197,31 +248,7 @@ func (f *Forest) Start(ctx context.Context) error {
 					continue // Already created
 				}
 
-				// Convert config to sources.SourceConfig
-				factoryCfg := sources.SourceConfig{
-					Name:       name,
-					Type:       srcCfg.Type,
-					Publishes:  srcCfg.Publishes,
-					Path:       srcCfg.Path,
-					Secret:     srcCfg.Secret,
-					Headers:    srcCfg.Headers,
-					URL:        srcCfg.URL,
-					Method:     srcCfg.Method,
-					Interval:   srcCfg.Interval,
-					ReqHeaders: srcCfg.ReqHeaders,
-					Body:       srcCfg.Body,
-					Timeout:    srcCfg.Timeout,
-					Payload:    srcCfg.Payload,
-					Script:     srcCfg.Script,
-					Hz:         srcCfg.Hz,
-				}
-				if srcCfg.Cursor != nil {
-					factoryCfg.Cursor = &sources.CursorConfig{
-						Param:   srcCfg.Cursor.Param,
-						Extract: srcCfg.Cursor.Extract,
-						Store:   srcCfg.Cursor.Store,
-					}
-				}
+				factoryCfg := sourceFactoryConfig(name, srcCfg)
 
 				src, err := f.sourceFactory.Create(factoryCfg)
 				if err != nil {
@@ -247,9 +274,20 @@ func (f *Forest) Start(ctx context.Context) error {
 			if _, exists := f.trees[name]; exists {
 				continue // Already created
 			}
+			if _, exists := f.goTrees[name]; exists {
+				continue
+			}
 			treeCfg.Name = name
-			scriptPath := f.config.ResolvePath(treeCfg.Script)
-			tree, err := NewTree(treeCfg, f.wind, f.river, scriptPath)
+			if treeCfg.Type != "" {
+				tree, err := NewGoTree(treeCfg, f.goDeps())
+				if err != nil {
+					log.Printf("[Forest] Warning: failed to create tree %s: %v", name, err)
+					continue
+				}
+				f.goTrees[name] = tree
+				continue
+			}
+			tree, err := newTree(treeCfg, f.wind, f.river, f.config)
 			if err != nil {
 				log.Printf("[Forest] Warning: failed to create tree %s: %v", name, err)
 				continue
@@ -258,6 +296,50 @@ func (f *Forest) Start(ctx context.Context) error {
 		}
 	}
 
+	// Create registered Go treehouses and nims from config
+	if f.config != nil {
+		for name, thCfg := range f.config.TreeHouses {
+			if _, exists := f.goTreeHouses[name]; exists || thCfg.Type == "" {
+				continue
+			}
+			thCfg.Name = name
+			th, err := NewGoTreeHouse(thCfg, f.goDeps())
+			if err != nil {
+				log.Printf("[Forest] Warning: failed to create treehouse %s: %v", name, err)
+				continue
+			}
+			f.goTreeHouses[name] = th
+		}
+		for name, nimCfg := range f.config.Nims {
+			if _, exists := f.goNims[name]; exists || nimCfg.Type == "" {
+				continue
+			}
+			nimCfg.Name = name
+			nim, err := NewGoNim(nimCfg, f.goDeps())
+			if err != nil {
+				log.Printf("[Forest] Warning: failed to create nim %s: %v", name, err)
+				continue
+			}
+			f.goNims[name] = nim
+		}
+	}
+
+	// Create Projections from config
+	if f.humus != nil && f.config != nil {
+		for name, projCfg := range f.config.Projections {
+			if _, exists := f.projections[name]; exists {
+				continue // Already created
+			}
+			projCfg.Name = name
+			proj, err := NewProjection(projCfg, f.humus, f.config.ResolvePath(projCfg.Script))
+			if err != nil {
+				log.Printf("[Forest] Warning: failed to create projection %s: %v", name, err)
+				continue
+			}
+			f.projections[name] = proj
+		}
+	}
+
 	// Mount and start webhook sources
 	if f.webhookServer != nil {
 		for _, src := range f.sources {
@@ -277,6 +359,23 @@ func (f *Forest) Start(ctx context.Context) error {
 		}
 	}
 
+	if f.clock != nil {
+		if err := f.clock.Start(); err != nil {
+			log.Printf("[Forest] Warning: failed to follow beats, scripts use the wall clock: %v", err)
+		}
+	}
+
+	// Give scripts the soil and humus access their config allows
+	for _, tree := range f.trees {
+		f.connectScript(tree)
+	}
+	for _, th := range f.treehouses {
+		f.connectScript(th)
+	}
+	for _, nim := range f.nims {
+		f.connectNim(nim)
+	}
+
 	// Start Sources
 	for name, src := range f.sources {
 		if err := src.Start(ctx); err != nil {
@@ -292,6 +391,13 @@ func (f *Forest) Start(ctx context.Context) error {
 		}
 	}
 
+	for name, tree := range f.goTrees {
+		if err := tree.Start(ctx); err != nil {
+			f.stopAll()
+			return fmt.Errorf("failed to start tree %s: %w", name, err)
+		}
+	}
+
 	// Start TreeHouses
 	for name, th := range f.treehouses {
 		if err := th.Start(ctx); err != nil {
@@ -300,6 +406,13 @@ func (f *Forest) Start(ctx context.Context) error {
 		}
 	}
 
+	for name, th := range f.goTreeHouses {
+		if err := th.Start(ctx); err != nil {
+			f.stopAll()
+			return fmt.Errorf("failed to start treehouse %s: %w", name, err)
+		}
+	}
+
 	// Start Nims
 	for name, nim := range f.nims {
 		if err := nim.Start(ctx); err != nil {
@@ -308,6 +421,13 @@ func (f *Forest) Start(ctx context.Context) error {
 		}
 	}
 
+	for name, nim := range f.goNims {
+		if err := nim.Start(ctx); err != nil {
+			f.stopAll()
+			return fmt.Errorf("failed to start nim %s: %w", name, err)
+		}
+	}
+
 	// Start Songbirds
 	for name, sb := range f.songbirds {
 		if err := sb.Start(ctx); err != nil {
@@ -316,85 +436,777 @@ func (f *Forest) Start(ctx context.Context) error {
 		}
 	}
 
-	f.running = true
-	log.Printf("[Forest] Started with %d sources, %d trees, %d treehouses, %d nims and %d songbirds",
-		len(f.sources), len(f.trees), len(f.treehouses), len(f.nims), len(f.songbirds))
-	return nil
+	// Start Projections
+	for name, proj := range f.projections {
+		if err := proj.Start(); err != nil {
+			f.stopAll()
+			return fmt.Errorf("failed to start projection %s: %w", name, err)
+		}
+	}
+
+	if f.config != nil && (f.config.Watch == nil || !f.config.Watch.Disabled) {
+		f.watcher = newScriptWatcher(f.config.Watch.interval(), f.watchedScripts)
+		f.watcher.Start()
+	}
+
+	f.running = true
+	log.Printf("[Forest] Started with %d sources, %d trees, %d treehouses, %d nims, %d songbirds and %d projections",
+		len(f.sources), len(f.trees)+len(f.goTrees), len(f.treehouses)+len(f.goTreeHouses), len(f.nims)+len(f.goNims), len(f.songbirds), len(f.projections))
+	return nil
+}
+
+// Stop stops all TreeHouses and Nims.
+func (f *Forest) Stop() error {
+	// Stop hot reload first: a poll in progress needs f.mu to finish, and
+	// must not reload a component after it was stopped
+	f.mu.Lock()
+	watcher := f.watcher
+	f.mu.Unlock()
+	if watcher != nil {
+		watcher.Stop()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.running {
+		return nil
+	}
+
+	f.stopAll()
+	f.running = false
+	log.Printf("[Forest] Stopped")
+	return nil
+}
+
+// stopAll stops all components without locking (internal use).
+func (f *Forest) stopAll() {
+	// Stop sources first
+	for _, src := range f.sources {
+		src.Stop()
+	}
+	// Stop webhook server
+	if f.webhookServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		f.webhookServer.Stop(ctx)
+		cancel()
+	}
+	for _, tree := range f.trees {
+		tree.Stop()
+	}
+	for _, tree := range f.goTrees {
+		tree.Stop()
+	}
+	for _, th := range f.treehouses {
+		th.Stop()
+	}
+	for _, th := range f.goTreeHouses {
+		th.Stop()
+	}
+	for _, nim := range f.nims {
+		nim.Stop()
+	}
+	for _, nim := range f.goNims {
+		nim.Stop()
+	}
+	for _, sb := range f.songbirds {
+		sb.Stop()
+	}
+	for name, proj := range f.projections {
+		proj.Stop()
+		delete(f.projections, name) // Recreated on the next Start
+	}
+	if f.clock != nil {
+		f.clock.Stop()
+	}
+}
+
+// watchedScripts lists the scripts and prompts of the Lua trees, treehouses
+// and template nims, and the partials the prompts share, for the script
+// watcher.
+func (f *Forest) watchedScripts() []watchedScript {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var scripts []watchedScript
+	for name, tree := range f.trees {
+		if tree.expr == nil {
+			scripts = append(scripts, watchedScript{"tree", name, f.config.ResolvePath(tree.config.Script), tree.ReloadScript})
+		}
+	}
+	for name, th := range f.treehouses {
+		if th.expr == nil {
+			scripts = append(scripts, watchedScript{"treehouse", name, f.config.ResolvePath(th.config.Script), th.ReloadScript})
+		}
+	}
+	for name, nim := range f.nims {
+		scripts = append(scripts, watchedScript{"nim", name, f.config.ResolvePath(nim.config.Prompt), nim.ReloadPrompt})
+	}
+	if dir := f.config.promptPartials(); dir != "" && len(f.nims) > 0 {
+		files, _ := promptPartialFiles(dir)
+		for _, path := range files {
+			scripts = append(scripts, watchedScript{"partial", filepath.Base(path), path, f.reloadPrompts})
+		}
+	}
+	return scripts
+}
+
+// reloadPrompts reloads the prompt of every template nim, after a partial
+// changed.
+func (f *Forest) reloadPrompts(string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var errs []error
+	for name, nim := range f.nims {
+		if err := nim.ReloadPrompt(f.config.ResolvePath(nim.config.Prompt)); err != nil {
+			errs = append(errs, fmt.Errorf("nim %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ScriptStatus returns the running version and latest reload of every script
+// and prompt, or nil when hot reload is off.
+func (f *Forest) ScriptStatus() []ScriptStatus {
+	f.mu.Lock()
+	watcher := f.watcher
+	f.mu.Unlock()
+	if watcher == nil {
+		return nil
+	}
+	return watcher.Status()
+}
+
+// TreeHouse returns a TreeHouse by name.
+func (f *Forest) TreeHouse(name string) *TreeHouse {
+	return f.treehouses[name]
+}
+
+// Nim returns a Nim by name.
+func (f *Forest) Nim(name string) *Nim {
+	return f.nims[name]
+}
+
+// GoNim returns a registered Go nim by name.
+func (f *Forest) GoNim(name string) *GoNim {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.goNims[name]
+}
+
+// GoTreeHouse returns a registered Go treehouse by name.
+func (f *Forest) GoTreeHouse(name string) *GoTreeHouse {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.goTreeHouses[name]
+}
+
+// GoTree returns a registered Go tree by name.
+func (f *Forest) GoTree(name string) *GoTree {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.goTrees[name]
+}
+
+// runningGoTypes returns the types (go:<name>) of the compiled trees and
+// nims the forest is running. f may be nil.
+func (f *Forest) runningGoTypes() (trees, nims map[string]bool) {
+	trees, nims = make(map[string]bool), make(map[string]bool)
+	if f == nil {
+		return trees, nims
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, tree := range f.goTrees {
+		if tree.IsRunning() {
+			trees[tree.config.Type] = true
+		}
+	}
+	for _, nim := range f.goNims {
+		if nim.IsRunning() {
+			nims[nim.config.Type] = true
+		}
+	}
+	return trees, nims
+}
+
+// newTree creates a script tree, running its Lua script or WASM module with
+// the limits of c.
+func newTree(cfg TreeConfig, wind *core.Wind, river *core.River, c *Config) (*Tree, error) {
+	scriptPath := c.ResolvePath(cfg.Script)
+	if cfg.engine() == EngineWASM {
+		return NewWASMTree(cfg, wind, river, scriptPath, c.WASM.Limits())
+	}
+	return NewTreeWithLimits(cfg, wind, river, scriptPath, c.Lua.Limits())
+}
+
+// newTreeHouse creates a script treehouse, running its Lua script or WASM
+// module with the limits of c.
+func newTreeHouse(cfg TreeHouseConfig, wind *core.Wind, c *Config) (*TreeHouse, error) {
+	scriptPath := c.ResolvePath(cfg.Script)
+	if cfg.engine() == EngineWASM {
+		return NewWASMTreeHouse(cfg, wind, scriptPath, c.WASM.Limits())
+	}
+	return NewTreeHouseWithLimits(cfg, wind, scriptPath, c.Lua.Limits())
+}
+
+// newNim creates a template nim whose prompt can include the shared
+// partials, recording to humus if it is set.
+func newNim(cfg NimConfig, wind *core.Wind, humus *core.Humus, b brain.Brain, c *Config) (*Nim, error) {
+	nim, err := NewNimWithPartials(cfg, wind, b, c.ResolvePath(cfg.Prompt), c.promptPartials())
+	if err != nil {
+		return nil, err
+	}
+	nim.humus = humus
+	return nim, nil
+}
+
+// connectNim gives a template nim's prompt the beat clock and the soil its
+// access allows. Callers must hold f.mu.
+func (f *Forest) connectNim(n *Nim) {
+	if f.clock != nil {
+		n.SetClock(f.clock.Now)
+	}
+	if f.soil != nil {
+		n.SetSoil(f.soil)
+	}
+}
+
+// scriptHost is a Lua tree or treehouse. Those run by the expr engine
+// ignore what they're given.
+type scriptHost interface {
+	SetSoil(soil *core.Soil)
+	SetHumus(humus *core.Humus)
+	SetClock(now func() time.Time)
+}
+
+// connectScript gives a Lua component's script the beat clock and the soil
+// and humus modules. What the script may read and compost is limited by its
+// configured access. Callers must hold f.mu.
+func (f *Forest) connectScript(c scriptHost) {
+	if f.clock != nil {
+		c.SetClock(f.clock.Now)
+	}
+	if f.soil != nil {
+		c.SetSoil(f.soil)
+	}
+	if f.humus != nil {
+		c.SetHumus(f.humus)
+	}
+	if th, ok := c.(*TreeHouse); ok && th.config.State != nil {
+		store, err := f.stateStore(th.config)
+		if err != nil {
+			log.Printf("[Forest] Warning: treehouse %s has no state module: %v", th.config.Name, err)
+			return
+		}
+		th.SetState(store)
+	}
+}
+
+// stateStore opens the state bucket of a treehouse. Callers must hold f.mu.
+func (f *Forest) stateStore(cfg TreeHouseConfig) (*core.StateStore, error) {
+	if f.js == nil {
+		return nil, fmt.Errorf("JetStream not available")
+	}
+	bucket := cfg.State.Bucket
+	if bucket == "" {
+		bucket = core.StateBucket(cfg.Name)
+	}
+	return core.NewStateStore(f.js, core.StateConfig{Bucket: bucket, TTL: cfg.State.ttl()})
+}
+
+// goDeps returns the connections registered Go components are created with.
+// Callers must hold f.mu.
+func (f *Forest) goDeps() GoDeps {
+	return GoDeps{Wind: f.wind, River: f.river, Humus: f.humus, Soil: f.soil}
+}
+
+// Config returns the forest configuration.
+func (f *Forest) Config() *Config {
+	return f.config
+}
+
+// IsRunning returns whether the forest is currently running.
+func (f *Forest) IsRunning() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.running
+}
+
+// Projection returns a Projection by name.
+func (f *Forest) Projection(name string) *Projection {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.projections[name]
+}
+
+// ListProjections returns the status of all projections, sorted by name.
+func (f *Forest) ListProjections() []core.ProjectionStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	statuses := make([]core.ProjectionStatus, 0, len(f.projections))
+	for _, proj := range f.projections {
+		statuses = append(statuses, proj.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// RebuildProjection discards a projection's view and replays Humus into it.
+func (f *Forest) RebuildProjection(name string) error {
+	f.mu.Lock()
+	proj, exists := f.projections[name]
+	f.mu.Unlock()
+
+	if !exists {
+		return fmt.Errorf("projection '%s' not found", name)
+	}
+	if err := proj.Rebuild(); err != nil {
+		return err
+	}
+
+	log.Printf("[Forest] Rebuilding projection '%s'", name)
+	return nil
+}
+
+// HumusStatus describes the HUMUS stream, its archiver and bedrock mirror.
+type HumusStatus struct {
+	Messages   uint64                   `json:"messages"`
+	Bytes      uint64                   `json:"bytes"`
+	FirstSlot  uint64                   `json:"first_slot"`
+	LastSlot   uint64                   `json:"last_slot"`
+	Partitions int                      `json:"partitions"`
+	MaxAge     string                   `json:"max_age"`
+	MaxMsgs    int64                    `json:"max_msgs"`
+	MaxBytes   int64                    `json:"max_bytes"`
+	Archive    *core.HumusArchiveStatus `json:"archive,omitempty"`
+	Bedrock    *core.BedrockSyncStatus  `json:"bedrock,omitempty"`
+}
+
+// SetHumusArchiver sets the humus archiver so it can be managed through the API.
+func (f *Forest) SetHumusArchiver(archiver *core.HumusArchiver) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.archiver = archiver
+}
+
+// SetBedrockSyncer sets the bedrock syncer so its progress is reported
+// with the humus status.
+func (f *Forest) SetBedrockSyncer(syncer *core.BedrockSyncer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.bedrock = syncer
+}
+
+// HumusStatus returns the state of the HUMUS stream, its archiver and bedrock mirror.
+func (f *Forest) HumusStatus() (*HumusStatus, error) {
+	f.mu.Lock()
+	humus, archiver, bedrock := f.humus, f.archiver, f.bedrock
+	f.mu.Unlock()
+
+	if humus == nil {
+		return nil, fmt.Errorf("humus not available")
+	}
+
+	info, err := humus.StreamInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &HumusStatus{
+		Messages:   info.State.Msgs,
+		Bytes:      info.State.Bytes,
+		FirstSlot:  info.State.FirstSeq,
+		LastSlot:   info.State.LastSeq,
+		Partitions: humus.Partitions(),
+		MaxAge:     info.Config.MaxAge.String(),
+		MaxMsgs:    info.Config.MaxMsgs,
+		MaxBytes:   info.Config.MaxBytes,
+	}
+	if archiver != nil {
+		archive := archiver.Status()
+		status.Archive = &archive
+	}
+	if bedrock != nil {
+		sync := bedrock.Status()
+		status.Bedrock = &sync
+	}
+	return status, nil
+}
+
+// RotateHumusArchive finishes the current humus archive file.
+func (f *Forest) RotateHumusArchive() error {
+	f.mu.Lock()
+	archiver := f.archiver
+	f.mu.Unlock()
+
+	if archiver == nil {
+		return fmt.Errorf("humus archiver not configured")
+	}
+	return archiver.Rotate()
+}
+
+// ImportHumusArchives loads archive files into a stream. An empty stream
+// imports into HUMUS itself, which replays the composts into soil. Files
+// must be in the configured archive dir; relative paths are resolved there.
+func (f *Forest) ImportHumusArchives(paths []string, stream string) (core.ImportResult, error) {
+	f.mu.Lock()
+	humus, cfg := f.humus, f.config
+	f.mu.Unlock()
+
+	if humus == nil {
+		return core.ImportResult{}, fmt.Errorf("humus not available")
+	}
+	if cfg == nil || cfg.Humus == nil || cfg.Humus.Archive == nil {
+		return core.ImportResult{}, fmt.Errorf("humus archive not configured")
+	}
+	files, err := archiveFiles(cfg.ResolvePath(cfg.Humus.Archive.Dir), paths)
+	if err != nil {
+		return core.ImportResult{}, err
+	}
+	return humus.ImportArchives(files, stream)
+}
+
+// ErrOutsideArchive is returned for archive imports naming files outside
+// the configured archive dir.
+var ErrOutsideArchive = errors.New("outside the humus archive dir")
+
+// archiveFiles resolves paths against the archive dir and rejects those
+// outside it, following symlinks.
+func archiveFiles(dir string, paths []string) ([]string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = real
+	}
+	files := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		path = filepath.Clean(path)
+		if real, err := filepath.EvalSymlinks(path); err == nil {
+			path = real
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("%s: %w %s", path, ErrOutsideArchive, dir)
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+// SetRiver sets the River connection for tree and source support.
+// Must be called before adding trees or sources.
+func (f *Forest) SetRiver(river *core.River) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.river = river
+	// Create source factory when river is set
+	if f.sourceFactory == nil && river != nil {
+		f.sourceFactory = sources.NewFactory(river, f.wind)
+	}
+}
+
+// SetJetStream sets the JetStream context treehouses keep their state in.
+// Must be called before starting treehouses that declare state.
+func (f *Forest) SetJetStream(js nats.JetStreamContext) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.js = js
+}
+
+// SetSoil sets the Soil connection used by Lua scripts and soil queries.
+// Must be called before Start for configured trees and treehouses.
+func (f *Forest) SetSoil(soil *core.Soil) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.soil = soil
+}
+
+// QuerySoil finds entities in soil matching a key pattern and filter.
+func (f *Forest) QuerySoil(pattern string, filter core.QueryFilter) ([]core.QueryResult, error) {
+	f.mu.Lock()
+	soil := f.soil
+	f.mu.Unlock()
+
+	if soil == nil {
+		return nil, fmt.Errorf("soil not available")
+	}
+	return soil.Query(pattern, filter)
+}
+
+// SoilBucketStatus describes a soil bucket.
+type SoilBucketStatus struct {
+	Name     string `json:"name"`
+	Bucket   string `json:"bucket"`
+	Entities uint64 `json:"entities"`
+	Bytes    uint64 `json:"bytes"`
+	History  int64  `json:"history"`
+	TTL      string `json:"ttl,omitempty"`
+}
+
+// SetSoilBuckets sets the named soil buckets configured in forest.yaml.
+func (f *Forest) SetSoilBuckets(buckets map[string]*core.Soil) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.soilBuckets = buckets
+}
+
+// SoilBucket returns a named soil bucket, or nil if it isn't configured.
+// Use core.Typed to read and write typed entities in it.
+func (f *Forest) SoilBucket(name string) *core.Soil {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.soilBuckets[name]
+}
+
+// ListSoilBuckets returns the status of the default soil bucket and every
+// named bucket, sorted by name.
+func (f *Forest) ListSoilBuckets() ([]SoilBucketStatus, error) {
+	f.mu.Lock()
+	buckets := make(map[string]*core.Soil, len(f.soilBuckets)+1)
+	for name, soil := range f.soilBuckets {
+		buckets[name] = soil
+	}
+	if f.soil != nil {
+		buckets["default"] = f.soil
+	}
+	f.mu.Unlock()
+
+	statuses := make([]SoilBucketStatus, 0, len(buckets))
+	for name, soil := range buckets {
+		status, err := soil.Status()
+		if err != nil {
+			return nil, err
+		}
+		s := SoilBucketStatus{
+			Name:     name,
+			Bucket:   status.Bucket(),
+			Entities: status.Values(),
+			Bytes:    status.Bytes(),
+			History:  status.History(),
+		}
+		if status.TTL() > 0 {
+			s.TTL = status.TTL().String()
+		}
+		statuses = append(statuses, s)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses, nil
+}
+
+// SetSoilExpirer sets the soil expirer so expiries can be managed through the API.
+func (f *Forest) SetSoilExpirer(expirer *core.SoilExpirer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.expirer = expirer
+}
+
+// soilExpirer returns the soil expirer or an error if it isn't running.
+func (f *Forest) soilExpirer() (*core.SoilExpirer, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.expirer == nil {
+		return nil, fmt.Errorf("soil expirer not available")
+	}
+	return f.expirer, nil
+}
+
+// PendingSoilExpiries returns the scheduled expiries that haven't fired.
+func (f *Forest) PendingSoilExpiries() ([]core.SoilExpiry, error) {
+	expirer, err := f.soilExpirer()
+	if err != nil {
+		return nil, err
+	}
+	return expirer.Pending(), nil
+}
+
+// ExpireSoilEntity schedules an entity to be deleted from soil after ttl.
+func (f *Forest) ExpireSoilEntity(entity string, ttl time.Duration) error {
+	expirer, err := f.soilExpirer()
+	if err != nil {
+		return err
+	}
+	return expirer.ExpireAfter(entity, ttl)
+}
+
+// CancelSoilExpiry removes the scheduled expiry of an entity.
+func (f *Forest) CancelSoilExpiry(entity string) error {
+	expirer, err := f.soilExpirer()
+	if err != nil {
+		return err
+	}
+	return expirer.Cancel(entity)
 }
 
-// Stop stops all TreeHouses and Nims.
-func (f *Forest) Stop() error {
+// soilByName returns the default soil for "" or "default", or a named bucket.
+func (f *Forest) soilByName(bucket string) (*core.Soil, error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	if !f.running {
-		return nil
+	if bucket == "" || bucket == "default" {
+		if f.soil == nil {
+			return nil, fmt.Errorf("soil not available")
+		}
+		return f.soil, nil
+	}
+	soil, ok := f.soilBuckets[bucket]
+	if !ok {
+		return nil, fmt.Errorf("soil bucket %q not found", bucket)
 	}
+	return soil, nil
+}
 
-	f.stopAll()
-	f.running = false
-	log.Printf("[Forest] Stopped")
-	return nil
+// ExportSoil writes the entities of a soil bucket matching pattern as JSON lines.
+func (f *Forest) ExportSoil(w io.Writer, bucket, pattern string) (int, error) {
+	soil, err := f.soilByName(bucket)
+	if err != nil {
+		return 0, err
+	}
+	return soil.Export(w, pattern)
 }
 
-// stopAll stops all components without locking (internal use).
-func (f *Forest) stopAll() {
-	// Stop sources first
-	for _, src := range f.sources {
-		src.Stop()
+// ImportSoil loads a soil export into a bucket. With viaHumus, writes are
+// added to humus as composts by nim "import" and applied by the decomposers,
+// which only write the default bucket.
+func (f *Forest) ImportSoil(r io.Reader, bucket string, policy core.SoilConflictPolicy, viaHumus bool) (*core.SoilImportResult, error) {
+	soil, err := f.soilByName(bucket)
+	if err != nil {
+		return nil, err
 	}
-	// Stop webhook server
-	if f.webhookServer != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		f.webhookServer.Stop(ctx)
-		cancel()
+
+	opts := core.SoilImportOptions{Policy: policy}
+	if viaHumus {
+		if bucket != "" && bucket != "default" {
+			return nil, fmt.Errorf("imports through humus only support the default soil bucket")
+		}
+		f.mu.Lock()
+		opts.Humus = f.humus
+		f.mu.Unlock()
+		if opts.Humus == nil {
+			return nil, fmt.Errorf("humus not available")
+		}
 	}
-	for _, tree := range f.trees {
-		tree.Stop()
+	return soil.Import(r, opts)
+}
+
+// SoilEntity is an entity's current state for the soil browser.
+type SoilEntity struct {
+	Entity   string          `json:"entity"`
+	Revision uint64          `json:"revision"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// AdminNim is the nim name soil browser edits are composted as.
+const AdminNim = "admin"
+
+// BrowseSoil returns the keys of a soil bucket starting with prefix.
+func (f *Forest) BrowseSoil(bucket, prefix string) ([]string, error) {
+	soil, err := f.soilByName(bucket)
+	if err != nil {
+		return nil, err
 	}
-	for _, th := range f.treehouses {
-		th.Stop()
+	return soil.KeysWithPrefix(prefix)
+}
+
+// GetSoilEntity returns an entity's current state.
+func (f *Forest) GetSoilEntity(bucket, entity string) (*SoilEntity, error) {
+	soil, err := f.soilByName(bucket)
+	if err != nil {
+		return nil, err
 	}
-	for _, nim := range f.nims {
-		nim.Stop()
+	data, revision, err := soil.Dig(entity)
+	if err != nil {
+		return nil, err
 	}
-	for _, sb := range f.songbirds {
-		sb.Stop()
+	if !json.Valid(data) {
+		data, _ = json.Marshal(string(data))
 	}
+	return &SoilEntity{Entity: entity, Revision: revision, Data: data}, nil
 }
 
-// TreeHouse returns a TreeHouse by name.
-func (f *Forest) TreeHouse(name string) *TreeHouse {
-	return f.treehouses[name]
+// SoilHistory returns the revisions of an entity kept by its bucket.
+func (f *Forest) SoilHistory(bucket, entity string) ([]core.SoilRevision, error) {
+	soil, err := f.soilByName(bucket)
+	if err != nil {
+		return nil, err
+	}
+	return soil.History(entity)
 }
 
-// Nim returns a Nim by name.
-func (f *Forest) Nim(name string) *Nim {
-	return f.nims[name]
+// DiffSoilEntity compares two revisions of an entity; to 0 means the latest.
+func (f *Forest) DiffSoilEntity(bucket, entity string, from, to uint64) ([]core.JSONChange, error) {
+	soil, err := f.soilByName(bucket)
+	if err != nil {
+		return nil, err
+	}
+	return soil.Diff(entity, from, to)
 }
 
-// Config returns the forest configuration.
-func (f *Forest) Config() *Config {
-	return f.config
+// EditSoilEntity composts new state for an entity as nim "admin", so the
+// edit is audited in humus and applied by the decomposers. A non-zero
+// revision must match the entity's current revision.
+func (f *Forest) EditSoilEntity(entity string, data []byte, revision uint64) (uint64, error) {
+	return f.adminCompost(entity, data, revision)
 }
 
-// IsRunning returns whether the forest is currently running.
-func (f *Forest) IsRunning() bool {
+// DeleteSoilEntity composts the deletion of an entity as nim "admin".
+func (f *Forest) DeleteSoilEntity(entity string, revision uint64) (uint64, error) {
+	return f.adminCompost(entity, nil, revision)
+}
+
+// adminCompost checks the expected revision and adds an admin compost.
+// Nil data deletes the entity. Returns the humus slot.
+func (f *Forest) adminCompost(entity string, data []byte, revision uint64) (uint64, error) {
 	f.mu.Lock()
-	defer f.mu.Unlock()
-	return f.running
+	soil, humus := f.soil, f.humus
+	f.mu.Unlock()
+
+	if soil == nil || humus == nil {
+		return 0, fmt.Errorf("soil and humus not available")
+	}
+	if data != nil && !json.Valid(data) {
+		return 0, core.ErrInvalidJSON
+	}
+
+	_, current, err := soil.Dig(entity)
+	if err != nil && !errors.Is(err, core.ErrEntityNotFound) {
+		return 0, err
+	}
+	exists := err == nil
+	if revision > 0 && current != revision {
+		return 0, fmt.Errorf("%w: %s is at revision %d, not %d", core.ErrRevisionConflict, entity, current, revision)
+	}
+
+	action := "update"
+	switch {
+	case data == nil:
+		if !exists {
+			return 0, err
+		}
+		action = "delete"
+	case !exists:
+		action = "create"
+	}
+	return humus.Add(AdminNim, entity, action, data)
 }
 
-// SetRiver sets the River connection for tree and source support.
-// Must be called before adding trees or sources.
-func (f *Forest) SetRiver(river *core.River) {
+// SoilIndexes returns the secondary indexes declared on soil.
+func (f *Forest) SoilIndexes() ([]core.SoilIndex, error) {
 	f.mu.Lock()
-	defer f.mu.Unlock()
-	f.river = river
-	// Create source factory when river is set
-	if f.sourceFactory == nil && river != nil {
-		f.sourceFactory = sources.NewFactory(river, f.wind)
+	soil := f.soil
+	f.mu.Unlock()
+
+	if soil == nil {
+		return nil, fmt.Errorf("soil not available")
 	}
+	return soil.Indexes(), nil
 }
 
 // GetWebhookAddress returns the webhook server address.
@@ -415,9 +1227,13 @@ type ComponentInfo struct {
 	Type       string `json:"type"` // "treehouse" or "nim"
 	Subscribes string `json:"subscribes"`
 	Publishes  string `json:"publishes"`
-	Script     string `json:"script,omitempty"` // TreeHouse only
-	Prompt     string `json:"prompt,omitempty"` // Nim only
+	Script     string `json:"script,omitempty"`  // TreeHouse only
+	Prompt     string `json:"prompt,omitempty"`  // Nim only
+	GoType     string `json:"go_type,omitempty"` // Registered Go component, e.g. "go:aftersales"
 	Running    bool   `json:"running"`
+
+	Determinism *DeterminismStats `json:"determinism,omitempty"` // Go TreeHouse only
+	Sandbox     *SandboxStats     `json:"sandbox,omitempty"`     // Lua TreeHouse only
 }
 
 // TreeInfo provides information about a running tree.
@@ -426,7 +1242,10 @@ type TreeInfo struct {
 	Watches   string `json:"watches"`
 	Publishes string `json:"publishes"`
 	Script    string `json:"script,omitempty"`
+	GoType    string `json:"go_type,omitempty"`
 	Running   bool   `json:"running"`
+
+	Sandbox *SandboxStats `json:"sandbox,omitempty"` // Lua Tree only
 }
 
 // SourceInfo provides information about a running source.
@@ -460,9 +1279,9 @@ func (f *Forest) Status() ForestStatus {
 	status := ForestStatus{
 		Running:    f.running,
 		Sources:    make([]SourceInfo, 0, len(f.sources)),
-		Trees:      make([]TreeInfo, 0, len(f.trees)),
-		TreeHouses: make([]ComponentInfo, 0, len(f.treehouses)),
-		Nims:       make([]ComponentInfo, 0, len(f.nims)),
+		Trees:      make([]TreeInfo, 0, len(f.trees)+len(f.goTrees)),
+		TreeHouses: make([]ComponentInfo, 0, len(f.treehouses)+len(f.goTreeHouses)),
+		Nims:       make([]ComponentInfo, 0, len(f.nims)+len(f.goNims)),
 	}
 
 	for name, src := range f.sources {
@@ -480,17 +1299,28 @@ func (f *Forest) Status() ForestStatus {
 
 	for name, tree := range f.trees {
 		cfg := f.config.Trees[name]
+		sandbox := tree.SandboxStats()
 		status.Trees = append(status.Trees, TreeInfo{
 			Name:      name,
 			Watches:   cfg.Watches,
 			Publishes: cfg.Publishes,
 			Script:    cfg.Script,
 			Running:   tree.IsRunning(),
+			Sandbox:   &sandbox,
+		})
+	}
+	for name, tree := range f.goTrees {
+		status.Trees = append(status.Trees, TreeInfo{
+			Name:    name,
+			Watches: tree.Watches(),
+			GoType:  tree.config.Type,
+			Running: tree.IsRunning(),
 		})
 	}
 
 	for name, th := range f.treehouses {
 		cfg := f.config.TreeHouses[name]
+		sandbox := th.SandboxStats()
 		status.TreeHouses = append(status.TreeHouses, ComponentInfo{
 			Name:       name,
 			Type:       "treehouse",
@@ -498,6 +1328,18 @@ func (f *Forest) Status() ForestStatus {
 			Publishes:  cfg.Publishes,
 			Script:     cfg.Script,
 			Running:    th.IsRunning(),
+			Sandbox:    &sandbox,
+		})
+	}
+	for name, th := range f.goTreeHouses {
+		stats := th.Stats()
+		status.TreeHouses = append(status.TreeHouses, ComponentInfo{
+			Name:        name,
+			Type:        "treehouse",
+			Subscribes:  th.Subscribes(),
+			GoType:      th.config.Type,
+			Running:     th.IsRunning(),
+			Determinism: &stats,
 		})
 	}
 
@@ -512,6 +1354,15 @@ func (f *Forest) Status() ForestStatus {
 			Running:    nim.IsRunning(),
 		})
 	}
+	for name, nim := range f.goNims {
+		status.Nims = append(status.Nims, ComponentInfo{
+			Name:       name,
+			Type:       "nim",
+			Subscribes: nim.Subscribes(),
+			GoType:     nim.config.Type,
+			Running:    nim.IsRunning(),
+		})
+	}
 
 	return status
 }
@@ -538,30 +1389,7 @@ func (f *Forest) AddSource(name string, cfg SourceConfig) error {
 	cfg.Name = name
 
 	// Convert to factory config
-	factoryCfg := sources.SourceConfig{
-		Name:       name,
-		Type:       cfg.Type,
-		Publishes:  cfg.Publishes,
-		Path:       cfg.Path,
-		Secret:     cfg.Secret,
-		Headers:    cfg.Headers,
-		URL:        cfg.URL,
-		Method:     cfg.Method,
-		Interval:   cfg.Interval,
-		ReqHeaders: cfg.ReqHeaders,
-		Body:       cfg.Body,
-		Timeout:    cfg.Timeout,
-		Payload:    cfg.Payload,
-		Script:     cfg.Script,
-		Hz:         cfg.Hz,
-	}
-	if cfg.Cursor != nil {
-		factoryCfg.Cursor = &sources.CursorConfig{
-			Param:   cfg.Cursor.Param,
-			Extract: cfg.Cursor.Extract,
-			Store:   cfg.Cursor.Store,
-		}
-	}
+	factoryCfg := sourceFactoryConfig(name, cfg)
 
 	// Create the source
 	src, err := f.sourceFactory.Create(factoryCfg)
@@ -604,6 +1432,35 @@ func (f *Forest) AddSource(name string, cfg SourceConfig) error {
 	return nil
 }
 
+// sourceFactoryConfig converts a source's forest.yaml config for the factory.
+func sourceFactoryConfig(name string, cfg SourceConfig) sources.SourceConfig {
+	factoryCfg := sources.SourceConfig{
+		Name:       name,
+		Type:       cfg.Type,
+		Publishes:  cfg.Publishes,
+		Path:       cfg.Path,
+		Secret:     cfg.Secret,
+		Headers:    cfg.Headers,
+		URL:        cfg.URL,
+		Method:     cfg.Method,
+		Interval:   cfg.Interval,
+		ReqHeaders: cfg.ReqHeaders,
+		Body:       cfg.Body,
+		Timeout:    cfg.Timeout,
+		Payload:    cfg.Payload,
+		Script:     cfg.Script,
+		Hz:         cfg.Hz,
+	}
+	if cfg.Cursor != nil {
+		factoryCfg.Cursor = &sources.CursorConfig{
+			Param:   cfg.Cursor.Param,
+			Extract: cfg.Cursor.Extract,
+			Store:   cfg.Cursor.Store,
+		}
+	}
+	return factoryCfg
+}
+
 // RemoveSource removes a Source at runtime.
 func (f *Forest) RemoveSource(name string) error {
 	f.mu.Lock()
@@ -698,6 +1555,9 @@ func (f *Forest) AddTree(name string, cfg TreeConfig) error {
 	if _, exists := f.trees[name]; exists {
 		return fmt.Errorf("tree '%s' already exists", name)
 	}
+	if _, exists := f.goTrees[name]; exists {
+		return fmt.Errorf("tree '%s' already exists", name)
+	}
 
 	if f.river == nil {
 		return fmt.Errorf("river is required for trees (call SetRiver first)")
@@ -706,14 +1566,22 @@ func (f *Forest) AddTree(name string, cfg TreeConfig) error {
 	// Ensure name is set
 	cfg.Name = name
 
-	// Resolve script path
-	scriptPath := f.config.ResolvePath(cfg.Script)
+	if cfg.Type != "" {
+		return f.addGoTree(name, cfg)
+	}
+	if err := validatePool(cfg.Pool, cfg.Ordered); err != nil {
+		return err
+	}
+	if err := cfg.validateEngine(); err != nil {
+		return err
+	}
 
 	// Create the Tree
-	tree, err := NewTree(cfg, f.wind, f.river, scriptPath)
+	tree, err := newTree(cfg, f.wind, f.river, f.config)
 	if err != nil {
 		return fmt.Errorf("failed to create tree: %w", err)
 	}
+	f.connectScript(tree)
 
 	// Start it if the forest is running
 	if f.running {
@@ -739,8 +1607,14 @@ func (f *Forest) RemoveTree(name string) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	tree, exists := f.trees[name]
-	if !exists {
+	var tree interface{ Stop() error }
+	if t, exists := f.trees[name]; exists {
+		tree = t
+		delete(f.trees, name)
+	} else if t, exists := f.goTrees[name]; exists {
+		tree = t
+		delete(f.goTrees, name)
+	} else {
 		return fmt.Errorf("tree '%s' not found", name)
 	}
 
@@ -749,8 +1623,7 @@ func (f *Forest) RemoveTree(name string) error {
 		log.Printf("[Forest] Warning: error stopping tree '%s': %v", name, err)
 	}
 
-	// Remove from maps
-	delete(f.trees, name)
+	// Remove from config
 	delete(f.config.Trees, name)
 
 	log.Printf("[Forest] Removed tree '%s'", name)
@@ -765,18 +1638,31 @@ func (f *Forest) AddTreeHouse(name string, cfg TreeHouseConfig) error {
 	if _, exists := f.treehouses[name]; exists {
 		return fmt.Errorf("treehouse '%s' already exists", name)
 	}
+	if _, exists := f.goTreeHouses[name]; exists {
+		return fmt.Errorf("treehouse '%s' already exists", name)
+	}
 
 	// Ensure name is set
 	cfg.Name = name
-
-	// Resolve script path
-	scriptPath := f.config.ResolvePath(cfg.Script)
+	if cfg.Type != "" {
+		return f.addGoTreeHouse(name, cfg)
+	}
+	if err := validatePool(cfg.Pool, cfg.Ordered); err != nil {
+		return err
+	}
+	if err := validateEmits(cfg.Emits); err != nil {
+		return err
+	}
+	if err := cfg.validateEngine(); err != nil {
+		return err
+	}
 
 	// Create the TreeHouse
-	th, err := NewTreeHouse(cfg, f.wind, scriptPath)
+	th, err := newTreeHouse(cfg, f.wind, f.config)
 	if err != nil {
 		return fmt.Errorf("failed to create treehouse: %w", err)
 	}
+	f.connectScript(th)
 
 	// Start it if the forest is running
 	if f.running {
@@ -802,18 +1688,19 @@ func (f *Forest) RemoveTreeHouse(name string) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	th, exists := f.treehouses[name]
-	if !exists {
+	if th, exists := f.treehouses[name]; exists {
+		if err := th.Stop(); err != nil {
+			log.Printf("[Forest] Warning: error stopping treehouse '%s': %v", name, err)
+		}
+		delete(f.treehouses, name)
+	} else if th, exists := f.goTreeHouses[name]; exists {
+		th.Stop()
+		delete(f.goTreeHouses, name)
+	} else {
 		return fmt.Errorf("treehouse '%s' not found", name)
 	}
 
-	// Stop it
-	if err := th.Stop(); err != nil {
-		log.Printf("[Forest] Warning: error stopping treehouse '%s': %v", name, err)
-	}
-
-	// Remove from maps
-	delete(f.treehouses, name)
+	// Remove from config
 	delete(f.config.TreeHouses, name)
 
 	log.Printf("[Forest] Removed treehouse '%s'", name)
@@ -828,26 +1715,23 @@ func (f *Forest) AddNim(name string, cfg NimConfig) error {
 	if _, exists := f.nims[name]; exists {
 		return fmt.Errorf("nim '%s' already exists", name)
 	}
+	if _, exists := f.goNims[name]; exists {
+		return fmt.Errorf("nim '%s' already exists", name)
+	}
 
 	// Ensure name is set
 	cfg.Name = name
 
-	// Resolve prompt path
-	promptPath := f.config.ResolvePath(cfg.Prompt)
-
-	// Create the Nim
-	var nim *Nim
-	var err error
-
-	if f.humus != nil {
-		nim, err = NewNimWithHumus(cfg, f.wind, f.humus, f.brain, promptPath)
-	} else {
-		nim, err = NewNim(cfg, f.wind, f.brain, promptPath)
+	if cfg.Type != "" {
+		return f.addGoNim(name, cfg)
 	}
 
+	// Create the Nim
+	nim, err := newNim(cfg, f.wind, f.humus, f.brain, f.config)
 	if err != nil {
 		return fmt.Errorf("failed to create nim: %w", err)
 	}
+	f.connectNim(nim)
 
 	// Start it if the forest is running
 	if f.running {
@@ -873,8 +1757,14 @@ func (f *Forest) RemoveNim(name string) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	nim, exists := f.nims[name]
-	if !exists {
+	var nim interface{ Stop() error }
+	if n, exists := f.nims[name]; exists {
+		nim = n
+		delete(f.nims, name)
+	} else if n, exists := f.goNims[name]; exists {
+		nim = n
+		delete(f.goNims, name)
+	} else {
 		return fmt.Errorf("nim '%s' not found", name)
 	}
 
@@ -883,125 +1773,89 @@ func (f *Forest) RemoveNim(name string) error {
 		log.Printf("[Forest] Warning: error stopping nim '%s': %v", name, err)
 	}
 
-	// Remove from maps
-	delete(f.nims, name)
+	// Remove from config
 	delete(f.config.Nims, name)
 
 	log.Printf("[Forest] Removed nim '%s'", name)
 	return nil
 }
 
-// Reload reloads the forest configuration, adding/removing components as needed.
-func (f *Forest) Reload(newCfg *Config) error {
-	f.mu.Lock()
-	defer f.mu.Unlock()
-
-	// Find TreeHouses to remove (in old config but not in new)
-	for name := range f.treehouses {
-		if _, exists := newCfg.TreeHouses[name]; !exists {
-			if th := f.treehouses[name]; th != nil {
-				th.Stop()
-			}
-			delete(f.treehouses, name)
-			log.Printf("[Forest] Removed treehouse '%s' (not in new config)", name)
-		}
+// addGoTree creates a registered Go tree and starts it if the forest is
+// running. Callers must hold f.mu.
+func (f *Forest) addGoTree(name string, cfg TreeConfig) error {
+	if err := validateGoType(cfg.Type); err != nil {
+		return err
 	}
-
-	// Find TreeHouses to add (in new config but not running)
-	for name, cfg := range newCfg.TreeHouses {
-		if _, exists := f.treehouses[name]; !exists {
-			cfg.Name = name
-			scriptPath := newCfg.ResolvePath(cfg.Script)
-			th, err := NewTreeHouse(cfg, f.wind, scriptPath)
-			if err != nil {
-				log.Printf("[Forest] Warning: failed to create treehouse '%s': %v", name, err)
-				continue
-			}
-			if f.running {
-				if err := th.Start(context.Background()); err != nil {
-					log.Printf("[Forest] Warning: failed to start treehouse '%s': %v", name, err)
-					continue
-				}
-			}
-			f.treehouses[name] = th
-			log.Printf("[Forest] Added treehouse '%s' from new config", name)
+	tree, err := NewGoTree(cfg, f.goDeps())
+	if err != nil {
+		return fmt.Errorf("failed to create tree: %w", err)
+	}
+	if f.running {
+		if err := tree.Start(context.Background()); err != nil {
+			return fmt.Errorf("failed to start tree: %w", err)
 		}
 	}
 
-	// Find Nims to remove
-	for name := range f.nims {
-		if _, exists := newCfg.Nims[name]; !exists {
-			if nim := f.nims[name]; nim != nil {
-				nim.Stop()
-			}
-			delete(f.nims, name)
-			log.Printf("[Forest] Removed nim '%s' (not in new config)", name)
-		}
+	f.goTrees[name] = tree
+	if f.config.Trees == nil {
+		f.config.Trees = make(map[string]TreeConfig)
 	}
+	f.config.Trees[name] = cfg
 
-	// Find Nims to add
-	for name, cfg := range newCfg.Nims {
-		if _, exists := f.nims[name]; !exists {
-			cfg.Name = name
-			promptPath := newCfg.ResolvePath(cfg.Prompt)
-			var nim *Nim
-			var err error
-			if f.humus != nil {
-				nim, err = NewNimWithHumus(cfg, f.wind, f.humus, f.brain, promptPath)
-			} else {
-				nim, err = NewNim(cfg, f.wind, f.brain, promptPath)
-			}
-			if err != nil {
-				log.Printf("[Forest] Warning: failed to create nim '%s': %v", name, err)
-				continue
-			}
-			if f.running {
-				if err := nim.Start(context.Background()); err != nil {
-					log.Printf("[Forest] Warning: failed to start nim '%s': %v", name, err)
-					continue
-				}
-			}
-			f.nims[name] = nim
-			log.Printf("[Forest] Added nim '%s' from new config", name)
+	log.Printf("[Forest] Added tree '%s' (type: %s, watches: %s)", name, cfg.Type, tree.Watches())
+	return nil
+}
+
+// addGoTreeHouse creates a registered Go treehouse and starts it if the
+// forest is running. Callers must hold f.mu.
+func (f *Forest) addGoTreeHouse(name string, cfg TreeHouseConfig) error {
+	if err := validateGoType(cfg.Type); err != nil {
+		return err
+	}
+	th, err := NewGoTreeHouse(cfg, f.goDeps())
+	if err != nil {
+		return fmt.Errorf("failed to create treehouse: %w", err)
+	}
+	if f.running {
+		if err := th.Start(context.Background()); err != nil {
+			return fmt.Errorf("failed to start treehouse: %w", err)
 		}
 	}
 
-	// Find Songbirds to remove
-	for name := range f.songbirds {
-		if _, exists := newCfg.Songbirds[name]; !exists {
-			if sb := f.songbirds[name]; sb != nil {
-				sb.Stop()
-			}
-			delete(f.songbirds, name)
-			log.Printf("[Forest] Removed songbird '%s' (not in new config)", name)
-		}
+	f.goTreeHouses[name] = th
+	if f.config.TreeHouses == nil {
+		f.config.TreeHouses = make(map[string]TreeHouseConfig)
 	}
+	f.config.TreeHouses[name] = cfg
 
-	// Find Songbirds to add
-	for name, cfg := range newCfg.Songbirds {
-		if _, exists := f.songbirds[name]; !exists {
-			cfg.Name = name
-			sb, err := createSongbird(name, cfg, f.wind)
-			if err != nil {
-				log.Printf("[Forest] Warning: failed to create songbird '%s': %v", name, err)
-				continue
-			}
-			if f.running {
-				if err := sb.Start(context.Background()); err != nil {
-					log.Printf("[Forest] Warning: failed to start songbird '%s': %v", name, err)
-					continue
-				}
-			}
-			f.songbirds[name] = sb
-			log.Printf("[Forest] Added songbird '%s' from new config", name)
+	log.Printf("[Forest] Added treehouse '%s' (type: %s, catches: %s, verify: %g)",
+		name, cfg.Type, th.Subscribes(), cfg.Verify)
+	return nil
+}
+
+// addGoNim creates a registered Go nim and starts it if the forest is
+// running. Callers must hold f.mu.
+func (f *Forest) addGoNim(name string, cfg NimConfig) error {
+	if err := validateGoType(cfg.Type); err != nil {
+		return err
+	}
+	nim, err := NewGoNim(cfg, f.goDeps())
+	if err != nil {
+		return fmt.Errorf("failed to create nim: %w", err)
+	}
+	if f.running {
+		if err := nim.Start(context.Background()); err != nil {
+			return fmt.Errorf("failed to start nim: %w", err)
 		}
 	}
 
-	// Update config reference
-	f.config = newCfg
+	f.goNims[name] = nim
+	if f.config.Nims == nil {
+		f.config.Nims = make(map[string]NimConfig)
+	}
+	f.config.Nims[name] = cfg
 
-	log.Printf("[Forest] Reloaded with %d treehouses, %d nims and %d songbirds",
-		len(f.treehouses), len(f.nims), len(f.songbirds))
+	log.Printf("[Forest] Added nim '%s' (type: %s, catches: %s)", name, cfg.Type, nim.Subscribes())
 	return nil
 }
 
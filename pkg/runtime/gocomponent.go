@@ -0,0 +1,158 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/yourusername/nimsforest/internal/core"
+)
+
+// GoNim is a runtime instance of a registered Go nim (`type: go:<name>`).
+// The compiled nim decides what it catches; the runtime manages its lifecycle.
+type GoNim struct {
+	config NimConfig
+	nim    core.Nim
+
+	mu      sync.Mutex
+	running bool
+}
+
+// NewGoNim creates the registered nim named by cfg.Type.
+func NewGoNim(cfg NimConfig, deps GoDeps) (*GoNim, error) {
+	if deps.Wind == nil {
+		return nil, fmt.Errorf("wind is required")
+	}
+	nim, err := newGoNim(cfg.Type, deps)
+	if err != nil {
+		return nil, err
+	}
+	return &GoNim{config: cfg, nim: nim}, nil
+}
+
+// Start starts the compiled nim.
+func (n *GoNim) Start(ctx context.Context) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.running {
+		return fmt.Errorf("nim %s already running", n.config.Name)
+	}
+	if err := n.nim.Start(ctx); err != nil {
+		n.nim.Stop() // Release anything caught before the failure
+		return err
+	}
+
+	n.running = true
+	log.Printf("[GoNim:%s] Started %s - catches: %s", n.config.Name, n.config.Type, n.Subscribes())
+	return nil
+}
+
+// Stop stops the compiled nim.
+func (n *GoNim) Stop() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if !n.running {
+		return nil
+	}
+	n.running = false
+	return n.nim.Stop()
+}
+
+// Name returns the Nim name.
+func (n *GoNim) Name() string {
+	return n.config.Name
+}
+
+// Subscribes returns the subjects the compiled nim catches.
+func (n *GoNim) Subscribes() string {
+	return strings.Join(n.nim.Subjects(), ", ")
+}
+
+// Unwrap returns the compiled nim.
+func (n *GoNim) Unwrap() core.Nim {
+	return n.nim
+}
+
+// IsRunning returns whether the Nim is currently running.
+func (n *GoNim) IsRunning() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.running
+}
+
+// GoTree is a runtime instance of a registered Go tree (`type: go:<name>`).
+type GoTree struct {
+	config TreeConfig
+	tree   core.Tree
+
+	mu      sync.Mutex
+	running bool
+}
+
+// NewGoTree creates the registered tree named by cfg.Type.
+func NewGoTree(cfg TreeConfig, deps GoDeps) (*GoTree, error) {
+	if deps.Wind == nil {
+		return nil, fmt.Errorf("wind is required")
+	}
+	tree, err := newGoTree(cfg.Type, deps)
+	if err != nil {
+		return nil, err
+	}
+	return &GoTree{config: cfg, tree: tree}, nil
+}
+
+// Start starts the compiled tree.
+func (t *GoTree) Start(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.running {
+		return fmt.Errorf("tree %s already running", t.config.Name)
+	}
+	if err := t.tree.Start(ctx); err != nil {
+		t.tree.Stop()
+		return err
+	}
+
+	t.running = true
+	log.Printf("[GoTree:%s] Started %s - watches: %s", t.config.Name, t.config.Type, t.Watches())
+	return nil
+}
+
+// Stop stops the compiled tree.
+func (t *GoTree) Stop() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.running {
+		return nil
+	}
+	t.running = false
+	return t.tree.Stop()
+}
+
+// Name returns the Tree name.
+func (t *GoTree) Name() string {
+	return t.config.Name
+}
+
+// Watches returns the river patterns the compiled tree watches.
+func (t *GoTree) Watches() string {
+	return strings.Join(t.tree.Patterns(), ", ")
+}
+
+// Unwrap returns the compiled tree.
+func (t *GoTree) Unwrap() core.Tree {
+	return t.tree
+}
+
+// IsRunning returns whether the Tree is currently running.
+func (t *GoTree) IsRunning() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.running
+}
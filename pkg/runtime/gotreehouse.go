@@ -0,0 +1,218 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/nats-io/nats.go"
+	"github.com/yourusername/nimsforest/internal/core"
+	"github.com/yourusername/nimsforest/internal/treehouses"
+)
+
+// NondeterministicSubject returns the subject a GoTreeHouse alert is dropped
+// on when re-executing a leaf produced a different output.
+func NondeterministicSubject(treehouse string) string {
+	return "treehouse.nondeterministic." + treehouse
+}
+
+// Nondeterminism is the data of a nondeterminism alert leaf.
+type Nondeterminism struct {
+	TreeHouse string     `json:"treehouse"`
+	Type      string     `json:"type"`
+	Input     core.Leaf  `json:"input"`
+	First     *core.Leaf `json:"first"`  // Nil if the first run dropped the leaf
+	Second    *core.Leaf `json:"second"` // Nil if the second run dropped the leaf
+}
+
+// DeterminismStats counts the leaves a GoTreeHouse processed and verified.
+type DeterminismStats struct {
+	Sample     float64 `json:"sample"`
+	Processed  uint64  `json:"processed"`
+	Verified   uint64  `json:"verified"`
+	Mismatches uint64  `json:"mismatches"`
+}
+
+// GoTreeHouse hosts a registered treehouses.GoTreeHouse (`type: go:<name>`).
+// It catches the treehouse's Subjects on the wind, calls Process for each
+// leaf and drops the result.
+//
+// GoTreeHouses must be deterministic. With a verify sample rate, the host
+// re-executes that fraction of leaves and, when the two outputs differ,
+// logs it and drops a Nondeterminism leaf on NondeterministicSubject.
+type GoTreeHouse struct {
+	config TreeHouseConfig
+	house  treehouses.GoTreeHouse
+	wind   *core.Wind
+
+	mu      sync.Mutex
+	subs    []*nats.Subscription
+	running bool
+
+	processed  atomic.Uint64
+	verified   atomic.Uint64
+	mismatches atomic.Uint64
+}
+
+// NewGoTreeHouse creates the registered treehouse named by cfg.Type.
+func NewGoTreeHouse(cfg TreeHouseConfig, deps GoDeps) (*GoTreeHouse, error) {
+	if deps.Wind == nil {
+		return nil, fmt.Errorf("wind is required")
+	}
+	if cfg.Verify < 0 || cfg.Verify > 1 {
+		return nil, fmt.Errorf("verify must be between 0 and 1")
+	}
+	house, err := newGoTreeHouse(cfg.Type, deps)
+	if err != nil {
+		return nil, err
+	}
+	return &GoTreeHouse{config: cfg, house: house, wind: deps.Wind}, nil
+}
+
+// Start begins catching the treehouse's subjects.
+func (th *GoTreeHouse) Start(ctx context.Context) error {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+
+	if th.running {
+		return fmt.Errorf("treehouse %s already running", th.config.Name)
+	}
+
+	for _, subject := range th.house.Subjects() {
+		sub, err := th.wind.Catch(subject, th.handleLeaf)
+		if err != nil {
+			th.unsubscribe()
+			return fmt.Errorf("failed to subscribe to %s: %w", subject, err)
+		}
+		th.subs = append(th.subs, sub)
+	}
+
+	th.running = true
+	log.Printf("[GoTreeHouse:%s] Started %s - subscribes: %s, verify: %g",
+		th.config.Name, th.config.Type, th.Subscribes(), th.config.Verify)
+	return nil
+}
+
+// Stop stops catching leaves.
+func (th *GoTreeHouse) Stop() error {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+
+	if !th.running {
+		return nil
+	}
+	th.unsubscribe()
+	th.running = false
+	log.Printf("[GoTreeHouse:%s] Stopped", th.config.Name)
+	return nil
+}
+
+func (th *GoTreeHouse) unsubscribe() {
+	for _, sub := range th.subs {
+		if err := sub.Unsubscribe(); err != nil {
+			log.Printf("[GoTreeHouse:%s] Error unsubscribing: %v", th.config.Name, err)
+		}
+	}
+	th.subs = nil
+}
+
+// handleLeaf processes a leaf, verifies a sample of them and drops the output.
+func (th *GoTreeHouse) handleLeaf(leaf core.Leaf) {
+	th.processed.Add(1)
+
+	verify := th.config.Verify > 0 && rand.Float64() < th.config.Verify
+	var replay core.Leaf
+	if verify {
+		replay = leaf
+		replay.Data = bytes.Clone(leaf.Data) // Process may modify its input
+	}
+
+	output := th.house.Process(leaf)
+
+	if verify {
+		th.verify(replay, output)
+	}
+
+	if output == nil {
+		return
+	}
+	if output.Source == "" {
+		output.Source = "treehouse:" + th.config.Name
+	}
+	if err := th.wind.Drop(*output); err != nil {
+		log.Printf("[GoTreeHouse:%s] Error dropping leaf to %s: %v", th.config.Name, output.Subject, err)
+	}
+}
+
+// verify re-executes a leaf and alerts if the output differs from first.
+func (th *GoTreeHouse) verify(input core.Leaf, first *core.Leaf) {
+	replay := input
+	replay.Data = bytes.Clone(input.Data)
+	second := th.house.Process(replay)
+	th.verified.Add(1)
+
+	if sameOutput(first, second) {
+		return
+	}
+	th.mismatches.Add(1)
+	log.Printf("[GoTreeHouse:%s] ⚠️  Nondeterministic output for leaf %s from %s",
+		th.config.Name, input.Subject, input.Source)
+
+	data, err := json.Marshal(Nondeterminism{
+		TreeHouse: th.config.Name,
+		Type:      th.config.Type,
+		Input:     input,
+		First:     first,
+		Second:    second,
+	})
+	if err != nil {
+		log.Printf("[GoTreeHouse:%s] Failed to encode alert: %v", th.config.Name, err)
+		return
+	}
+	alert := core.NewLeaf(NondeterministicSubject(th.config.Name), data, "treehouse:"+th.config.Name)
+	if err := th.wind.Drop(*alert); err != nil {
+		log.Printf("[GoTreeHouse:%s] Failed to drop alert: %v", th.config.Name, err)
+	}
+}
+
+// sameOutput compares two outputs by subject, source and data. Timestamps
+// are ignored, since leaves are stamped when they are created.
+func sameOutput(a, b *core.Leaf) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Subject == b.Subject && a.Source == b.Source && bytes.Equal(a.Data, b.Data)
+}
+
+// Name returns the TreeHouse name.
+func (th *GoTreeHouse) Name() string {
+	return th.config.Name
+}
+
+// Subscribes returns the subjects the treehouse catches.
+func (th *GoTreeHouse) Subscribes() string {
+	return strings.Join(th.house.Subjects(), ", ")
+}
+
+// Stats returns how many leaves were processed and verified.
+func (th *GoTreeHouse) Stats() DeterminismStats {
+	return DeterminismStats{
+		Sample:     th.config.Verify,
+		Processed:  th.processed.Load(),
+		Verified:   th.verified.Load(),
+		Mismatches: th.mismatches.Load(),
+	}
+}
+
+// IsRunning returns whether the TreeHouse is currently running.
+func (th *GoTreeHouse) IsRunning() bool {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	return th.running
+}
@@ -6,23 +6,53 @@ import (
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	lua "github.com/yuin/gopher-lua"
 )
 
-// LuaVM is a wrapper around a Lua state with helper functions preloaded.
+// LuaVM is a wrapper around a sandboxed Lua state with helper functions and
+// the standard library modules (see LuaStdlibDocs) preloaded.
 type LuaVM struct {
-	state *lua.LState
+	state  *lua.LState
+	limits LuaLimits
+
+	clock    func() time.Time // time.now(); the wall clock unless SetClock is called
+	patterns patternCache     // Compiled strings patterns
+
+	guard          *lua.LFunction // Runs calls with errors classified by raise
+	allocated      int64          // Bytes library functions returned during the running call
+	memoryExceeded bool           // Set when the running call passed the memory limit
+	stackExceeded  bool           // Set when the running call overflowed the stack
+	counters       sandboxCounters
 }
 
-// NewLuaVM creates a new Lua VM with helper functions preloaded.
+// NewLuaVM creates a new Lua VM with the default limits.
 func NewLuaVM() *LuaVM {
-	L := lua.NewState()
-	vm := &LuaVM{state: L}
+	return NewLuaVMWithLimits(DefaultLuaLimits())
+}
+
+// NewLuaVMWithLimits creates a new Lua VM with helper functions preloaded.
+// Zero limits take their default.
+func NewLuaVMWithLimits(limits LuaLimits) *LuaVM {
+	limits = limits.withDefaults()
+	vm := &LuaVM{state: newSandboxState(limits), limits: limits, clock: time.Now}
+	vm.guard = vm.state.NewFunction(vm.guarded)
 	vm.registerHelpers()
+	vm.registerStdlib()
+	vm.meterLibraries()
 	return vm
 }
 
+// SetClock sets the clock time.now() reads, e.g. BeatClock.Now.
+func (vm *LuaVM) SetClock(now func() time.Time) {
+	vm.clock = now
+}
+
+func (vm *LuaVM) now() time.Time {
+	return vm.clock()
+}
+
 // Close closes the Lua VM and releases resources.
 func (vm *LuaVM) Close() {
 	vm.state.Close()
@@ -37,9 +67,14 @@ func (vm *LuaVM) LoadScript(path string) error {
 	return vm.LoadString(string(data))
 }
 
-// LoadString loads a Lua script from a string.
+// LoadString loads a Lua script from a string. Running the script's top
+// level is subject to the VM's limits.
 func (vm *LuaVM) LoadString(script string) error {
-	if err := vm.state.DoString(script); err != nil {
+	fn, err := vm.state.LoadString(script)
+	if err == nil {
+		err = vm.call(lua.P{Fn: fn, NRet: 0, Protect: true})
+	}
+	if err != nil {
 		return fmt.Errorf("failed to load script: %w", err)
 	}
 	return nil
@@ -47,7 +82,47 @@ func (vm *LuaVM) LoadString(script string) error {
 
 // CallProcess calls the process(input) function in the loaded script.
 // Input is converted from Go map to Lua table, and output is converted back.
+// A nil output means the script returned nil.
 func (vm *LuaVM) CallProcess(input map[string]interface{}) (map[string]interface{}, error) {
+	result, err := vm.callProcess(input)
+	if err != nil {
+		return nil, err
+	}
+
+	// nil filters the input out
+	if result == lua.LNil {
+		return nil, nil
+	}
+
+	// Convert result to Go map
+	if result.Type() != lua.LTTable {
+		return nil, fmt.Errorf("process function must return a table, got %s", result.Type())
+	}
+
+	output := vm.tableToMap(result.(*lua.LTable))
+	return output, nil
+}
+
+// CallProcessValue calls process(input) like CallProcess, but returns a
+// table that is a list as a slice instead of a map.
+func (vm *LuaVM) CallProcessValue(input map[string]interface{}) (interface{}, error) {
+	result, err := vm.callProcess(input)
+	if err != nil {
+		return nil, err
+	}
+
+	switch result := result.(type) {
+	case *lua.LNilType:
+		return nil, nil
+	case *lua.LTable:
+		return tableToGoValue(result), nil
+	default:
+		return nil, fmt.Errorf("process function must return a table or nil, got %s", result.Type())
+	}
+}
+
+// callProcess calls process(input) and returns its result.
+func (vm *LuaVM) callProcess(input map[string]interface{}) (lua.LValue, error) {
 	// Get the process function
 	fn := vm.state.GetGlobal("process")
 	if fn == lua.LNil {
@@ -58,7 +133,7 @@ func (vm *LuaVM) CallProcess(input map[string]interface{}) (map[string]interface
 	inputTable := vm.mapToTable(input)
 
 	// Call process(input)
-	if err := vm.state.CallByParam(lua.P{
+	if err := vm.call(lua.P{
 		Fn:      fn,
 		NRet:    1,
 		Protect: true,
@@ -69,14 +144,7 @@ func (vm *LuaVM) CallProcess(input map[string]interface{}) (map[string]interface
 	// Get result
 	result := vm.state.Get(-1)
 	vm.state.Pop(1)
-
-	// Convert result to Go map
-	if result.Type() != lua.LTTable {
-		return nil, fmt.Errorf("process function must return a table, got %s", result.Type())
-	}
-
-	output := vm.tableToMap(result.(*lua.LTable))
-	return output, nil
+	return result, nil
 }
 
 // registerHelpers registers helper functions available to Lua scripts.
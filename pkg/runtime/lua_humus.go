@@ -0,0 +1,57 @@
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/yourusername/nimsforest/internal/core"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// SetHumus lets the script record state changes through the humus module:
+//
+//	humus.compost(entity, action, data)
+//
+// action is create, update or delete, and data is a table (optional for
+// delete). Composts are attributed to nim, and only entities starting with
+// one of the writable prefixes can be composted ("*" allows every entity).
+// compost returns the humus slot, or nil and an error message.
+func (vm *LuaVM) SetHumus(humus *core.Humus, nim string, writable []string) {
+	mod := vm.state.NewTable()
+	vm.state.SetField(mod, "compost", vm.state.NewFunction(func(L *lua.LState) int {
+		return luaHumusCompost(L, humus, nim, writable)
+	}))
+	vm.state.SetGlobal("humus", mod)
+}
+
+// luaHumusCompost implements humus.compost(entity, action, data) in Lua
+func luaHumusCompost(L *lua.LState, humus *core.Humus, nim string, writable []string) int {
+	entity := L.CheckString(1)
+	action := L.CheckString(2)
+	tbl := L.OptTable(3, nil)
+
+	if !keyAllowed(writable, entity) {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(fmt.Sprintf("access denied: cannot compost %s", entity)))
+		return 2
+	}
+
+	var data []byte
+	if tbl != nil {
+		var err error
+		if data, err = json.Marshal(tableToGoValue(tbl)); err != nil {
+			L.Push(lua.LNil)
+			L.Push(lua.LString(err.Error()))
+			return 2
+		}
+	}
+
+	slot, err := humus.Add(nim, entity, action, data)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	L.Push(lua.LNumber(slot))
+	return 1
+}
@@ -0,0 +1,280 @@
+package runtime
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultLuaPoolSize is the number of VMs a tree or treehouse runs when its
+// config sets no pool size.
+const DefaultLuaPoolSize = 4
+
+// LuaPool is a fixed set of VMs with the same script loaded, so a component
+// can process several leaves at once. Each VM runs one call at a time; a
+// script's globals are per VM, so state kept in them is not shared.
+type LuaPool struct {
+	vms    []*LuaVM
+	idle   chan *LuaVM
+	wg     sync.WaitGroup
+	eachMu sync.Mutex // Each takes the whole pool; two at once would deadlock
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewLuaPool creates size VMs within limits and loads scriptPath into each.
+func NewLuaPool(size int, scriptPath string, limits LuaLimits) (*LuaPool, error) {
+	if size < 1 {
+		size = 1
+	}
+	p := &LuaPool{idle: make(chan *LuaVM, size)}
+	for i := 0; i < size; i++ {
+		vm := NewLuaVMWithLimits(limits)
+		if err := vm.LoadScript(scriptPath); err != nil {
+			vm.Close()
+			p.Close()
+			return nil, err
+		}
+		p.vms = append(p.vms, vm)
+		p.idle <- vm
+	}
+	return p, nil
+}
+
+// Size returns the number of VMs in the pool.
+func (p *LuaPool) Size() int {
+	return len(p.vms)
+}
+
+// Each calls fn for every VM, e.g. to connect soil. It first takes every
+// VM out of the pool, waiting for running calls, so no call runs while fn
+// does; it must not be called from a call on the same pool. Once the pool
+// is closed, fn is not run and Each returns false.
+func (p *LuaPool) Each(fn func(vm *LuaVM)) bool {
+	p.eachMu.Lock()
+	defer p.eachMu.Unlock()
+
+	held := make([]*LuaVM, 0, len(p.vms))
+	defer func() {
+		for _, vm := range held {
+			p.release(vm)
+		}
+	}()
+	for range p.vms {
+		vm, ok := p.acquire()
+		if !ok {
+			return false
+		}
+		held = append(held, vm)
+	}
+	for _, vm := range held {
+		fn(vm)
+	}
+	return true
+}
+
+// Go waits for an idle VM and runs fn with it on a new goroutine. Waiting
+// here blocks the caller, which holds back delivery when every VM is busy.
+// Once the pool is closed, fn is not run and Go returns false.
+func (p *LuaPool) Go(fn func(vm *LuaVM)) bool {
+	vm, ok := p.acquire()
+	if !ok {
+		return false
+	}
+	go func() {
+		defer p.release(vm)
+		fn(vm)
+	}()
+	return true
+}
+
+// Do waits for an idle VM and runs fn with it on the calling goroutine.
+// Once the pool is closed, fn is not run and Do returns false.
+func (p *LuaPool) Do(fn func(vm *LuaVM)) bool {
+	vm, ok := p.acquire()
+	if !ok {
+		return false
+	}
+	defer p.release(vm)
+	fn(vm)
+	return true
+}
+
+func (p *LuaPool) acquire() (*LuaVM, bool) {
+	vm := <-p.idle
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		p.idle <- vm
+		return nil, false
+	}
+	p.wg.Add(1)
+	return vm, true
+}
+
+func (p *LuaPool) release(vm *LuaVM) {
+	p.idle <- vm
+	p.wg.Done()
+}
+
+// Stats returns the sandbox failures of all VMs in the pool.
+func (p *LuaPool) Stats() SandboxStats {
+	var total SandboxStats
+	for _, vm := range p.vms {
+		total = total.add(vm.Stats())
+	}
+	return total
+}
+
+func (s SandboxStats) add(o SandboxStats) SandboxStats {
+	s.Timeouts += o.Timeouts
+	s.MemoryExceeded += o.MemoryExceeded
+	s.StackOverflows += o.StackOverflows
+	s.Errors += o.Errors
+	return s
+}
+
+// Close waits for running calls and closes every VM.
+func (p *LuaPool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+
+	p.wg.Wait()
+	for _, vm := range p.vms {
+		vm.Close()
+	}
+}
+
+// poolSize returns the pool size for a component's pool and ordered settings.
+func poolSize(pool int, ordered bool) int {
+	if ordered {
+		return 1
+	}
+	if pool <= 0 {
+		return DefaultLuaPoolSize
+	}
+	return pool
+}
+
+// queueGroup returns the queue group a component's pooled subscription joins,
+// so forests running the same component share its leaves.
+func queueGroup(kind, name string) string {
+	return fmt.Sprintf("%s-%s", kind, name)
+}
+
+// scriptPool is the pool a tree or treehouse runs its script on. Reloading
+// the script builds a new pool and swaps it in; calls already running finish
+// on the old one. A nil scriptPool, as used by the expr engine, has no VMs.
+type scriptPool struct {
+	current atomic.Pointer[LuaPool]
+
+	mu      sync.Mutex
+	size    int
+	limits  LuaLimits
+	setup   []func(vm *LuaVM) // Applied to every VM, including reloaded ones
+	retired SandboxStats      // Stats of replaced pools
+}
+
+func newScriptPool(size int, scriptPath string, limits LuaLimits) (*scriptPool, error) {
+	pool, err := NewLuaPool(size, scriptPath, limits)
+	if err != nil {
+		return nil, err
+	}
+	sp := &scriptPool{size: size, limits: limits}
+	sp.current.Store(pool)
+	return sp, nil
+}
+
+// configure applies fn to every VM now and after each reload.
+func (sp *scriptPool) configure(fn func(vm *LuaVM)) {
+	if sp == nil {
+		return
+	}
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.setup = append(sp.setup, fn)
+	if pool := sp.current.Load(); pool != nil {
+		pool.Each(fn)
+	}
+}
+
+// reload loads scriptPath into a new pool and swaps it in. On error the
+// current pool keeps running.
+func (sp *scriptPool) reload(scriptPath string) error {
+	if sp == nil {
+		return fmt.Errorf("not a Lua script")
+	}
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	if sp.current.Load() == nil {
+		return fmt.Errorf("stopped")
+	}
+	pool, err := NewLuaPool(sp.size, scriptPath, sp.limits)
+	if err != nil {
+		return err
+	}
+	for _, fn := range sp.setup {
+		pool.Each(fn)
+	}
+	old := sp.current.Swap(pool)
+	if old != nil {
+		old.Close()
+		sp.retired = sp.retired.add(old.Stats())
+	}
+	return nil
+}
+
+// run processes with a VM of the current pool, on a new goroutine unless
+// ordered. A call that raced with a reload is retried on the new pool.
+// It returns false if the component was closed and fn did not run.
+func (sp *scriptPool) run(ordered bool, fn func(vm *LuaVM)) bool {
+	for {
+		pool := sp.current.Load()
+		if pool == nil {
+			return false
+		}
+		if ordered && pool.Do(fn) || !ordered && pool.Go(fn) {
+			return true
+		}
+		if sp.current.Load() == pool {
+			return false // Closed without a replacement
+		}
+	}
+}
+
+func (sp *scriptPool) vms() int {
+	if sp == nil {
+		return 0
+	}
+	return sp.size
+}
+
+// stats returns the sandbox failures since the component was created.
+func (sp *scriptPool) stats() SandboxStats {
+	if sp == nil {
+		return SandboxStats{}
+	}
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	total := sp.retired
+	if pool := sp.current.Load(); pool != nil {
+		total = total.add(pool.Stats())
+	}
+	return total
+}
+
+// close stops the pool after running calls finish.
+func (sp *scriptPool) close() {
+	if sp == nil {
+		return
+	}
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	if pool := sp.current.Swap(nil); pool != nil {
+		pool.Close()
+		sp.retired = sp.retired.add(pool.Stats())
+	}
+}
@@ -0,0 +1,284 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Sandbox violations. Script errors caused by a limit wrap one of these.
+var (
+	ErrScriptTimeout       = errors.New("script exceeded its time limit")
+	ErrScriptMemory        = errors.New("script exceeded its memory limit")
+	ErrScriptStackOverflow = errors.New("script exceeded its stack limit")
+)
+
+// LuaLimits bounds what a script can use. Every LuaVM is sandboxed: only the
+// base (without file loading), table, string, math and coroutine libraries
+// and os.time, os.date, os.clock and os.difftime are available.
+type LuaLimits struct {
+	Timeout       time.Duration // Per call, including loading the script
+	CallStackSize int           // Nested Lua calls
+	RegistrySize  int           // Maximum value stack slots
+	MemoryBytes   int64         // Bytes a call may allocate through library functions
+}
+
+// DefaultLuaLimits returns the limits used when forest.yaml sets none.
+func DefaultLuaLimits() LuaLimits {
+	return LuaLimits{
+		Timeout:       time.Second,
+		CallStackSize: 200,
+		RegistrySize:  256 * 1024,
+		MemoryBytes:   128 << 20,
+	}
+}
+
+func (l LuaLimits) withDefaults() LuaLimits {
+	def := DefaultLuaLimits()
+	if l.Timeout <= 0 {
+		l.Timeout = def.Timeout
+	}
+	if l.CallStackSize <= 0 {
+		l.CallStackSize = def.CallStackSize
+	}
+	if l.RegistrySize <= 0 {
+		l.RegistrySize = def.RegistrySize
+	}
+	if l.MemoryBytes <= 0 {
+		l.MemoryBytes = def.MemoryBytes
+	}
+	return l
+}
+
+// SandboxStats counts the calls a VM failed, by cause.
+type SandboxStats struct {
+	Timeouts       uint64 `json:"timeouts"`
+	MemoryExceeded uint64 `json:"memory_exceeded"`
+	StackOverflows uint64 `json:"stack_overflows"`
+	Errors         uint64 `json:"errors"` // Other script errors
+}
+
+type sandboxCounters struct {
+	timeouts, memory, stack, errors atomic.Uint64
+}
+
+// newSandboxState creates a Lua state with only the safe libraries opened.
+func newSandboxState(limits LuaLimits) *lua.LState {
+	L := lua.NewState(lua.Options{
+		SkipOpenLibs:    true,
+		CallStackSize:   limits.CallStackSize,
+		RegistrySize:    min(lua.RegistrySize, limits.RegistrySize),
+		RegistryMaxSize: limits.RegistrySize,
+	})
+
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+		{lua.CoroutineLibName, lua.OpenCoroutine},
+		{lua.OsLibName, lua.OpenOs},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+
+	for _, name := range []string{"dofile", "loadfile", "load", "loadstring", "require", "module", "collectgarbage", "_printregs"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+
+	// Keep the clock functions of os; drop exec, exit, getenv, remove, ...
+	safeOs := L.NewTable()
+	if osLib, ok := L.GetGlobal(lua.OsLibName).(*lua.LTable); ok {
+		for _, name := range []string{"time", "date", "clock", "difftime"} {
+			safeOs.RawSetString(name, osLib.RawGetString(name))
+		}
+	}
+	L.SetGlobal(lua.OsLibName, safeOs)
+	return L
+}
+
+// meteredModules are the global tables whose functions allocate on the
+// script's behalf; what they return is charged to the VM's memory limit.
+//
+// The memory limit is a per-call budget for these functions, so it doesn't
+// depend on the clock or on what else the process allocates. gopher-lua has
+// no allocation hooks, so strings built with the .. operator and tables
+// built by constructors and writes are not charged; the time and stack
+// limits bound them instead.
+var meteredModules = []string{lua.StringLibName, lua.TabLibName, "json", "time", "crypto", "strings", "tables"}
+
+// slotBytes is what a table slot or value is charged.
+const slotBytes = 16
+
+// meterLibraries wraps the library functions so the memory they allocate
+// is counted per VM, and guards the functions that push a script-chosen
+// number of values onto the stack.
+func (vm *LuaVM) meterLibraries() {
+	strLib := vm.state.GetGlobal(lua.StringLibName).(*lua.LTable)
+	rep := strLib.RawGetString("rep").(*lua.LFunction).GFunction
+	byteFn := strLib.RawGetString("byte").(*lua.LFunction).GFunction
+	unpack := vm.state.GetGlobal("unpack").(*lua.LFunction).GFunction
+
+	for _, name := range meteredModules {
+		mod, ok := vm.state.GetGlobal(name).(*lua.LTable)
+		if !ok {
+			continue
+		}
+		mod.ForEach(func(key, value lua.LValue) {
+			if fn, ok := value.(*lua.LFunction); ok && fn.IsG {
+				mod.RawSet(key, vm.state.NewFunction(vm.metered(fn.GFunction)))
+			}
+		})
+	}
+
+	strLib.RawSetString("rep", vm.state.NewFunction(vm.metered(vm.guardRep(rep))))
+	strLib.RawSetString("byte", vm.state.NewFunction(vm.metered(vm.guardPush(byteFn, func(L *lua.LState) int {
+		s := L.CheckString(1)
+		i := L.OptInt(2, 1)
+		j := L.OptInt(3, i)
+		if j < 0 {
+			j += len(s) + 1
+		}
+		return j - i + 1
+	}))))
+	vm.state.SetGlobal("unpack", vm.state.NewFunction(vm.guardPush(unpack, func(L *lua.LState) int {
+		t := L.CheckTable(1)
+		return L.OptInt(3, t.Len()) - L.OptInt(2, 1) + 1
+	})))
+}
+
+// metered calls fn and charges the values it returns.
+func (vm *LuaVM) metered(fn lua.LGFunction) lua.LGFunction {
+	return func(L *lua.LState) int {
+		n := fn(L)
+		for i := 1; i <= n; i++ {
+			vm.charge(L, luaValueBytes(L.Get(-i)))
+		}
+		if n == 0 {
+			vm.charge(L, slotBytes) // table.insert and other in-place changes
+		}
+		return n
+	}
+}
+
+// guardRep refuses string.rep results that would pass the memory limit
+// before they are allocated.
+func (vm *LuaVM) guardRep(rep lua.LGFunction) lua.LGFunction {
+	return func(L *lua.LState) int {
+		s := L.CheckString(1)
+		n := L.CheckInt(2)
+		if n > 0 && int64(len(s))*int64(n) > vm.limits.MemoryBytes-vm.allocated {
+			vm.memoryExceeded = true
+			L.RaiseError("string.rep result exceeds the memory limit")
+		}
+		return rep(L)
+	}
+}
+
+// guardPush refuses calls that would push more values than the stack holds.
+func (vm *LuaVM) guardPush(fn lua.LGFunction, count func(L *lua.LState) int) lua.LGFunction {
+	return func(L *lua.LState) int {
+		if count(L) > vm.limits.RegistrySize {
+			vm.stackExceeded = true
+			L.RaiseError("too many results to fit the stack")
+		}
+		return fn(L)
+	}
+}
+
+// charge adds n bytes to the running call's allocations and fails the call
+// once they pass the memory limit.
+func (vm *LuaVM) charge(L *lua.LState, n int64) {
+	vm.allocated += n
+	if vm.allocated > vm.limits.MemoryBytes {
+		vm.memoryExceeded = true
+		L.RaiseError("script allocated more than %d MB", vm.limits.MemoryBytes>>20)
+	}
+}
+
+// luaValueBytes estimates the memory of a value a library function
+// returned. Tables are counted by their own slots and strings; nested
+// tables may already exist, so they are counted as one slot.
+func luaValueBytes(v lua.LValue) int64 {
+	switch v := v.(type) {
+	case lua.LString:
+		return int64(len(v)) + slotBytes
+	case *lua.LTable:
+		size := int64(slotBytes)
+		v.ForEach(func(key, value lua.LValue) {
+			size += slotBytes
+			if s, ok := key.(lua.LString); ok {
+				size += int64(len(s))
+			}
+			if s, ok := value.(lua.LString); ok {
+				size += int64(len(s))
+			}
+		})
+		return size
+	}
+	return slotBytes
+}
+
+// call runs fn within the VM's limits and classifies a failure.
+func (vm *LuaVM) call(p lua.P, args ...lua.LValue) error {
+	ctx, cancel := context.WithTimeout(context.Background(), vm.limits.Timeout)
+	defer cancel()
+	vm.state.SetContext(ctx)
+	defer vm.state.RemoveContext()
+
+	vm.allocated, vm.memoryExceeded, vm.stackExceeded = 0, false, false
+	err := vm.state.CallByParam(lua.P{Fn: vm.guard, NRet: p.NRet, Protect: true}, append([]lua.LValue{p.Fn}, args...)...)
+	if err == nil {
+		return nil
+	}
+
+	switch {
+	case vm.memoryExceeded:
+		vm.counters.memory.Add(1)
+		return fmt.Errorf("%w (%d MB): %v", ErrScriptMemory, vm.limits.MemoryBytes>>20, err)
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		vm.counters.timeouts.Add(1)
+		return fmt.Errorf("%w (%s)", ErrScriptTimeout, vm.limits.Timeout)
+	case vm.stackExceeded:
+		vm.counters.stack.Add(1)
+		return fmt.Errorf("%w: %v", ErrScriptStackOverflow, err)
+	}
+	vm.counters.errors.Add(1)
+	return err
+}
+
+// guarded calls the function below its arguments with errors routed
+// through raise, which sees the call stack before it unwinds.
+func (vm *LuaVM) guarded(L *lua.LState) int {
+	L.Panic = vm.raise
+	L.Call(L.GetTop()-1, lua.MultRet)
+	return L.GetTop()
+}
+
+// raise records whether an error was raised with the call stack full, then
+// unwinds like gopher-lua's protected calls do.
+func (vm *LuaVM) raise(L *lua.LState) {
+	if _, full := L.GetStack(vm.limits.CallStackSize - 1); full {
+		vm.stackExceeded = true
+	}
+	panic(&lua.ApiError{Type: lua.ApiErrorRun, Object: L.Get(-1)})
+}
+
+// Stats returns how many calls failed, by cause.
+func (vm *LuaVM) Stats() SandboxStats {
+	return SandboxStats{
+		Timeouts:       vm.counters.timeouts.Load(),
+		MemoryExceeded: vm.counters.memory.Load(),
+		StackOverflows: vm.counters.stack.Load(),
+		Errors:         vm.counters.errors.Load(),
+	}
+}
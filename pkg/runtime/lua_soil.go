@@ -0,0 +1,151 @@
+package runtime
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/yourusername/nimsforest/internal/core"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// SetSoil gives the script read access to soil through the soil module:
+//
+//	soil.get(key)
+//	soil.query(pattern, {where = ..., sort = "field", desc = true, limit = 10})
+//
+// get returns the decoded entity and its revision, or nil if it doesn't
+// exist. where is either a table of field = value equality conditions or a
+// list of {field, op, value} conditions. The query result is a list of
+// {entity, revision, data} tables. Both return nil and an error message on
+// failure.
+//
+// Only keys starting with one of the readable prefixes can be read ("*"
+// allows every key); query leaves out entities the script can't read.
+func (vm *LuaVM) SetSoil(soil *core.Soil, readable []string) {
+	mod := vm.state.NewTable()
+	vm.state.SetField(mod, "get", vm.state.NewFunction(func(L *lua.LState) int {
+		return luaSoilGet(L, soil, readable)
+	}))
+	vm.state.SetField(mod, "query", vm.state.NewFunction(func(L *lua.LState) int {
+		return luaSoilQuery(L, soil, readable)
+	}))
+	vm.state.SetGlobal("soil", mod)
+}
+
+// keyAllowed reports whether key starts with one of the prefixes.
+func keyAllowed(prefixes []string, key string) bool {
+	for _, prefix := range prefixes {
+		if prefix == "*" || strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// luaSoilGet implements soil.get(key) in Lua
+func luaSoilGet(L *lua.LState, soil *core.Soil, readable []string) int {
+	key := L.CheckString(1)
+	if !keyAllowed(readable, key) {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(fmt.Sprintf("access denied: cannot read %s", key)))
+		return 2
+	}
+
+	data, revision, err := soil.Dig(key)
+	if errors.Is(err, core.ErrEntityNotFound) {
+		L.Push(lua.LNil)
+		return 1
+	}
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(fmt.Sprintf("failed to decode %s: %v", key, err)))
+		return 2
+	}
+	L.Push(goValueToLua(L, value))
+	L.Push(lua.LNumber(revision))
+	return 2
+}
+
+// luaSoilQuery implements soil.query(pattern, filter) in Lua
+func luaSoilQuery(L *lua.LState, soil *core.Soil, readable []string) int {
+	pattern := L.CheckString(1)
+	filter, err := luaQueryFilter(L.OptTable(2, nil))
+	if err == nil {
+		if len(readable) == 0 {
+			L.Push(L.NewTable())
+			return 1
+		}
+		if !slices.Contains(readable, "*") {
+			// Leave out unreadable entities before the limit is applied
+			filter.Prefixes = readable
+		}
+		var results []core.QueryResult
+		if results, err = soil.Query(pattern, filter); err == nil {
+			list := L.NewTable()
+			for _, r := range results {
+				var data interface{}
+				json.Unmarshal(r.Data, &data)
+				row := L.NewTable()
+				row.RawSetString("entity", lua.LString(r.Entity))
+				row.RawSetString("revision", lua.LNumber(r.Revision))
+				row.RawSetString("data", goValueToLua(L, data))
+				list.Append(row)
+			}
+			L.Push(list)
+			return 1
+		}
+	}
+	L.Push(lua.LNil)
+	L.Push(lua.LString(err.Error()))
+	return 2
+}
+
+// luaQueryFilter converts a Lua filter table into a QueryFilter.
+func luaQueryFilter(tbl *lua.LTable) (core.QueryFilter, error) {
+	var filter core.QueryFilter
+	if tbl == nil {
+		return filter, nil
+	}
+
+	if where, ok := tbl.RawGetString("where").(*lua.LTable); ok {
+		if where.Len() > 0 {
+			// List of {field, op, value}
+			var err error
+			where.ForEach(func(_, v lua.LValue) {
+				cond, ok := v.(*lua.LTable)
+				if !ok || cond.Len() != 3 {
+					err = fmt.Errorf("where conditions must be {field, op, value}")
+					return
+				}
+				filter.Where = append(filter.Where, core.QueryCondition{
+					Field: lua.LVAsString(cond.RawGetInt(1)),
+					Op:    lua.LVAsString(cond.RawGetInt(2)),
+					Value: luaValueToGo(cond.RawGetInt(3)),
+				})
+			})
+			if err != nil {
+				return filter, err
+			}
+		} else {
+			// Map of field = value equality conditions
+			where.ForEach(func(k, v lua.LValue) {
+				filter.Where = append(filter.Where, core.Eq(lua.LVAsString(k), luaValueToGo(v)))
+			})
+		}
+	}
+
+	filter.Sort = lua.LVAsString(tbl.RawGetString("sort"))
+	filter.Desc = lua.LVAsBool(tbl.RawGetString("desc"))
+	filter.Limit = int(lua.LVAsNumber(tbl.RawGetString("limit")))
+	return filter, nil
+}
@@ -0,0 +1,189 @@
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/yourusername/nimsforest/internal/core"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// SetState gives the script keyed state that survives restarts through the
+// state module:
+//
+//	state.get(key)
+//	state.set(key, value, ttl)
+//	state.incr(key, delta, ttl)
+//	state.delete(key)
+//	state.tumbling(key, size, value)
+//	state.sliding(key, size, step, value)
+//
+// Keys are NATS KV keys, e.g. "failures.acme". ttl, size and step are
+// seconds or duration strings such as "1h"; ttl and delta are optional, and
+// without a ttl the configured default applies. tumbling and sliding add
+// value, if given, to the key's window containing time.now() and return
+// {count, sum, min, max, avg, start, end}. Every function returns nil and an
+// error message on failure.
+func (vm *LuaVM) SetState(store *core.StateStore) {
+	mod := vm.state.NewTable()
+	for name, fn := range map[string]func(L *lua.LState, store *core.StateStore) int{
+		"get":    luaStateGet,
+		"set":    luaStateSet,
+		"incr":   luaStateIncr,
+		"delete": luaStateDelete,
+	} {
+		vm.state.SetField(mod, name, vm.state.NewFunction(func(L *lua.LState) int {
+			return fn(L, store)
+		}))
+	}
+	vm.state.SetField(mod, "tumbling", vm.state.NewFunction(func(L *lua.LState) int {
+		return vm.luaStateWindow(L, store, false)
+	}))
+	vm.state.SetField(mod, "sliding", vm.state.NewFunction(func(L *lua.LState) int {
+		return vm.luaStateWindow(L, store, true)
+	}))
+	vm.state.SetGlobal("state", mod)
+}
+
+// luaStateGet implements state.get(key) in Lua
+func luaStateGet(L *lua.LState, store *core.StateStore) int {
+	data, ok, err := store.Get(L.CheckString(1))
+	if err != nil {
+		return luaStateError(L, err)
+	}
+	if !ok {
+		L.Push(lua.LNil)
+		return 1
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return luaStateError(L, err)
+	}
+	L.Push(goValueToLua(L, value))
+	return 1
+}
+
+// luaStateSet implements state.set(key, value, ttl) in Lua
+func luaStateSet(L *lua.LState, store *core.StateStore) int {
+	key := L.CheckString(1)
+	value := L.CheckAny(2)
+	ttl, err := luaDurationArg(L, 3)
+	if err != nil {
+		return luaStateError(L, err)
+	}
+
+	var goValue interface{}
+	if tbl, ok := value.(*lua.LTable); ok {
+		goValue = tableToGoValue(tbl)
+	} else {
+		goValue = luaValueToGo(value)
+	}
+	data, err := json.Marshal(goValue)
+	if err == nil {
+		err = store.Set(key, data, ttl)
+	}
+	if err != nil {
+		return luaStateError(L, err)
+	}
+	L.Push(lua.LTrue)
+	return 1
+}
+
+// luaStateIncr implements state.incr(key, delta, ttl) in Lua
+func luaStateIncr(L *lua.LState, store *core.StateStore) int {
+	key := L.CheckString(1)
+	delta := float64(L.OptNumber(2, 1))
+	ttl, err := luaDurationArg(L, 3)
+	if err != nil {
+		return luaStateError(L, err)
+	}
+
+	n, err := store.Incr(key, delta, ttl)
+	if err != nil {
+		return luaStateError(L, err)
+	}
+	L.Push(lua.LNumber(n))
+	return 1
+}
+
+// luaStateDelete implements state.delete(key) in Lua
+func luaStateDelete(L *lua.LState, store *core.StateStore) int {
+	if err := store.Delete(L.CheckString(1)); err != nil {
+		return luaStateError(L, err)
+	}
+	L.Push(lua.LTrue)
+	return 1
+}
+
+// luaStateWindow implements state.tumbling(key, size, value) and
+// state.sliding(key, size, step, value) in Lua
+func (vm *LuaVM) luaStateWindow(L *lua.LState, store *core.StateStore, sliding bool) int {
+	key := L.CheckString(1)
+	var w core.Window
+	var err error
+	if w.Size, err = luaDurationArg(L, 2); err == nil && w.Size == 0 {
+		err = fmt.Errorf("window size is required")
+	}
+	valueArg := 3
+	if err == nil && sliding {
+		if w.Step, err = luaDurationArg(L, 3); err == nil && w.Step == 0 {
+			err = fmt.Errorf("window step is required")
+		}
+		valueArg = 4
+	}
+	if err != nil {
+		return luaStateError(L, err)
+	}
+
+	var agg core.WindowAggregate
+	if L.Get(valueArg) == lua.LNil {
+		agg, err = store.ReadWindow(key, w, vm.now())
+	} else {
+		agg, err = store.AddToWindow(key, w, vm.now(), float64(L.CheckNumber(valueArg)))
+	}
+	if err != nil {
+		return luaStateError(L, err)
+	}
+
+	tbl := L.NewTable()
+	tbl.RawSetString("count", lua.LNumber(agg.Count))
+	tbl.RawSetString("sum", lua.LNumber(agg.Sum))
+	if agg.Count > 0 {
+		tbl.RawSetString("min", lua.LNumber(agg.Min))
+		tbl.RawSetString("max", lua.LNumber(agg.Max))
+		tbl.RawSetString("avg", lua.LNumber(agg.Sum/float64(agg.Count)))
+	}
+	tbl.RawSetString("start", luaTimestamp(agg.Start))
+	tbl.RawSetString("end", luaTimestamp(agg.End))
+	L.Push(tbl)
+	return 1
+}
+
+// luaDurationArg reads an optional duration argument given in seconds or as
+// a duration string. A missing argument is zero.
+func luaDurationArg(L *lua.LState, n int) (time.Duration, error) {
+	switch v := L.Get(n).(type) {
+	case *lua.LNilType:
+		return 0, nil
+	case lua.LNumber:
+		if v < 0 {
+			return 0, fmt.Errorf("duration must not be negative")
+		}
+		return time.Duration(float64(v) * float64(time.Second)), nil
+	case lua.LString:
+		d, err := time.ParseDuration(string(v))
+		if err != nil || d < 0 {
+			return 0, fmt.Errorf("invalid duration %q", string(v))
+		}
+		return d, nil
+	default:
+		return 0, fmt.Errorf("duration must be seconds or a string like \"1h\", got %s", v.Type())
+	}
+}
+
+func luaStateError(L *lua.LState, err error) int {
+	L.Push(lua.LNil)
+	L.Push(lua.LString(err.Error()))
+	return 2
+}
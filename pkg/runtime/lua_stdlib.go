@@ -0,0 +1,394 @@
+package runtime
+
+import (
+	"container/list"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// luaFunc is a function of a built-in Lua module. Usage and Doc are
+// rendered by LuaStdlibDocs.
+type luaFunc struct {
+	Name  string
+	Usage string
+	Doc   string
+	Fn    func(vm *LuaVM, L *lua.LState) int
+}
+
+// luaModule is a built-in Lua module, registered as a global table.
+type luaModule struct {
+	Name  string
+	Doc   string
+	Funcs []luaFunc
+}
+
+// luaStdlib lists the modules every Lua tree, treehouse and projection gets.
+var luaStdlib = []luaModule{
+	{
+		Name: "time",
+		Doc:  "Timestamps are Unix seconds (with fractions); formatted times are UTC.",
+		Funcs: []luaFunc{
+			{"now", "time.now()", "Returns the time of the current WindWaker beat, so every script sees the same time within a beat. Falls back to the wall clock when no beats arrive.", luaTimeNow},
+			{"parse", "time.parse(s [, layout])", "Parses an RFC3339 time (or one in a Go layout) into a timestamp. Returns nil and an error message if it doesn't parse.", luaTimeParse},
+			{"format", "time.format(ts [, layout])", "Formats a timestamp as RFC3339 (or in a Go layout).", luaTimeFormat},
+			{"duration", "time.duration(s)", "Parses a duration such as \"1h30m\" into seconds. Returns nil and an error message if it doesn't parse.", luaTimeDuration},
+			{"format_duration", "time.format_duration(seconds)", "Formats seconds as a duration such as \"1h30m0s\".", luaTimeFormatDuration},
+		},
+	},
+	{
+		Name: "crypto",
+		Doc:  "Hashes are returned hex encoded.",
+		Funcs: []luaFunc{
+			{"sha256", "crypto.sha256(s)", "Returns the SHA-256 hash of s.", luaCryptoSHA256},
+			{"hmac", "crypto.hmac(key, s)", "Returns the HMAC-SHA256 of s, e.g. to verify webhook signatures.", luaCryptoHMAC},
+			{"base64_encode", "crypto.base64_encode(s)", "Encodes s as standard base64.", luaCryptoBase64Encode},
+			{"base64_decode", "crypto.base64_decode(s)", "Decodes standard base64. Returns nil and an error message if s isn't valid.", luaCryptoBase64Decode},
+			{"uuid", "crypto.uuid()", "Returns a random (version 4) UUID.", luaCryptoUUID},
+		},
+	},
+	{
+		Name: "strings",
+		Doc:  "Patterns are Go regular expressions (RE2 syntax); an invalid pattern returns nil and an error message.",
+		Funcs: []luaFunc{
+			{"split", "strings.split(s, sep)", "Splits s around each sep into a list.", luaStringsSplit},
+			{"trim", "strings.trim(s [, cutset])", "Removes leading and trailing whitespace, or the characters in cutset.", luaStringsTrim},
+			{"test", "strings.test(s, pattern)", "Reports whether s matches pattern.", luaStringsTest},
+			{"match", "strings.match(s, pattern)", "Returns the first match and its capture groups as a list ({whole, group1, ...}), or nil.", luaStringsMatch},
+			{"replace", "strings.replace(s, pattern, repl)", "Replaces every match of pattern; repl may refer to groups as ${1}.", luaStringsReplace},
+		},
+	},
+	{
+		Name: "tables",
+		Doc:  "Lists keep their order; other tables keep their keys.",
+		Funcs: []luaFunc{
+			{"map", "tables.map(t, fn)", "Returns a new table with fn(value, key) applied to every value.", luaTablesMap},
+			{"filter", "tables.filter(t, fn)", "Returns a new table with the values for which fn(value, key) is true.", luaTablesFilter},
+			{"deepcopy", "tables.deepcopy(t)", "Returns a copy of t and every table nested in it.", luaTablesDeepCopy},
+		},
+	},
+}
+
+// registerStdlib registers the luaStdlib modules.
+func (vm *LuaVM) registerStdlib() {
+	for _, m := range luaStdlib {
+		mod := vm.state.NewTable()
+		for _, f := range m.Funcs {
+			fn := f.Fn
+			vm.state.SetField(mod, f.Name, vm.state.NewFunction(func(L *lua.LState) int {
+				return fn(vm, L)
+			}))
+		}
+		vm.state.SetGlobal(m.Name, mod)
+	}
+}
+
+// LuaStdlibDocs renders the built-in Lua modules as Markdown.
+func LuaStdlibDocs() string {
+	var b strings.Builder
+	b.WriteString("# Lua standard library\n\n")
+	b.WriteString("<!-- Generated from pkg/runtime/lua_stdlib.go by `make lua-docs`. Do not edit. -->\n\n")
+	b.WriteString("These modules are available to every Lua tree, treehouse and projection, alongside `json`, `contains` and `log`.\n")
+	for _, m := range luaStdlib {
+		fmt.Fprintf(&b, "\n## %s\n\n%s\n\n", m.Name, m.Doc)
+		b.WriteString("| Function | Description |\n|---|---|\n")
+		for _, f := range m.Funcs {
+			fmt.Fprintf(&b, "| `%s` | %s |\n", f.Usage, f.Doc)
+		}
+	}
+	return b.String()
+}
+
+// luaFail pushes nil and an error message.
+func luaFail(L *lua.LState, err error) int {
+	L.Push(lua.LNil)
+	L.Push(lua.LString(err.Error()))
+	return 2
+}
+
+// =============================================================================
+// time
+// =============================================================================
+
+func luaTimestamp(t time.Time) lua.LNumber {
+	return lua.LNumber(float64(t.UnixNano()) / float64(time.Second))
+}
+
+func luaTime(ts lua.LNumber) time.Time {
+	return time.Unix(0, int64(float64(ts)*float64(time.Second))).UTC()
+}
+
+func luaTimeNow(vm *LuaVM, L *lua.LState) int {
+	L.Push(luaTimestamp(vm.now()))
+	return 1
+}
+
+func luaTimeParse(vm *LuaVM, L *lua.LState) int {
+	s := L.CheckString(1)
+	layout := L.OptString(2, time.RFC3339)
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		return luaFail(L, err)
+	}
+	L.Push(luaTimestamp(t))
+	return 1
+}
+
+func luaTimeFormat(vm *LuaVM, L *lua.LState) int {
+	ts := L.CheckNumber(1)
+	layout := L.OptString(2, time.RFC3339)
+	L.Push(lua.LString(luaTime(ts).Format(layout)))
+	return 1
+}
+
+func luaTimeDuration(vm *LuaVM, L *lua.LState) int {
+	d, err := time.ParseDuration(L.CheckString(1))
+	if err != nil {
+		return luaFail(L, err)
+	}
+	L.Push(lua.LNumber(d.Seconds()))
+	return 1
+}
+
+func luaTimeFormatDuration(vm *LuaVM, L *lua.LState) int {
+	seconds := L.CheckNumber(1)
+	L.Push(lua.LString(time.Duration(float64(seconds) * float64(time.Second)).String()))
+	return 1
+}
+
+// =============================================================================
+// crypto
+// =============================================================================
+
+func luaCryptoSHA256(vm *LuaVM, L *lua.LState) int {
+	sum := sha256.Sum256([]byte(L.CheckString(1)))
+	L.Push(lua.LString(hex.EncodeToString(sum[:])))
+	return 1
+}
+
+func luaCryptoHMAC(vm *LuaVM, L *lua.LState) int {
+	mac := hmac.New(sha256.New, []byte(L.CheckString(1)))
+	mac.Write([]byte(L.CheckString(2)))
+	L.Push(lua.LString(hex.EncodeToString(mac.Sum(nil))))
+	return 1
+}
+
+func luaCryptoBase64Encode(vm *LuaVM, L *lua.LState) int {
+	L.Push(lua.LString(base64.StdEncoding.EncodeToString([]byte(L.CheckString(1)))))
+	return 1
+}
+
+func luaCryptoBase64Decode(vm *LuaVM, L *lua.LState) int {
+	data, err := base64.StdEncoding.DecodeString(L.CheckString(1))
+	if err != nil {
+		return luaFail(L, err)
+	}
+	L.Push(lua.LString(data))
+	return 1
+}
+
+func luaCryptoUUID(vm *LuaVM, L *lua.LState) int {
+	var u [16]byte
+	rand.Read(u[:])
+	u[6] = (u[6] & 0x0f) | 0x40 // Version 4
+	u[8] = (u[8] & 0x3f) | 0x80 // RFC 4122 variant
+	L.Push(lua.LString(fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])))
+	return 1
+}
+
+// =============================================================================
+// strings
+// =============================================================================
+
+func luaStringsSplit(vm *LuaVM, L *lua.LState) int {
+	list := L.NewTable()
+	for _, part := range strings.Split(L.CheckString(1), L.CheckString(2)) {
+		list.Append(lua.LString(part))
+	}
+	L.Push(list)
+	return 1
+}
+
+func luaStringsTrim(vm *LuaVM, L *lua.LState) int {
+	s := L.CheckString(1)
+	if L.GetTop() >= 2 {
+		L.Push(lua.LString(strings.Trim(s, L.CheckString(2))))
+	} else {
+		L.Push(lua.LString(strings.TrimSpace(s)))
+	}
+	return 1
+}
+
+// maxCachedPatterns is how many compiled strings patterns a VM keeps.
+const maxCachedPatterns = 64
+
+// patternCache keeps the most recently used compiled patterns.
+type patternCache struct {
+	order *list.List // Front is the most recently used
+	items map[string]*list.Element
+}
+
+type cachedPattern struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+// regexp compiles a pattern, caching the most recently used ones.
+func (vm *LuaVM) regexp(pattern string) (*regexp.Regexp, error) {
+	c := &vm.patterns
+	if el, ok := c.items[pattern]; ok {
+		c.order.MoveToFront(el)
+		return el.Value.(*cachedPattern).re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if c.items == nil {
+		c.order = list.New()
+		c.items = make(map[string]*list.Element)
+	}
+	c.items[pattern] = c.order.PushFront(&cachedPattern{pattern, re})
+	if c.order.Len() > maxCachedPatterns {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cachedPattern).pattern)
+	}
+	return re, nil
+}
+
+func luaStringsTest(vm *LuaVM, L *lua.LState) int {
+	s := L.CheckString(1)
+	re, err := vm.regexp(L.CheckString(2))
+	if err != nil {
+		return luaFail(L, err)
+	}
+	L.Push(lua.LBool(re.MatchString(s)))
+	return 1
+}
+
+func luaStringsMatch(vm *LuaVM, L *lua.LState) int {
+	s := L.CheckString(1)
+	re, err := vm.regexp(L.CheckString(2))
+	if err != nil {
+		return luaFail(L, err)
+	}
+	groups := re.FindStringSubmatch(s)
+	if groups == nil {
+		L.Push(lua.LNil)
+		return 1
+	}
+	list := L.NewTable()
+	for _, g := range groups {
+		list.Append(lua.LString(g))
+	}
+	L.Push(list)
+	return 1
+}
+
+func luaStringsReplace(vm *LuaVM, L *lua.LState) int {
+	s := L.CheckString(1)
+	re, err := vm.regexp(L.CheckString(2))
+	if err != nil {
+		return luaFail(L, err)
+	}
+	L.Push(lua.LString(re.ReplaceAllString(s, L.CheckString(3))))
+	return 1
+}
+
+// =============================================================================
+// tables
+// =============================================================================
+
+// isLuaList reports whether a table is a non-empty list (keys 1..n).
+func isLuaList(tbl *lua.LTable) bool {
+	n := tbl.Len()
+	if n == 0 {
+		return false
+	}
+	count := 0
+	tbl.ForEach(func(_, _ lua.LValue) { count++ })
+	return count == n
+}
+
+// luaCall calls fn(value, key) and returns its first result.
+func luaCall(L *lua.LState, fn *lua.LFunction, value, key lua.LValue) lua.LValue {
+	L.Push(fn)
+	L.Push(value)
+	L.Push(key)
+	L.Call(2, 1)
+	ret := L.Get(-1)
+	L.Pop(1)
+	return ret
+}
+
+func luaTablesMap(vm *LuaVM, L *lua.LState) int {
+	tbl := L.CheckTable(1)
+	fn := L.CheckFunction(2)
+	out := L.NewTable()
+	if isLuaList(tbl) {
+		for i := 1; i <= tbl.Len(); i++ {
+			out.RawSetInt(i, luaCall(L, fn, tbl.RawGetInt(i), lua.LNumber(i)))
+		}
+	} else {
+		tbl.ForEach(func(k, v lua.LValue) {
+			out.RawSet(k, luaCall(L, fn, v, k))
+		})
+	}
+	L.Push(out)
+	return 1
+}
+
+func luaTablesFilter(vm *LuaVM, L *lua.LState) int {
+	tbl := L.CheckTable(1)
+	fn := L.CheckFunction(2)
+	out := L.NewTable()
+	if isLuaList(tbl) {
+		for i := 1; i <= tbl.Len(); i++ {
+			if v := tbl.RawGetInt(i); lua.LVAsBool(luaCall(L, fn, v, lua.LNumber(i))) {
+				out.Append(v)
+			}
+		}
+	} else {
+		tbl.ForEach(func(k, v lua.LValue) {
+			if lua.LVAsBool(luaCall(L, fn, v, k)) {
+				out.RawSet(k, v)
+			}
+		})
+	}
+	L.Push(out)
+	return 1
+}
+
+func luaTablesDeepCopy(vm *LuaVM, L *lua.LState) int {
+	L.Push(deepCopyTable(L, L.CheckTable(1), make(map[*lua.LTable]*lua.LTable)))
+	return 1
+}
+
+// deepCopyTable copies a table and its nested tables. Tables that appear more
+// than once (including cycles) are copied once.
+func deepCopyTable(L *lua.LState, tbl *lua.LTable, seen map[*lua.LTable]*lua.LTable) *lua.LTable {
+	if out, ok := seen[tbl]; ok {
+		return out
+	}
+	out := L.NewTable()
+	seen[tbl] = out
+	tbl.ForEach(func(k, v lua.LValue) {
+		if nested, ok := v.(*lua.LTable); ok {
+			v = deepCopyTable(L, nested, seen)
+		}
+		out.RawSet(k, v)
+	})
+	if mt, ok := L.GetMetatable(tbl).(*lua.LTable); ok {
+		L.SetMetatable(out, mt)
+	}
+	return out
+}
@@ -1,7 +1,20 @@
 package runtime
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/yourusername/nimsforest/internal/core"
+	"github.com/yourusername/nimsforest/internal/windwaker"
+	lua "github.com/yuin/gopher-lua"
 )
 
 func TestLuaVMBasic(t *testing.T) {
@@ -243,3 +256,901 @@ end
 		t.Errorf("expected details.active=true, got %v", details["active"])
 	}
 }
+
+func TestLuaSoilQuery(t *testing.T) {
+	soil, err := core.NewSoil(setupTestJS(t))
+	if err != nil {
+		t.Fatalf("Failed to create soil: %v", err)
+	}
+	soil.Put("tasks/1", []byte(`{"customer_id":"acme","status":"open","priority":3}`))
+	soil.Put("tasks/2", []byte(`{"customer_id":"acme","status":"done","priority":1}`))
+	soil.Put("tasks/3", []byte(`{"customer_id":"acme","status":"open","priority":7}`))
+	soil.Put("secret/1", []byte(`{"customer_id":"acme","status":"open","priority":9}`))
+
+	vm := NewLuaVM()
+	defer vm.Close()
+	vm.SetSoil(soil, []string{"tasks/"})
+
+	script := `
+function process(input)
+    local open = soil.query("tasks/*", {where = {customer_id = input.customer, status = "open"}, sort = "priority", desc = true})
+    local urgent = soil.query("tasks/*", {where = {{"priority", ">", 5}}})
+    local all = soil.query("*", {where = {status = "open"}})
+    local top = soil.query("*", {where = {status = "open"}, sort = "priority", desc = true, limit = 1})
+    local _, err = soil.query("tasks/*", {where = {{"priority", "~", 5}}})
+    return {
+        first = open[1].entity,
+        first_priority = open[1].data.priority,
+        open_count = #open,
+        urgent_count = #urgent,
+        readable_count = #all,
+        top = top[1] and top[1].entity,
+        err = err
+    }
+end
+`
+	if err := vm.LoadString(script); err != nil {
+		t.Fatalf("LoadString failed: %v", err)
+	}
+
+	output, err := vm.CallProcess(map[string]interface{}{"customer": "acme"})
+	if err != nil {
+		t.Fatalf("CallProcess failed: %v", err)
+	}
+
+	if output["first"] != "tasks/3" || output["first_priority"] != float64(7) {
+		t.Errorf("expected tasks/3 with priority 7 first, got %v (%v)", output["first"], output["first_priority"])
+	}
+	if output["open_count"] != float64(2) {
+		t.Errorf("expected 2 open tasks, got %v", output["open_count"])
+	}
+	if output["urgent_count"] != float64(1) {
+		t.Errorf("expected 1 urgent task, got %v", output["urgent_count"])
+	}
+	if output["readable_count"] != float64(2) {
+		t.Errorf("expected query to leave out unreadable entities, got %v", output["readable_count"])
+	}
+	if output["top"] != "tasks/3" {
+		t.Errorf("expected the limit to apply to readable entities, got %v", output["top"])
+	}
+	if output["err"] == nil {
+		t.Error("expected error for unknown operator")
+	}
+}
+
+func TestLuaSoilGetAndCompost(t *testing.T) {
+	js := setupTestJS(t)
+	soil, err := core.NewSoil(js)
+	if err != nil {
+		t.Fatalf("Failed to create soil: %v", err)
+	}
+	humus, err := core.NewHumus(js)
+	if err != nil {
+		t.Fatalf("Failed to create humus: %v", err)
+	}
+	soil.Put("contacts/42", []byte(`{"name":"Ada","scored":true}`))
+	soil.Put("billing/42", []byte(`{"plan":"pro"}`))
+
+	vm := NewLuaVM()
+	defer vm.Close()
+	vm.SetSoil(soil, []string{"contacts/"})
+	vm.SetHumus(humus, "treehouse:scoring", []string{"leads/"})
+
+	script := `
+function process(input)
+    local contact, rev = soil.get("contacts/42")
+    local missing, missing_err = soil.get("contacts/7")
+    local _, read_err = soil.get("billing/42")
+    local slot = humus.compost("leads/42", "create", {score = 90, name = contact.name})
+    local _, write_err = humus.compost("contacts/42", "update", {scored = false})
+    local _, action_err = humus.compost("leads/42", "upsert", {score = 1})
+    return {
+        name = contact.name,
+        scored = contact.scored,
+        revision = rev,
+        missing = missing == nil and missing_err == nil,
+        read_err = read_err,
+        slot = slot,
+        write_err = write_err,
+        action_err = action_err
+    }
+end
+`
+	if err := vm.LoadString(script); err != nil {
+		t.Fatalf("LoadString failed: %v", err)
+	}
+
+	output, err := vm.CallProcess(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("CallProcess failed: %v", err)
+	}
+
+	if output["name"] != "Ada" || output["scored"] != true || output["revision"] == nil {
+		t.Errorf("expected contacts/42 to be read, got %v", output)
+	}
+	if output["missing"] != true {
+		t.Errorf("expected nil without error for a missing key, got %v", output["missing"])
+	}
+	if err, _ := output["read_err"].(string); !strings.Contains(err, "access denied") {
+		t.Errorf("expected access denied reading billing/42, got %v", output["read_err"])
+	}
+	if err, _ := output["write_err"].(string); !strings.Contains(err, "access denied") {
+		t.Errorf("expected access denied composting contacts/42, got %v", output["write_err"])
+	}
+	if output["action_err"] == nil {
+		t.Error("expected error for invalid action")
+	}
+
+	slot, ok := output["slot"].(float64)
+	if !ok {
+		t.Fatalf("expected compost slot, got %v", output["slot"])
+	}
+	msg, err := js.GetMsg("HUMUS", uint64(slot))
+	if err != nil {
+		t.Fatalf("Failed to read compost: %v", err)
+	}
+	var compost core.Compost
+	json.Unmarshal(msg.Data, &compost)
+	if compost.Entity != "leads/42" || compost.Action != "create" || compost.NimName != "treehouse:scoring" {
+		t.Errorf("unexpected compost: %+v", compost)
+	}
+	var data map[string]interface{}
+	json.Unmarshal(compost.Data, &data)
+	if data["score"] != float64(90) || data["name"] != "Ada" {
+		t.Errorf("unexpected compost data: %v", data)
+	}
+}
+
+func TestLuaStdlibTime(t *testing.T) {
+	vm := NewLuaVM()
+	defer vm.Close()
+	beat := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	vm.SetClock(func() time.Time { return beat })
+
+	script := `
+function process(input)
+    local ts = time.parse(input.at)
+    local _, parse_err = time.parse("yesterday")
+    local _, duration_err = time.duration("soon")
+    return {
+        now = time.format(time.now()),
+        later = time.format(ts + time.duration("1h30m")),
+        day = time.format(ts, "2006-01-02"),
+        custom = time.parse("01/03/2026", "01/02/2006"),
+        duration = time.format_duration(5400),
+        parse_err = parse_err,
+        duration_err = duration_err
+    }
+end
+`
+	if err := vm.LoadString(script); err != nil {
+		t.Fatalf("LoadString failed: %v", err)
+	}
+	output, err := vm.CallProcess(map[string]interface{}{"at": "2026-03-01T10:00:00+02:00"})
+	if err != nil {
+		t.Fatalf("CallProcess failed: %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"now":      "2026-03-01T12:00:00Z",
+		"later":    "2026-03-01T09:30:00Z",
+		"day":      "2026-03-01",
+		"custom":   float64(time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC).Unix()),
+		"duration": "1h30m0s",
+	}
+	for k, want := range expected {
+		if output[k] != want {
+			t.Errorf("%s: expected %v, got %v", k, want, output[k])
+		}
+	}
+	if output["parse_err"] == nil || output["duration_err"] == nil {
+		t.Errorf("expected parse errors, got %v and %v", output["parse_err"], output["duration_err"])
+	}
+}
+
+func TestLuaStdlibCrypto(t *testing.T) {
+	vm := NewLuaVM()
+	defer vm.Close()
+
+	script := `
+function process(input)
+    local _, decode_err = crypto.base64_decode("not base64!")
+    return {
+        sha = crypto.sha256("abc"),
+        mac = crypto.hmac("key", "The quick brown fox jumps over the lazy dog"),
+        encoded = crypto.base64_encode("hello forest"),
+        decoded = crypto.base64_decode("aGVsbG8gZm9yZXN0"),
+        decode_err = decode_err,
+        uuid = crypto.uuid(),
+        other = crypto.uuid()
+    }
+end
+`
+	if err := vm.LoadString(script); err != nil {
+		t.Fatalf("LoadString failed: %v", err)
+	}
+	output, err := vm.CallProcess(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("CallProcess failed: %v", err)
+	}
+
+	if output["sha"] != "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" {
+		t.Errorf("unexpected sha256: %v", output["sha"])
+	}
+	if output["mac"] != "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8" {
+		t.Errorf("unexpected hmac: %v", output["mac"])
+	}
+	if output["encoded"] != "aGVsbG8gZm9yZXN0" || output["decoded"] != "hello forest" {
+		t.Errorf("unexpected base64: %v / %v", output["encoded"], output["decoded"])
+	}
+	if output["decode_err"] == nil {
+		t.Error("expected error decoding invalid base64")
+	}
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	if s, _ := output["uuid"].(string); !uuid.MatchString(s) {
+		t.Errorf("expected a v4 uuid, got %v", output["uuid"])
+	}
+	if output["uuid"] == output["other"] {
+		t.Error("expected uuids to differ")
+	}
+}
+
+func TestLuaStdlibStrings(t *testing.T) {
+	vm := NewLuaVM()
+	defer vm.Close()
+
+	script := `
+function process(input)
+    local parts = strings.split(input.tags, ",")
+    local m = strings.match(input.ref, "^INV-(\\d+)-(\\w+)$")
+    local _, pattern_err = strings.test("x", "(")
+    return {
+        count = #parts,
+        second = strings.trim(parts[2]),
+        cut = strings.trim("--id--", "-"),
+        is_invoice = strings.test(input.ref, "^INV-"),
+        number = m[2],
+        region = m[3],
+        no_match = strings.match("abc", "\\d+") == nil,
+        masked = strings.replace("call 555-1234 or 555-9876", "(\\d{3})-\\d{4}", "${1}-XXXX"),
+        pattern_err = pattern_err
+    }
+end
+`
+	if err := vm.LoadString(script); err != nil {
+		t.Fatalf("LoadString failed: %v", err)
+	}
+	output, err := vm.CallProcess(map[string]interface{}{"tags": "vip, churn-risk ,eu", "ref": "INV-2041-eu"})
+	if err != nil {
+		t.Fatalf("CallProcess failed: %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"count":      float64(3),
+		"second":     "churn-risk",
+		"cut":        "id",
+		"is_invoice": true,
+		"number":     "2041",
+		"region":     "eu",
+		"no_match":   true,
+		"masked":     "call 555-XXXX or 555-XXXX",
+	}
+	for k, want := range expected {
+		if output[k] != want {
+			t.Errorf("%s: expected %v, got %v", k, want, output[k])
+		}
+	}
+	if output["pattern_err"] == nil {
+		t.Error("expected error for invalid pattern")
+	}
+
+	// Scripts building patterns from input don't grow the cache without bound
+	first, _ := vm.regexp("^first$")
+	for i := 0; i < 3*maxCachedPatterns; i++ {
+		if _, err := vm.regexp(fmt.Sprintf("^id-%d$", i)); err != nil {
+			t.Fatalf("regexp failed: %v", err)
+		}
+	}
+	if n := len(vm.patterns.items); n != maxCachedPatterns || vm.patterns.order.Len() != n {
+		t.Errorf("expected %d cached patterns, got %d", maxCachedPatterns, n)
+	}
+	if again, _ := vm.regexp("^first$"); again == first {
+		t.Error("expected the least recently used pattern to be evicted")
+	}
+}
+
+func TestLuaStdlibTables(t *testing.T) {
+	vm := NewLuaVM()
+	defer vm.Close()
+
+	script := `
+function process(input)
+    local doubled = tables.map(input.scores, function(v) return v * 2 end)
+    local high = tables.filter(input.scores, function(v) return v > 50 end)
+    local flags = tables.map({a = 1, b = 2}, function(v, k) return k .. v end)
+    local active = tables.filter(input.users, function(u) return u.active end)
+
+    local copy = tables.deepcopy(input)
+    copy.contact.name = "changed"
+    table.insert(copy.scores, 1)
+
+    return {
+        doubled = doubled,
+        high = high,
+        flags = flags,
+        active = active,
+        original_name = input.contact.name,
+        original_count = #input.scores,
+        copy_name = copy.contact.name
+    }
+end
+`
+	if err := vm.LoadString(script); err != nil {
+		t.Fatalf("LoadString failed: %v", err)
+	}
+	input := map[string]interface{}{
+		"scores":  []interface{}{float64(10), float64(60), float64(90)},
+		"contact": map[string]interface{}{"name": "Ada"},
+		"users": map[string]interface{}{
+			"ada": map[string]interface{}{"active": true},
+			"bob": map[string]interface{}{"active": false},
+		},
+	}
+	output, err := vm.CallProcess(input)
+	if err != nil {
+		t.Fatalf("CallProcess failed: %v", err)
+	}
+
+	if got, _ := json.Marshal(output["doubled"]); string(got) != "[20,120,180]" {
+		t.Errorf("unexpected map result: %s", got)
+	}
+	if got, _ := json.Marshal(output["high"]); string(got) != "[60,90]" {
+		t.Errorf("unexpected filter result: %s", got)
+	}
+	if got, _ := json.Marshal(output["flags"]); string(got) != `{"a":"a1","b":"b2"}` {
+		t.Errorf("unexpected map over keys: %s", got)
+	}
+	if got, _ := json.Marshal(output["active"]); string(got) != `{"ada":{"active":true}}` {
+		t.Errorf("unexpected filter over keys: %s", got)
+	}
+	if output["original_name"] != "Ada" || output["original_count"] != float64(3) || output["copy_name"] != "changed" {
+		t.Errorf("expected deepcopy to leave the original alone, got %v", output)
+	}
+}
+
+func TestLuaStdlibDocsUpToDate(t *testing.T) {
+	docs, err := os.ReadFile("../../docs/guides/LUA_STDLIB.md")
+	if err != nil {
+		t.Fatalf("Failed to read docs: %v", err)
+	}
+	if string(docs) != LuaStdlibDocs() {
+		t.Error("docs/guides/LUA_STDLIB.md is out of date, run make lua-docs")
+	}
+	for _, m := range luaStdlib {
+		for _, f := range m.Funcs {
+			if !strings.Contains(string(docs), "`"+f.Usage+"`") {
+				t.Errorf("%s.%s is not documented", m.Name, f.Name)
+			}
+		}
+	}
+}
+
+func TestBeatClock(t *testing.T) {
+	_, wind, cleanup := setupTestForest(t)
+	defer cleanup()
+
+	clock := NewBeatClock(wind)
+	if err := clock.Start(); err != nil {
+		t.Fatalf("Failed to start clock: %v", err)
+	}
+	defer clock.Stop()
+
+	if d := time.Since(clock.Now()); d < 0 || d > time.Second {
+		t.Errorf("expected the wall clock before any beat, got %v ago", d)
+	}
+
+	beat := time.Now().Add(-200 * time.Millisecond).Truncate(time.Millisecond)
+	data, _ := json.Marshal(windwaker.Beat{Seq: 1, Ts: beat.UnixNano(), Hz: 90})
+	wind.Drop(*core.NewLeaf(windwaker.SubjectDanceBeat, data, "windwaker"))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !clock.Now().Equal(beat) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the beat time %v, got %v", beat, clock.Now())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLuaSandboxLibraries(t *testing.T) {
+	vm := NewLuaVM()
+	defer vm.Close()
+
+	script := `
+function process(input)
+    return {
+        io = io == nil,
+        exec = os.execute == nil,
+        getenv = os.getenv == nil,
+        dofile = dofile == nil,
+        loadfile = loadfile == nil,
+        loadstring = loadstring == nil,
+        require = require == nil,
+        debug = debug == nil,
+        date = os.date("!%Y", 0),
+        upper = string.upper("ok"),
+        floor = math.floor(2.5),
+        joined = table.concat({"a", "b"}, ",")
+    }
+end
+`
+	if err := vm.LoadString(script); err != nil {
+		t.Fatalf("LoadString failed: %v", err)
+	}
+	output, err := vm.CallProcess(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("CallProcess failed: %v", err)
+	}
+	for _, unsafe := range []string{"io", "exec", "getenv", "dofile", "loadfile", "loadstring", "require", "debug"} {
+		if output[unsafe] != true {
+			t.Errorf("expected %s not to be available", unsafe)
+		}
+	}
+	if output["date"] != "1970" || output["upper"] != "OK" || output["floor"] != float64(2) || output["joined"] != "a,b" {
+		t.Errorf("expected safe libraries to work, got %v", output)
+	}
+}
+
+func TestLuaSandboxLimits(t *testing.T) {
+	vm := NewLuaVMWithLimits(LuaLimits{Timeout: 500 * time.Millisecond, CallStackSize: 50, MemoryBytes: 16 << 20})
+	defer vm.Close()
+
+	script := `
+function recurse(n) return recurse(n + 1) + 1 end
+
+function process(input)
+    if input.mode == "loop" then while true do end end
+    if input.mode == "recurse" then recurse(1) end
+    if input.mode == "unpack" then return {n = select("#", unpack({}, 1, 1e8))} end
+    if input.mode == "fail" then error("boom") end
+    if input.mode == "fake" then error("stack overflow") end
+    return {ok = true}
+end
+`
+	if err := vm.LoadString(script); err != nil {
+		t.Fatalf("LoadString failed: %v", err)
+	}
+
+	cases := []struct {
+		mode string
+		want error
+	}{
+		{"loop", ErrScriptTimeout},
+		{"recurse", ErrScriptStackOverflow},
+		{"unpack", ErrScriptStackOverflow},
+	}
+	for _, tc := range cases {
+		start := time.Now()
+		_, err := vm.CallProcess(map[string]interface{}{"mode": tc.mode})
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.mode, tc.want, err)
+		}
+		if time.Since(start) > 2*time.Second {
+			t.Errorf("%s: took %v to stop", tc.mode, time.Since(start))
+		}
+	}
+	if _, err := vm.CallProcess(map[string]interface{}{"mode": "fail"}); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("expected script error, got %v", err)
+	}
+	// Overflows are told apart by the VM's state, not the message
+	if _, err := vm.CallProcess(map[string]interface{}{"mode": "fake"}); err == nil || errors.Is(err, ErrScriptStackOverflow) {
+		t.Errorf("expected a plain script error, got %v", err)
+	}
+
+	// The VM stays usable after a violation
+	output, err := vm.CallProcess(map[string]interface{}{})
+	if err != nil || output["ok"] != true {
+		t.Fatalf("expected VM to recover, got %v, %v", output, err)
+	}
+
+	stats := vm.Stats()
+	if stats.Timeouts != 1 || stats.StackOverflows != 2 || stats.MemoryExceeded != 0 || stats.Errors != 2 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	// Top-level code runs within the limits too
+	if err := vm.LoadString("while true do end"); !errors.Is(err, ErrScriptTimeout) {
+		t.Errorf("expected timeout loading a looping script, got %v", err)
+	}
+}
+
+func TestLuaSandboxMemory(t *testing.T) {
+	// The memory budget counts library allocations, not time: a limit this
+	// long never decides a memory case, however slow the machine.
+	vm := NewLuaVMWithLimits(LuaLimits{Timeout: time.Minute, MemoryBytes: 16 << 20})
+	defer vm.Close()
+
+	script := `
+function grow()
+    local t = {}
+    local i = 0
+    while true do
+        i = i + 1
+        t[i] = string.rep("x", 4096) .. i
+    end
+end
+function joins()
+    local parts = {}
+    for i = 1, 1024 do parts[i] = string.rep("x", 1024) end
+    local all = {}
+    while true do all[#all + 1] = table.concat(parts) end
+end
+function cogrow()
+    local co = coroutine.create(grow)
+    return coroutine.resume(co)
+end
+
+function process(input)
+    if input.mode == "grow" then grow() end
+    if input.mode == "joins" then joins() end
+    if input.mode == "cogrow" then local ok, err = cogrow() error(err) end
+    if input.mode == "rep" then return {s = string.rep("x", 1e9)} end
+    return {ok = true}
+end
+`
+	if err := vm.LoadString(script); err != nil {
+		t.Fatalf("LoadString failed: %v", err)
+	}
+	for _, mode := range []string{"grow", "joins", "cogrow", "rep"} {
+		if _, err := vm.CallProcess(map[string]interface{}{"mode": mode}); !errors.Is(err, ErrScriptMemory) {
+			t.Errorf("%s: expected %v, got %v", mode, ErrScriptMemory, err)
+		}
+	}
+	if stats := vm.Stats(); stats.MemoryExceeded != 4 || stats.Timeouts != 0 || stats.Errors != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	// Allocations are counted per call: staying under the limit twice is fine
+	if err := vm.LoadString(`function process(input)
+    local t = {}
+    for i = 1, 3000 do t[i] = string.rep("x", 4096) end
+    return {n = #t}
+end`); err != nil {
+		t.Fatalf("LoadString failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if output, err := vm.CallProcess(map[string]interface{}{}); err != nil || output["n"] != float64(3000) {
+			t.Fatalf("expected 12 MB within a 16 MB limit to pass, got %v, %v", output, err)
+		}
+	}
+}
+
+func TestLuaSandboxMemoryHeldOutsideVM(t *testing.T) {
+	vm := NewLuaVMWithLimits(LuaLimits{Timeout: 2 * time.Second, MemoryBytes: 16 << 20})
+	defer vm.Close()
+
+	// A host call that blocks while the process grows its heap well past
+	// the script's limit, like a slow soil read under load.
+	var held [][]byte
+	vm.state.SetGlobal("slow_host_call", vm.state.NewFunction(func(L *lua.LState) int {
+		for i := 0; i < 8; i++ {
+			buf := make([]byte, 8<<20)
+			buf[len(buf)-1] = 1
+			held = append(held, buf)
+		}
+		time.Sleep(50 * time.Millisecond)
+		return 0
+	}))
+	if err := vm.LoadString(`function process(input)
+    slow_host_call()
+    local n = 0
+    for i = 1, 100000 do n = n + i end
+    return {n = n}
+end`); err != nil {
+		t.Fatalf("LoadString failed: %v", err)
+	}
+
+	output, err := vm.CallProcess(map[string]interface{}{})
+	if errors.Is(err, ErrScriptMemory) {
+		t.Fatalf("expected memory held outside the VM not to count, got %v", err)
+	}
+	if err != nil || output["n"] != float64(5000050000) {
+		t.Fatalf("expected the script to finish, got %v, %v", output, err)
+	}
+	if len(held) != 8 || vm.Stats().MemoryExceeded != 0 {
+		t.Errorf("unexpected stats: %+v", vm.Stats())
+	}
+}
+
+func TestLuaPool(t *testing.T) {
+	script := t.TempDir() + "/echo.lua"
+	os.WriteFile(script, []byte(`function process(input) return input end`), 0644)
+
+	pool, err := NewLuaPool(3, script, DefaultLuaLimits())
+	if err != nil {
+		t.Fatalf("NewLuaPool failed: %v", err)
+	}
+
+	// Every VM is handed out at once; a fourth call waits for one to return.
+	started := make(chan *LuaVM, 4)
+	release := make(chan struct{})
+	for i := 0; i < 3; i++ {
+		pool.Go(func(vm *LuaVM) {
+			started <- vm
+			<-release
+		})
+	}
+	seen := map[*LuaVM]bool{}
+	for i := 0; i < 3; i++ {
+		seen[<-started] = true
+	}
+	if len(seen) != 3 {
+		t.Fatalf("expected 3 distinct VMs in use, got %d", len(seen))
+	}
+
+	fourth := make(chan struct{})
+	go pool.Go(func(vm *LuaVM) { close(fourth) })
+	select {
+	case <-fourth:
+		t.Fatal("expected the fourth call to wait for an idle VM")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-fourth
+
+	// Each waits for running calls and holds back new ones while it runs
+	running, finish := make(chan struct{}), make(chan struct{})
+	pool.Go(func(vm *LuaVM) {
+		close(running)
+		<-finish
+	})
+	<-running
+	configured := make(chan int)
+	go func() {
+		n := 0
+		pool.Each(func(vm *LuaVM) { n++ })
+		configured <- n
+	}()
+	select {
+	case <-configured:
+		t.Fatal("expected Each to wait for the running call")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(finish)
+	if n := <-configured; n != 3 {
+		t.Errorf("expected Each to visit 3 VMs, got %d", n)
+	}
+
+	pool.Close()
+	if pool.Each(func(vm *LuaVM) {}) {
+		t.Error("expected Each to do nothing after Close")
+	}
+	ran := false
+	pool.Do(func(vm *LuaVM) { ran = true })
+	if ran {
+		t.Error("expected no calls after Close")
+	}
+}
+
+func TestTreeHousePoolAndOrdered(t *testing.T) {
+	_, wind, cleanup := setupTestForest(t)
+	defer cleanup()
+
+	dir := t.TempDir()
+	os.WriteFile(dir+"/count.lua", []byte(`
+local seen = 0
+function process(input)
+  seen = seen + 1
+  return {n = input.n, seen = seen}
+end
+`), 0644)
+
+	collect := func(subject string) chan map[string]any {
+		ch := make(chan map[string]any, 100)
+		wind.Catch(subject, func(leaf core.Leaf) {
+			var out map[string]any
+			json.Unmarshal(leaf.Data, &out)
+			ch <- out
+		})
+		return ch
+	}
+	pooled := collect("pooled.out")
+	ordered := collect("ordered.out")
+
+	// Two instances of the same pooled treehouse share leaves through the
+	// queue group, as two forests would.
+	for i := 0; i < 2; i++ {
+		th, err := NewTreeHouse(TreeHouseConfig{
+			Name: "pooled", Subscribes: "count.in", Publishes: "pooled.out", Script: "count.lua", Pool: 2,
+		}, wind, dir+"/count.lua")
+		if err != nil {
+			t.Fatalf("NewTreeHouse failed: %v", err)
+		}
+		th.Start(context.Background())
+		defer th.Stop()
+	}
+	th, err := NewTreeHouse(TreeHouseConfig{
+		Name: "ordered", Subscribes: "count.in", Publishes: "ordered.out", Script: "count.lua", Ordered: true,
+	}, wind, dir+"/count.lua")
+	if err != nil {
+		t.Fatalf("NewTreeHouse failed: %v", err)
+	}
+	th.Start(context.Background())
+	defer th.Stop()
+	time.Sleep(50 * time.Millisecond)
+
+	const leaves = 20
+	for i := 1; i <= leaves; i++ {
+		data, _ := json.Marshal(map[string]int{"n": i})
+		wind.Drop(*core.NewLeaf("count.in", data, "test"))
+	}
+
+	for i := 1; i <= leaves; i++ {
+		select {
+		case out := <-ordered:
+			if out["n"] != float64(i) || out["seen"] != float64(i) {
+				t.Fatalf("expected leaf %d in order, got %v", i, out)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for ordered leaf %d", i)
+		}
+	}
+
+	got := map[float64]bool{}
+	for i := 0; i < leaves; i++ {
+		select {
+		case out := <-pooled:
+			if got[out["n"].(float64)] {
+				t.Fatalf("leaf %v processed twice", out["n"])
+			}
+			got[out["n"].(float64)] = true
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out after %d pooled leaves", i)
+		}
+	}
+	select {
+	case out := <-pooled:
+		t.Errorf("unexpected extra output %v", out)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestTreeHouseOutputs(t *testing.T) {
+	_, wind, cleanup := setupTestForest(t)
+	defer cleanup()
+
+	dir := t.TempDir()
+	os.WriteFile(dir+"/route.lua", []byte(`
+function process(input)
+  if input.kind == "drop" then return nil end
+  if input.kind == "single" then return {region = input.region, n = input.n} end
+  return {
+    {data = {region = input.region, n = input.n}},
+    {subject = "alerts.{level}", data = {level = "high", n = input.n}},
+    {subject = "audit.raw", data = {n = input.n}},
+  }
+end
+`), 0644)
+
+	out := make(chan string, 10)
+	wind.Catch(">", func(leaf core.Leaf) {
+		if leaf.Source == "treehouse:router" {
+			out <- leaf.Subject
+		}
+	})
+
+	th, err := NewTreeHouse(TreeHouseConfig{
+		Name: "router", Subscribes: "route.in", Publishes: "orders.{region}", Emits: []string{"alerts.*"}, Script: "route.lua",
+	}, wind, dir+"/route.lua")
+	if err != nil {
+		t.Fatalf("NewTreeHouse failed: %v", err)
+	}
+	th.Start(context.Background())
+	defer th.Stop()
+	time.Sleep(50 * time.Millisecond)
+
+	expect := func(kind string, subjects ...string) {
+		t.Helper()
+		data, _ := json.Marshal(map[string]any{"kind": kind, "region": "eu", "n": 1})
+		wind.Drop(*core.NewLeaf("route.in", data, "test"))
+		got := map[string]bool{}
+		for range subjects {
+			select {
+			case subject := <-out:
+				got[subject] = true
+			case <-time.After(2 * time.Second):
+				t.Fatalf("%s: timed out, got %v", kind, got)
+			}
+		}
+		for _, subject := range subjects {
+			if !got[subject] {
+				t.Errorf("%s: expected a leaf on %s, got %v", kind, subject, got)
+			}
+		}
+		select {
+		case subject := <-out:
+			t.Errorf("%s: unexpected leaf on %s", kind, subject)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	expect("single", "orders.eu")
+	expect("multi", "orders.eu", "alerts.high") // audit.raw is not allowed
+	expect("drop")
+}
+
+func TestSubjectMatches(t *testing.T) {
+	tests := []struct {
+		pattern, subject string
+		want             bool
+	}{
+		{"orders.eu", "orders.eu", true},
+		{"orders.*", "orders.eu", true},
+		{"orders.*", "orders.eu.west", false},
+		{"orders.>", "orders.eu.west", true},
+		{"orders.>", "orders", false},
+		{subjectPattern("orders.{region}.new"), "orders.eu.new", true},
+		{subjectPattern("orders.{region}.new"), "orders.eu.old", false},
+	}
+	for _, tt := range tests {
+		if got := subjectMatches(tt.pattern, tt.subject); got != tt.want {
+			t.Errorf("subjectMatches(%q, %q) = %v, want %v", tt.pattern, tt.subject, got, tt.want)
+		}
+	}
+}
+
+func TestTreeHouseState(t *testing.T) {
+	forest, wind, cleanup := setupTestForest(t)
+	defer cleanup()
+	forest.SetJetStream(setupTestJS(t))
+
+	os.WriteFile(filepath.Join(forest.config.BaseDir, "failures.lua"), []byte(`
+function process(payment)
+  local total = state.incr("failures." .. payment.customer)
+  local hour = state.tumbling("amount." .. payment.customer, "1h", payment.amount)
+  local recent = state.sliding("recent." .. payment.customer, 600, 60, payment.amount)
+  state.set("last." .. payment.customer, {amount = payment.amount}, "1h")
+  return {total = total, hour_sum = hour.sum, recent_count = recent.count, last = state.get("last." .. payment.customer).amount}
+end
+`), 0644)
+
+	cfg := TreeHouseConfig{
+		Subscribes: "payment.failed", Publishes: "payment.failures", Script: "failures.lua",
+		Ordered: true, State: &ScriptStateConfig{TTL: "24h"},
+	}
+	if err := forest.AddTreeHouse("failures", cfg); err != nil {
+		t.Fatalf("AddTreeHouse failed: %v", err)
+	}
+
+	out := make(chan map[string]float64, 10)
+	wind.Catch("payment.failures", func(leaf core.Leaf) {
+		var data map[string]float64
+		json.Unmarshal(leaf.Data, &data)
+		out <- data
+	})
+	time.Sleep(50 * time.Millisecond)
+
+	expect := func(amount, total, hourSum float64) {
+		t.Helper()
+		data, _ := json.Marshal(map[string]any{"customer": "acme", "amount": amount})
+		wind.Drop(*core.NewLeaf("payment.failed", data, "test"))
+		select {
+		case got := <-out:
+			if got["total"] != total || got["hour_sum"] != hourSum || got["last"] != amount || got["recent_count"] == 0 {
+				t.Errorf("expected total %v and hour sum %v, got %v", total, hourSum, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for total %v", total)
+		}
+	}
+	expect(10, 1, 10)
+	expect(5, 2, 15)
+
+	// State survives recreating the treehouse
+	forest.RemoveTreeHouse("failures")
+	if err := forest.AddTreeHouse("failures", cfg); err != nil {
+		t.Fatalf("AddTreeHouse failed: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	expect(1, 3, 16)
+}
@@ -1,15 +1,15 @@
 package runtime
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"regexp"
 	"sync"
+	"sync/atomic"
 	"text/template"
+	"time"
 
 	"github.com/nats-io/nats.go"
 	"github.com/yourusername/nimsforest/internal/core"
@@ -25,7 +25,10 @@ type Nim struct {
 	wind     *core.Wind
 	humus    *core.Humus // Optional: for recording state changes
 	brain    brain.Brain
-	template *template.Template
+	template atomic.Pointer[template.Template] // Swapped by ReloadPrompt
+	partials string                            // Directory of shared templates, if any
+	soil     *core.Soil                        // For {{soil "key"}}
+	clock    func() time.Time                  // For {{now}}; the wall clock if nil
 	sub      *nats.Subscription
 
 	mu      sync.Mutex
@@ -34,6 +37,12 @@ type Nim struct {
 
 // NewNim creates a new Nim instance using Wind for pub/sub.
 func NewNim(cfg NimConfig, wind *core.Wind, b brain.Brain, promptPath string) (*Nim, error) {
+	return NewNimWithPartials(cfg, wind, b, promptPath, "")
+}
+
+// NewNimWithPartials creates a Nim whose prompt can include the templates
+// in the partials directory.
+func NewNimWithPartials(cfg NimConfig, wind *core.Wind, b brain.Brain, promptPath, partials string) (*Nim, error) {
 	if wind == nil {
 		return nil, fmt.Errorf("wind is required")
 	}
@@ -41,23 +50,32 @@ func NewNim(cfg NimConfig, wind *core.Wind, b brain.Brain, promptPath string) (*
 		return nil, fmt.Errorf("brain is required")
 	}
 
+	nim := &Nim{
+		config:   cfg,
+		wind:     wind,
+		brain:    b,
+		partials: partials,
+	}
+
 	// Load prompt template
-	tmplData, err := os.ReadFile(promptPath)
+	tmpl, err := nim.loadPrompt(promptPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read prompt %s: %w", promptPath, err)
+		return nil, err
 	}
+	nim.template.Store(tmpl)
+	return nim, nil
+}
 
-	tmpl, err := template.New(cfg.Name).Parse(string(tmplData))
+// ReloadPrompt parses the prompt at promptPath and swaps it in for the
+// following leaves. If it fails to parse, the nim keeps the old prompt.
+func (n *Nim) ReloadPrompt(promptPath string) error {
+	tmpl, err := n.loadPrompt(promptPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse prompt template: %w", err)
+		return err
 	}
-
-	return &Nim{
-		config:   cfg,
-		wind:     wind,
-		brain:    b,
-		template: tmpl,
-	}, nil
+	n.template.Store(tmpl)
+	log.Printf("[Nim:%s] Reloaded %s", n.config.Name, promptPath)
+	return nil
 }
 
 // NewNimWithHumus creates a new Nim with Humus for state change recording.
@@ -70,6 +88,18 @@ func NewNimWithHumus(cfg NimConfig, wind *core.Wind, humus *core.Humus, b brain.
 	return nim, nil
 }
 
+// SetSoil lets the prompt read the soil keys in access.soil. Must be called
+// before Start.
+func (n *Nim) SetSoil(soil *core.Soil) {
+	n.soil = soil
+}
+
+// SetClock sets the clock the prompt's now reads. Must be called before
+// Start.
+func (n *Nim) SetClock(now func() time.Time) {
+	n.clock = now
+}
+
 // Start begins processing messages.
 func (n *Nim) Start(ctx context.Context) error {
 	n.mu.Lock()
@@ -128,7 +158,7 @@ func (n *Nim) handleLeaf(ctx context.Context, leaf core.Leaf) {
 		n.config.Name, n.config.Subscribes, leaf.Source)
 
 	// Process and get output
-	output, err := n.processInput(ctx, input)
+	output, err := n.processInput(ctx, leaf, input)
 	if err != nil {
 		log.Printf("[Nim:%s] Error processing: %v", n.config.Name, err)
 		return
@@ -177,13 +207,12 @@ func resolveDynamicSubject(subject string, data map[string]interface{}) string {
 }
 
 // processInput is the common processing logic.
-func (n *Nim) processInput(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+func (n *Nim) processInput(ctx context.Context, leaf core.Leaf, input map[string]interface{}) (map[string]interface{}, error) {
 	// Render prompt template
-	var buf bytes.Buffer
-	if err := n.template.Execute(&buf, input); err != nil {
-		return nil, fmt.Errorf("error rendering prompt: %w", err)
+	prompt, err := n.renderPrompt(leaf, input)
+	if err != nil {
+		return nil, err
 	}
-	prompt := buf.String()
 
 	// Call brain
 	response, err := n.brain.Ask(ctx, prompt)
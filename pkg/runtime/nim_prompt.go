@@ -0,0 +1,230 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/yourusername/nimsforest/internal/core"
+)
+
+// A nim's prompt is a text/template rendered with the leaf's decoded data,
+// plus .Leaf for the leaf itself:
+//
+//	Lead {{.contact_id}} from {{.Leaf.Source}} at {{.Leaf.Timestamp.Format "15:04"}}
+//	{{with soil (printf "contacts/%s" .contact_id)}}Known as: {{toYaml .}}{{end}}
+//	Notes: {{.notes | truncate 500 | default "none"}}
+//	{{template "reply.md" .}}
+//
+// Templates in the prompts.partials directory are available to every
+// prompt by file name.
+
+// promptLeaf is .Leaf in a prompt.
+type promptLeaf struct {
+	Subject   string
+	Source    string
+	Timestamp time.Time
+	Data      map[string]interface{} // The decoded payload, as the top-level fields
+}
+
+// promptPartialFiles lists the templates in a partials directory.
+func promptPartialFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read partials: %w", err)
+	}
+	var files []string
+	for _, e := range entries {
+		if e.Type().IsRegular() && !strings.HasPrefix(e.Name(), ".") {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	return files, nil
+}
+
+// loadPrompt reads and parses the nim's prompt template and the partials it
+// may include.
+func (n *Nim) loadPrompt(promptPath string) (*template.Template, error) {
+	tmplData, err := os.ReadFile(promptPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt %s: %w", promptPath, err)
+	}
+
+	tmpl := template.New(n.config.Name).Funcs(n.promptFuncs())
+	if n.partials != "" {
+		files, err := promptPartialFiles(n.partials)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range files {
+			name := filepath.Base(path)
+			if name == n.config.Name {
+				return nil, fmt.Errorf("partial %s has the nim's name", name)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read partial %s: %w", name, err)
+			}
+			if _, err := tmpl.New(name).Parse(string(data)); err != nil {
+				return nil, fmt.Errorf("failed to parse partial %s: %w", name, err)
+			}
+		}
+	}
+
+	if _, err := tmpl.Parse(string(tmplData)); err != nil {
+		return nil, fmt.Errorf("failed to parse prompt template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// promptLeafKey is the top-level key prompts read the leaf from. Leaf data
+// with a field of that name is refused rather than hidden by the leaf.
+const promptLeafKey = "Leaf"
+
+// renderPrompt executes the prompt for a leaf and its decoded data.
+func (n *Nim) renderPrompt(leaf core.Leaf, input map[string]interface{}) (string, error) {
+	if _, ok := input[promptLeafKey]; ok {
+		return "", fmt.Errorf("leaf data has a top-level %q field, which prompts reserve for the leaf itself", promptLeafKey)
+	}
+	data := make(map[string]interface{}, len(input)+1)
+	for k, v := range input {
+		data[k] = v
+	}
+	data[promptLeafKey] = promptLeaf{Subject: leaf.Subject, Source: leaf.Source, Timestamp: leaf.Timestamp, Data: input}
+
+	var buf bytes.Buffer
+	if err := n.template.Load().Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("error rendering prompt: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// promptFuncs returns the functions prompts can call.
+func (n *Nim) promptFuncs() template.FuncMap {
+	return template.FuncMap{
+		"json":     promptJSON,
+		"toYaml":   promptYAML,
+		"truncate": promptTruncate,
+		"default":  promptDefault,
+		"join":     promptJoin,
+		"now":      n.now,
+		"soil":     n.soilGet,
+	}
+}
+
+// now returns the time of the latest WindWaker beat, like time.now() in Lua.
+func (n *Nim) now() time.Time {
+	if n.clock != nil {
+		return n.clock()
+	}
+	return time.Now()
+}
+
+// soilGet implements {{soil "key"}}: the entity decoded, or nil when it
+// doesn't exist. Keys outside access.soil fail the render.
+func (n *Nim) soilGet(key string) (interface{}, error) {
+	if !keyAllowed(n.config.Access.Soil, key) {
+		return nil, fmt.Errorf("access denied: cannot read %s", key)
+	}
+	if n.soil == nil {
+		return nil, fmt.Errorf("soil not available")
+	}
+	data, _, err := n.soil.Dig(key)
+	if errors.Is(err, core.ErrEntityNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", key, err)
+	}
+	return value, nil
+}
+
+// promptJSON implements {{json .}}
+func promptJSON(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// promptYAML implements {{toYaml .}}
+func promptYAML(v interface{}) (string, error) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+// promptTruncate implements {{truncate 100 .notes}}, cutting to n
+// characters and marking the cut with an ellipsis.
+func promptTruncate(n int, v interface{}) string {
+	s := promptString(v)
+	runes := []rune(s)
+	if n < 0 || len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "…"
+}
+
+// promptDefault implements {{default "none" .notes}}: def when v is
+// missing, false, zero or empty.
+func promptDefault(def, v interface{}) interface{} {
+	if v == nil {
+		return def
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map, reflect.String:
+		if rv.Len() == 0 {
+			return def
+		}
+	default:
+		if rv.IsZero() {
+			return def
+		}
+	}
+	return v
+}
+
+// promptJoin implements {{join ", " .tags}}
+func promptJoin(sep string, v interface{}) (string, error) {
+	if v == nil {
+		return "", nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return "", fmt.Errorf("join: expected a list, got %T", v)
+	}
+	parts := make([]string, rv.Len())
+	for i := range parts {
+		parts[i] = promptString(rv.Index(i).Interface())
+	}
+	return strings.Join(parts, sep), nil
+}
+
+// promptString formats a value for text, with nil as empty.
+func promptString(v interface{}) string {
+	switch s := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return s
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,125 @@
+package runtime
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/nimsforest/internal/core"
+)
+
+func TestNimPrompt(t *testing.T) {
+	_, wind, cleanup := setupTestForest(t)
+	defer cleanup()
+
+	soil, err := core.NewSoil(setupTestJS(t))
+	if err != nil {
+		t.Fatalf("Failed to create soil: %v", err)
+	}
+	soil.Put("contacts/c1", []byte(`{"name": "Ada", "tier": "gold"}`))
+
+	dir := t.TempDir()
+	partials := filepath.Join(dir, "partials")
+	os.Mkdir(partials, 0755)
+	os.WriteFile(filepath.Join(partials, "reply.md"), []byte(`Reply with JSON for {{.contact_id}}.`), 0644)
+
+	prompt := filepath.Join(dir, "qualify.md")
+	os.WriteFile(prompt, []byte(strings.Join([]string{
+		`{{.Leaf.Subject}} from {{.Leaf.Source}} at {{.Leaf.Timestamp.Format "15:04"}}, rendered {{now.Year}}`,
+		`{{with soil (printf "contacts/%s" .contact_id)}}Known: {{json .}}{{end}}`,
+		`{{with soil "contacts/c9"}}unexpected{{else}}Unknown c9{{end}}`,
+		`Tags: {{join ", " .tags}}`,
+		`Notes: {{.notes | truncate 5}} / {{.missing | default "none"}}`,
+		`{{toYaml .Leaf.Data.deal}}`,
+		`{{template "reply.md" .}}`,
+	}, "\n")), 0644)
+
+	nim, err := NewNimWithPartials(NimConfig{Name: "qualify", Access: ScriptAccess{Soil: []string{"contacts/"}}}, wind, &mockBrain{}, prompt, partials)
+	if err != nil {
+		t.Fatalf("NewNimWithPartials failed: %v", err)
+	}
+	nim.SetSoil(soil)
+	nim.SetClock(func() time.Time { return time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC) })
+
+	leaf := core.Leaf{Subject: "lead.scored", Source: "treehouse:scoring", Timestamp: time.Date(2026, 5, 1, 9, 30, 0, 0, time.UTC)}
+	input := map[string]interface{}{
+		"contact_id": "c1",
+		"tags":       []interface{}{"b2b", 3.0},
+		"notes":      "Très long notes",
+		"deal":       map[string]interface{}{"size": 5000.0},
+	}
+	got, err := nim.renderPrompt(leaf, input)
+	if err != nil {
+		t.Fatalf("renderPrompt failed: %v", err)
+	}
+	want := strings.Join([]string{
+		`lead.scored from treehouse:scoring at 09:30, rendered 2030`,
+		`Known: {"name":"Ada","tier":"gold"}`,
+		`Unknown c9`,
+		`Tags: b2b, 3`,
+		`Notes: Très … / none`,
+		`size: 5000`,
+		`Reply with JSON for c1.`,
+	}, "\n")
+	if got != want {
+		t.Errorf("unexpected prompt:\n%s\nwant:\n%s", got, want)
+	}
+	if _, ok := input["Leaf"]; ok {
+		t.Error("expected the input to be left as is")
+	}
+
+	// A data field named Leaf would be hidden by the leaf, so it is refused
+	clash := map[string]interface{}{"contact_id": "c1", "Leaf": "payload"}
+	if _, err := nim.renderPrompt(leaf, clash); err == nil || !strings.Contains(err.Error(), `"Leaf" field`) {
+		t.Errorf("expected the Leaf field to be refused, got %v", err)
+	}
+
+	// Keys outside access.soil fail the render
+	os.WriteFile(prompt, []byte(`{{soil "secrets/key"}}`), 0644)
+	if err := nim.ReloadPrompt(prompt); err != nil {
+		t.Fatalf("ReloadPrompt failed: %v", err)
+	}
+	if _, err := nim.renderPrompt(leaf, input); err == nil || !strings.Contains(err.Error(), "access denied") {
+		t.Errorf("expected access denied, got %v", err)
+	}
+
+	// Partials are read again on reload
+	os.WriteFile(prompt, []byte(`{{template "reply.md" .}}`), 0644)
+	os.WriteFile(filepath.Join(partials, "reply.md"), []byte(`Answer for {{.contact_id}}.`), 0644)
+	if err := nim.ReloadPrompt(prompt); err != nil {
+		t.Fatalf("ReloadPrompt failed: %v", err)
+	}
+	if got, _ := nim.renderPrompt(leaf, input); got != "Answer for c1." {
+		t.Errorf("expected the new partial, got %q", got)
+	}
+
+	os.WriteFile(filepath.Join(partials, "bad.md"), []byte(`{{.x`), 0644)
+	if err := nim.ReloadPrompt(prompt); err == nil || !strings.Contains(err.Error(), "partial bad.md") {
+		t.Errorf("expected a partial parse error, got %v", err)
+	}
+}
+
+func TestPromptFuncs(t *testing.T) {
+	if got := promptTruncate(10, "short"); got != "short" {
+		t.Errorf("truncate: got %q", got)
+	}
+	if got := promptTruncate(3, nil); got != "" {
+		t.Errorf("truncate nil: got %q", got)
+	}
+	for _, empty := range []interface{}{nil, "", 0.0, false, []interface{}{}, map[string]interface{}{}} {
+		if got := promptDefault("d", empty); got != "d" {
+			t.Errorf("default(%#v): got %v", empty, got)
+		}
+	}
+	if got := promptDefault("d", 2.0); got != 2.0 {
+		t.Errorf("default: got %v", got)
+	}
+	if _, err := promptJoin(",", "text"); err == nil {
+		t.Error("join: expected an error for a string")
+	}
+	if got, _ := promptJoin(",", []string{"a", "b"}); got != "a,b" {
+		t.Errorf("join: got %q", got)
+	}
+}
@@ -0,0 +1,306 @@
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/yourusername/nimsforest/internal/core"
+	lua "github.com/yuin/gopher-lua"
+)
+
+var (
+	projectionFuncsMu sync.RWMutex
+	projectionFuncs   = make(map[string]core.ProjectionFunc)
+)
+
+// RegisterProjection registers a Go projection function under name,
+// so forest.yaml can refer to it with `func: <name>`.
+// It is typically called from an init function.
+func RegisterProjection(name string, fn core.ProjectionFunc) {
+	projectionFuncsMu.Lock()
+	defer projectionFuncsMu.Unlock()
+	projectionFuncs[name] = fn
+}
+
+// RegisteredProjections returns the names of all registered Go projections, sorted.
+func RegisteredProjections() []string {
+	projectionFuncsMu.RLock()
+	defer projectionFuncsMu.RUnlock()
+
+	names := make([]string, 0, len(projectionFuncs))
+	for name := range projectionFuncs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupProjection(name string) (core.ProjectionFunc, bool) {
+	projectionFuncsMu.RLock()
+	defer projectionFuncsMu.RUnlock()
+	fn, ok := projectionFuncs[name]
+	return fn, ok
+}
+
+// Projection is a runtime instance of a Projection configuration.
+// It runs a Lua script or registered Go function over Humus and keeps the
+// result in the projection's KV bucket.
+type Projection struct {
+	config     ProjectionConfig
+	projection *core.Projection
+	lua        *luaProjection // nil for Go projections
+}
+
+// NewProjection creates a new Projection instance.
+// scriptPath is only used for Lua projections.
+func NewProjection(cfg ProjectionConfig, humus *core.Humus, scriptPath string) (*Projection, error) {
+	if humus == nil {
+		return nil, fmt.Errorf("humus is required for projections")
+	}
+
+	p := &Projection{config: cfg}
+
+	var fn core.ProjectionFunc
+	if cfg.Func != "" {
+		registered, ok := lookupProjection(cfg.Func)
+		if !ok {
+			return nil, fmt.Errorf("projection func %q not registered", cfg.Func)
+		}
+		fn = registered
+	} else {
+		lp, err := newLuaProjection(scriptPath)
+		if err != nil {
+			return nil, err
+		}
+		p.lua = lp
+		fn = lp.project
+	}
+
+	filter := core.CompostFilter{
+		EntityType: cfg.Entities,
+		Nim:        cfg.Nim,
+		Action:     cfg.Action,
+	}
+
+	projection, err := core.NewProjection(humus, cfg.Name, filter, cfg.Bucket, fn)
+	if err != nil {
+		p.close()
+		return nil, err
+	}
+	p.projection = projection
+
+	return p, nil
+}
+
+// Start begins consuming Humus.
+func (p *Projection) Start() error {
+	return p.projection.Start()
+}
+
+// Stop stops consuming Humus and releases the Lua VM.
+func (p *Projection) Stop() error {
+	err := p.projection.Stop()
+	p.close()
+	return err
+}
+
+// pause stops consuming Humus but keeps the Lua VM, so Start can resume it.
+func (p *Projection) pause() error {
+	return p.projection.Stop()
+}
+
+// Rebuild discards the projection's view and replays Humus.
+func (p *Projection) Rebuild() error {
+	return p.projection.Rebuild()
+}
+
+// Status returns the projection's progress.
+func (p *Projection) Status() core.ProjectionStatus {
+	return p.projection.Status()
+}
+
+// Get returns the decoded value stored at key in the projection's view.
+func (p *Projection) Get(key string) (interface{}, bool, error) {
+	data, err := p.projection.View().Get(key)
+	if err != nil || data == nil {
+		return nil, false, err
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, false, fmt.Errorf("failed to decode view key %s: %w", key, err)
+	}
+	return value, true, nil
+}
+
+// Keys returns all keys in the projection's view.
+func (p *Projection) Keys() ([]string, error) {
+	return p.projection.View().Keys()
+}
+
+// Name returns the projection name.
+func (p *Projection) Name() string {
+	return p.config.Name
+}
+
+func (p *Projection) close() {
+	if p.lua != nil {
+		p.lua.vm.Close()
+		p.lua = nil
+	}
+}
+
+// luaProjection runs project(compost) from a Lua script.
+// The script updates the view through the global view module:
+//
+//	view.get(key)            -- decoded value or nil
+//	view.put(key, value)     -- store any JSON-encodable value
+//	view.delete(key)
+//	view.incr(key[, delta])  -- add to a counter, returns the new value
+//	view.add(key, member)    -- add to a set
+//	view.remove(key, member) -- remove from a set
+//	view.members(key)        -- set members as a list
+type luaProjection struct {
+	vm *LuaVM
+
+	mu   sync.Mutex
+	view *core.View // View for the compost being projected
+}
+
+func newLuaProjection(scriptPath string) (*luaProjection, error) {
+	lp := &luaProjection{vm: NewLuaVM()}
+	lp.registerView()
+
+	if err := lp.vm.LoadScript(scriptPath); err != nil {
+		lp.vm.Close()
+		return nil, fmt.Errorf("failed to load script %s: %w", scriptPath, err)
+	}
+	if lp.vm.state.GetGlobal("project") == lua.LNil {
+		lp.vm.Close()
+		return nil, fmt.Errorf("project function not defined in script %s", scriptPath)
+	}
+	return lp, nil
+}
+
+// project implements core.ProjectionFunc.
+func (lp *luaProjection) project(compost core.Compost, view *core.View) error {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+
+	lp.view = view
+	defer func() { lp.view = nil }()
+
+	L := lp.vm.state
+	input := map[string]interface{}{
+		"entity": compost.Entity,
+		"action": compost.Action,
+		"nim":    compost.NimName,
+		"slot":   float64(compost.Slot),
+		"ts":     compost.Timestamp.Unix(),
+	}
+	if len(compost.Data) > 0 {
+		var data interface{}
+		if err := json.Unmarshal(compost.Data, &data); err == nil {
+			input["data"] = data
+		}
+	}
+
+	if err := lp.vm.call(lua.P{
+		Fn:      L.GetGlobal("project"),
+		NRet:    0,
+		Protect: true,
+	}, goMapToTable(L, input)); err != nil {
+		return fmt.Errorf("project function error: %w", err)
+	}
+	return nil
+}
+
+// registerView registers the view module.
+func (lp *luaProjection) registerView() {
+	L := lp.vm.state
+	mod := L.NewTable()
+
+	L.SetField(mod, "get", L.NewFunction(func(L *lua.LState) int {
+		data, err := lp.currentView(L).Get(L.CheckString(1))
+		if err != nil {
+			L.RaiseError("%v", err)
+		}
+		if data == nil {
+			L.Push(lua.LNil)
+			return 1
+		}
+		var value interface{}
+		if err := json.Unmarshal(data, &value); err != nil {
+			L.RaiseError("%v", err)
+		}
+		L.Push(goValueToLua(L, value))
+		return 1
+	}))
+
+	L.SetField(mod, "put", L.NewFunction(func(L *lua.LState) int {
+		key := L.CheckString(1)
+		data, err := json.Marshal(luaValueToGo(L.CheckAny(2)))
+		if err != nil {
+			L.RaiseError("%v", err)
+		}
+		if err := lp.currentView(L).Put(key, data); err != nil {
+			L.RaiseError("%v", err)
+		}
+		return 0
+	}))
+
+	L.SetField(mod, "delete", L.NewFunction(func(L *lua.LState) int {
+		if err := lp.currentView(L).Delete(L.CheckString(1)); err != nil {
+			L.RaiseError("%v", err)
+		}
+		return 0
+	}))
+
+	L.SetField(mod, "incr", L.NewFunction(func(L *lua.LState) int {
+		value, err := lp.currentView(L).Incr(L.CheckString(1), float64(L.OptNumber(2, 1)))
+		if err != nil {
+			L.RaiseError("%v", err)
+		}
+		L.Push(lua.LNumber(value))
+		return 1
+	}))
+
+	L.SetField(mod, "add", L.NewFunction(func(L *lua.LState) int {
+		if err := lp.currentView(L).Add(L.CheckString(1), L.CheckString(2)); err != nil {
+			L.RaiseError("%v", err)
+		}
+		return 0
+	}))
+
+	L.SetField(mod, "remove", L.NewFunction(func(L *lua.LState) int {
+		if err := lp.currentView(L).Remove(L.CheckString(1), L.CheckString(2)); err != nil {
+			L.RaiseError("%v", err)
+		}
+		return 0
+	}))
+
+	L.SetField(mod, "members", L.NewFunction(func(L *lua.LState) int {
+		members, err := lp.currentView(L).Members(L.CheckString(1))
+		if err != nil {
+			L.RaiseError("%v", err)
+		}
+		tbl := L.NewTable()
+		for _, m := range members {
+			tbl.Append(lua.LString(m))
+		}
+		L.Push(tbl)
+		return 1
+	}))
+
+	L.SetGlobal("view", mod)
+}
+
+// currentView returns the view being projected, raising a Lua error when
+// the view module is used outside project().
+func (lp *luaProjection) currentView(L *lua.LState) *core.View {
+	if lp.view == nil {
+		L.RaiseError("view is only available inside project()")
+	}
+	return lp.view
+}
@@ -0,0 +1,174 @@
+package runtime
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/yourusername/nimsforest/internal/core"
+)
+
+func setupTestHumus(t *testing.T) *core.Humus {
+	t.Helper()
+
+	humus, err := core.NewHumus(setupTestJS(t))
+	if err != nil {
+		t.Fatalf("Failed to create humus: %v", err)
+	}
+	return humus
+}
+
+// setupTestJS starts an embedded JetStream server for the test.
+func setupTestJS(t *testing.T) nats.JetStreamContext {
+	t.Helper()
+
+	ns, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create NATS server: %v", err)
+	}
+	go ns.Start()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server not ready")
+	}
+	t.Cleanup(ns.Shutdown)
+
+	nc, err := nats.Connect(ns.ClientURL())
+	if err != nil {
+		t.Fatalf("Failed to connect to NATS: %v", err)
+	}
+	t.Cleanup(nc.Close)
+
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatalf("Failed to get JetStream context: %v", err)
+	}
+	return js
+}
+
+func waitForProcessed(t *testing.T, p *Projection, n uint64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for p.Status().Processed < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %d composts, processed %d", n, p.Status().Processed)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestProjection_Lua(t *testing.T) {
+	humus := setupTestHumus(t)
+
+	script := `
+function project(c)
+  local prev = view.get("task:" .. c.entity)
+  if prev and prev.status == "open" then
+    view.remove("open:" .. prev.customer, c.entity)
+    view.incr("open_count", -1)
+  end
+
+  if c.action == "delete" then
+    view.delete("task:" .. c.entity)
+    return
+  end
+
+  view.put("task:" .. c.entity, c.data)
+  if c.data.status == "open" then
+    view.add("open:" .. c.data.customer, c.entity)
+    view.incr("open_count")
+  end
+end
+`
+	scriptPath := filepath.Join(t.TempDir(), "open_tasks.lua")
+	os.WriteFile(scriptPath, []byte(script), 0644)
+
+	proj, err := NewProjection(ProjectionConfig{Name: "open-tasks", Entities: "tasks", Script: scriptPath}, humus, scriptPath)
+	if err != nil {
+		t.Fatalf("Failed to create projection: %v", err)
+	}
+	if err := proj.Start(); err != nil {
+		t.Fatalf("Failed to start projection: %v", err)
+	}
+	defer proj.Stop()
+
+	humus.Add("nim", "tasks/1", "create", []byte(`{"status":"open","customer":"acme"}`))
+	humus.Add("nim", "tasks/2", "create", []byte(`{"status":"open","customer":"acme"}`))
+	humus.Add("nim", "tasks/3", "create", []byte(`{"status":"open","customer":"globex"}`))
+	humus.Add("nim", "tasks/1", "update", []byte(`{"status":"done","customer":"acme"}`))
+	humus.Add("nim", "tasks/3", "delete", nil)
+
+	waitForProcessed(t, proj, 5)
+
+	if status := proj.Status(); status.Errors != 0 {
+		t.Fatalf("Unexpected projection errors: %s", status.LastError)
+	}
+
+	open, _, _ := proj.Get("open:acme")
+	if !reflect.DeepEqual(open, []interface{}{"tasks/2"}) {
+		t.Errorf("Expected open tasks [tasks/2] for acme, got %v", open)
+	}
+	globex, _, _ := proj.Get("open:globex")
+	if !reflect.DeepEqual(globex, []interface{}{}) {
+		t.Errorf("Expected no open tasks for globex, got %v", globex)
+	}
+	count, _, _ := proj.Get("open_count")
+	if count != float64(1) {
+		t.Errorf("Expected open_count 1, got %v", count)
+	}
+	if _, found, _ := proj.Get("task:tasks/3"); found {
+		t.Error("Expected deleted task to be removed from the view")
+	}
+}
+
+func TestProjection_Func(t *testing.T) {
+	humus := setupTestHumus(t)
+
+	RegisterProjection("test-actions", func(c core.Compost, v *core.View) error {
+		_, err := v.Incr("actions."+c.Action, 1)
+		return err
+	})
+
+	proj, err := NewProjection(ProjectionConfig{Name: "actions", Func: "test-actions"}, humus, "")
+	if err != nil {
+		t.Fatalf("Failed to create projection: %v", err)
+	}
+	if err := proj.Start(); err != nil {
+		t.Fatalf("Failed to start projection: %v", err)
+	}
+	defer proj.Stop()
+
+	humus.Add("nim", "tasks/1", "create", []byte(`{}`))
+	humus.Add("nim", "tasks/1", "update", []byte(`{}`))
+	humus.Add("nim", "tasks/2", "create", []byte(`{}`))
+	waitForProcessed(t, proj, 3)
+
+	if creates, _, _ := proj.Get("actions.create"); creates != float64(2) {
+		t.Errorf("Expected 2 creates, got %v", creates)
+	}
+
+	// Rebuild replays humus into an empty view
+	if err := proj.Rebuild(); err != nil {
+		t.Fatalf("Failed to rebuild: %v", err)
+	}
+	waitForProcessed(t, proj, 3)
+	if creates, _, _ := proj.Get("actions.create"); creates != float64(2) {
+		t.Errorf("Expected 2 creates after rebuild, got %v", creates)
+	}
+}
+
+func TestProjection_UnknownFunc(t *testing.T) {
+	humus := setupTestHumus(t)
+
+	if _, err := NewProjection(ProjectionConfig{Name: "x", Func: "does-not-exist"}, humus, ""); err == nil {
+		t.Error("Expected error for unregistered projection func")
+	}
+}
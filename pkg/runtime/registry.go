@@ -0,0 +1,131 @@
+package runtime
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/yourusername/nimsforest/internal/core"
+	"github.com/yourusername/nimsforest/internal/treehouses"
+)
+
+// GoTypePrefix marks a component type implemented in Go, e.g. "go:aftersales".
+const GoTypePrefix = "go:"
+
+// GoDeps are the connections a registered Go component is created with.
+// River, Humus and Soil are nil when the forest runs without them.
+type GoDeps struct {
+	Wind  *core.Wind
+	River *core.River
+	Humus *core.Humus
+	Soil  *core.Soil
+}
+
+// GoNimFactory creates a compiled nim.
+type GoNimFactory func(deps GoDeps) (core.Nim, error)
+
+// GoTreeFactory creates a compiled tree.
+type GoTreeFactory func(deps GoDeps) (core.Tree, error)
+
+// GoTreeHouseFactory creates a compiled treehouse.
+type GoTreeHouseFactory func(deps GoDeps) (treehouses.GoTreeHouse, error)
+
+var (
+	goRegistryMu     sync.RWMutex
+	goNimTypes       = make(map[string]GoNimFactory)
+	goTreeTypes      = make(map[string]GoTreeFactory)
+	goTreeHouseTypes = make(map[string]GoTreeHouseFactory)
+)
+
+// RegisterNim registers a Go nim under a type name, so forest.yaml can
+// instantiate it with `type: go:<name>`.
+// It is typically called from an init function.
+func RegisterNim(typeName string, factory GoNimFactory) {
+	goRegistryMu.Lock()
+	defer goRegistryMu.Unlock()
+	goNimTypes[typeName] = factory
+}
+
+// RegisterTree registers a Go tree under a type name.
+func RegisterTree(typeName string, factory GoTreeFactory) {
+	goRegistryMu.Lock()
+	defer goRegistryMu.Unlock()
+	goTreeTypes[typeName] = factory
+}
+
+// RegisterTreeHouse registers a Go treehouse under a type name.
+func RegisterTreeHouse(typeName string, factory GoTreeHouseFactory) {
+	goRegistryMu.Lock()
+	defer goRegistryMu.Unlock()
+	goTreeHouseTypes[typeName] = factory
+}
+
+// GoRegistry lists the registered Go component types by kind.
+type GoRegistry struct {
+	Nims       []string `json:"nims"`
+	Trees      []string `json:"trees"`
+	TreeHouses []string `json:"treehouses"`
+}
+
+// RegisteredTypes returns the names of all registered Go component types, sorted.
+func RegisteredTypes() GoRegistry {
+	goRegistryMu.RLock()
+	defer goRegistryMu.RUnlock()
+	return GoRegistry{
+		Nims:       sortedKeys(goNimTypes),
+		Trees:      sortedKeys(goTreeTypes),
+		TreeHouses: sortedKeys(goTreeHouseTypes),
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// goTypeName returns the registered type name of a "go:<name>" component
+// type, and false for script and prompt components.
+func goTypeName(componentType string) (string, bool) {
+	if !strings.HasPrefix(componentType, GoTypePrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(componentType, GoTypePrefix), true
+}
+
+func newGoNim(componentType string, deps GoDeps) (core.Nim, error) {
+	name, _ := goTypeName(componentType)
+	goRegistryMu.RLock()
+	factory, ok := goNimTypes[name]
+	goRegistryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("nim type %q not registered", componentType)
+	}
+	return factory(deps)
+}
+
+func newGoTree(componentType string, deps GoDeps) (core.Tree, error) {
+	name, _ := goTypeName(componentType)
+	goRegistryMu.RLock()
+	factory, ok := goTreeTypes[name]
+	goRegistryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("tree type %q not registered", componentType)
+	}
+	return factory(deps)
+}
+
+func newGoTreeHouse(componentType string, deps GoDeps) (treehouses.GoTreeHouse, error) {
+	name, _ := goTypeName(componentType)
+	goRegistryMu.RLock()
+	factory, ok := goTreeHouseTypes[name]
+	goRegistryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("treehouse type %q not registered", componentType)
+	}
+	return factory(deps)
+}
@@ -0,0 +1,306 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yourusername/nimsforest/internal/core"
+	"github.com/yourusername/nimsforest/internal/treehouses"
+)
+
+// countingNim is a compiled nim that counts the leaves it catches.
+type countingNim struct {
+	*core.BaseNim
+	caught *atomic.Int64
+}
+
+func (n *countingNim) Subjects() []string { return []string{"registry.test"} }
+
+func (n *countingNim) Handle(ctx context.Context, leaf core.Leaf) error {
+	n.caught.Add(1)
+	return nil
+}
+
+func (n *countingNim) Start(ctx context.Context) error {
+	return n.Catch("registry.test", func(leaf core.Leaf) { n.Handle(ctx, leaf) })
+}
+
+func (n *countingNim) Stop() error {
+	n.StopCatching()
+	return nil
+}
+
+func registerCountingNim(caught *atomic.Int64) {
+	RegisterNim("test-counter", func(deps GoDeps) (core.Nim, error) {
+		return &countingNim{BaseNim: core.NewBaseNim("counter", deps.Wind, deps.Humus, deps.Soil), caught: caught}, nil
+	})
+}
+
+func waitForCount(t *testing.T, n *atomic.Int64, want int64) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for n.Load() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d leaves, got %d", want, n.Load())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRegisteredTypes(t *testing.T) {
+	registry := RegisteredTypes()
+	if !slices.Contains(registry.Nims, "aftersales") || !slices.Contains(registry.Nims, "general") {
+		t.Errorf("Expected built-in nims, got %v", registry.Nims)
+	}
+	if !slices.Contains(registry.Trees, "payment") {
+		t.Errorf("Expected built-in payment tree, got %v", registry.Trees)
+	}
+}
+
+func TestForest_GoNimFromConfig(t *testing.T) {
+	var caught atomic.Int64
+	registerCountingNim(&caught)
+
+	_, wind, cleanup := setupTestForest(t)
+	defer cleanup()
+
+	cfg := &Config{Nims: map[string]NimConfig{
+		"counter":    {Name: "counter", Type: "go:test-counter"},
+		"aftersales": {Name: "aftersales", Type: "go:aftersales"}, // No humus: skipped with a warning
+	}}
+	f, err := NewForestFromConfig(cfg, wind, &mockBrain{})
+	if err != nil {
+		t.Fatalf("Failed to create forest: %v", err)
+	}
+	if err := f.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start forest: %v", err)
+	}
+	defer f.Stop()
+
+	nim := f.GoNim("counter")
+	if nim == nil || !nim.IsRunning() {
+		t.Fatalf("Expected go nim to be running, got %+v", nim)
+	}
+	if f.GoNim("aftersales") != nil {
+		t.Error("Expected aftersales nim without humus not to be created")
+	}
+
+	wind.Drop(*core.NewLeaf("registry.test", []byte(`{}`), "test"))
+	waitForCount(t, &caught, 1)
+
+	if err := f.Stop(); err != nil {
+		t.Fatalf("Failed to stop forest: %v", err)
+	}
+	if nim.IsRunning() {
+		t.Error("Expected go nim to stop with the forest")
+	}
+}
+
+func TestAPIGoNimHotAddRemove(t *testing.T) {
+	var caught atomic.Int64
+	registerCountingNim(&caught)
+
+	forest, wind, cleanup := setupTestForest(t)
+	defer cleanup()
+	handler := NewAPI(APIConfig{Address: "127.0.0.1:0", Forest: forest}).server.Handler
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("POST", "/api/v1/nims", `{"name":"counter","type":"go:test-counter"}`); w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body)
+	}
+	if w := do("POST", "/api/v1/nims", `{"name":"counter","type":"go:test-counter"}`); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for duplicate nim, got %d", w.Code)
+	}
+	if w := do("POST", "/api/v1/nims", `{"name":"missing","type":"go:missing"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for unregistered type, got %d", w.Code)
+	}
+
+	w := do("GET", "/api/v1/nims", "")
+	var nims []ComponentInfo
+	json.NewDecoder(w.Body).Decode(&nims)
+	if len(nims) != 1 || nims[0].GoType != "go:test-counter" || nims[0].Subscribes != "registry.test" || !nims[0].Running {
+		t.Errorf("Unexpected nims: %+v", nims)
+	}
+
+	wind.Drop(*core.NewLeaf("registry.test", []byte(`{}`), "test"))
+	waitForCount(t, &caught, 1)
+
+	if w := do("DELETE", "/api/v1/nims/counter", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204, got %d", w.Code)
+	}
+	wind.Drop(*core.NewLeaf("registry.test", []byte(`{}`), "test"))
+	time.Sleep(100 * time.Millisecond)
+	if caught.Load() != 1 {
+		t.Errorf("Expected removed nim to stop catching, got %d leaves", caught.Load())
+	}
+
+	w = do("GET", "/api/v1/registry", "")
+	var registry GoRegistry
+	json.NewDecoder(w.Body).Decode(&registry)
+	if !slices.Contains(registry.Nims, "test-counter") {
+		t.Errorf("Expected test-counter in registry, got %+v", registry)
+	}
+}
+
+// stampingTreeHouse tags leaves; with drift set it appends a call counter,
+// which makes it nondeterministic.
+type stampingTreeHouse struct {
+	drift bool
+	calls atomic.Int64
+}
+
+func (h *stampingTreeHouse) Name() string       { return "stamper" }
+func (h *stampingTreeHouse) Subjects() []string { return []string{"registry.in"} }
+
+func (h *stampingTreeHouse) Process(leaf core.Leaf) *core.Leaf {
+	n := h.calls.Add(1)
+	data := string(leaf.Data)
+	if h.drift {
+		data = fmt.Sprintf(`{"call":%d}`, n)
+	}
+	return core.NewLeaf("registry.out", []byte(data), "")
+}
+
+func TestGoTreeHouseVerify(t *testing.T) {
+	RegisterTreeHouse("test-stable", func(deps GoDeps) (treehouses.GoTreeHouse, error) {
+		return &stampingTreeHouse{}, nil
+	})
+	RegisterTreeHouse("test-drifting", func(deps GoDeps) (treehouses.GoTreeHouse, error) {
+		return &stampingTreeHouse{drift: true}, nil
+	})
+
+	forest, wind, cleanup := setupTestForest(t)
+	defer cleanup()
+
+	var outputs, alerts atomic.Int64
+	var alert Nondeterminism
+	var alertMu sync.Mutex
+	wind.Catch("registry.out", func(leaf core.Leaf) {
+		if leaf.Source == "treehouse:stable" || leaf.Source == "treehouse:drifting" {
+			outputs.Add(1)
+		}
+	})
+	wind.Catch(NondeterministicSubject("drifting"), func(leaf core.Leaf) {
+		alertMu.Lock()
+		json.Unmarshal(leaf.Data, &alert)
+		alertMu.Unlock()
+		alerts.Add(1)
+	})
+
+	if err := forest.AddTreeHouse("stable", TreeHouseConfig{Type: "go:test-stable", Verify: 1}); err != nil {
+		t.Fatalf("Failed to add stable treehouse: %v", err)
+	}
+	if err := forest.AddTreeHouse("drifting", TreeHouseConfig{Type: "go:test-drifting", Verify: 1}); err != nil {
+		t.Fatalf("Failed to add drifting treehouse: %v", err)
+	}
+	if err := forest.AddTreeHouse("stable", TreeHouseConfig{Type: "go:test-stable"}); err == nil {
+		t.Error("Expected error adding duplicate treehouse")
+	}
+
+	wind.Drop(*core.NewLeaf("registry.in", []byte(`{"id":1}`), "test"))
+	waitForCount(t, &outputs, 2)
+	waitForCount(t, &alerts, 1)
+
+	stable := forest.GoTreeHouse("stable").Stats()
+	if stable.Processed != 1 || stable.Verified != 1 || stable.Mismatches != 0 {
+		t.Errorf("Unexpected stable stats: %+v", stable)
+	}
+	drifting := forest.GoTreeHouse("drifting").Stats()
+	if drifting.Verified != 1 || drifting.Mismatches != 1 {
+		t.Errorf("Unexpected drifting stats: %+v", drifting)
+	}
+
+	alertMu.Lock()
+	if alert.TreeHouse != "drifting" || alert.Type != "go:test-drifting" || string(alert.Input.Data) != `{"id":1}` ||
+		alert.First == nil || alert.Second == nil || string(alert.First.Data) == string(alert.Second.Data) {
+		t.Errorf("Unexpected alert: %+v", alert)
+	}
+	alertMu.Unlock()
+
+	for _, th := range forest.Status().TreeHouses {
+		if th.Name == "drifting" && (th.Determinism == nil || th.Determinism.Mismatches != 1 || th.Subscribes != "registry.in") {
+			t.Errorf("Unexpected drifting status: %+v", th)
+		}
+	}
+
+	if err := forest.RemoveTreeHouse("drifting"); err != nil {
+		t.Fatalf("Failed to remove treehouse: %v", err)
+	}
+	if forest.GoTreeHouse("drifting") != nil {
+		t.Error("Expected drifting treehouse to be removed")
+	}
+}
+
+func TestStartBuiltins(t *testing.T) {
+	forest, wind, cleanup := setupTestForest(t)
+	defer cleanup()
+
+	js := setupTestJS(t)
+	river, err := core.NewRiver(js)
+	if err != nil {
+		t.Fatalf("Failed to create river: %v", err)
+	}
+	humus, _ := core.NewHumus(js)
+	soil, _ := core.NewSoil(js)
+	deps := GoDeps{Wind: wind, River: river, Humus: humus, Soil: soil}
+
+	payments := make(chan core.Leaf, 1)
+	sub, _ := wind.Catch("payment.completed", func(leaf core.Leaf) { payments <- leaf })
+	defer sub.Unsubscribe()
+
+	// Without a forest every built-in runs
+	builtins, err := StartBuiltins(context.Background(), nil, deps)
+	if err != nil {
+		t.Fatalf("StartBuiltins failed: %v", err)
+	}
+	if len(builtins.Trees) != 2 || len(builtins.Nims) != 2 {
+		t.Fatalf("Expected 2 trees and 2 nims, got %d and %d", len(builtins.Trees), len(builtins.Nims))
+	}
+
+	river.Flow("river.stripe.webhook", []byte(`{"type":"charge.succeeded","data":{"object":{"amount":1500,"currency":"usd","customer":"c1"}}}`))
+	select {
+	case leaf := <-payments:
+		if leaf.Source != "payment-tree" {
+			t.Errorf("Expected a leaf from the payment tree, got %+v", leaf)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Expected the built-in payment tree to emit payment.completed")
+	}
+	builtins.Stop()
+
+	// Built-ins the forest runs are left to it. The forest has no humus or
+	// soil, so its aftersales nim fails and the built-in one runs instead.
+	forest.SetRiver(river)
+	if err := forest.AddTree("payments", TreeConfig{Type: "go:payment"}); err != nil {
+		t.Fatalf("AddTree failed: %v", err)
+	}
+	if err := forest.AddNim("aftersales", NimConfig{Type: "go:aftersales"}); err == nil {
+		t.Fatal("Expected the aftersales nim to fail without humus and soil")
+	}
+	builtins, err = StartBuiltins(context.Background(), forest, deps)
+	if err != nil {
+		t.Fatalf("StartBuiltins failed: %v", err)
+	}
+	defer builtins.Stop()
+	if len(builtins.Trees) != 1 || builtins.Trees[0].Name() != "general-tree" {
+		t.Errorf("Expected only the general tree, got %d trees", len(builtins.Trees))
+	}
+	if len(builtins.Nims) != 2 {
+		t.Errorf("Expected both nims, got %d nims", len(builtins.Nims))
+	}
+}
@@ -0,0 +1,560 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/yourusername/nimsforest/internal/core"
+	"github.com/yourusername/nimsforest/internal/songbirds"
+	"github.com/yourusername/nimsforest/internal/sources"
+)
+
+// ReloadChange is a component a reload adds, changes or removes.
+type ReloadChange struct {
+	Kind   string   `json:"kind"` // "source", "tree", "treehouse", "nim", "songbird" or "projection"
+	Name   string   `json:"name"`
+	Fields []string `json:"fields,omitempty"` // Changed settings, e.g. "publishes"
+	Error  string   `json:"error,omitempty"`  // Why it failed
+}
+
+// ReloadResult is the plan of a reload or, once applied, its outcome.
+// Components that failed to apply are only listed in Failed.
+type ReloadResult struct {
+	DryRun  bool           `json:"dry_run"`
+	Added   []ReloadChange `json:"added"`
+	Changed []ReloadChange `json:"changed"`
+	Removed []ReloadChange `json:"removed"`
+	Failed  []ReloadChange `json:"failed"`
+
+	// Config is the config the forest runs after the reload: the new one,
+	// with failed components at their old config or left out. The config
+	// passed to Reload is not modified.
+	Config *Config `json:"-"`
+}
+
+// reloadable is a component the reload can start and stop.
+type reloadable interface {
+	Start(ctx context.Context) error
+	Stop() error
+}
+
+// reloadKind reconciles one kind of component with a new config.
+type reloadKind struct {
+	kind string
+
+	// configs returns the kind's configs by component name.
+	configs func(c *Config) map[string]any
+	// set puts a config back into c when applying it failed; nil deletes it.
+	set func(c *Config, name string, cfg any)
+	// limits returns the config section the component runs within ("lua",
+	// "wasm" or "prompts"), so it is recreated when it changes.
+	limits func(cfg any) string
+	// build creates a component from cfg without starting it. It returns
+	// nil when the forest creates the component on Start instead.
+	build func(f *Forest, name string, cfg *Config) (reloadable, error)
+	// install adds a built component to the forest.
+	install func(f *Forest, name string, c reloadable) error
+	// uninstall removes a component from the forest and returns it, if
+	// any, without stopping it.
+	uninstall func(f *Forest, name string) reloadable
+}
+
+// reloadKinds are reconciled in this order, so sources feed the river
+// before the trees watching it are started.
+var reloadKinds = []reloadKind{
+	{
+		kind:    "source",
+		configs: func(c *Config) map[string]any { return configMap(c.Sources) },
+		set:     func(c *Config, name string, cfg any) { setConfig(&c.Sources, name, cfg) },
+		build: func(f *Forest, name string, c *Config) (reloadable, error) {
+			if !f.running {
+				return nil, nil // Created on Start, when river is set
+			}
+			if f.river == nil {
+				return nil, fmt.Errorf("river is required for sources")
+			}
+			if f.sourceFactory == nil {
+				f.sourceFactory = sources.NewFactory(f.river, f.wind)
+			}
+			return f.sourceFactory.Create(sourceFactoryConfig(name, c.Sources[name]))
+		},
+		install: func(f *Forest, name string, c reloadable) error {
+			if err := f.mountSource(c); err != nil {
+				return err
+			}
+			f.sources[name] = c.(core.Source)
+			return nil
+		},
+		uninstall: func(f *Forest, name string) reloadable {
+			src, ok := f.sources[name]
+			if !ok {
+				return nil
+			}
+			if f.webhookServer != nil {
+				f.webhookServer.Unmount(name)
+			}
+			delete(f.sources, name)
+			return src
+		},
+	},
+	{
+		kind:    "tree",
+		configs: func(c *Config) map[string]any { return configMap(c.Trees) },
+		set:     func(c *Config, name string, cfg any) { setConfig(&c.Trees, name, cfg) },
+		limits:  func(cfg any) string { return scriptLimits(cfg.(TreeConfig).Type, cfg.(TreeConfig).engine()) },
+		build: func(f *Forest, name string, c *Config) (reloadable, error) {
+			if !f.running {
+				return nil, nil // Created on Start, when river is set
+			}
+			if f.river == nil {
+				return nil, fmt.Errorf("river is required for trees")
+			}
+			cfg := c.Trees[name]
+			cfg.Name = name
+			if cfg.Type != "" {
+				return NewGoTree(cfg, f.goDeps())
+			}
+			tree, err := newTree(cfg, f.wind, f.river, c)
+			if err != nil {
+				return nil, err
+			}
+			f.connectScript(tree)
+			return tree, nil
+		},
+		install: func(f *Forest, name string, c reloadable) error {
+			switch tree := c.(type) {
+			case *Tree:
+				f.trees[name] = tree
+			case *GoTree:
+				f.goTrees[name] = tree
+			}
+			return nil
+		},
+		uninstall: func(f *Forest, name string) reloadable {
+			if tree, ok := f.trees[name]; ok {
+				delete(f.trees, name)
+				return tree
+			}
+			if tree, ok := f.goTrees[name]; ok {
+				delete(f.goTrees, name)
+				return tree
+			}
+			return nil
+		},
+	},
+	{
+		kind:    "treehouse",
+		configs: func(c *Config) map[string]any { return configMap(c.TreeHouses) },
+		set:     func(c *Config, name string, cfg any) { setConfig(&c.TreeHouses, name, cfg) },
+		limits:  func(cfg any) string { return scriptLimits(cfg.(TreeHouseConfig).Type, cfg.(TreeHouseConfig).engine()) },
+		build: func(f *Forest, name string, c *Config) (reloadable, error) {
+			cfg := c.TreeHouses[name]
+			cfg.Name = name
+			if cfg.Type != "" {
+				if !f.running {
+					return nil, nil
+				}
+				return NewGoTreeHouse(cfg, f.goDeps())
+			}
+			th, err := newTreeHouse(cfg, f.wind, c)
+			if err != nil {
+				return nil, err
+			}
+			f.connectScript(th)
+			return th, nil
+		},
+		install: func(f *Forest, name string, c reloadable) error {
+			switch th := c.(type) {
+			case *TreeHouse:
+				f.treehouses[name] = th
+			case *GoTreeHouse:
+				f.goTreeHouses[name] = th
+			}
+			return nil
+		},
+		uninstall: func(f *Forest, name string) reloadable {
+			if th, ok := f.treehouses[name]; ok {
+				delete(f.treehouses, name)
+				return th
+			}
+			if th, ok := f.goTreeHouses[name]; ok {
+				delete(f.goTreeHouses, name)
+				return th
+			}
+			return nil
+		},
+	},
+	{
+		kind:    "nim",
+		configs: func(c *Config) map[string]any { return configMap(c.Nims) },
+		set:     func(c *Config, name string, cfg any) { setConfig(&c.Nims, name, cfg) },
+		limits: func(cfg any) string {
+			if cfg.(NimConfig).Type != "" {
+				return ""
+			}
+			return "prompts"
+		},
+		build: func(f *Forest, name string, c *Config) (reloadable, error) {
+			cfg := c.Nims[name]
+			cfg.Name = name
+			if cfg.Type != "" {
+				if !f.running {
+					return nil, nil
+				}
+				return NewGoNim(cfg, f.goDeps())
+			}
+			nim, err := newNim(cfg, f.wind, f.humus, f.brain, c)
+			if err != nil {
+				return nil, err
+			}
+			f.connectNim(nim)
+			return nim, nil
+		},
+		install: func(f *Forest, name string, c reloadable) error {
+			switch nim := c.(type) {
+			case *Nim:
+				f.nims[name] = nim
+			case *GoNim:
+				f.goNims[name] = nim
+			}
+			return nil
+		},
+		uninstall: func(f *Forest, name string) reloadable {
+			if nim, ok := f.nims[name]; ok {
+				delete(f.nims, name)
+				return nim
+			}
+			if nim, ok := f.goNims[name]; ok {
+				delete(f.goNims, name)
+				return nim
+			}
+			return nil
+		},
+	},
+	{
+		kind:    "songbird",
+		configs: func(c *Config) map[string]any { return configMap(c.Songbirds) },
+		set:     func(c *Config, name string, cfg any) { setConfig(&c.Songbirds, name, cfg) },
+		build: func(f *Forest, name string, c *Config) (reloadable, error) {
+			return createSongbird(name, c.Songbirds[name], f.wind)
+		},
+		install: func(f *Forest, name string, c reloadable) error {
+			f.songbirds[name] = c.(songbirds.Songbird)
+			return nil
+		},
+		uninstall: func(f *Forest, name string) reloadable {
+			sb, ok := f.songbirds[name]
+			if !ok {
+				return nil
+			}
+			delete(f.songbirds, name)
+			return sb
+		},
+	},
+	{
+		kind:    "projection",
+		configs: func(c *Config) map[string]any { return configMap(c.Projections) },
+		set:     func(c *Config, name string, cfg any) { setConfig(&c.Projections, name, cfg) },
+		build: func(f *Forest, name string, c *Config) (reloadable, error) {
+			if f.humus == nil || !f.running {
+				return nil, nil // Created on Start, when humus is set
+			}
+			cfg := c.Projections[name]
+			cfg.Name = name
+			proj, err := NewProjection(cfg, f.humus, c.ResolvePath(cfg.Script))
+			if err != nil {
+				return nil, err
+			}
+			return reloadableProjection{proj}, nil
+		},
+		install: func(f *Forest, name string, c reloadable) error {
+			f.projections[name] = c.(reloadableProjection).Projection
+			return nil
+		},
+		uninstall: func(f *Forest, name string) reloadable {
+			proj, ok := f.projections[name]
+			if !ok {
+				return nil
+			}
+			delete(f.projections, name)
+			return reloadableProjection{proj}
+		},
+	},
+}
+
+// reloadableProjection adapts Projection.Start to the reloadable interface.
+type reloadableProjection struct{ *Projection }
+
+func (p reloadableProjection) Start(ctx context.Context) error { return p.Projection.Start() }
+
+func configMap[T any](m map[string]T) map[string]any {
+	configs := make(map[string]any, len(m))
+	for name, cfg := range m {
+		configs[name] = cfg
+	}
+	return configs
+}
+
+// cloneComponents returns a copy of c whose component maps can be changed
+// without changing c's. Other sections are shared.
+func cloneComponents(c *Config) *Config {
+	clone := *c
+	clone.Sources = maps.Clone(c.Sources)
+	clone.Trees = maps.Clone(c.Trees)
+	clone.TreeHouses = maps.Clone(c.TreeHouses)
+	clone.Nims = maps.Clone(c.Nims)
+	clone.Songbirds = maps.Clone(c.Songbirds)
+	clone.Projections = maps.Clone(c.Projections)
+	return &clone
+}
+
+func setConfig[T any](m *map[string]T, name string, cfg any) {
+	if cfg == nil {
+		delete(*m, name)
+		return
+	}
+	if *m == nil {
+		*m = make(map[string]T)
+	}
+	(*m)[name] = cfg.(T)
+}
+
+// scriptLimits returns the limits a script component of engine runs
+// within; Go and expr components have none.
+func scriptLimits(goType, engine string) string {
+	if goType != "" || engine == EngineExpr {
+		return ""
+	}
+	return engine
+}
+
+// changedFields returns the yaml names of the settings that differ.
+func changedFields(old, new any) []string {
+	ov, nv := reflect.ValueOf(old), reflect.ValueOf(new)
+	var fields []string
+	for i := 0; i < ov.NumField(); i++ {
+		field := ov.Type().Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if opts == "inline" && field.Type.Kind() == reflect.Struct {
+			fields = append(fields, changedFields(ov.Field(i).Interface(), nv.Field(i).Interface())...)
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		if !reflect.DeepEqual(ov.Field(i).Interface(), nv.Field(i).Interface()) {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// diff compares the kind's components in two configs.
+func (k reloadKind) diff(old, new *Config) (added, changed, removed []ReloadChange) {
+	oldCfgs, newCfgs := k.configs(old), k.configs(new)
+	limitsChanged := map[string]bool{
+		EngineLua:  old.Lua.Limits() != new.Lua.Limits(),
+		EngineWASM: old.WASM.Limits() != new.WASM.Limits(),
+		"prompts":  old.promptPartials() != new.promptPartials(),
+	}
+
+	for name, cfg := range newCfgs {
+		prev, exists := oldCfgs[name]
+		if !exists {
+			added = append(added, ReloadChange{Kind: k.kind, Name: name})
+			continue
+		}
+		fields := changedFields(prev, cfg)
+		if k.limits != nil && limitsChanged[k.limits(cfg)] {
+			fields = append(fields, k.limits(cfg))
+		}
+		if len(fields) > 0 {
+			changed = append(changed, ReloadChange{Kind: k.kind, Name: name, Fields: fields})
+		}
+	}
+	for name := range oldCfgs {
+		if _, exists := newCfgs[name]; !exists {
+			removed = append(removed, ReloadChange{Kind: k.kind, Name: name})
+		}
+	}
+	for _, list := range [][]ReloadChange{added, changed, removed} {
+		sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	}
+	return added, changed, removed
+}
+
+// PlanReload returns what Reload would add, change and remove for newCfg
+// without applying it.
+func (f *Forest) PlanReload(newCfg *Config) *ReloadResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	plan := &ReloadResult{DryRun: true}
+	for _, k := range reloadKinds {
+		added, changed, removed := k.diff(f.config, newCfg)
+		plan.Added = append(plan.Added, added...)
+		plan.Changed = append(plan.Changed, changed...)
+		plan.Removed = append(plan.Removed, removed...)
+	}
+	return plan
+}
+
+// Reload reconciles the forest's components with newCfg. Removed components
+// are stopped, added ones are created and started, and changed ones are
+// recreated from their new config. A component that fails to build or start
+// keeps running its old version; the result lists it under Failed and its
+// Config holds what the forest now runs. newCfg itself is not modified.
+func (f *Forest) Reload(newCfg *Config) (*ReloadResult, error) {
+	if newCfg == nil {
+		return nil, fmt.Errorf("config is required")
+	}
+	newCfg = cloneComponents(newCfg)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	result := &ReloadResult{Config: newCfg}
+	for _, k := range reloadKinds {
+		added, changed, removed := k.diff(f.config, newCfg)
+
+		for _, change := range removed {
+			if c := k.uninstall(f, change.Name); c != nil {
+				c.Stop()
+			}
+			result.Removed = append(result.Removed, change)
+			log.Printf("[Forest] Removed %s '%s' (not in new config)", k.kind, change.Name)
+		}
+		for _, change := range changed {
+			if err := f.applyReload(k, change.Name, newCfg, true); err != nil {
+				change.Error = err.Error()
+				result.Failed = append(result.Failed, change)
+				log.Printf("[Forest] Warning: failed to change %s '%s': %v", k.kind, change.Name, err)
+				continue
+			}
+			result.Changed = append(result.Changed, change)
+			log.Printf("[Forest] Changed %s '%s' (%s)", k.kind, change.Name, strings.Join(change.Fields, ", "))
+		}
+		for _, change := range added {
+			if err := f.applyReload(k, change.Name, newCfg, false); err != nil {
+				change.Error = err.Error()
+				result.Failed = append(result.Failed, change)
+				log.Printf("[Forest] Warning: failed to add %s '%s': %v", k.kind, change.Name, err)
+				continue
+			}
+			result.Added = append(result.Added, change)
+			log.Printf("[Forest] Added %s '%s' from new config", k.kind, change.Name)
+		}
+	}
+
+	// Update config reference
+	f.config = newCfg
+
+	log.Printf("[Forest] Reloaded: %d added, %d changed, %d removed, %d failed",
+		len(result.Added), len(result.Changed), len(result.Removed), len(result.Failed))
+	return result, nil
+}
+
+// applyReload builds a component from newCfg and swaps it in for the running
+// one, if any. The old component is only paused once the new one is built,
+// and is resumed if the new one fails to start. On failure newCfg, the
+// reload's own copy, is left holding the config of whatever now runs under
+// name, if anything.
+func (f *Forest) applyReload(k reloadKind, name string, newCfg *Config, replace bool) error {
+	oldCfg := k.configs(f.config)[name]
+	c, err := k.build(f, name, newCfg)
+	if err != nil {
+		k.set(newCfg, name, oldCfg)
+		return err
+	}
+	var prev reloadable
+	if replace {
+		if prev = k.uninstall(f, name); prev != nil {
+			pauseReload(prev)
+		}
+	}
+	if c == nil {
+		if prev != nil {
+			prev.Stop()
+		}
+		return nil // Created on Start
+	}
+	err = f.startReload(k, name, c)
+	if err == nil {
+		if prev != nil {
+			prev.Stop()
+		}
+		return nil
+	}
+	if prev == nil {
+		k.set(newCfg, name, nil) // Re-added by the next reload
+		return err
+	}
+	if restoreErr := f.startReload(k, name, prev); restoreErr != nil {
+		k.set(newCfg, name, nil)
+		return fmt.Errorf("%w (the previous version failed to restart: %v)", err, restoreErr)
+	}
+	k.set(newCfg, name, oldCfg)
+	return err
+}
+
+// pauser is a component whose Stop releases what it needs to run, such as
+// its script VMs. pause stops it in a way Start can undo instead.
+type pauser interface {
+	pause() error
+}
+
+// pauseReload stops c so that it can be started again.
+func pauseReload(c reloadable) {
+	if p, ok := c.(pauser); ok {
+		p.pause()
+		return
+	}
+	c.Stop()
+}
+
+// startReload installs c under name and starts it if the forest is running.
+// On failure nothing is left installed and c is stopped.
+func (f *Forest) startReload(k reloadKind, name string, c reloadable) error {
+	if err := k.install(f, name, c); err != nil {
+		c.Stop()
+		return err
+	}
+	if f.running {
+		if err := c.Start(context.Background()); err != nil {
+			k.uninstall(f, name)
+			c.Stop()
+			return err
+		}
+	}
+	return nil
+}
+
+// mountSource mounts a webhook or telegram source on the webhook server,
+// starting the server if needed. Callers must hold f.mu.
+func (f *Forest) mountSource(c reloadable) error {
+	ws, isWebhook := c.(*sources.WebhookSource)
+	ts, isTelegram := c.(*sources.TelegramSource)
+	if !isWebhook && !isTelegram {
+		return nil
+	}
+	if f.webhookServer == nil {
+		f.webhookServer = sources.NewWebhookServer(GetWebhookAddress())
+		if f.running {
+			if err := f.webhookServer.Start(); err != nil {
+				return fmt.Errorf("failed to start webhook server: %w", err)
+			}
+		}
+	}
+	if isWebhook {
+		return f.webhookServer.Mount(ws)
+	}
+	return f.webhookServer.MountHandler(ts.Name(), ts.Path(), ts.Handler())
+}
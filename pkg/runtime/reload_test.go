@@ -0,0 +1,289 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yourusername/nimsforest/internal/core"
+)
+
+// nextConfig copies the forest's trees, treehouses and nims into a new config.
+func nextConfig(f *Forest) *Config {
+	cfg := &Config{
+		Trees:      make(map[string]TreeConfig),
+		TreeHouses: make(map[string]TreeHouseConfig),
+		Nims:       make(map[string]NimConfig),
+		BaseDir:    f.config.BaseDir,
+	}
+	for name, tree := range f.config.Trees {
+		cfg.Trees[name] = tree
+	}
+	for name, th := range f.config.TreeHouses {
+		cfg.TreeHouses[name] = th
+	}
+	for name, nim := range f.config.Nims {
+		cfg.Nims[name] = nim
+	}
+	return cfg
+}
+
+func TestReload(t *testing.T) {
+	forest, wind, cleanup := setupTestForest(t)
+	defer cleanup()
+
+	dir := forest.config.BaseDir
+	os.WriteFile(filepath.Join(dir, "echo.lua"), []byte(`function process(input) return input end`), 0644)
+	os.WriteFile(filepath.Join(dir, "broken.lua"), []byte(`function process(input) return {x = ) end`), 0644)
+	os.WriteFile(filepath.Join(dir, "prompt.md"), []byte(`Hello`), 0644)
+
+	if err := forest.AddTreeHouse("echo", TreeHouseConfig{Subscribes: "echo.in", Publishes: "echo.v1", Script: "echo.lua"}); err != nil {
+		t.Fatalf("AddTreeHouse failed: %v", err)
+	}
+	if err := forest.AddTreeHouse("stable", TreeHouseConfig{Subscribes: "stable.in", Publishes: "stable.out", Script: "echo.lua"}); err != nil {
+		t.Fatalf("AddTreeHouse failed: %v", err)
+	}
+	if err := forest.AddNim("old", NimConfig{Subscribes: "old.in", Publishes: "old.out", Prompt: "prompt.md"}); err != nil {
+		t.Fatalf("AddNim failed: %v", err)
+	}
+
+	next := nextConfig(forest)
+	echo := next.TreeHouses["echo"]
+	echo.Publishes = "echo.v2"
+	next.TreeHouses["echo"] = echo
+	stable := next.TreeHouses["stable"]
+	stable.Script = "broken.lua"
+	next.TreeHouses["stable"] = stable
+	delete(next.Nims, "old")
+	next.Nims["new"] = NimConfig{Subscribes: "new.in", Publishes: "new.out", Prompt: "prompt.md"}
+
+	// A dry run reports the plan and applies nothing
+	plan := forest.PlanReload(next)
+	if !plan.DryRun || len(plan.Added) != 1 || len(plan.Changed) != 2 || len(plan.Removed) != 1 {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+	if plan.Changed[0].Name != "echo" || len(plan.Changed[0].Fields) != 1 || plan.Changed[0].Fields[0] != "publishes" {
+		t.Errorf("expected echo to change publishes, got %+v", plan.Changed[0])
+	}
+	if _, ok := forest.nims["old"]; !ok {
+		t.Fatal("dry run removed a nim")
+	}
+
+	result, err := forest.Reload(next)
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if len(result.Added) != 1 || result.Added[0].Name != "new" {
+		t.Errorf("expected nim new added, got %+v", result.Added)
+	}
+	if len(result.Removed) != 1 || result.Removed[0].Name != "old" {
+		t.Errorf("expected nim old removed, got %+v", result.Removed)
+	}
+	if len(result.Changed) != 1 || result.Changed[0].Name != "echo" {
+		t.Errorf("expected treehouse echo changed, got %+v", result.Changed)
+	}
+	if len(result.Failed) != 1 || result.Failed[0].Name != "stable" || result.Failed[0].Error == "" {
+		t.Errorf("expected treehouse stable to fail, got %+v", result.Failed)
+	}
+	if _, ok := forest.nims["new"]; !ok {
+		t.Error("nim new not running")
+	}
+	if _, ok := forest.nims["old"]; ok {
+		t.Error("nim old still running")
+	}
+	if got := forest.config.TreeHouses["stable"].Script; got != "echo.lua" {
+		t.Errorf("expected the failed treehouse to keep its old config, got script %q", got)
+	}
+
+	out := make(chan string, 10)
+	for _, subject := range []string{"echo.v1", "echo.v2", "stable.out"} {
+		wind.Catch(subject, func(leaf core.Leaf) { out <- leaf.Subject })
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	expect := func(in, want string) {
+		t.Helper()
+		wind.Drop(*core.NewLeaf(in, []byte(`{"n": 1}`), "test"))
+		select {
+		case got := <-out:
+			if got != want {
+				t.Errorf("expected a leaf on %s, got %s", want, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Errorf("timed out waiting for %s", want)
+		}
+	}
+	expect("echo.in", "echo.v2")
+	expect("stable.in", "stable.out") // Old version keeps running
+}
+
+func TestReloadTree(t *testing.T) {
+	forest, wind, cleanup := setupTestForest(t)
+	defer cleanup()
+
+	js := setupTestJS(t)
+	river, err := core.NewRiver(js)
+	if err != nil {
+		t.Fatalf("Failed to create river: %v", err)
+	}
+	forest.SetRiver(river)
+
+	os.WriteFile(filepath.Join(forest.config.BaseDir, "echo.lua"), []byte(`function process(input) return input end`), 0644)
+	if err := forest.AddTree("echo", TreeConfig{Watches: "river.echo", Publishes: "echo.v1", Script: "echo.lua"}); err != nil {
+		t.Fatalf("AddTree failed: %v", err)
+	}
+
+	out := make(chan string, 10)
+	for _, subject := range []string{"echo.v1", "echo.v2"} {
+		wind.Catch(subject, func(leaf core.Leaf) { out <- leaf.Subject })
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	expect := func(in, want string) {
+		t.Helper()
+		river.Flow(in, []byte(`{"n": 1}`))
+		select {
+		case got := <-out:
+			if got != want {
+				t.Errorf("expected a leaf on %s, got %s", want, got)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("timed out waiting for %s", want)
+		}
+	}
+	expect("river.echo", "echo.v1")
+
+	next := nextConfig(forest)
+	echo := next.Trees["echo"]
+	echo.Publishes = "echo.v2"
+	next.Trees["echo"] = echo
+	result, err := forest.Reload(next)
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if len(result.Changed) != 1 || result.Changed[0].Name != "echo" || len(result.Failed) != 0 {
+		t.Fatalf("expected tree echo changed, got %+v", result)
+	}
+
+	// The old tree's consumer is gone, so the new one gets every message
+	expect("river.echo", "echo.v2")
+	expect("river.echo", "echo.v2")
+	if info, _ := river.StreamInfo(); info.State.Consumers != 1 {
+		t.Errorf("expected 1 river consumer after reload, got %d", info.State.Consumers)
+	}
+
+	// A consumer filtered on the old watches would make this one fail
+	next = nextConfig(forest)
+	echo.Watches = "river.echo2"
+	next.Trees["echo"] = echo
+	if result, err := forest.Reload(next); err != nil || len(result.Failed) != 0 {
+		t.Fatalf("Reload failed: %v %+v", err, result)
+	}
+	expect("river.echo2", "echo.v2")
+}
+
+// failingNim is a compiled nim that cannot start.
+type failingNim struct{ *core.BaseNim }
+
+func (n *failingNim) Subjects() []string                               { return nil }
+func (n *failingNim) Handle(ctx context.Context, leaf core.Leaf) error { return nil }
+func (n *failingNim) Start(ctx context.Context) error                  { return fmt.Errorf("cannot start") }
+func (n *failingNim) Stop() error                                      { return nil }
+
+func TestReloadStartFails(t *testing.T) {
+	var caught atomic.Int64
+	registerCountingNim(&caught)
+	RegisterNim("test-failing", func(deps GoDeps) (core.Nim, error) {
+		return &failingNim{core.NewBaseNim("failing", deps.Wind, deps.Humus, deps.Soil)}, nil
+	})
+
+	forest, wind, cleanup := setupTestForest(t)
+	defer cleanup()
+
+	if err := forest.AddNim("counter", NimConfig{Type: "go:test-counter"}); err != nil {
+		t.Fatalf("AddNim failed: %v", err)
+	}
+
+	before := forest.GoNim("counter")
+
+	next := nextConfig(forest)
+	next.Nims["counter"] = NimConfig{Type: "go:test-failing"}
+	result, err := forest.Reload(next)
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if len(result.Failed) != 1 || result.Failed[0].Name != "counter" || len(result.Changed) != 0 {
+		t.Fatalf("expected nim counter to fail, got %+v", result)
+	}
+	if got := forest.config.Nims["counter"].Type; got != "go:test-counter" {
+		t.Errorf("expected the failed nim to keep its old config, got type %q", got)
+	}
+	if result.Config != forest.config {
+		t.Error("expected the result to hold the config the forest runs")
+	}
+	if got := next.Nims["counter"].Type; got != "go:test-failing" {
+		t.Errorf("expected the caller's config to be left as it was, got type %q", got)
+	}
+
+	// The old version was restarted rather than built again
+	if got := forest.GoNim("counter"); got == nil {
+		t.Fatal("nim counter not running")
+	} else if got != before {
+		t.Error("expected the old nim instance to be restarted")
+	}
+	time.Sleep(50 * time.Millisecond)
+	wind.Drop(*core.NewLeaf("registry.test", []byte(`{"n": 1}`), "test"))
+	waitForCount(t, &caught, 1)
+}
+
+func TestReloadResumesScript(t *testing.T) {
+	forest, wind, cleanup := setupTestForest(t)
+	defer cleanup()
+
+	os.WriteFile(filepath.Join(forest.config.BaseDir, "echo.lua"), []byte(`function process(input) return input end`), 0644)
+	if err := forest.AddTreeHouse("echo", TreeHouseConfig{Subscribes: "echo.in", Publishes: "echo.out", Script: "echo.lua"}); err != nil {
+		t.Fatalf("AddTreeHouse failed: %v", err)
+	}
+	before := forest.treehouses["echo"]
+
+	// Builds, but cannot subscribe
+	next := nextConfig(forest)
+	echo := next.TreeHouses["echo"]
+	echo.Subscribes = "echo in"
+	next.TreeHouses["echo"] = echo
+	result, err := forest.Reload(next)
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if len(result.Failed) != 1 || result.Failed[0].Name != "echo" {
+		t.Fatalf("expected treehouse echo to fail, got %+v", result)
+	}
+
+	// The old instance is resumed with its VMs intact
+	if got := forest.treehouses["echo"]; got != before {
+		t.Fatal("expected the old treehouse instance to be resumed")
+	}
+	out := make(chan string, 1)
+	wind.Catch("echo.out", func(leaf core.Leaf) { out <- leaf.Subject })
+	time.Sleep(50 * time.Millisecond)
+	wind.Drop(*core.NewLeaf("echo.in", []byte(`{"n": 1}`), "test"))
+	select {
+	case <-out:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the resumed treehouse")
+	}
+}
+
+func TestChangedFields(t *testing.T) {
+	old := TreeHouseConfig{Engine: EngineExpr, Publishes: "a", ExprConfig: ExprConfig{Filter: "x > 1"}}
+	new := old
+	new.ExprConfig = ExprConfig{Filter: "x > 2", Map: map[string]string{"y": "x"}}
+	fields := changedFields(old, new)
+	if len(fields) != 2 || fields[0] != "filter" || fields[1] != "map" {
+		t.Errorf("expected the inline filter and map fields, got %v", fields)
+	}
+}
@@ -0,0 +1,197 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/yourusername/nimsforest/internal/core"
+)
+
+// ScriptCase is one test case for a tree or treehouse script: an input and
+// the output process(input) must return.
+type ScriptCase struct {
+	Name string
+
+	// Input is the table process() receives, as a treehouse decodes a leaf.
+	Input map[string]interface{}
+	// Payload is raw JSON, decoded into the input like leaf or river data.
+	Payload string
+	// Subject, when set, is added to the input as _subject with _source
+	// "river", as a tree does for river data.
+	Subject string
+
+	Expect    interface{} // A table, or for treehouses a list of {subject, data}
+	ExpectNil bool        // process() must return nil
+}
+
+// scriptCaseFile is the YAML layout of a cases file.
+type scriptCaseFile struct {
+	Cases []struct {
+		Name    string                 `yaml:"name"`
+		Input   map[string]interface{} `yaml:"input"`
+		Payload string                 `yaml:"payload"`
+		Subject string                 `yaml:"subject"`
+		Expect  yaml.Node              `yaml:"expect"` // A table or list, or null for nil
+	} `yaml:"cases"`
+}
+
+// LoadScriptCases reads script test cases from a YAML file.
+func LoadScriptCases(path string) ([]ScriptCase, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cases: %w", err)
+	}
+
+	var file scriptCaseFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse cases: %w", err)
+	}
+	if len(file.Cases) == 0 {
+		return nil, fmt.Errorf("no cases in %s", path)
+	}
+
+	cases := make([]ScriptCase, 0, len(file.Cases))
+	for i, c := range file.Cases {
+		sc := ScriptCase{Name: c.Name, Input: c.Input, Payload: c.Payload, Subject: c.Subject}
+		if sc.Name == "" {
+			sc.Name = fmt.Sprintf("case %d", i+1)
+		}
+		if c.Input != nil && c.Payload != "" {
+			return nil, fmt.Errorf("%s: set either input or payload", sc.Name)
+		}
+		switch {
+		case c.Expect.Kind == 0:
+			return nil, fmt.Errorf("%s: expect is required (use expect: null for nil)", sc.Name)
+		case c.Expect.Tag == "!!null":
+			sc.ExpectNil = true
+		default:
+			if err := c.Expect.Decode(&sc.Expect); err != nil {
+				return nil, fmt.Errorf("%s: invalid expect: %w", sc.Name, err)
+			}
+		}
+		cases = append(cases, sc)
+	}
+	return cases, nil
+}
+
+// ScriptCaseResult is the outcome of one case.
+type ScriptCaseResult struct {
+	Name    string
+	Passed  bool
+	Output  interface{}       // nil when process() returned nil
+	Changes []core.JSONChange // From the expected output to the actual one
+	Error   string            // Load, input or script error
+}
+
+// RunScriptCases runs each case on a fresh LuaVM with scriptPath loaded,
+// the same VM and limits trees and treehouses use.
+func RunScriptCases(scriptPath string, cases []ScriptCase, limits LuaLimits) []ScriptCaseResult {
+	results := make([]ScriptCaseResult, 0, len(cases))
+	for _, c := range cases {
+		results = append(results, runScriptCase(c, func(input map[string]interface{}) (interface{}, error) {
+			vm := NewLuaVMWithLimits(limits)
+			defer vm.Close()
+			if err := vm.LoadScript(scriptPath); err != nil {
+				return nil, err
+			}
+			return vm.CallProcessValue(input)
+		}))
+	}
+	return results
+}
+
+// RunWASMCases runs each case on a fresh instance of the WASM module at
+// modulePath, compiled once. Host functions have no soil.
+func RunWASMCases(modulePath string, cases []ScriptCase, limits WASMLimits) []ScriptCaseResult {
+	pool, err := newWASMPool(1, modulePath, limits, &wasmHost{name: "test"})
+	if pool != nil {
+		defer pool.Close()
+	}
+	results := make([]ScriptCaseResult, 0, len(cases))
+	for _, c := range cases {
+		results = append(results, runScriptCase(c, func(input map[string]interface{}) (interface{}, error) {
+			if err != nil {
+				return nil, err
+			}
+			w, err := pool.instantiate()
+			if err != nil {
+				return nil, err
+			}
+			defer w.mod.Close(context.Background())
+			return w.CallProcessValue(input)
+		}))
+	}
+	return results
+}
+
+// runScriptCase builds the case's input, calls process with it and compares
+// the output with the expected one.
+func runScriptCase(c ScriptCase, process func(map[string]interface{}) (interface{}, error)) ScriptCaseResult {
+	result := ScriptCaseResult{Name: c.Name}
+
+	input, err := c.input()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	output, err := process(input)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Output = output
+
+	switch {
+	case c.ExpectNil:
+		result.Passed = output == nil
+		if !result.Passed {
+			result.Changes = []core.JSONChange{{Op: "changed", From: nil, To: output}}
+		}
+	case output == nil:
+		result.Changes = []core.JSONChange{{Op: "changed", From: c.Expect, To: nil}}
+	default:
+		expected, _ := json.Marshal(c.Expect)
+		actual, err := json.Marshal(output)
+		if err != nil {
+			result.Error = fmt.Sprintf("failed to encode output: %v", err)
+			return result
+		}
+		result.Changes, err = core.DiffJSON(expected, actual)
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		result.Passed = len(result.Changes) == 0
+	}
+	return result
+}
+
+// input builds the table process() receives, the way trees and treehouses do.
+func (c ScriptCase) input() (map[string]interface{}, error) {
+	var input map[string]interface{}
+	if c.Payload != "" {
+		if err := json.Unmarshal([]byte(c.Payload), &input); err != nil {
+			return nil, fmt.Errorf("invalid payload: %w", err)
+		}
+	} else {
+		// Round trip through JSON so numbers are float64, as in a decoded leaf
+		data, err := json.Marshal(c.Input)
+		if err != nil {
+			return nil, fmt.Errorf("invalid input: %w", err)
+		}
+		json.Unmarshal(data, &input)
+	}
+	if input == nil {
+		input = make(map[string]interface{})
+	}
+	if c.Subject != "" {
+		input["_subject"] = c.Subject
+		input["_source"] = "river"
+	}
+	return input, nil
+}
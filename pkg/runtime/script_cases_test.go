@@ -0,0 +1,67 @@
+package runtime
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunScriptCases(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "score.lua")
+	os.WriteFile(script, []byte(`
+function process(input)
+  if input.score < 50 then return nil end
+  return {priority = "high", score = input.score, subject = input._subject}
+end
+`), 0644)
+
+	casesPath := filepath.Join(dir, "cases.yaml")
+	os.WriteFile(casesPath, []byte(`
+cases:
+  - name: hot lead
+    input: {score: 90}
+    expect: {priority: high, score: 90}
+  - name: cold lead
+    input: {score: 10}
+    expect: null
+  - name: river data
+    subject: crm.contact
+    payload: '{"score": 70}'
+    expect: {priority: low, score: 70, subject: crm.contact}
+  - input: {score: 10}
+    expect: {priority: high}
+`), 0644)
+
+	cases, err := LoadScriptCases(casesPath)
+	if err != nil {
+		t.Fatalf("LoadScriptCases failed: %v", err)
+	}
+	if len(cases) != 4 || !cases[1].ExpectNil || cases[3].Name != "case 4" {
+		t.Fatalf("unexpected cases: %+v", cases)
+	}
+
+	results := RunScriptCases(script, cases, DefaultLuaLimits())
+	if !results[0].Passed || !results[1].Passed {
+		t.Errorf("expected the first two cases to pass, got %+v", results[:2])
+	}
+	if r := results[2]; r.Passed || len(r.Changes) != 1 || r.Changes[0].Path != "priority" {
+		t.Errorf("expected a priority diff, got %+v", r)
+	}
+	if r := results[3]; r.Passed || r.Output != nil || len(r.Changes) != 1 {
+		t.Errorf("expected a nil output diff, got %+v", r)
+	}
+
+	// Script errors fail every case
+	os.WriteFile(script, []byte(`function process(input) return {x = ) end`), 0644)
+	for _, r := range RunScriptCases(script, cases, DefaultLuaLimits()) {
+		if r.Passed || r.Error == "" {
+			t.Errorf("expected a load error, got %+v", r)
+		}
+	}
+
+	os.WriteFile(casesPath, []byte("cases:\n  - input: {score: 1}\n"), 0644)
+	if _, err := LoadScriptCases(casesPath); err == nil {
+		t.Error("expected an error for a case without expect")
+	}
+}
@@ -0,0 +1,9 @@
+package runtime
+
+import _ "embed"
+
+// soilBrowserHTML is the soil browser page served at /soil. It is a thin
+// client of the /api/v1/soil endpoints.
+//
+//go:embed static/soil_browser.html
+var soilBrowserHTML []byte
@@ -0,0 +1,98 @@
+// Command score is the WASM treehouse the runtime tests run. It scores leads
+// like scripts/treehouses/scoring.lua, using the host's log and soil_get.
+//
+//	GOOS=wasip1 GOARCH=wasm go build -buildmode=c-shared -o score.wasm
+package main
+
+import (
+	"encoding/json"
+	"unsafe"
+)
+
+func main() {}
+
+// buffers keeps the memory handed to the host alive until it is freed.
+var buffers = map[uint32][]byte{}
+
+//go:wasmimport forest log
+func hostLog(ptr unsafe.Pointer, size uint32)
+
+//go:wasmimport forest soil_get
+func hostSoilGet(ptr unsafe.Pointer, size uint32) uint64
+
+//go:wasmexport alloc
+func alloc(size uint32) uint32 {
+	buf := make([]byte, max(size, 1))
+	ptr := uint32(uintptr(unsafe.Pointer(&buf[0])))
+	buffers[ptr] = buf
+	return ptr
+}
+
+//go:wasmexport dealloc
+func dealloc(ptr, size uint32) {
+	delete(buffers, ptr)
+}
+
+func logf(msg string) {
+	hostLog(unsafe.Pointer(unsafe.StringData(msg)), uint32(len(msg)))
+}
+
+func soilGet(key string) map[string]any {
+	packed := hostSoilGet(unsafe.Pointer(unsafe.StringData(key)), uint32(len(key)))
+	if packed == 0 {
+		return nil
+	}
+	ptr := uint32(packed >> 32)
+	var value map[string]any
+	json.Unmarshal(buffers[ptr][:uint32(packed)], &value)
+	dealloc(ptr, uint32(packed))
+	return value
+}
+
+//go:wasmexport process
+func process(ptr, size uint32) uint64 {
+	var lead struct {
+		ID          string  `json:"id"`
+		Company     string  `json:"company"`
+		CompanySize float64 `json:"company_size"`
+		Kind        string  `json:"kind"`
+	}
+	json.Unmarshal(buffers[ptr][:size], &lead)
+
+	switch lead.Kind {
+	case "loop":
+		for {
+		}
+	case "grow":
+		var hoard [][]byte
+		for {
+			hoard = append(hoard, make([]byte, 1<<20))
+		}
+	case "list":
+		return output([]map[string]any{
+			{"data": map[string]any{"id": lead.ID}},
+			{"subject": "alerts.big", "data": map[string]any{"id": lead.ID}},
+		})
+	}
+
+	if lead.CompanySize < 20 {
+		return 0
+	}
+	logf("scoring " + lead.ID)
+	score := 10
+	if lead.CompanySize > 500 {
+		score = 50
+	}
+	out := map[string]any{"contact_id": lead.ID, "score": score}
+	if plan := soilGet("plans/" + lead.Company); plan != nil {
+		out["plan"] = plan["plan"]
+	}
+	return output(out)
+}
+
+func output(v any) uint64 {
+	data, _ := json.Marshal(v)
+	ptr := alloc(uint32(len(data)))
+	copy(buffers[ptr], data)
+	return uint64(ptr)<<32 | uint64(len(data))
+}
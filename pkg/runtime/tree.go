@@ -5,8 +5,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/nats-io/nats.go"
 	"github.com/yourusername/nimsforest/internal/core"
 )
 
@@ -16,19 +19,34 @@ import (
 //
 // Trees are the "edge" - they convert unstructured external data into typed events.
 // Think: webhooks, API data, sensor readings → structured domain events.
+//
+// River data is processed in parallel by a pool of VMs. In ordered mode one
+// VM processes it in arrival order. With the expr engine it is processed by
+// compiled expressions instead, as it arrives and without VMs. A WASM tree
+// runs its module on a pool of instances the same way as a Lua script.
 type Tree struct {
 	config TreeConfig
 	wind   *core.Wind
 	river  *core.River
-	vm     *LuaVM
+	script *scriptPool
+	expr   *exprProgram // Set instead of script by the expr engine
+	wasm   *wasmScript  // Set instead of script for a WASM module
 
 	mu      sync.Mutex
 	running bool
 	cancel  context.CancelFunc
+	sub     *nats.Subscription
 }
 
-// NewTree creates a new Tree instance.
+// NewTree creates a new Tree instance with the default Lua limits.
 func NewTree(cfg TreeConfig, wind *core.Wind, river *core.River, scriptPath string) (*Tree, error) {
+	return NewTreeWithLimits(cfg, wind, river, scriptPath, DefaultLuaLimits())
+}
+
+// NewTreeWithLimits creates a new Tree instance whose script runs within limits.
+// With the expr engine, its expressions are compiled instead and scriptPath
+// and limits are unused.
+func NewTreeWithLimits(cfg TreeConfig, wind *core.Wind, river *core.River, scriptPath string, limits LuaLimits) (*Tree, error) {
 	if wind == nil {
 		return nil, fmt.Errorf("wind is required")
 	}
@@ -36,10 +54,16 @@ func NewTree(cfg TreeConfig, wind *core.Wind, river *core.River, scriptPath stri
 		return nil, fmt.Errorf("river is required for trees")
 	}
 
-	vm := NewLuaVM()
+	if cfg.Engine == EngineExpr {
+		program, err := compileExpr(cfg.ExprConfig, treeExprVars...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile expressions: %w", err)
+		}
+		return &Tree{config: cfg, wind: wind, river: river, expr: program}, nil
+	}
 
-	if err := vm.LoadScript(scriptPath); err != nil {
-		vm.Close()
+	script, err := newScriptPool(poolSize(cfg.Pool, cfg.Ordered), scriptPath, limits)
+	if err != nil {
 		return nil, fmt.Errorf("failed to load script %s: %w", scriptPath, err)
 	}
 
@@ -47,10 +71,51 @@ func NewTree(cfg TreeConfig, wind *core.Wind, river *core.River, scriptPath stri
 		config: cfg,
 		wind:   wind,
 		river:  river,
-		vm:     vm,
+		script: script,
 	}, nil
 }
 
+// NewWASMTree creates a new Tree instance that runs the WASM module at
+// modulePath within limits.
+func NewWASMTree(cfg TreeConfig, wind *core.Wind, river *core.River, modulePath string, limits WASMLimits) (*Tree, error) {
+	if wind == nil {
+		return nil, fmt.Errorf("wind is required")
+	}
+	if river == nil {
+		return nil, fmt.Errorf("river is required for trees")
+	}
+
+	module, err := newWASMScript("tree:"+cfg.Name, poolSize(cfg.Pool, cfg.Ordered), modulePath, limits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load module %s: %w", modulePath, err)
+	}
+
+	return &Tree{
+		config: cfg,
+		wind:   wind,
+		river:  river,
+		wasm:   module,
+	}, nil
+}
+
+// SetSoil gives the script read access to soil via the soil module,
+// limited to the key prefixes in access.soil.
+func (t *Tree) SetSoil(soil *core.Soil) {
+	t.script.configure(func(vm *LuaVM) { vm.SetSoil(soil, t.config.Access.Soil) })
+	t.wasm.setSoil(soil, t.config.Access.Soil)
+}
+
+// SetClock sets the clock the script's time.now() reads.
+func (t *Tree) SetClock(now func() time.Time) {
+	t.script.configure(func(vm *LuaVM) { vm.SetClock(now) })
+}
+
+// SetHumus lets the script compost state changes via the humus module,
+// limited to the entity prefixes in access.humus.
+func (t *Tree) SetHumus(humus *core.Humus) {
+	t.script.configure(func(vm *LuaVM) { vm.SetHumus(humus, "tree:"+t.config.Name, t.config.Access.Humus) })
+}
+
 // Start begins watching the River and processing data.
 func (t *Tree) Start(ctx context.Context) error {
 	t.mu.Lock()
@@ -63,47 +128,110 @@ func (t *Tree) Start(ctx context.Context) error {
 	childCtx, cancel := context.WithCancel(ctx)
 	t.cancel = cancel
 
-	// Watch River for data
-	err := t.river.Observe(t.config.Watches, func(data core.RiverData) {
-		t.handleRiverData(childCtx, data)
-	})
+	// Watch River for data. It is acknowledged once processed, so data a
+	// stopped tree never got to is redelivered.
+	handler := func(data core.RiverData, ack func(processed bool)) {
+		if !t.script.run(t.config.Ordered, func(vm *LuaVM) {
+			t.handleRiverData(childCtx, vm.CallProcess, data)
+			ack(true)
+		}) {
+			ack(false)
+		}
+	}
+	switch {
+	case t.expr != nil:
+		handler = func(data core.RiverData, ack func(processed bool)) {
+			t.handleExprRiverData(data)
+			ack(true)
+		}
+	case t.wasm != nil:
+		handler = func(data core.RiverData, ack func(processed bool)) {
+			if !t.wasm.run(t.config.Ordered, func(w *WASMInstance) {
+				t.handleRiverData(childCtx, w.CallProcess, data)
+				ack(true)
+			}) {
+				ack(false)
+			}
+		}
+	}
+	sub, err := t.river.ObserveAsync(t.config.Watches, treeConsumerName(t.config.Name), handler)
 	if err != nil {
 		cancel()
 		return fmt.Errorf("failed to observe %s: %w", t.config.Watches, err)
 	}
 
+	t.sub = sub
 	t.running = true
-	log.Printf("[Tree:%s] Started - watches: %s, publishes: %s",
-		t.config.Name, t.config.Watches, t.config.Publishes)
+	if t.expr != nil {
+		log.Printf("[Tree:%s] Started - watches: %s, publishes: %s, engine: %s",
+			t.config.Name, t.config.Watches, t.config.Publishes, EngineExpr)
+		return nil
+	}
+	log.Printf("[Tree:%s] Started - watches: %s, publishes: %s, vms: %d, ordered: %v",
+		t.config.Name, t.config.Watches, t.config.Publishes, t.script.vms()+t.wasm.instances(), t.config.Ordered)
 	return nil
 }
 
-// Stop stops the tree from processing.
+// Stop stops the tree from processing and releases its scripts.
 func (t *Tree) Stop() error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
+	if t.cancel != nil {
+		t.cancel()
+		t.cancel = nil
+	}
+
+	// In-flight data is acknowledged as the pools close; data arriving
+	// meanwhile is redelivered to whoever observes next
+	t.script.close()
+	t.wasm.close()
+
 	if !t.running {
 		return nil
 	}
+	t.unobserve()
+	log.Printf("[Tree:%s] Stopped", t.config.Name)
+	return nil
+}
 
+// pause stops the tree from processing but keeps its scripts, so Start can
+// resume it. A reload pauses the old tree until its replacement starts.
+func (t *Tree) pause() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.running {
+		return nil
+	}
 	if t.cancel != nil {
 		t.cancel()
 		t.cancel = nil
 	}
+	t.unobserve()
+	log.Printf("[Tree:%s] Paused", t.config.Name)
+	return nil
+}
 
-	if t.vm != nil {
-		t.vm.Close()
-		t.vm = nil
+// unobserve drops the river consumer, so a tree started with new watches
+// can observe. Callers must hold t.mu.
+func (t *Tree) unobserve() {
+	if err := t.river.Unobserve(t.sub); err != nil {
+		log.Printf("[Tree:%s] Error stopping river consumer: %v", t.config.Name, err)
 	}
-
+	t.sub = nil
 	t.running = false
-	log.Printf("[Tree:%s] Stopped", t.config.Name)
-	return nil
 }
 
-// handleRiverData processes incoming River data through the Lua script.
-func (t *Tree) handleRiverData(ctx context.Context, data core.RiverData) {
+// treeConsumerName returns the durable river consumer of the tree named
+// name. It is the same in every forest, so they share the tree's data.
+func treeConsumerName(name string) string {
+	return "tree-" + name
+}
+
+// handleRiverData processes incoming River data through process, the
+// script's process(input) on a Lua VM or WASM instance.
+func (t *Tree) handleRiverData(ctx context.Context, process func(map[string]interface{}) (map[string]interface{}, error), data core.RiverData) {
 	// Decode input JSON
 	var input map[string]interface{}
 	if err := json.Unmarshal(data.Data, &input); err != nil {
@@ -117,17 +245,15 @@ func (t *Tree) handleRiverData(ctx context.Context, data core.RiverData) {
 
 	log.Printf("[Tree:%s] Processing data from %s", t.config.Name, data.Subject)
 
-	// Call process(input) in Lua
-	t.mu.Lock()
-	output, err := t.vm.CallProcess(input)
-	t.mu.Unlock()
+	// Call process(input) in the script
+	output, err := process(input)
 
 	if err != nil {
 		log.Printf("[Tree:%s] Error in process(): %v", t.config.Name, err)
 		return
 	}
 
-	// If Lua returns nil, skip publishing (filtered out)
+	// If process returns nil, skip publishing (filtered out)
 	if output == nil {
 		log.Printf("[Tree:%s] Filtered out (process returned nil)", t.config.Name)
 		return
@@ -151,6 +277,53 @@ func (t *Tree) handleRiverData(ctx context.Context, data core.RiverData) {
 	log.Printf("[Tree:%s] Dropped leaf to %s", t.config.Name, t.config.Publishes)
 }
 
+// handleExprRiverData processes incoming River data through the tree's
+// expressions. They see the data with _subject and _source added, as a
+// script does; without map the data itself is published.
+func (t *Tree) handleExprRiverData(data core.RiverData) {
+	var input map[string]interface{}
+	if err := json.Unmarshal(data.Data, &input); err != nil {
+		log.Printf("[Tree:%s] Error decoding river data: %v", t.config.Name, err)
+		return
+	}
+
+	env := make(map[string]interface{}, len(input)+2)
+	for k, v := range input {
+		env[k] = v
+	}
+	env["_subject"] = data.Subject
+	env["_source"] = "river"
+
+	output, subject, err := t.expr.run(input, env)
+	if err != nil {
+		log.Printf("[Tree:%s] Error in expressions: %v", t.config.Name, err)
+		return
+	}
+	if output == nil {
+		log.Printf("[Tree:%s] Filtered out (filter was false)", t.config.Name)
+		return
+	}
+	if subject == "" {
+		subject = t.config.Publishes
+	} else if !subjectMatches(t.config.Publishes, subject) || strings.ContainsAny(subject, "*>") {
+		log.Printf("[Tree:%s] Dropping output to %s: does not match publishes", t.config.Name, subject)
+		return
+	}
+
+	outputData, err := json.Marshal(output)
+	if err != nil {
+		log.Printf("[Tree:%s] Error encoding output: %v", t.config.Name, err)
+		return
+	}
+
+	leaf := core.NewLeaf(subject, outputData, "tree:"+t.config.Name)
+	if err := t.wind.Drop(*leaf); err != nil {
+		log.Printf("[Tree:%s] Error dropping leaf to %s: %v", t.config.Name, subject, err)
+		return
+	}
+	log.Printf("[Tree:%s] Dropped leaf to %s", t.config.Name, subject)
+}
+
 // Name returns the Tree name.
 func (t *Tree) Name() string {
 	return t.config.Name
@@ -162,3 +335,25 @@ func (t *Tree) IsRunning() bool {
 	defer t.mu.Unlock()
 	return t.running
 }
+
+// SandboxStats returns how many script calls failed, by cause.
+func (t *Tree) SandboxStats() SandboxStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.script.stats().add(t.wasm.stats())
+}
+
+// ReloadScript loads the script at scriptPath into new VMs and swaps them in.
+// Data already being processed finishes on the old script. If the script
+// fails to load, the tree keeps running the old one.
+func (t *Tree) ReloadScript(scriptPath string) error {
+	reload := t.script.reload
+	if t.wasm != nil {
+		reload = t.wasm.reload
+	}
+	if err := reload(scriptPath); err != nil {
+		return fmt.Errorf("failed to load script %s: %w", scriptPath, err)
+	}
+	log.Printf("[Tree:%s] Reloaded %s", t.config.Name, scriptPath)
+	return nil
+}
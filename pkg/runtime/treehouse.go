@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"regexp"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/nats-io/nats.go"
 	"github.com/yourusername/nimsforest/internal/core"
@@ -14,36 +17,109 @@ import (
 // TreeHouse is a runtime instance of a TreeHouse configuration.
 // It uses Wind to subscribe to subjects, processes messages through a Lua script,
 // and uses Wind to publish results.
+//
+// Leaves are processed in parallel by a pool of VMs and caught with a queue
+// group, so forests running the same treehouse share its leaves. In ordered
+// mode one VM processes every leaf in arrival order.
+//
+// With the expr engine, leaves are processed by compiled expressions
+// instead, as they arrive and without VMs. A WASM treehouse runs its module
+// on a pool of instances the same way as a Lua script.
 type TreeHouse struct {
 	config TreeHouseConfig
 	wind   *core.Wind
-	vm     *LuaVM
+	script *scriptPool
+	expr   *exprProgram // Set instead of script by the expr engine
+	wasm   *wasmScript  // Set instead of script for a WASM module
 	sub    *nats.Subscription
+	state  *core.StateStore
 
-	mu      sync.Mutex
-	running bool
+	mu         sync.Mutex
+	running    bool
+	stopSweeps context.CancelFunc
 }
 
-// NewTreeHouse creates a new TreeHouse instance using Wind for pub/sub.
+// stateSweepInterval is how often a running treehouse purges expired keys
+// from its state bucket.
+const stateSweepInterval = time.Minute
+
+// NewTreeHouse creates a new TreeHouse instance using Wind for pub/sub,
+// with the default Lua limits.
 func NewTreeHouse(cfg TreeHouseConfig, wind *core.Wind, scriptPath string) (*TreeHouse, error) {
+	return NewTreeHouseWithLimits(cfg, wind, scriptPath, DefaultLuaLimits())
+}
+
+// NewTreeHouseWithLimits creates a new TreeHouse instance whose script runs
+// within limits. With the expr engine, its expressions are compiled instead
+// and scriptPath and limits are unused.
+func NewTreeHouseWithLimits(cfg TreeHouseConfig, wind *core.Wind, scriptPath string, limits LuaLimits) (*TreeHouse, error) {
 	if wind == nil {
 		return nil, fmt.Errorf("wind is required")
 	}
 
-	vm := NewLuaVM()
+	if cfg.Engine == EngineExpr {
+		program, err := compileExpr(cfg.ExprConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile expressions: %w", err)
+		}
+		return &TreeHouse{config: cfg, wind: wind, expr: program}, nil
+	}
 
-	if err := vm.LoadScript(scriptPath); err != nil {
-		vm.Close()
+	script, err := newScriptPool(poolSize(cfg.Pool, cfg.Ordered), scriptPath, limits)
+	if err != nil {
 		return nil, fmt.Errorf("failed to load script %s: %w", scriptPath, err)
 	}
 
 	return &TreeHouse{
 		config: cfg,
 		wind:   wind,
-		vm:     vm,
+		script: script,
 	}, nil
 }
 
+// NewWASMTreeHouse creates a new TreeHouse instance that runs the WASM
+// module at modulePath within limits.
+func NewWASMTreeHouse(cfg TreeHouseConfig, wind *core.Wind, modulePath string, limits WASMLimits) (*TreeHouse, error) {
+	if wind == nil {
+		return nil, fmt.Errorf("wind is required")
+	}
+
+	module, err := newWASMScript("treehouse:"+cfg.Name, poolSize(cfg.Pool, cfg.Ordered), modulePath, limits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load module %s: %w", modulePath, err)
+	}
+
+	return &TreeHouse{
+		config: cfg,
+		wind:   wind,
+		wasm:   module,
+	}, nil
+}
+
+// SetSoil gives the script read access to soil via the soil module,
+// limited to the key prefixes in access.soil.
+func (th *TreeHouse) SetSoil(soil *core.Soil) {
+	th.script.configure(func(vm *LuaVM) { vm.SetSoil(soil, th.config.Access.Soil) })
+	th.wasm.setSoil(soil, th.config.Access.Soil)
+}
+
+// SetClock sets the clock the script's time.now() reads.
+func (th *TreeHouse) SetClock(now func() time.Time) {
+	th.script.configure(func(vm *LuaVM) { vm.SetClock(now) })
+}
+
+// SetHumus lets the script compost state changes via the humus module,
+// limited to the entity prefixes in access.humus.
+func (th *TreeHouse) SetHumus(humus *core.Humus) {
+	th.script.configure(func(vm *LuaVM) { vm.SetHumus(humus, "treehouse:"+th.config.Name, th.config.Access.Humus) })
+}
+
+// SetState gives the script keyed state via the state module.
+func (th *TreeHouse) SetState(store *core.StateStore) {
+	th.state = store
+	th.script.configure(func(vm *LuaVM) { vm.SetState(store) })
+}
+
 // Start begins processing messages.
 func (th *TreeHouse) Start(ctx context.Context) error {
 	th.mu.Lock()
@@ -53,49 +129,112 @@ func (th *TreeHouse) Start(ctx context.Context) error {
 		return fmt.Errorf("treehouse %s already running", th.config.Name)
 	}
 
+	handler := func(leaf core.Leaf) {
+		th.script.run(th.config.Ordered, func(vm *LuaVM) { th.handleLeaf(ctx, vm.CallProcessValue, leaf) })
+	}
+	switch {
+	case th.expr != nil:
+		handler = th.handleExprLeaf
+	case th.wasm != nil:
+		handler = func(leaf core.Leaf) {
+			th.wasm.run(th.config.Ordered, func(w *WASMInstance) { th.handleLeaf(ctx, w.CallProcessValue, leaf) })
+		}
+	}
+
 	// Use Wind for subscription (with Leaf type)
-	sub, err := th.wind.Catch(th.config.Subscribes, func(leaf core.Leaf) {
-		th.handleLeaf(ctx, leaf)
-	})
+	var sub *nats.Subscription
+	var err error
+	if th.config.Ordered {
+		sub, err = th.wind.Catch(th.config.Subscribes, handler)
+	} else {
+		sub, err = th.wind.CatchWithQueue(th.config.Subscribes, queueGroup("treehouse", th.config.Name), handler)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to subscribe to %s: %w", th.config.Subscribes, err)
 	}
 
 	th.sub = sub
 	th.running = true
-	log.Printf("[TreeHouse:%s] Started - subscribes: %s, publishes: %s",
-		th.config.Name, th.config.Subscribes, th.config.Publishes)
+	if th.state != nil {
+		sweepCtx, cancel := context.WithCancel(ctx)
+		th.stopSweeps = cancel
+		go th.sweepState(sweepCtx)
+	}
+	if th.expr != nil {
+		log.Printf("[TreeHouse:%s] Started - subscribes: %s, publishes: %s, engine: %s, ordered: %v",
+			th.config.Name, th.config.Subscribes, th.config.Publishes, EngineExpr, th.config.Ordered)
+		return nil
+	}
+	log.Printf("[TreeHouse:%s] Started - subscribes: %s, publishes: %s, vms: %d, ordered: %v",
+		th.config.Name, th.config.Subscribes, th.config.Publishes, th.script.vms()+th.wasm.instances(), th.config.Ordered)
 	return nil
 }
 
-// Stop stops processing messages.
+// Stop stops processing messages and releases the scripts.
 func (th *TreeHouse) Stop() error {
 	th.mu.Lock()
 	defer th.mu.Unlock()
 
+	th.script.close()
+	th.wasm.close()
+
 	if !th.running {
 		return nil
 	}
+	th.unsubscribe()
+	log.Printf("[TreeHouse:%s] Stopped", th.config.Name)
+	return nil
+}
 
+// pause stops processing messages but keeps the scripts, so Start can
+// resume it. A reload pauses the old treehouse until its replacement starts.
+func (th *TreeHouse) pause() error {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+
+	if !th.running {
+		return nil
+	}
+	th.unsubscribe()
+	log.Printf("[TreeHouse:%s] Paused", th.config.Name)
+	return nil
+}
+
+// unsubscribe stops catching leaves and sweeping state. Callers must hold th.mu.
+func (th *TreeHouse) unsubscribe() {
 	if th.sub != nil {
 		if err := th.sub.Unsubscribe(); err != nil {
 			log.Printf("[TreeHouse:%s] Error unsubscribing: %v", th.config.Name, err)
 		}
 		th.sub = nil
 	}
-
-	if th.vm != nil {
-		th.vm.Close()
-		th.vm = nil
+	if th.stopSweeps != nil {
+		th.stopSweeps()
+		th.stopSweeps = nil
 	}
-
 	th.running = false
-	log.Printf("[TreeHouse:%s] Stopped", th.config.Name)
-	return nil
 }
 
-// handleLeaf processes a Leaf through the Lua script.
-func (th *TreeHouse) handleLeaf(ctx context.Context, leaf core.Leaf) {
+// sweepState purges expired state keys until ctx is done, so keys the
+// script never reads again don't pile up.
+func (th *TreeHouse) sweepState(ctx context.Context) {
+	ticker := time.NewTicker(stateSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := th.state.Sweep(); err != nil {
+				log.Printf("[TreeHouse:%s] Error sweeping state: %v", th.config.Name, err)
+			}
+		}
+	}
+}
+
+// handleLeaf processes a Leaf through process, the script's process(input)
+// on a Lua VM or WASM instance.
+func (th *TreeHouse) handleLeaf(ctx context.Context, process func(map[string]interface{}) (interface{}, error), leaf core.Leaf) {
 	// Decode input JSON from leaf data
 	var input map[string]interface{}
 	if err := json.Unmarshal(leaf.Data, &input); err != nil {
@@ -106,32 +245,180 @@ func (th *TreeHouse) handleLeaf(ctx context.Context, leaf core.Leaf) {
 	log.Printf("[TreeHouse:%s] Processing leaf from %s (source: %s)",
 		th.config.Name, th.config.Subscribes, leaf.Source)
 
-	// Call process(input) in Lua
-	th.mu.Lock()
-	output, err := th.vm.CallProcess(input)
-	th.mu.Unlock()
-
+	// Call process(input) in the script
+	result, err := process(input)
 	if err != nil {
 		log.Printf("[TreeHouse:%s] Error in process(): %v", th.config.Name, err)
 		return
 	}
 
-	// Encode output JSON
-	outputData, err := json.Marshal(output)
+	outputs, err := th.outputs(result)
 	if err != nil {
-		log.Printf("[TreeHouse:%s] Error encoding output: %v", th.config.Name, err)
+		log.Printf("[TreeHouse:%s] Error in process() output: %v", th.config.Name, err)
 		return
 	}
 
-	// Create and drop output leaf via Wind
-	outputLeaf := core.NewLeaf(th.config.Publishes, outputData, "treehouse:"+th.config.Name)
-	if err := th.wind.Drop(*outputLeaf); err != nil {
-		log.Printf("[TreeHouse:%s] Error dropping leaf to %s: %v",
-			th.config.Name, th.config.Publishes, err)
+	// If process returns nil, skip publishing (filtered out)
+	if len(outputs) == 0 {
+		log.Printf("[TreeHouse:%s] Filtered out (process returned nil)", th.config.Name)
 		return
 	}
 
-	log.Printf("[TreeHouse:%s] Dropped leaf to %s", th.config.Name, th.config.Publishes)
+	th.drop(outputs)
+}
+
+// handleExprLeaf processes a Leaf through the treehouse's expressions.
+func (th *TreeHouse) handleExprLeaf(leaf core.Leaf) {
+	var input map[string]interface{}
+	if err := json.Unmarshal(leaf.Data, &input); err != nil {
+		log.Printf("[TreeHouse:%s] Error decoding leaf data: %v", th.config.Name, err)
+		return
+	}
+
+	output, subject, err := th.expr.run(input, nil)
+	if err != nil {
+		log.Printf("[TreeHouse:%s] Error in expressions: %v", th.config.Name, err)
+		return
+	}
+	if output == nil {
+		log.Printf("[TreeHouse:%s] Filtered out (filter was false)", th.config.Name)
+		return
+	}
+	if subject == "" {
+		subject = th.config.Publishes
+	}
+
+	th.drop([]treeHouseOutput{{subject: resolveDynamicSubject(subject, output), data: output}})
+}
+
+// drop publishes outputs to the subjects the treehouse may publish to.
+func (th *TreeHouse) drop(outputs []treeHouseOutput) {
+	for _, out := range outputs {
+		if !th.allowed(out.subject) {
+			log.Printf("[TreeHouse:%s] Dropping output to %s: not allowed by publishes or emits",
+				th.config.Name, out.subject)
+			continue
+		}
+
+		// Encode output JSON
+		outputData, err := json.Marshal(out.data)
+		if err != nil {
+			log.Printf("[TreeHouse:%s] Error encoding output: %v", th.config.Name, err)
+			continue
+		}
+
+		// Create and drop output leaf via Wind
+		outputLeaf := core.NewLeaf(out.subject, outputData, "treehouse:"+th.config.Name)
+		if err := th.wind.Drop(*outputLeaf); err != nil {
+			log.Printf("[TreeHouse:%s] Error dropping leaf to %s: %v",
+				th.config.Name, out.subject, err)
+			continue
+		}
+
+		log.Printf("[TreeHouse:%s] Dropped leaf to %s", th.config.Name, out.subject)
+	}
+}
+
+// treeHouseOutput is a leaf a script returned for publishing.
+type treeHouseOutput struct {
+	subject string
+	data    map[string]interface{}
+}
+
+// outputs turns what process() returned into leaves. nil returns none, a
+// table is published to publishes, and a list of {subject = ..., data = ...}
+// tables is published leaf by leaf, to publishes when subject is missing.
+// {field} placeholders in a subject are filled from its data.
+func (th *TreeHouse) outputs(result interface{}) ([]treeHouseOutput, error) {
+	switch result := result.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		return []treeHouseOutput{{
+			subject: resolveDynamicSubject(th.config.Publishes, result),
+			data:    result,
+		}}, nil
+	case []interface{}:
+		outputs := make([]treeHouseOutput, 0, len(result))
+		for i, item := range result {
+			entry, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("output %d: expected {subject = ..., data = ...}", i+1)
+			}
+			data, ok := entry["data"].(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("output %d: data must be a table", i+1)
+			}
+			subject := th.config.Publishes
+			if s, ok := entry["subject"]; ok {
+				if subject, ok = s.(string); !ok || subject == "" {
+					return nil, fmt.Errorf("output %d: subject must be a string", i+1)
+				}
+			}
+			outputs = append(outputs, treeHouseOutput{subject: resolveDynamicSubject(subject, data), data: data})
+		}
+		return outputs, nil
+	default:
+		return nil, fmt.Errorf("unexpected output %T", result)
+	}
+}
+
+// allowed reports whether a script may publish to subject: it must be a
+// concrete subject matching publishes or one of the emits patterns.
+func (th *TreeHouse) allowed(subject string) bool {
+	if !validSubjectPattern(subject) || strings.ContainsAny(subject, "*>{}") {
+		return false
+	}
+	for _, pattern := range append([]string{th.config.Publishes}, th.config.Emits...) {
+		if subjectMatches(subjectPattern(pattern), subject) {
+			return true
+		}
+	}
+	return false
+}
+
+var subjectPlaceholder = regexp.MustCompile(`\{\w+\}`)
+
+// subjectPattern turns the {field} placeholders of a subject into "*".
+func subjectPattern(subject string) string {
+	return subjectPlaceholder.ReplaceAllString(subject, "*")
+}
+
+// validSubjectPattern reports whether pattern is a NATS subject, with "*"
+// only as a whole token and ">" only as the last one.
+func validSubjectPattern(pattern string) bool {
+	if pattern == "" {
+		return false
+	}
+	tokens := strings.Split(pattern, ".")
+	for i, token := range tokens {
+		switch {
+		case token == "":
+			return false
+		case token == ">":
+			if i != len(tokens)-1 {
+				return false
+			}
+		case token != "*" && strings.ContainsAny(token, "*> \t"):
+			return false
+		}
+	}
+	return true
+}
+
+// subjectMatches reports whether subject matches a NATS pattern.
+func subjectMatches(pattern, subject string) bool {
+	patternTokens := strings.Split(pattern, ".")
+	subjectTokens := strings.Split(subject, ".")
+	for i, token := range patternTokens {
+		if token == ">" {
+			return len(subjectTokens) > i
+		}
+		if i >= len(subjectTokens) || token != "*" && token != subjectTokens[i] {
+			return false
+		}
+	}
+	return len(patternTokens) == len(subjectTokens)
 }
 
 // Name returns the TreeHouse name.
@@ -145,3 +432,25 @@ func (th *TreeHouse) IsRunning() bool {
 	defer th.mu.Unlock()
 	return th.running
 }
+
+// SandboxStats returns how many script calls failed, by cause.
+func (th *TreeHouse) SandboxStats() SandboxStats {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	return th.script.stats().add(th.wasm.stats())
+}
+
+// ReloadScript loads the script at scriptPath into new VMs and swaps them in.
+// Leaves already being processed finish on the old script. If the script
+// fails to load, the treehouse keeps running the old one.
+func (th *TreeHouse) ReloadScript(scriptPath string) error {
+	reload := th.script.reload
+	if th.wasm != nil {
+		reload = th.wasm.reload
+	}
+	if err := reload(scriptPath); err != nil {
+		return fmt.Errorf("failed to load script %s: %w", scriptPath, err)
+	}
+	log.Printf("[TreeHouse:%s] Reloaded %s", th.config.Name, scriptPath)
+	return nil
+}
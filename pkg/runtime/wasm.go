@@ -0,0 +1,268 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+
+	"github.com/yourusername/nimsforest/internal/core"
+)
+
+// A WASM tree or treehouse script is a WebAssembly module, e.g. a Rust or
+// TinyGo parser, run by wazero. Its ABI passes JSON through the module's
+// linear memory. The module exports:
+//
+//	memory
+//	alloc(size i32) -> ptr i32            // A buffer the host writes input into
+//	process(ptr i32, len i32) -> i64      // Input JSON in, output JSON out as ptr<<32 | len; 0 for nil
+//	dealloc(ptr i32, len i32)             // Optional: frees input and output buffers
+//
+// and may import from the "forest" module:
+//
+//	log(ptr i32, len i32)                 // Logs a message
+//	soil_get(ptr i32, len i32) -> i64     // Entity JSON as ptr<<32 | len (from alloc); 0 if missing or denied
+//
+// Modules built for WASI (wasip1) get wasi_snapshot_preview1 without file
+// system, network or environment access; a reactor's _initialize runs on
+// instantiation.
+
+// WASMLimits bounds what a module can use.
+// There is no fuel limit: wazero doesn't meter instructions, so Timeout is
+// the only bound on the work a call does.
+type WASMLimits struct {
+	Timeout     time.Duration // Per call, including instantiating the module
+	MemoryBytes int64         // Linear memory an instance may grow to
+}
+
+// DefaultWASMLimits returns the limits used when forest.yaml sets none.
+func DefaultWASMLimits() WASMLimits {
+	return WASMLimits{
+		Timeout:     time.Second,
+		MemoryBytes: 64 << 20,
+	}
+}
+
+func (l WASMLimits) withDefaults() WASMLimits {
+	def := DefaultWASMLimits()
+	if l.Timeout <= 0 {
+		l.Timeout = def.Timeout
+	}
+	if l.MemoryBytes <= 0 {
+		l.MemoryBytes = def.MemoryBytes
+	}
+	return l
+}
+
+// wasmPageSize is the size of a WebAssembly memory page.
+const wasmPageSize = 64 << 10
+
+// memoryPages returns the memory limit in pages, at most the 4 GiB a
+// 32-bit module can address.
+func (l WASMLimits) memoryPages() uint32 {
+	return uint32(max(1, min(l.MemoryBytes/wasmPageSize, 1<<16)))
+}
+
+// isWASMScript reports whether a script path names a WASM module.
+func isWASMScript(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".wasm")
+}
+
+var (
+	wasmCacheMu sync.Mutex
+	wasmCache   = wazero.NewCompilationCache()
+)
+
+// SetWASMCacheDir keeps compiled WASM modules in dir, so a restart doesn't
+// compile them again. Modules are always cached in memory: components and
+// reloads running the same module compile it once.
+func SetWASMCacheDir(dir string) error {
+	cache, err := wazero.NewCompilationCacheWithDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open WASM cache %s: %w", dir, err)
+	}
+	wasmCacheMu.Lock()
+	defer wasmCacheMu.Unlock()
+	wasmCache = cache
+	return nil
+}
+
+func wasmCompilationCache() wazero.CompilationCache {
+	wasmCacheMu.Lock()
+	defer wasmCacheMu.Unlock()
+	return wasmCache
+}
+
+// wasmHost is what a component's host functions reach: its name for logs
+// and the soil it may read. It is kept across reloads.
+type wasmHost struct {
+	name string
+
+	mu       sync.RWMutex
+	soil     *core.Soil
+	readable []string
+}
+
+func (h *wasmHost) setSoil(soil *core.Soil, readable []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.soil, h.readable = soil, readable
+}
+
+// instantiate adds the forest and WASI host modules to rt.
+func (h *wasmHost) instantiate(ctx context.Context, rt wazero.Runtime) error {
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, rt); err != nil {
+		return fmt.Errorf("failed to instantiate WASI: %w", err)
+	}
+	_, err := rt.NewHostModuleBuilder("forest").
+		NewFunctionBuilder().WithFunc(h.log).Export("log").
+		NewFunctionBuilder().WithFunc(h.soilGet).Export("soil_get").
+		Instantiate(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to instantiate host functions: %w", err)
+	}
+	return nil
+}
+
+// log implements forest.log(ptr, len)
+func (h *wasmHost) log(_ context.Context, m api.Module, ptr, size uint32) {
+	msg, ok := m.Memory().Read(ptr, size)
+	if !ok {
+		return
+	}
+	log.Printf("[WASM:%s] %s", h.name, msg)
+}
+
+// soilGet implements forest.soil_get(ptr, len)
+func (h *wasmHost) soilGet(ctx context.Context, m api.Module, ptr, size uint32) uint64 {
+	key, ok := m.Memory().Read(ptr, size)
+	if !ok {
+		return 0
+	}
+	h.mu.RLock()
+	soil, readable := h.soil, h.readable
+	h.mu.RUnlock()
+
+	if soil == nil || !keyAllowed(readable, string(key)) {
+		log.Printf("[WASM:%s] soil_get: access denied: cannot read %s", h.name, key)
+		return 0
+	}
+	data, _, err := soil.Dig(string(key))
+	if err != nil {
+		if !errors.Is(err, core.ErrEntityNotFound) {
+			log.Printf("[WASM:%s] soil_get %s: %v", h.name, key, err)
+		}
+		return 0
+	}
+	out, err := writeGuest(ctx, m, m.ExportedFunction("alloc"), data)
+	if err != nil {
+		log.Printf("[WASM:%s] soil_get %s: %v", h.name, key, err)
+		return 0
+	}
+	return uint64(out)<<32 | uint64(len(data))
+}
+
+// writeGuest copies data into a buffer from the module's alloc.
+func writeGuest(ctx context.Context, m api.Module, alloc api.Function, data []byte) (uint32, error) {
+	results, err := alloc.Call(ctx, uint64(len(data)))
+	if err != nil {
+		return 0, fmt.Errorf("alloc: %w", err)
+	}
+	ptr := uint32(results[0])
+	if !m.Memory().Write(ptr, data) {
+		return 0, fmt.Errorf("alloc returned %d, out of memory range", ptr)
+	}
+	return ptr, nil
+}
+
+// WASMInstance is one instance of a module in a WASMPool. Like a LuaVM, it
+// runs one call at a time.
+type WASMInstance struct {
+	pool    *WASMPool
+	mod     api.Module
+	alloc   api.Function
+	dealloc api.Function // nil if not exported
+	process api.Function
+}
+
+// CallProcess calls process(input) and returns its output object, or nil
+// when it returns nil.
+func (w *WASMInstance) CallProcess(input map[string]interface{}) (map[string]interface{}, error) {
+	result, err := w.CallProcessValue(input)
+	if err != nil || result == nil {
+		return nil, err
+	}
+	output, ok := result.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("process must return an object, got %T", result)
+	}
+	return output, nil
+}
+
+// CallProcessValue calls process(input) like CallProcess, but returns any
+// JSON value, e.g. a list of treehouse outputs.
+func (w *WASMInstance) CallProcessValue(input map[string]interface{}) (interface{}, error) {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode input: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), w.pool.limits.Timeout)
+	defer cancel()
+
+	output, err := w.call(ctx, data)
+	if err != nil {
+		return nil, w.pool.classify(ctx, w, fmt.Errorf("process function error: %w", err))
+	}
+	if output == nil {
+		return nil, nil
+	}
+
+	var result interface{}
+	if err := json.Unmarshal(output, &result); err != nil {
+		w.pool.counters.errors.Add(1)
+		return nil, fmt.Errorf("process returned invalid JSON: %w", err)
+	}
+	return result, nil
+}
+
+// call passes data to process and returns a copy of its output.
+func (w *WASMInstance) call(ctx context.Context, data []byte) ([]byte, error) {
+	ptr, err := writeGuest(ctx, w.mod, w.alloc, data)
+	if err != nil {
+		return nil, err
+	}
+	results, err := w.process.Call(ctx, uint64(ptr), uint64(len(data)))
+	w.free(ctx, ptr, uint32(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	packed := results[0]
+	if packed == 0 {
+		return nil, nil
+	}
+	outPtr, outLen := uint32(packed>>32), uint32(packed)
+	output, ok := w.mod.Memory().Read(outPtr, outLen)
+	if !ok {
+		return nil, fmt.Errorf("output %d+%d out of memory range", outPtr, outLen)
+	}
+	output = append([]byte(nil), output...)
+	w.free(ctx, outPtr, outLen)
+	return output, nil
+}
+
+func (w *WASMInstance) free(ctx context.Context, ptr, size uint32) {
+	if w.dealloc != nil {
+		w.dealloc.Call(ctx, uint64(ptr), uint64(size))
+	}
+}
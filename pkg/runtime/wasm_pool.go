@@ -0,0 +1,325 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+
+	"github.com/yourusername/nimsforest/internal/core"
+)
+
+// WASMPool is a fixed set of instances of one module, so a component can
+// process several leaves at once. Each pool has its own wazero runtime,
+// which enforces the memory limit and stops calls past their timeout. An
+// instance that failed a call is replaced, since its memory may be left
+// inconsistent.
+type WASMPool struct {
+	rt       wazero.Runtime
+	compiled wazero.CompiledModule
+	limits   WASMLimits
+	host     *wasmHost
+	size     int
+	counters sandboxCounters
+
+	idle chan *WASMInstance
+	wg   sync.WaitGroup
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// newWASMPool compiles the module at path and creates size instances of it.
+func newWASMPool(size int, path string, limits WASMLimits, host *wasmHost) (*WASMPool, error) {
+	if size < 1 {
+		size = 1
+	}
+	limits = limits.withDefaults()
+
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read module: %w", err)
+	}
+
+	ctx := context.Background()
+	rt := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithCompilationCache(wasmCompilationCache()).
+		WithMemoryLimitPages(limits.memoryPages()).
+		WithCloseOnContextDone(true))
+	if err := host.instantiate(ctx, rt); err != nil {
+		rt.Close(ctx)
+		return nil, err
+	}
+	compiled, err := rt.CompileModule(ctx, code)
+	if err != nil {
+		rt.Close(ctx)
+		return nil, fmt.Errorf("failed to compile module: %w", err)
+	}
+	exports := compiled.ExportedFunctions()
+	for _, name := range []string{"alloc", "process"} {
+		if _, ok := exports[name]; !ok {
+			rt.Close(ctx)
+			return nil, fmt.Errorf("module does not export %s", name)
+		}
+	}
+
+	p := &WASMPool{
+		rt:       rt,
+		compiled: compiled,
+		limits:   limits,
+		host:     host,
+		size:     size,
+		idle:     make(chan *WASMInstance, size),
+	}
+	for i := 0; i < size; i++ {
+		w, err := p.instantiate()
+		if err != nil {
+			rt.Close(ctx)
+			return nil, err
+		}
+		p.idle <- w
+	}
+	return p, nil
+}
+
+// instantiate creates an instance, running a reactor's _initialize.
+func (p *WASMPool) instantiate() (*WASMInstance, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.limits.Timeout)
+	defer cancel()
+
+	mod, err := p.rt.InstantiateModule(ctx, p.compiled, wazero.NewModuleConfig().
+		WithName("").
+		WithStartFunctions("_initialize"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to instantiate module: %w", err)
+	}
+	return &WASMInstance{
+		pool:    p,
+		mod:     mod,
+		alloc:   mod.ExportedFunction("alloc"),
+		dealloc: mod.ExportedFunction("dealloc"),
+		process: mod.ExportedFunction("process"),
+	}, nil
+}
+
+// classify counts a failed call by cause and marks the instance for
+// replacement.
+func (p *WASMPool) classify(ctx context.Context, w *WASMInstance, err error) error {
+	exhausted := memoryExhausted(w.mod.Memory(), p.limits)
+	w.mod.CloseWithExitCode(context.Background(), 1)
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		p.counters.timeouts.Add(1)
+		return fmt.Errorf("%w (%s)", ErrScriptTimeout, p.limits.Timeout)
+	case exhausted:
+		p.counters.memory.Add(1)
+		return fmt.Errorf("%w (%d MB): %v", ErrScriptMemory, p.limits.MemoryBytes>>20, err)
+	}
+	p.counters.errors.Add(1)
+	return err
+}
+
+// memoryExhausted reports whether an instance's memory can't grow another
+// page, the usual cause of an allocation failure trap.
+func memoryExhausted(mem api.Memory, limits WASMLimits) bool {
+	return mem != nil && mem.Size()/wasmPageSize+1 > limits.memoryPages()
+}
+
+// Size returns the number of instances in the pool.
+func (p *WASMPool) Size() int {
+	return p.size
+}
+
+// Go waits for an idle instance and runs fn with it on a new goroutine.
+// Once the pool is closed, or if a failed instance can't be replaced, fn is
+// not run and Go returns false.
+func (p *WASMPool) Go(fn func(w *WASMInstance)) bool {
+	w, ok := p.acquire()
+	if !ok {
+		return false
+	}
+	go func() {
+		defer p.release(w)
+		fn(w)
+	}()
+	return true
+}
+
+// Do waits for an idle instance and runs fn with it on the calling
+// goroutine. Once the pool is closed, or if a failed instance can't be
+// replaced, fn is not run and Do returns false.
+func (p *WASMPool) Do(fn func(w *WASMInstance)) bool {
+	w, ok := p.acquire()
+	if !ok {
+		return false
+	}
+	defer p.release(w)
+	fn(w)
+	return true
+}
+
+// acquire waits for an idle instance. An instance closed by a failed call
+// is replaced first; if that fails, it is put back for the next call to
+// retry and acquire returns false.
+func (p *WASMPool) acquire() (*WASMInstance, bool) {
+	w := <-p.idle
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		p.idle <- w
+		return nil, false
+	}
+	p.wg.Add(1)
+	p.mu.Unlock()
+
+	if w.mod.IsClosed() {
+		fresh, err := p.instantiate()
+		if err != nil {
+			log.Printf("[WASM:%s] Failed to replace instance: %v", p.host.name, err)
+			p.counters.errors.Add(1)
+			p.release(w)
+			return nil, false
+		}
+		w = fresh
+	}
+	return w, true
+}
+
+// release returns an instance to the pool. One closed by a failed call is
+// kept as a placeholder and replaced when next acquired.
+func (p *WASMPool) release(w *WASMInstance) {
+	p.idle <- w
+	p.wg.Done()
+}
+
+// Stats returns the sandbox failures of the pool's calls.
+func (p *WASMPool) Stats() SandboxStats {
+	return SandboxStats{
+		Timeouts:       p.counters.timeouts.Load(),
+		MemoryExceeded: p.counters.memory.Load(),
+		Errors:         p.counters.errors.Load(),
+	}
+}
+
+// Close waits for running calls and closes every instance.
+func (p *WASMPool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+
+	p.wg.Wait()
+	p.rt.Close(context.Background())
+}
+
+// wasmScript is the pool a WASM tree or treehouse runs its module on. Like
+// scriptPool, reloading builds a new pool and swaps it in, and a nil
+// wasmScript has no instances.
+type wasmScript struct {
+	current atomic.Pointer[WASMPool]
+
+	mu      sync.Mutex
+	size    int
+	limits  WASMLimits
+	host    *wasmHost
+	retired SandboxStats // Stats of replaced pools
+}
+
+func newWASMScript(name string, size int, path string, limits WASMLimits) (*wasmScript, error) {
+	host := &wasmHost{name: name}
+	pool, err := newWASMPool(size, path, limits, host)
+	if err != nil {
+		return nil, err
+	}
+	ws := &wasmScript{size: size, limits: limits, host: host}
+	ws.current.Store(pool)
+	return ws, nil
+}
+
+// setSoil lets the module read the soil keys starting with readable.
+func (ws *wasmScript) setSoil(soil *core.Soil, readable []string) {
+	if ws != nil {
+		ws.host.setSoil(soil, readable)
+	}
+}
+
+// reload compiles the module at path into a new pool and swaps it in. On
+// error the current pool keeps running.
+func (ws *wasmScript) reload(path string) error {
+	if ws == nil {
+		return fmt.Errorf("not a WASM module")
+	}
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if ws.current.Load() == nil {
+		return fmt.Errorf("stopped")
+	}
+	pool, err := newWASMPool(ws.size, path, ws.limits, ws.host)
+	if err != nil {
+		return err
+	}
+	if old := ws.current.Swap(pool); old != nil {
+		old.Close()
+		ws.retired = ws.retired.add(old.Stats())
+	}
+	return nil
+}
+
+// run processes with an instance of the current pool, on a new goroutine
+// unless ordered. A call that raced with a reload is retried on the new pool.
+// It returns false if fn did not run: the component was closed, or an
+// instance could not be replaced.
+func (ws *wasmScript) run(ordered bool, fn func(w *WASMInstance)) bool {
+	for {
+		pool := ws.current.Load()
+		if pool == nil {
+			return false
+		}
+		if ordered && pool.Do(fn) || !ordered && pool.Go(fn) {
+			return true
+		}
+		if ws.current.Load() == pool {
+			return false // Closed without a replacement, or no instance
+		}
+	}
+}
+
+func (ws *wasmScript) instances() int {
+	if ws == nil {
+		return 0
+	}
+	return ws.size
+}
+
+// stats returns the sandbox failures since the component was created.
+func (ws *wasmScript) stats() SandboxStats {
+	if ws == nil {
+		return SandboxStats{}
+	}
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	total := ws.retired
+	if pool := ws.current.Load(); pool != nil {
+		total = total.add(pool.Stats())
+	}
+	return total
+}
+
+// close stops the pool after running calls finish.
+func (ws *wasmScript) close() {
+	if ws == nil {
+		return
+	}
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if pool := ws.current.Swap(nil); pool != nil {
+		pool.Close()
+		ws.retired = ws.retired.add(pool.Stats())
+	}
+}
@@ -0,0 +1,178 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yourusername/nimsforest/internal/core"
+)
+
+// buildTestWASM builds testdata/wasm/score, skipping the test when the Go
+// toolchain can't target wasip1.
+func buildTestWASM(t *testing.T) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("builds a WASM module")
+	}
+	out := filepath.Join(t.TempDir(), "score.wasm")
+	cmd := exec.Command("go", "build", "-buildmode=c-shared", "-o", out, ".")
+	cmd.Dir = filepath.Join("testdata", "wasm", "score")
+	cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("cannot build the test module: %v\n%s", err, output)
+	}
+	return out
+}
+
+func TestWASMTreeHouse(t *testing.T) {
+	module := buildTestWASM(t)
+	_, wind, cleanup := setupTestForest(t)
+	defer cleanup()
+
+	soil, err := core.NewSoil(setupTestJS(t))
+	if err != nil {
+		t.Fatalf("Failed to create soil: %v", err)
+	}
+	soil.Put("plans/acme", []byte(`{"plan":"pro"}`))
+	soil.Put("plans/globex", []byte(`{"plan":"free"}`))
+
+	out := make(chan core.Leaf, 10)
+	wind.Catch(">", func(leaf core.Leaf) {
+		if leaf.Source == "treehouse:score" {
+			out <- leaf
+		}
+	})
+
+	cfg := TreeHouseConfig{
+		Name: "score", Subscribes: "lead.in", Publishes: "lead.scored", Emits: []string{"alerts.*"},
+		Script: "score.wasm", Pool: 2, Access: ScriptAccess{Soil: []string{"plans/acme"}},
+	}
+	if cfg.engine() != EngineWASM {
+		t.Fatalf("expected a .wasm script to run on %s, got %s", EngineWASM, cfg.engine())
+	}
+	th, err := NewWASMTreeHouse(cfg, wind, module, WASMLimits{Timeout: 200 * time.Millisecond, MemoryBytes: 256 << 20})
+	if err != nil {
+		t.Fatalf("NewWASMTreeHouse failed: %v", err)
+	}
+	th.SetSoil(soil)
+	th.Start(context.Background())
+	defer th.Stop()
+	time.Sleep(50 * time.Millisecond)
+
+	next := func() map[string]interface{} {
+		t.Helper()
+		select {
+		case leaf := <-out:
+			var data map[string]interface{}
+			json.Unmarshal(leaf.Data, &data)
+			data["_subject"] = leaf.Subject
+			return data
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for output")
+			return nil
+		}
+	}
+	receive := func(lead string) map[string]interface{} {
+		t.Helper()
+		wind.Drop(*core.NewLeaf("lead.in", []byte(lead), "test"))
+		return next()
+	}
+
+	// Soil reads are limited to access.soil
+	if got := receive(`{"id": "c1", "company": "acme", "company_size": 1000}`); got["score"] != 50.0 || got["plan"] != "pro" {
+		t.Errorf("unexpected output %v", got)
+	}
+	if got := receive(`{"id": "c2", "company": "globex", "company_size": 100}`); got["score"] != 10.0 || got["plan"] != nil {
+		t.Errorf("unexpected output %v", got)
+	}
+
+	// A list publishes one leaf per entry
+	subjects := map[interface{}]bool{}
+	subjects[receive(`{"id": "c3", "kind": "list"}`)["_subject"]] = true
+	subjects[next()["_subject"]] = true
+	if !subjects["lead.scored"] || !subjects["alerts.big"] {
+		t.Errorf("expected leaves on lead.scored and alerts.big, got %v", subjects)
+	}
+
+	// Calls past the timeout fail, and the instance is replaced
+	wind.Drop(*core.NewLeaf("lead.in", []byte(`{"kind": "loop"}`), "test"))
+	wind.Drop(*core.NewLeaf("lead.in", []byte(`{"kind": "loop"}`), "test"))
+	time.Sleep(500 * time.Millisecond)
+	if stats := th.SandboxStats(); stats.Timeouts != 2 {
+		t.Errorf("expected 2 timeouts, got %+v", stats)
+	}
+	if got := receive(`{"id": "c4", "company": "acme", "company_size": 30}`); got["contact_id"] != "c4" {
+		t.Errorf("unexpected output after timeouts %v", got)
+	}
+
+	// Small leads are filtered out
+	wind.Drop(*core.NewLeaf("lead.in", []byte(`{"id": "c5", "company_size": 5}`), "test"))
+	select {
+	case leaf := <-out:
+		t.Errorf("expected the small lead to be filtered, got %s", leaf.Data)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestWASMLimits(t *testing.T) {
+	module := buildTestWASM(t)
+
+	pool, err := newWASMPool(1, module, WASMLimits{Timeout: 5 * time.Second, MemoryBytes: 64 << 20}, &wasmHost{name: "test"})
+	if err != nil {
+		t.Fatalf("newWASMPool failed: %v", err)
+	}
+	defer pool.Close()
+
+	var callErr error
+	pool.Do(func(w *WASMInstance) { _, callErr = w.CallProcessValue(map[string]interface{}{"kind": "grow"}) })
+	if !errors.Is(callErr, ErrScriptMemory) {
+		t.Errorf("expected a memory error, got %v", callErr)
+	}
+
+	// A failed instance that can't be replaced is not handed out; the next
+	// call retries the replacement
+	pool.limits.Timeout = time.Nanosecond
+	if pool.Do(func(w *WASMInstance) { t.Error("expected no call on a closed instance") }) {
+		t.Error("expected the call to be refused")
+	}
+	pool.limits.Timeout = 5 * time.Second
+
+	var output map[string]interface{}
+	pool.Do(func(w *WASMInstance) {
+		output, callErr = w.CallProcess(map[string]interface{}{"id": "c1", "company_size": 600.0})
+	})
+	if callErr != nil || output["score"] != 50.0 {
+		t.Errorf("expected the replaced instance to work, got %v (err=%v)", output, callErr)
+	}
+
+	if _, err := newWASMPool(1, filepath.Join(t.TempDir(), "missing.wasm"), DefaultWASMLimits(), &wasmHost{}); err == nil {
+		t.Error("expected an error for a missing module")
+	}
+	bad := filepath.Join(t.TempDir(), "bad.wasm")
+	os.WriteFile(bad, []byte("not wasm"), 0644)
+	if _, err := newWASMPool(1, bad, DefaultWASMLimits(), &wasmHost{}); err == nil {
+		t.Error("expected an error for an invalid module")
+	}
+}
+
+func TestRunWASMCases(t *testing.T) {
+	module := buildTestWASM(t)
+	cases := []ScriptCase{
+		{Name: "big", Input: map[string]interface{}{"id": "c1", "company_size": 900}, Expect: map[string]interface{}{"contact_id": "c1", "score": 50}},
+		{Name: "small", Input: map[string]interface{}{"company_size": 1}, ExpectNil: true},
+		{Name: "wrong", Input: map[string]interface{}{"id": "c2", "company_size": 30}, Expect: map[string]interface{}{"contact_id": "c2", "score": 50}},
+	}
+	results := RunWASMCases(module, cases, DefaultWASMLimits())
+	if !results[0].Passed || !results[1].Passed {
+		t.Errorf("expected the first two cases to pass, got %+v", results[:2])
+	}
+	if r := results[2]; r.Passed || len(r.Changes) != 1 || r.Changes[0].Path != "score" {
+		t.Errorf("expected a score diff, got %+v", r)
+	}
+}
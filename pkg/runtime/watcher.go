@@ -0,0 +1,193 @@
+package runtime
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultWatchInterval is how often the script watcher checks files when
+// forest.yaml sets no interval.
+const DefaultWatchInterval = time.Second
+
+// ScriptStatus reports the script or prompt a component is running and the
+// outcome of the latest reload.
+type ScriptStatus struct {
+	Kind     string     `json:"kind"` // "tree", "treehouse", "nim" or "partial"
+	Name     string     `json:"name"`
+	Path     string     `json:"path"`
+	Hash     string     `json:"hash"` // SHA-256 of the running version
+	LoadedAt time.Time  `json:"loaded_at"`
+	Reloads  int        `json:"reloads"`
+	Error    string     `json:"error,omitempty"` // Why the latest change was not loaded
+	ErrorAt  *time.Time `json:"error_at,omitempty"`
+}
+
+// watchedScript is a component's script or prompt and how to reload it.
+type watchedScript struct {
+	kind   string
+	name   string
+	path   string
+	reload func(path string) error
+}
+
+type scriptState struct {
+	ScriptStatus
+	seen string // Hash of the latest contents, loaded or not
+}
+
+// ScriptWatcher polls the scripts and prompts of a forest's components and
+// reloads a component when the contents of its file change. A version that
+// fails to load is reported and the component keeps the previous one.
+type ScriptWatcher struct {
+	interval time.Duration
+	scripts  func() []watchedScript
+
+	polling sync.Mutex // One poll at a time
+
+	mu     sync.Mutex
+	status map[string]*scriptState // By kind/name
+	stop   chan struct{}
+	wg     sync.WaitGroup // The polling goroutine
+}
+
+// newScriptWatcher creates a watcher for the files scripts lists on each poll.
+func newScriptWatcher(interval time.Duration, scripts func() []watchedScript) *ScriptWatcher {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	return &ScriptWatcher{
+		interval: interval,
+		scripts:  scripts,
+		status:   make(map[string]*scriptState),
+	}
+}
+
+// Start records the current files and begins polling.
+func (w *ScriptWatcher) Start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stop != nil {
+		return
+	}
+	w.stop = make(chan struct{})
+	w.wg.Add(1)
+	go w.run(w.stop)
+	log.Printf("[Watcher] Watching scripts and prompts every %s", w.interval)
+}
+
+// Stop stops polling and waits for a poll in progress, so no component is
+// reloaded after Stop returns. It must not be called while holding a lock
+// the reloads take.
+func (w *ScriptWatcher) Stop() {
+	w.mu.Lock()
+	if w.stop != nil {
+		close(w.stop)
+		w.stop = nil
+	}
+	w.mu.Unlock()
+	w.wg.Wait()
+}
+
+func (w *ScriptWatcher) run(stop <-chan struct{}) {
+	defer w.wg.Done()
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	w.Poll()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			w.Poll()
+		}
+	}
+}
+
+// Poll checks every file once and reloads the components whose file changed.
+// The first time a component is seen its file is taken as already loaded.
+func (w *ScriptWatcher) Poll() {
+	w.polling.Lock()
+	defer w.polling.Unlock()
+
+	scripts := w.scripts()
+	current := make(map[string]bool, len(scripts))
+
+	for _, s := range scripts {
+		key := s.kind + "/" + s.name
+		current[key] = true
+
+		data, err := os.ReadFile(s.path)
+		if err != nil {
+			continue // Being replaced; check again on the next poll
+		}
+		sum := sha256.Sum256(data)
+		hash := hex.EncodeToString(sum[:])
+
+		w.mu.Lock()
+		state := w.status[key]
+		if state == nil || state.Path != s.path {
+			w.status[key] = &scriptState{
+				ScriptStatus: ScriptStatus{Kind: s.kind, Name: s.name, Path: s.path, Hash: hash, LoadedAt: time.Now()},
+				seen:         hash,
+			}
+			w.mu.Unlock()
+			continue
+		}
+		if hash == state.seen {
+			w.mu.Unlock()
+			continue
+		}
+		state.seen = hash
+		w.mu.Unlock()
+
+		err = s.reload(s.path)
+
+		w.mu.Lock()
+		now := time.Now()
+		if err != nil {
+			state.Error = err.Error()
+			state.ErrorAt = &now
+			log.Printf("[Watcher] Keeping the running version of %s %s: %v", s.kind, s.name, err)
+		} else {
+			state.Hash = hash
+			state.LoadedAt = now
+			state.Reloads++
+			state.Error = ""
+			state.ErrorAt = nil
+		}
+		w.mu.Unlock()
+	}
+
+	// Forget removed components
+	w.mu.Lock()
+	for key := range w.status {
+		if !current[key] {
+			delete(w.status, key)
+		}
+	}
+	w.mu.Unlock()
+}
+
+// Status returns the script status of every watched component.
+func (w *ScriptWatcher) Status() []ScriptStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	status := make([]ScriptStatus, 0, len(w.status))
+	for _, s := range w.status {
+		status = append(status, s.ScriptStatus)
+	}
+	sort.Slice(status, func(i, j int) bool {
+		if status[i].Kind != status[j].Kind {
+			return status[i].Kind < status[j].Kind
+		}
+		return status[i].Name < status[j].Name
+	})
+	return status
+}
@@ -0,0 +1,144 @@
+package runtime
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yourusername/nimsforest/internal/core"
+)
+
+func TestScriptHotReload(t *testing.T) {
+	forest, wind, cleanup := setupTestForest(t)
+	defer cleanup()
+
+	script := filepath.Join(forest.config.BaseDir, "version.lua")
+	writeVersion := func(body string) {
+		if err := os.WriteFile(script, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeVersion(`function process(input) return {version = 1} end`)
+
+	if err := forest.AddTreeHouse("versioned", TreeHouseConfig{
+		Subscribes: "version.in", Publishes: "version.out", Script: "version.lua",
+	}); err != nil {
+		t.Fatalf("AddTreeHouse failed: %v", err)
+	}
+
+	out := make(chan float64, 10)
+	wind.Catch("version.out", func(leaf core.Leaf) {
+		var data map[string]float64
+		json.Unmarshal(leaf.Data, &data)
+		out <- data["version"]
+	})
+	time.Sleep(50 * time.Millisecond)
+
+	expect := func(version float64) {
+		t.Helper()
+		wind.Drop(*core.NewLeaf("version.in", []byte(`{}`), "test"))
+		select {
+		case got := <-out:
+			if got != version {
+				t.Fatalf("expected version %v, got %v", version, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for version %v", version)
+		}
+	}
+	status := func() ScriptStatus {
+		t.Helper()
+		for _, s := range forest.ScriptStatus() {
+			if s.Kind == "treehouse" && s.Name == "versioned" {
+				return s
+			}
+		}
+		t.Fatal("treehouse versioned not watched")
+		return ScriptStatus{}
+	}
+
+	forest.watcher.Poll()
+	expect(1)
+	first := status()
+
+	writeVersion(`function process(input) return {version = 2} end`)
+	forest.watcher.Poll()
+	expect(2)
+	if s := status(); s.Reloads != 1 || s.Hash == first.Hash || s.Error != "" {
+		t.Errorf("expected one clean reload, got %+v", s)
+	}
+
+	// A script that does not compile is reported and the old one keeps running
+	writeVersion(`function process(input) return {version = ) end`)
+	forest.watcher.Poll()
+	expect(2)
+	if s := status(); s.Reloads != 1 || s.Error == "" || s.ErrorAt == nil {
+		t.Errorf("expected the compile error in the status, got %+v", s)
+	}
+
+	writeVersion(`function process(input) return {version = 3} end`)
+	forest.watcher.Poll()
+	expect(3)
+	if s := status(); s.Reloads != 2 || s.Error != "" {
+		t.Errorf("expected the error cleared after a good reload, got %+v", s)
+	}
+}
+
+func TestNimReloadPrompt(t *testing.T) {
+	_, wind, cleanup := setupTestForest(t)
+	defer cleanup()
+
+	prompt := filepath.Join(t.TempDir(), "prompt.md")
+	os.WriteFile(prompt, []byte(`Hello {{.name}}`), 0644)
+
+	nim, err := NewNim(NimConfig{Name: "greeter", Subscribes: "in", Publishes: "out"}, wind, &mockBrain{}, prompt)
+	if err != nil {
+		t.Fatalf("NewNim failed: %v", err)
+	}
+	before := nim.template.Load()
+
+	os.WriteFile(prompt, []byte(`Hello {{.name`), 0644)
+	if err := nim.ReloadPrompt(prompt); err == nil {
+		t.Fatal("expected a parse error")
+	}
+	if nim.template.Load() != before {
+		t.Error("expected the old prompt to be kept")
+	}
+
+	os.WriteFile(prompt, []byte(`Hi {{.name}}`), 0644)
+	if err := nim.ReloadPrompt(prompt); err != nil {
+		t.Fatalf("ReloadPrompt failed: %v", err)
+	}
+	if nim.template.Load() == before {
+		t.Error("expected the new prompt to be swapped in")
+	}
+}
+
+func TestScriptWatcherStopWaitsForPoll(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "slow.lua")
+	os.WriteFile(script, []byte("-- v1"), 0644)
+
+	reloading := make(chan struct{})
+	reloaded := false
+	w := newScriptWatcher(10*time.Millisecond, func() []watchedScript {
+		return []watchedScript{{"tree", "slow", script, func(string) error {
+			close(reloading)
+			time.Sleep(100 * time.Millisecond)
+			reloaded = true
+			return nil
+		}}}
+	})
+	w.Start()
+	for len(w.Status()) == 0 {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	os.WriteFile(script, []byte("-- v2"), 0644)
+	<-reloading
+	w.Stop()
+	if !reloaded {
+		t.Error("expected Stop to wait for the reload in progress")
+	}
+}